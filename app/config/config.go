@@ -5,16 +5,30 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bbapp-org/auth-service/app/dto/input"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Service  input.ServiceConfig
-	Database input.DatabaseConfig
-	Server   input.ServerConfig
-	App      input.AppConfig
+	Service        input.ServiceConfig
+	Database       input.DatabaseConfig
+	Server         input.ServerConfig
+	App            input.AppConfig
+	JWT            input.JWTConfig
+	ForwardAuth    input.ForwardAuthConfig
+	Revocation     input.RevocationConfig
+	MFA            input.MFAConfig
+	WebAuthn       input.WebAuthnConfig
+	PasswordReset  input.PasswordResetConfig
+	PasswordPolicy input.PasswordPolicyConfig
+	RateLimit      input.RateLimitConfig
+	Invitation     input.InvitationConfig
+	Impersonation  input.ImpersonationConfig
+	StepUp         input.StepUpConfig
+	Notification   input.NotificationConfig
 }
 
 func LoadConfig() *Config {
@@ -33,16 +47,101 @@ func LoadConfig() *Config {
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
 		},
 		Server: input.ServerConfig{
-			Host: getEnv("SERVER_HOST", "localhost"),
-			Port: getEnvAsInt("SERVER_PORT", 8088),
+			Host:           getEnv("SERVER_HOST", "localhost"),
+			Port:           getEnvAsInt("SERVER_PORT", 8088),
+			TrustedProxies: getEnvAsList("SERVER_TRUSTED_PROXIES"),
+			ProxyHeader:    getEnv("SERVER_PROXY_HEADER", "X-Forwarded-For"),
 		},
 		App: input.AppConfig{
 			Environment:    getEnv("ENV", "development"),
 			JWTSecret:      getEnv("JWT_SECRET", ""),
 			ServerPort:     getEnv("SERVER_PORT", "8088"),
 			AllowedOrigins: getEnv("CORS_ALLOWED_ORIGINS", "*"),
+			PublicURL:      getEnv("AUTH_PUBLIC_URL", ""),
+		},
+		JWT: input.JWTConfig{
+			SigningAlgorithm: getEnv("JWT_SIGNING_ALG", "RS256"),
+			PrivateKeyFile:   getEnv("JWT_PRIVATE_KEY_FILE", ""),
+			PreviousKeyFiles: getEnvAsList("JWT_PREVIOUS_KEY_FILES"),
+			KeyDir:           getEnv("JWT_KEY_DIR", ""),
+			RotationInterval: getEnvAsDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+			KeyRetention:     getEnvAsDuration("JWT_KEY_RETENTION", 92*24*time.Hour),
+			Issuer:           getEnv("JWT_ISSUER", getEnv("AUTH_PUBLIC_URL", "github.com/bbapp-org/auth-service")),
+			IDTokenAudience:  getEnv("JWT_ID_TOKEN_AUDIENCE", "bbapp"),
+		},
+		ForwardAuth: input.ForwardAuthConfig{
+			PolicyFile:     getEnv("FORWARD_AUTH_POLICY_FILE", ""),
+			ReloadInterval: getEnvAsDuration("FORWARD_AUTH_POLICY_RELOAD_INTERVAL", 10*time.Second),
+		},
+		Revocation: input.RevocationConfig{
+			Backend:      getEnv("REVOCATION_BACKEND", "database"),
+			SyncInterval: getEnvAsDuration("REVOCATION_SYNC_INTERVAL", 5*time.Second),
+		},
+		MFA: input.MFAConfig{
+			Issuer:       getEnv("MFA_ISSUER", "BBApp"),
+			ChallengeTTL: getEnvAsDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		},
+		WebAuthn: input.WebAuthnConfig{
+			RPID:         getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPName:       getEnv("WEBAUTHN_RP_NAME", "BBApp"),
+			Origins:      getEnvAsList("WEBAUTHN_ORIGINS"),
+			ChallengeTTL: getEnvAsDuration("WEBAUTHN_CHALLENGE_TTL", 5*time.Minute),
+		},
+		PasswordReset: input.PasswordResetConfig{
+			ResetURL: getEnv("PASSWORD_RESET_URL", ""),
+			TokenTTL: getEnvAsDuration("PASSWORD_RESET_TOKEN_TTL", 30*time.Minute),
+		},
+		PasswordPolicy: input.PasswordPolicyConfig{
+			MinLength:        getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+			RequireUpper:     getEnvAsBool("PASSWORD_REQUIRE_UPPER", true),
+			RequireLower:     getEnvAsBool("PASSWORD_REQUIRE_LOWER", true),
+			RequireDigit:     getEnvAsBool("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSymbol:    getEnvAsBool("PASSWORD_REQUIRE_SYMBOL", false),
+			MaxAge:           getEnvAsDuration("PASSWORD_MAX_AGE", 0),
+			HistorySize:      getEnvAsInt("PASSWORD_HISTORY_SIZE", 5),
+			BreachedListFile: getEnv("PASSWORD_BREACHED_LIST_FILE", ""),
+		},
+		Invitation: input.InvitationConfig{
+			AcceptURL: getEnv("INVITATION_URL", ""),
+			TokenTTL:  getEnvAsDuration("INVITATION_TOKEN_TTL", 72*time.Hour),
+		},
+		Impersonation: input.ImpersonationConfig{
+			TokenTTL: getEnvAsDuration("IMPERSONATION_TOKEN_TTL", 15*time.Minute),
+		},
+		StepUp: input.StepUpConfig{
+			MaxAge:   getEnvAsDuration("STEP_UP_MAX_AGE", 10*time.Minute),
+			TokenTTL: getEnvAsDuration("STEP_UP_TOKEN_TTL", 10*time.Minute),
+		},
+		Notification: input.NotificationConfig{
+			EmailProvider:   getEnv("NOTIFICATION_EMAIL_PROVIDER", "http"),
+			SMSProvider:     getEnv("NOTIFICATION_SMS_PROVIDER", "http"),
+			ServiceURL:      getEnv("NOTIFICATION_SERVICE_URL", "http://notification-service"),
+			SMTPHost:        getEnv("SMTP_HOST", ""),
+			SMTPPort:        getEnvAsInt("SMTP_PORT", 587),
+			SMTPUsername:    getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:    getEnv("SMTP_PASSWORD", ""),
+			SMTPFrom:        getEnv("SMTP_FROM", ""),
+			FilePath:        getEnv("NOTIFICATION_FILE", "notifications.log"),
+			TemplateDir:     getEnv("NOTIFICATION_TEMPLATE_DIR", ""),
+			DefaultLanguage: getEnv("NOTIFICATION_DEFAULT_LANGUAGE", "en"),
+			PollInterval:    getEnvAsDuration("NOTIFICATION_POLL_INTERVAL", 5*time.Second),
+			BatchSize:       getEnvAsInt("NOTIFICATION_BATCH_SIZE", 50),
+			MaxAttempts:     getEnvAsInt("NOTIFICATION_MAX_ATTEMPTS", 8),
+			RetryBackoff:    getEnvAsDuration("NOTIFICATION_RETRY_BACKOFF", 30*time.Second),
+			MaxRetryBackoff: getEnvAsDuration("NOTIFICATION_MAX_RETRY_BACKOFF", time.Hour),
+		},
+		RateLimit: input.RateLimitConfig{
+			Backend:            getEnv("RATE_LIMIT_BACKEND", "database"),
+			LoginPerIP:         getEnvAsInt("RATE_LIMIT_LOGIN_PER_IP", 50),
+			LoginPerIdentity:   getEnvAsInt("RATE_LIMIT_LOGIN_PER_IDENTITY", 10),
+			LoginWindow:        getEnvAsDuration("RATE_LIMIT_LOGIN_WINDOW", 15*time.Minute),
+			OTPPerIP:           getEnvAsInt("RATE_LIMIT_OTP_PER_IP", 20),
+			OTPPerIdentity:     getEnvAsInt("RATE_LIMIT_OTP_PER_IDENTITY", 3),
+			OTPWindow:          getEnvAsDuration("RATE_LIMIT_OTP_WINDOW", 15*time.Minute),
+			LockoutThreshold:   getEnvAsInt("LOCKOUT_THRESHOLD", 5),
+			LockoutDuration:    getEnvAsDuration("LOCKOUT_DURATION", time.Minute),
+			LockoutMaxDuration: getEnvAsDuration("LOCKOUT_MAX_DURATION", 24*time.Hour),
 		},
-		
 	}
 
 	log.Printf("MySQL database configuration loaded:")
@@ -105,3 +204,35 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := getEnv(key, ""); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := getEnv(key, ""); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsList(key string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
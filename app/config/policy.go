@@ -0,0 +1,8 @@
+package config
+
+import _ "embed"
+
+// DefaultForwardAuthPolicy is used when FORWARD_AUTH_POLICY_FILE is not set.
+//
+//go:embed policies/forward_auth.yaml
+var DefaultForwardAuthPolicy []byte
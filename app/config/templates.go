@@ -0,0 +1,10 @@
+package config
+
+import "embed"
+
+// DefaultNotificationTemplates is used when NOTIFICATION_TEMPLATE_DIR is not
+// set. Templates live in templates/<name>/<language>.<part>.tmpl, where part
+// is "subject", "html" or "txt".
+//
+//go:embed templates
+var DefaultNotificationTemplates embed.FS
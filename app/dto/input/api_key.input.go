@@ -0,0 +1,9 @@
+package input
+
+import "time"
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=255"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,15 @@
+package input
+
+// AuditEventQuery filters the audit log. From and To are RFC 3339 times; To
+// is exclusive.
+type AuditEventQuery struct {
+	Type      string `query:"type"`
+	ActorID   uint   `query:"actor_id"`
+	SubjectID uint   `query:"subject_id"`
+	Outcome   string `query:"outcome" validate:"omitempty,oneof=success failure"`
+	IPAddress string `query:"ip"`
+	From      string `query:"from"`
+	To        string `query:"to"`
+	Page      int    `query:"page"`
+	Limit     int    `query:"limit"`
+}
@@ -39,6 +39,44 @@ type VerifyOTPRequest struct {
 	OTP   string `json:"otp" validate:"required,len=6"`
 }
 
+type ContactVerificationRequest struct {
+	Channel string `json:"channel" validate:"required,oneof=email phone"`
+}
+
+type ConfirmContactRequest struct {
+	Channel string `json:"channel" validate:"required,oneof=email phone"`
+	OTP     string `json:"otp" validate:"required,len=6"`
+}
+
+// ReauthenticateRequest proves the caller again with one of the methods.
+// Password takes the account password, totp an authenticator code and otp a
+// code sent to Channel through /auth/reauthenticate/otp.
+type ReauthenticateRequest struct {
+	Method   string `json:"method" validate:"required,oneof=password totp otp"`
+	Password string `json:"password,omitempty" validate:"required_if=Method password"`
+	Code     string `json:"code,omitempty" validate:"required_unless=Method password"`
+	Channel  string `json:"channel,omitempty" validate:"required_if=Method otp,omitempty,oneof=email phone"`
+}
+
+// ChangeContactRequest asks to replace either the email or the phone.
+type ChangeContactRequest struct {
+	Email string `json:"email,omitempty" validate:"required_without=Phone,excluded_with=Phone,omitempty,email"`
+	Phone string `json:"phone,omitempty" validate:"required_without=Email,excluded_with=Email"`
+}
+
+// LinkIdentityRequest links a Google or Apple account, proven by one of its
+// ID tokens. Email and phone are linked through ChangeContactRequest.
+type LinkIdentityRequest struct {
+	Provider string `json:"provider" validate:"required,oneof=google apple"`
+	Token    string `json:"token" validate:"required"`
+}
+
+// MergeAccountRequest merges the account signed in with AccessToken into the
+// current one.
+type MergeAccountRequest struct {
+	AccessToken string `json:"access_token" validate:"required"`
+}
+
 type ChangePasswordRequest struct {
 	CurrentPassword string `json:"current_password" validate:"required"`
 	NewPassword     string `json:"new_password" validate:"required,min=8"`
@@ -49,15 +87,29 @@ type ResetPasswordRequest struct {
 	NewPassword string `json:"new_password" validate:"required,min=8"`
 }
 
+type AcceptInvitationRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type CompletePasswordResetRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
 type ResetUserPasswordRequest struct {
 	OldPassword string `json:"old_password" validate:"required"`
 	NewPassword string `json:"new_password" validate:"required,min=8"`
 }
 
+// CreateUserRequest invites a new admin or partner, who then sets their own
+// password.
 type CreateUserRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Username string `json:"username,omitempty"`
-	Password string `json:"password" validate:"required,min=8"`
 	UserType string `json:"user_type" validate:"required,oneof=admin partner"`
 	RoleName string `json:"role_name" validate:"required,oneof=admin partner"`
 	Phone    string `json:"phone,omitempty"`
@@ -71,6 +123,12 @@ type CreateSuperAdminRequest struct {
 	Phone    string `json:"phone,omitempty"`
 }
 
+// ImpersonateRequest explains why a superadmin is acting as a user; the
+// reason is kept in the audit log.
+type ImpersonateRequest struct {
+	Reason string `json:"reason" validate:"max=255"`
+}
+
 type UpdateUserRequest struct {
 	Email    *string `json:"email,omitempty" validate:"omitempty,email"`
 	Username *string `json:"username,omitempty"`
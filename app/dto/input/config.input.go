@@ -1,5 +1,7 @@
 package input
 
+import "time"
+
 type DatabaseConfig struct {
 	Host     string
 	Port     int
@@ -16,6 +18,12 @@ type DatabaseConfig struct {
 type ServerConfig struct {
 	Host string
 	Port int
+	// TrustedProxies lists the IP addresses and CIDR ranges of the reverse
+	// proxies in front of the service. Only requests from them have their
+	// ProxyHeader believed; with none, the header is ignored and the client
+	// IP is the peer address.
+	TrustedProxies []string
+	ProxyHeader    string
 }
 
 type AppConfig struct {
@@ -23,6 +31,128 @@ type AppConfig struct {
 	JWTSecret   string
 	ServerPort  string
 	AllowedOrigins string
+	PublicURL   string
+}
+
+type JWTConfig struct {
+	SigningAlgorithm string
+	PrivateKeyFile   string
+	PreviousKeyFiles []string
+	KeyDir           string
+	RotationInterval time.Duration
+	KeyRetention     time.Duration
+	Issuer           string
+	IDTokenAudience  string
+}
+
+type ForwardAuthConfig struct {
+	PolicyFile     string
+	ReloadInterval time.Duration
+}
+
+type MFAConfig struct {
+	Issuer       string
+	ChallengeTTL time.Duration
+}
+
+// WebAuthnConfig identifies this service as a WebAuthn relying party.
+// Origins lists the web origins allowed to run ceremonies for RPID.
+type WebAuthnConfig struct {
+	RPID         string
+	RPName       string
+	Origins      []string
+	ChallengeTTL time.Duration
+}
+
+// InvitationConfig controls emailed invitations. AcceptURL is the page that
+// accepts the token; it is appended as the "token" query parameter.
+type InvitationConfig struct {
+	AcceptURL string
+	TokenTTL  time.Duration
+}
+
+// StepUpConfig controls reauthentication for sensitive operations. MaxAge
+// is how long ago the user may have authenticated; TokenTTL is the lifetime
+// of the token issued on reauthenticating.
+type StepUpConfig struct {
+	MaxAge   time.Duration
+	TokenTTL time.Duration
+}
+
+// ImpersonationConfig controls the access tokens superadmins are issued to
+// act as another user.
+type ImpersonationConfig struct {
+	TokenTTL time.Duration
+}
+
+// PasswordResetConfig controls emailed reset links. ResetURL is the page
+// that accepts the token; it is appended as the "token" query parameter.
+type PasswordResetConfig struct {
+	ResetURL string
+	TokenTTL time.Duration
+}
+
+// PasswordPolicyConfig is applied wherever a password is set. MaxAge and
+// HistorySize are disabled at zero. BreachedListFile names a file of
+// compromised passwords, one per line, either in plain text or as SHA-1 hex
+// (optionally followed by ":count" as in the Have I Been Pwned downloads).
+type PasswordPolicyConfig struct {
+	MinLength        int
+	RequireUpper     bool
+	RequireLower     bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	MaxAge           time.Duration
+	HistorySize      int
+	BreachedListFile string
+}
+
+// RateLimitConfig caps attempts per sliding window, separately for the
+// caller's IP and for the targeted account (email, phone or user ID); a zero
+// limit disables that check. After LockoutThreshold wrong passwords in a row
+// an account is locked for LockoutDuration, doubling with each consecutive
+// lockout up to LockoutMaxDuration.
+type RateLimitConfig struct {
+	Backend            string
+	LoginPerIP         int
+	LoginPerIdentity   int
+	LoginWindow        time.Duration
+	OTPPerIP           int
+	OTPPerIdentity     int
+	OTPWindow          time.Duration
+	LockoutThreshold   int
+	LockoutDuration    time.Duration
+	LockoutMaxDuration time.Duration
+}
+
+// NotificationConfig picks how emails and SMS are delivered. EmailProvider
+// and SMSProvider are one of "http" (the notification service at ServiceURL),
+// "smtp" (email only), "console", "file" (appended to FilePath) or "memory".
+// Messages wait in the outbox until delivered, retried with a backoff that
+// starts at RetryBackoff and doubles up to MaxRetryBackoff, and are given up
+// after MaxAttempts. TemplateDir replaces the built-in templates.
+type NotificationConfig struct {
+	EmailProvider   string
+	SMSProvider     string
+	ServiceURL      string
+	SMTPHost        string
+	SMTPPort        int
+	SMTPUsername    string
+	SMTPPassword    string
+	SMTPFrom        string
+	FilePath        string
+	TemplateDir     string
+	DefaultLanguage string
+	PollInterval    time.Duration
+	BatchSize       int
+	MaxAttempts     int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+type RevocationConfig struct {
+	Backend      string
+	SyncInterval time.Duration
 }
 
 type GCSConfig struct {
@@ -0,0 +1,26 @@
+package input
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// MFAVerifyRequest completes a login with either a TOTP code or one of the
+// user's recovery codes.
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code,omitempty" validate:"omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type MFAChallengeRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+type MFAConfirmChallengeRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type UpdateMFAPolicyRequest struct {
+	RequiredUserTypes []string `json:"required_user_types"`
+}
@@ -0,0 +1,63 @@
+package input
+
+type AuthorizeRequest struct {
+	ResponseType        string `query:"response_type" form:"response_type"`
+	ClientID            string `query:"client_id" form:"client_id"`
+	RedirectURI         string `query:"redirect_uri" form:"redirect_uri"`
+	Scope               string `query:"scope" form:"scope"`
+	State               string `query:"state" form:"state"`
+	Nonce               string `query:"nonce" form:"nonce"`
+	CodeChallenge       string `query:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" form:"code_challenge_method"`
+}
+
+type AuthorizeLoginRequest struct {
+	LoginMethod string `form:"login_method"`
+	Email       string `form:"email"`
+	Phone       string `form:"phone"`
+	Password    string `form:"password"`
+	OTP         string `form:"otp"`
+	MFAToken    string `form:"mfa_token"`
+	Code        string `form:"code"`
+}
+
+type OAuthTokenRequest struct {
+	GrantType    string `json:"grant_type" form:"grant_type"`
+	Code         string `json:"code" form:"code"`
+	RedirectURI  string `json:"redirect_uri" form:"redirect_uri"`
+	CodeVerifier string `json:"code_verifier" form:"code_verifier"`
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	Scope        string `json:"scope" form:"scope"`
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+}
+
+// OAuthTokenActionRequest is the body of /oauth/introspect (RFC 7662) and
+// /oauth/revoke (RFC 7009). Clients may send their credentials with HTTP
+// Basic authentication instead.
+type OAuthTokenActionRequest struct {
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+	ClientID      string `json:"client_id" form:"client_id"`
+	ClientSecret  string `json:"client_secret" form:"client_secret"`
+}
+
+type CreateOAuthClientRequest struct {
+	Name          string   `json:"name" validate:"required"`
+	RedirectURIs  []string `json:"redirect_uris" validate:"required,min=1,dive,url"`
+	AllowedScopes []string `json:"allowed_scopes"`
+	IsPublic      bool     `json:"is_public"`
+}
+
+type UpdateOAuthClientRequest struct {
+	Name          *string  `json:"name,omitempty"`
+	RedirectURIs  []string `json:"redirect_uris,omitempty" validate:"omitempty,min=1,dive,url"`
+	AllowedScopes []string `json:"allowed_scopes,omitempty"`
+	IsActive      *bool    `json:"is_active,omitempty"`
+}
+
+type CreateServiceAccountRequest struct {
+	Name          string   `json:"name" validate:"required"`
+	Description   string   `json:"description"`
+	AllowedScopes []string `json:"allowed_scopes" validate:"required,min=1"`
+}
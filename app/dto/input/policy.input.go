@@ -0,0 +1,21 @@
+package input
+
+// PolicyTestRequest describes a request to run through the forward-auth
+// policy without forwarding it. The caller is taken from Token when set,
+// otherwise from Subject; with neither the request is evaluated anonymously.
+type PolicyTestRequest struct {
+	Method  string             `json:"method" validate:"required"`
+	Path    string             `json:"path" validate:"required"`
+	Token   string             `json:"token,omitempty"`
+	Subject *PolicyTestSubject `json:"subject,omitempty"`
+}
+
+type PolicyTestSubject struct {
+	UserID      uint   `json:"user_id"`
+	UserType    string `json:"user_type" validate:"required"`
+	Role        string `json:"role"`
+	FirebaseUID string `json:"firebase_uid,omitempty"`
+	GoogleID    string `json:"google_id,omitempty"`
+	AppleID     string `json:"apple_id,omitempty"`
+	Scope       string `json:"scope,omitempty"`
+}
@@ -0,0 +1,13 @@
+package input
+
+type CreateRoleRequest struct {
+	RoleName    string   `json:"role_name" validate:"required,max=100"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRoleRequest struct {
+	Description *string  `json:"description,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	IsActive    *bool    `json:"is_active,omitempty"`
+}
@@ -0,0 +1,34 @@
+package input
+
+// WebAuthn request bodies follow the JSON form of PublicKeyCredential, with
+// binary fields base64url encoded.
+
+type WebAuthnAttestationResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON" validate:"required"`
+	AttestationObject string   `json:"attestationObject" validate:"required"`
+	Transports        []string `json:"transports,omitempty"`
+}
+
+type WebAuthnRegistrationRequest struct {
+	Name     string                      `json:"name" validate:"max=100"`
+	ID       string                      `json:"id" validate:"required"`
+	Type     string                      `json:"type" validate:"required,eq=public-key"`
+	Response WebAuthnAttestationResponse `json:"response" validate:"required"`
+}
+
+type WebAuthnLoginBeginRequest struct {
+	Email string `json:"email,omitempty" validate:"omitempty,email"`
+}
+
+type WebAuthnAssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+	AuthenticatorData string `json:"authenticatorData" validate:"required"`
+	Signature         string `json:"signature" validate:"required"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+type WebAuthnAssertionRequest struct {
+	ID       string                    `json:"id" validate:"required"`
+	Type     string                    `json:"type" validate:"required,eq=public-key"`
+	Response WebAuthnAssertionResponse `json:"response" validate:"required"`
+}
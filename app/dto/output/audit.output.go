@@ -0,0 +1,9 @@
+package output
+
+// AuditChainVerification reports whether the audit log's hash chain is
+// intact. BrokenAt is the first event whose hash does not match, if any.
+type AuditChainVerification struct {
+	Valid    bool  `json:"valid"`
+	Checked  int   `json:"checked"`
+	BrokenAt *uint `json:"broken_at,omitempty"`
+}
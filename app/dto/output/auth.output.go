@@ -7,20 +7,54 @@ type AuthResponse struct {
 	RefreshToken string   `json:"refresh_token"`
 	TokenType    string   `json:"token_type"`
 	ExpiresIn    int      `json:"expires_in"`
+	IDToken      string   `json:"id_token,omitempty"`
+	Scope        string   `json:"scope,omitempty"`
 	User         UserInfo `json:"user"`
+
+	// Set instead of tokens when the password step must be followed by a
+	// second factor. MFAToken is then passed to /auth/login/mfa.
+	MFARequired           bool     `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool     `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string   `json:"mfa_token,omitempty"`
+	RecoveryCodes         []string `json:"recovery_codes,omitempty"`
+}
+
+// ImpersonationResponse carries a short-lived access token for User. There is
+// no refresh token; a new impersonation has to be started once it expires.
+type ImpersonationResponse struct {
+	AccessToken    string   `json:"access_token"`
+	TokenType      string   `json:"token_type"`
+	ExpiresIn      int      `json:"expires_in"`
+	ImpersonatorID uint     `json:"impersonator_id"`
+	User           UserInfo `json:"user"`
+}
+
+// ReauthenticationResponse carries a short-lived access token whose
+// auth_time is the moment of reauthentication. It replaces the caller's
+// access token for sensitive operations only; there is no refresh token.
+type ReauthenticationResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	AuthTime    int64  `json:"auth_time"`
 }
 
 type UserInfo struct {
-	ID          uint       `json:"id"`
-	Email       *string    `json:"email,omitempty"`
-	Phone       *string    `json:"phone,omitempty"`
-	Username    *string    `json:"username,omitempty"`
-	UserType    string     `json:"user_type"`
-	Role        string     `json:"role"`
-	Status      string     `json:"status"`
-	VendorID    *uint      `json:"vendor_id,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	ID            uint       `json:"id"`
+	Email         *string    `json:"email,omitempty"`
+	Phone         *string    `json:"phone,omitempty"`
+	Username      *string    `json:"username,omitempty"`
+	UserType      string     `json:"user_type"`
+	Role          string     `json:"role"`
+	Status        string     `json:"status"`
+	EmailVerified bool       `json:"email_verified"`
+	PhoneVerified bool       `json:"phone_verified"`
+	VendorID      *uint      `json:"vendor_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastLoginAt   *time.Time `json:"last_login_at,omitempty"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	PendingEmail  *string    `json:"pending_email,omitempty"`
+	PendingPhone  *string    `json:"pending_phone,omitempty"`
 }
 
 type SocialUserData struct {
@@ -61,6 +95,41 @@ type Claims struct {
 	AppleID      string `json:"apple_id,omitempty"`
 	FirebaseUID  string `json:"firebase_uid,omitempty"`
 	IdentityType string `json:"identity_type"`
+	ClientID     string `json:"client_id,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	TokenID      string `json:"jti,omitempty"`
+	SessionID    string `json:"sid,omitempty"`
+	IssuedAt     int64  `json:"iat,omitempty"`
+	ExpiresAt    int64  `json:"exp,omitempty"`
+
+	// AuthTime is when the user last actively authenticated and AMR how,
+	// as RFC 8176 method references. Both are carried across refreshes.
+	AuthTime int64    `json:"auth_time,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
+
+	// ServiceAccountID identifies the service account of a client
+	// credentials token, whose UserID is always zero.
+	ServiceAccountID uint `json:"service_account_id,omitempty"`
+
+	// ImpersonatorID is the superadmin acting as the user, taken from the
+	// token's "act" claim. Zero for ordinary tokens.
+	ImpersonatorID uint `json:"impersonator_id,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	KID string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -69,6 +138,19 @@ type ErrorResponse struct {
 	Code    int    `json:"code,omitempty"`
 }
 
+// ValidationErrorResponse lists every rule a request broke.
+type ValidationErrorResponse struct {
+	Error      bool        `json:"error"`
+	Message    string      `json:"message"`
+	Code       int         `json:"code"`
+	Violations []Violation `json:"violations"`
+}
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
 type SuccessResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
@@ -99,6 +181,7 @@ type UserListResponse struct {
 	CreatedAt   time.Time  `json:"created_at"`
 	CreatedBy   *uint      `json:"created_by,omitempty"`
 	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
 }
 
 type DashboardStatsResponse struct {
@@ -0,0 +1,13 @@
+package output
+
+// IdentityResponse is one way of signing in to an account. Identifier is the
+// email, phone number or provider subject.
+type IdentityResponse struct {
+	Provider   string `json:"provider"`
+	Identifier string `json:"identifier"`
+	Verified   bool   `json:"verified"`
+}
+
+type IdentitiesResponse struct {
+	Identities []IdentityResponse `json:"identities"`
+}
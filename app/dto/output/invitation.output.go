@@ -0,0 +1,15 @@
+package output
+
+import "time"
+
+type InvitationResponse struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"user_id"`
+	Email     string    `json:"email"`
+	UserType  string    `json:"user_type"`
+	Role      string    `json:"role"`
+	InvitedBy uint      `json:"invited_by,omitempty"`
+	Expired   bool      `json:"expired"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package output
+
+import "time"
+
+type MFAStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	Required               bool       `json:"required"`
+	ConfirmedAt            *time.Time `json:"confirmed_at,omitempty"`
+	LockedUntil            *time.Time `json:"locked_until,omitempty"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+// MFAEnrollmentResponse carries a new TOTP secret. URI is the otpauth://
+// link to render as a QR code; Secret is for manual entry.
+type MFAEnrollmentResponse struct {
+	Secret    string `json:"secret"`
+	URI       string `json:"uri"`
+	Issuer    string `json:"issuer"`
+	Account   string `json:"account"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period"`
+	Algorithm string `json:"algorithm"`
+}
+
+// MFARecoveryCodesResponse is the only time recovery codes are shown.
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAPolicyResponse struct {
+	RequiredUserTypes []string `json:"required_user_types"`
+}
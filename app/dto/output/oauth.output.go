@@ -0,0 +1,50 @@
+package output
+
+import "time"
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// TokenIntrospectionResponse is an RFC 7662 introspection response. Inactive
+// tokens carry nothing but Active; active ones add the token's claims, whose
+// jti, iat, exp, client_id and scope are the standard members.
+type TokenIntrospectionResponse struct {
+	Active bool `json:"active"`
+	// TokenType is "Bearer" for access tokens. TokenUse tells access and
+	// refresh tokens apart.
+	TokenType string `json:"token_type,omitempty"`
+	TokenUse  string `json:"token_use,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	*Claims
+}
+
+type OAuthClientResponse struct {
+	ID            uint      `json:"id"`
+	ClientID      string    `json:"client_id"`
+	ClientSecret  string    `json:"client_secret,omitempty"`
+	Name          string    `json:"name"`
+	RedirectURIs  []string  `json:"redirect_uris"`
+	AllowedScopes []string  `json:"allowed_scopes"`
+	IsPublic      bool      `json:"is_public"`
+	IsActive      bool      `json:"is_active"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ServiceAccountResponse struct {
+	ID            uint       `json:"id"`
+	ClientID      string     `json:"client_id"`
+	ClientSecret  string     `json:"client_secret,omitempty"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description,omitempty"`
+	AllowedScopes []string   `json:"allowed_scopes"`
+	IsActive      bool       `json:"is_active"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
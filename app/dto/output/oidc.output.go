@@ -0,0 +1,49 @@
+package output
+
+import "fmt"
+
+type OIDCUserInfo struct {
+	Sub                 string `json:"sub"`
+	Email               string `json:"email,omitempty"`
+	EmailVerified       bool   `json:"email_verified"`
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified bool   `json:"phone_number_verified"`
+	Name                string `json:"name,omitempty"`
+}
+
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                     string   `json:"token_endpoint,omitempty"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint                string   `json:"revocation_endpoint,omitempty"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported,omitempty"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// NewOIDCUserInfo maps a user profile to the OpenID Connect standard claims.
+func NewOIDCUserInfo(u UserInfo) OIDCUserInfo {
+	info := OIDCUserInfo{
+		Sub:                 fmt.Sprintf("%d", u.ID),
+		EmailVerified:       u.EmailVerified,
+		PhoneNumberVerified: u.PhoneVerified,
+	}
+	if u.Email != nil {
+		info.Email = *u.Email
+	}
+	if u.Phone != nil {
+		info.PhoneNumber = *u.Phone
+	}
+	if u.Username != nil {
+		info.Name = *u.Username
+	}
+	return info
+}
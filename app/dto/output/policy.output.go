@@ -0,0 +1,18 @@
+package output
+
+// PolicyDecision explains how forward-auth decided a request.
+type PolicyDecision struct {
+	Rule    string            `json:"rule,omitempty"`
+	Public  bool              `json:"public"`
+	Allowed bool              `json:"allowed"`
+	Params  map[string]string `json:"params,omitempty"`
+	Reason  string            `json:"reason"`
+}
+
+type PolicyTestResponse struct {
+	Method        string         `json:"method"`
+	Path          string         `json:"path"`
+	Authenticated bool           `json:"authenticated"`
+	Subject       *Claims        `json:"subject,omitempty"`
+	Decision      PolicyDecision `json:"decision"`
+}
@@ -0,0 +1,23 @@
+package output
+
+import "time"
+
+type RevocationStats struct {
+	Backend                string     `json:"backend"`
+	RevokedTokens          int        `json:"revoked_tokens"`
+	RevokedSessions        int        `json:"revoked_sessions"`
+	RevokedUsers           int        `json:"revoked_users"`
+	RevokedServiceAccounts int        `json:"revoked_service_accounts"`
+	RejectedTokens         uint64     `json:"rejected_tokens"`
+	LastSyncAt             *time.Time `json:"last_sync_at,omitempty"`
+	LastSyncError          string     `json:"last_sync_error,omitempty"`
+}
+
+type ForwardAuthStats struct {
+	CacheEntries     int             `json:"cache_entries"`
+	CacheHits        uint64          `json:"cache_hits"`
+	CacheMisses      uint64          `json:"cache_misses"`
+	CacheHitRatio    float64         `json:"cache_hit_ratio"`
+	CacheRevocations uint64          `json:"cache_revocations"`
+	Revocation       RevocationStats `json:"revocation"`
+}
@@ -0,0 +1,14 @@
+package output
+
+import "time"
+
+type RoleResponse struct {
+	ID          uint      `json:"id"`
+	RoleName    string    `json:"role_name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	IsActive    bool      `json:"is_active"`
+	UserCount   int64     `json:"user_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package output
+
+import "time"
+
+type SessionResponse struct {
+	SessionID  string     `json:"session_id"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	ClientID   string     `json:"client_id,omitempty"`
+	Current    bool       `json:"current"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
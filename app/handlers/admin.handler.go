@@ -6,6 +6,7 @@ import (
 	"github.com/bbapp-org/auth-service/app/dto/input"
 	"github.com/bbapp-org/auth-service/app/dto/output"
 	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -32,6 +33,9 @@ func (h *AdminHandler) CreateUser(c *fiber.Ctx) error {
 	createdBy := c.Locals("user_id").(uint)
 
 	resp, err := h.adminService.CreateUser(c.Context(), createdBy, &req)
+	if validationErr, ok := err.(*utils.ValidationError); ok {
+		return validationErrorResponse(c, validationErr)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
 			Error:   true,
@@ -59,6 +63,9 @@ func (h *AdminHandler) CreateSuperAdmin(c *fiber.Ctx) error {
 	}
 
 	resp, err := h.adminService.CreateSuperAdmin(c.Context(), createdBy, &req)
+	if validationErr, ok := err.(*utils.ValidationError); ok {
+		return validationErrorResponse(c, validationErr)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
 			Error:   true,
@@ -79,6 +86,9 @@ func (h *AdminHandler) ResetAdminPassword(c *fiber.Ctx) error {
 	}
 
 	err := h.adminService.ResetPassword(c.Context(), &req)
+	if validationErr, ok := err.(*utils.ValidationError); ok {
+		return validationErrorResponse(c, validationErr)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
 			Error:   true,
@@ -109,6 +119,9 @@ func (h *AdminHandler) ResetUserPassword(c *fiber.Ctx) error {
 	}
 
 	err = h.adminService.ResetUserPassword(c.Context(), &req, userID)
+	if validationErr, ok := err.(*utils.ValidationError); ok {
+		return validationErrorResponse(c, validationErr)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
 			Error:   true,
@@ -283,6 +296,28 @@ func (h *AdminHandler) UpdateUserRole(c *fiber.Ctx) error {
 	})
 }
 
+func (h *AdminHandler) UnlockUser(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid user ID",
+		})
+	}
+
+	if err := h.adminService.UnlockUser(c.Context(), uint(userID)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "User unlocked successfully",
+	})
+}
+
 func (h *AdminHandler) GetDashboardStats(c *fiber.Ctx) error {
 	filter := &input.DashboardStatsFilter{}
 
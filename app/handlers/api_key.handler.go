@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type APIKeyHandler struct {
+	apiKeyService services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+func (h *APIKeyHandler) handleError(c *fiber.Ctx, err error) error {
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req input.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.apiKeyService.CreateAPIKey(c.Context(), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+func (h *APIKeyHandler) GetAPIKeys(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.apiKeyService.GetAPIKeys(c.Context(), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *APIKeyHandler) RotateAPIKey(c *fiber.Ctx) error {
+	keyID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid API key ID",
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.apiKeyService.RotateAPIKey(c.Context(), userID, uint(keyID))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	keyID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid API key ID",
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	if err := h.apiKeyService.RevokeAPIKey(c.Context(), userID, uint(keyID)); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "API key revoked successfully",
+	})
+}
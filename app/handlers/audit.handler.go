@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditHandler struct {
+	auditService services.AuditService
+}
+
+func NewAuditHandler(auditService services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+func (h *AuditHandler) handleError(c *fiber.Ctx, err error) error {
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *AuditHandler) parseQuery(c *fiber.Ctx) (*input.AuditEventQuery, error) {
+	var query input.AuditEventQuery
+	if err := c.QueryParser(&query); err != nil {
+		return nil, utils.NewBadRequestError("Invalid query parameters")
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(query); err != nil {
+		return nil, utils.NewBadRequestError(err.Error())
+	}
+
+	return &query, nil
+}
+
+func (h *AuditHandler) ListEvents(c *fiber.Ctx) error {
+	query, err := h.parseQuery(c)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	resp, err := h.auditService.ListEvents(c.Context(), query)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+// ExportEvents downloads the matching events as NDJSON. The export is
+// built before anything is sent so that a failure is still reported as an
+// error response.
+func (h *AuditHandler) ExportEvents(c *fiber.Ctx) error {
+	query, err := h.parseQuery(c)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	var body bytes.Buffer
+	if err := h.auditService.ExportEvents(c.Context(), query, &body); err != nil {
+		return h.handleError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Attachment("audit-events.ndjson")
+	return c.Send(body.Bytes())
+}
+
+func (h *AuditHandler) VerifyChain(c *fiber.Ctx) error {
+	resp, err := h.auditService.VerifyChain(c.Context())
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
@@ -5,9 +5,11 @@ import (
 
 	"github.com/bbapp-org/auth-service/app/dto/input"
 	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
 	"github.com/bbapp-org/auth-service/app/services"
 	"github.com/bbapp-org/auth-service/app/utils"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -22,6 +24,14 @@ func NewAuthHandler(authService services.AuthService) *AuthHandler {
 }
 
 func (h *AuthHandler) handleError(c *fiber.Ctx, err error) error {
+	if validationErr, ok := err.(*utils.ValidationError); ok {
+		return validationErrorResponse(c, validationErr)
+	}
+
+	if rateLimitErr, ok := err.(*utils.RateLimitError); ok {
+		return rateLimitResponse(c, rateLimitErr)
+	}
+
 	if httpErr, ok := err.(*utils.HTTPError); ok {
 		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
 			Error:   true,
@@ -47,10 +57,7 @@ func (h *AuthHandler) RegisterEmail(c *fiber.Ctx) error {
 
 	resp, err := h.authService.RegisterEmail(c.Context(), &req)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
-			Error:   true,
-			Message: err.Error(),
-		})
+		return h.handleError(c, err)
 	}
 
 	return c.JSON(resp)
@@ -67,10 +74,7 @@ func (h *AuthHandler) RegisterPhone(c *fiber.Ctx) error {
 
 	resp, err := h.authService.RegisterPhone(c.Context(), &req)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
-			Error:   true,
-			Message: err.Error(),
-		})
+		return h.handleError(c, err)
 	}
 
 	return c.JSON(resp)
@@ -87,10 +91,7 @@ func (h *AuthHandler) RegisterGoogle(c *fiber.Ctx) error {
 
 	resp, err := h.authService.RegisterGoogle(c.Context(), &req)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
-			Error:   true,
-			Message: err.Error(),
-		})
+		return h.handleError(c, err)
 	}
 
 	return c.JSON(resp)
@@ -181,6 +182,40 @@ func (h *AuthHandler) LoginPassword(c *fiber.Ctx) error {
 	return c.JSON(resp)
 }
 
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req input.VerifyOTPRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	resp, err := h.authService.VerifyEmail(c.Context(), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) VerifyPhone(c *fiber.Ctx) error {
+	var req input.VerifyOTPRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	resp, err := h.authService.VerifyPhone(c.Context(), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 	var req input.RefreshTokenRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -191,6 +226,17 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 	}
 
 	resp, err := h.authService.RefreshToken(c.Context(), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) GetUserInfo(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.authService.GetUserInfo(c.Context(), userID)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
 			Error:   true,
@@ -201,29 +247,99 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 	return c.JSON(resp)
 }
 
-func (h *AuthHandler) GetUserInfo(c *fiber.Ctx) error {
-	userIDStr := c.Locals("user_id").(string)
-	userID, err := strconv.ParseUint(userIDStr, 10, 64)
+// OIDCUserInfo serves the OpenID Connect userinfo endpoint.
+func (h *AuthHandler) OIDCUserInfo(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.authService.GetOIDCUserInfo(c.Context(), userID)
 	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	var req input.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	if err := h.authService.ChangePassword(c.Context(), userID, &req); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "Password changed successfully",
+	})
+}
+
+func (h *AuthHandler) Reauthenticate(c *fiber.Ctx) error {
+	claims, ok := c.Locals("user_claims").(*output.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Authentication required",
+		})
+	}
+
+	var req input.ReauthenticateRequest
+	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
 			Error:   true,
-			Message: "Invalid user ID",
+			Message: "Invalid request body",
+		})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
 		})
 	}
 
-	resp, err := h.authService.GetUserInfo(c.Context(), uint(userID))
+	resp, err := h.authService.Reauthenticate(c.Context(), claims, &req)
 	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) SendReauthenticationOTP(c *fiber.Ctx) error {
+	var req input.ContactVerificationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
 			Error:   true,
 			Message: err.Error(),
 		})
 	}
 
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.authService.SendReauthenticationOTP(c.Context(), userID, models.OTPChannel(req.Channel))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
 	return c.JSON(resp)
 }
 
-func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
-	var req input.ChangePasswordRequest
+func (h *AuthHandler) SendContactVerification(c *fiber.Ctx) error {
+	var req input.ContactVerificationRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
 			Error:   true,
@@ -231,27 +347,73 @@ func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 		})
 	}
 
-	userIDStr := c.Locals("user_id").(string)
-	userID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err := validator.New().Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.authService.SendContactVerification(c.Context(), userID, models.OTPChannel(req.Channel))
 	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) ConfirmContactVerification(c *fiber.Ctx) error {
+	var req input.ConfirmContactRequest
+	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
 			Error:   true,
-			Message: "Invalid user ID",
+			Message: "Invalid request body",
 		})
 	}
 
-	err = h.authService.ChangePassword(c.Context(), uint(userID), &req)
+	if err := validator.New().Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.authService.ConfirmContactVerification(c.Context(), userID, &req)
 	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *AuthHandler) ChangeContact(c *fiber.Ctx) error {
+	var req input.ChangeContactRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
 			Error:   true,
 			Message: err.Error(),
 		})
 	}
 
-	return c.JSON(output.SuccessResponse{
-		Success: true,
-		Message: "Password changed successfully",
-	})
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.authService.RequestContactChange(c.Context(), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
 }
 
 func (h *AuthHandler) ValidateToken(c *fiber.Ctx) error {
@@ -275,16 +437,29 @@ func (h *AuthHandler) ValidateToken(c *fiber.Ctx) error {
 }
 
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
-	userID := c.Locals("user_id").(uint)
+	claims, ok := c.Locals("user_claims").(*output.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Authentication required",
+		})
+	}
 
-	tokenID := "placeholder_token_id"
+	// The refresh token is optional: without it every refresh token the
+	// user holds is revoked.
+	var req input.RefreshTokenRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+				Error:   true,
+				Message: "Invalid request body",
+			})
+		}
+	}
 
-	err := h.authService.Logout(c.Context(), userID, tokenID)
+	err := h.authService.Logout(c.Context(), claims, req.RefreshToken)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
-			Error:   true,
-			Message: err.Error(),
-		})
+		return h.handleError(c, err)
 	}
 
 	return c.JSON(output.SuccessResponse{
@@ -292,3 +467,13 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 		Message: "Logged out successfully",
 	})
 }
+
+// rateLimitResponse tells the client when it may try again.
+func rateLimitResponse(c *fiber.Ctx, err *utils.RateLimitError) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(err.RetryAfterSeconds()))
+	return c.Status(fiber.StatusTooManyRequests).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Message,
+		Code:    fiber.StatusTooManyRequests,
+	})
+}
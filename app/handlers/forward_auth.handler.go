@@ -1,38 +1,55 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/bbapp-org/auth-service/app/dto/input"
 	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
 	"github.com/bbapp-org/auth-service/app/utils"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 )
 
 type ForwardAuthHandler struct {
-	cache      map[string]*CacheEntry
-	cacheMutex sync.RWMutex
+	policyService     services.PolicyService
+	revocationService services.RevocationService
+	auditService      services.AuditService
+	cache             map[string]*CacheEntry
+	cacheMutex        sync.RWMutex
+
+	cacheHits        atomic.Uint64
+	cacheMisses      atomic.Uint64
+	cacheRevocations atomic.Uint64
 }
 
+// CacheEntry holds the claims of a validated token. They are kept whole so
+// that policy rules matching on any claim behave the same on a cache hit.
 type CacheEntry struct {
-	Valid        bool
-	UserID       uint
-	UserType     string
-	Role         string
-	Email        string
-	Phone        string
-	IdentityType string
-	ExpiresAt    time.Time
+	Claims    *output.Claims
+	ExpiresAt time.Time
 }
 
-func NewForwardAuthHandler() *ForwardAuthHandler {
+// forwardAuthCacheTTL caps how long a validated token is cached when it
+// expires later than that.
+const forwardAuthCacheTTL = 24 * time.Hour
+
+func NewForwardAuthHandler(policyService services.PolicyService, revocationService services.RevocationService, auditService services.AuditService) *ForwardAuthHandler {
 	handler := &ForwardAuthHandler{
-		cache: make(map[string]*CacheEntry),
+		policyService:     policyService,
+		revocationService: revocationService,
+		auditService:      auditService,
+		cache:             make(map[string]*CacheEntry),
 	}
 
+	revocationService.OnRoleInvalidated(handler.evictRole)
+
 	go handler.startCacheCleanup()
 
 	return handler
@@ -75,6 +92,25 @@ func (h *ForwardAuthHandler) getCachedAuth(token string) (*CacheEntry, bool) {
 	return entry, true
 }
 
+func (h *ForwardAuthHandler) evictCachedAuth(token string) {
+	h.cacheMutex.Lock()
+	delete(h.cache, token)
+	h.cacheMutex.Unlock()
+}
+
+// evictRole drops the cached tokens of a role that changed, so they are
+// validated and authorized afresh.
+func (h *ForwardAuthHandler) evictRole(roleName string) {
+	h.cacheMutex.Lock()
+	defer h.cacheMutex.Unlock()
+
+	for key, entry := range h.cache {
+		if entry.Claims.Role == roleName {
+			delete(h.cache, key)
+		}
+	}
+}
+
 func (h *ForwardAuthHandler) ForwardAuth(c *fiber.Ctx) error {
 	originalURI := c.Get("X-Forwarded-Uri")
 	originalMethod := c.Get("X-Forwarded-Method")
@@ -127,23 +163,39 @@ func (h *ForwardAuthHandler) ForwardAuth(c *fiber.Ctx) error {
 
 	var claims *output.Claims
 
-	if cachedEntry, found := h.getCachedAuth(token); found {
+	cachedEntry, cached := h.getCachedAuth(token)
+	if cached {
+		h.cacheHits.Add(1)
 		fmt.Printf("CACHE HIT: Found cached auth for user %d (%s) - but still checking RBAC for URI: %s\n",
-			cachedEntry.UserID, cachedEntry.UserType, originalURI)
+			cachedEntry.Claims.UserID, cachedEntry.Claims.UserType, originalURI)
 
-		claims = &output.Claims{
-			UserID:       cachedEntry.UserID,
-			UserType:     cachedEntry.UserType,
-			Role:         cachedEntry.Role,
-			Email:        cachedEntry.Email,
-			Phone:        cachedEntry.Phone,
-			IdentityType: cachedEntry.IdentityType,
+		claims = cachedEntry.Claims
+
+		// Cached entries are re-checked so a revocation applies on the next
+		// request rather than when the entry expires.
+		if h.revocationService.IsRevoked(claims) {
+			h.cacheRevocations.Add(1)
+			h.evictCachedAuth(token)
+			fmt.Printf("CACHE: Evicted revoked token for user %d\n", claims.UserID)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   true,
+				"message": "Token has been revoked",
+				"code":    "TOKEN_REVOKED",
+			})
 		}
 	} else {
+		h.cacheMisses.Add(1)
 		fmt.Printf("CACHE MISS: Validating fresh JWT token for URI: %s\n", originalURI)
 
 		var err error
 		claims, err = utils.ValidateJWT(token)
+		if errors.Is(err, utils.ErrTokenRevoked) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   true,
+				"message": "Token has been revoked",
+				"code":    "TOKEN_REVOKED",
+			})
+		}
 		if err != nil {
 			fmt.Printf("Token validation failed for %s %s: %v\n", originalMethod, originalURI, err)
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -166,7 +218,17 @@ func (h *ForwardAuthHandler) ForwardAuth(c *fiber.Ctx) error {
 	fmt.Printf("RBAC: Checking authorization for user %d (%s) accessing %s %s\n",
 		claims.UserID, claims.UserType, originalMethod, originalURI)
 
-	if !h.isAuthorized(claims, originalURI, originalMethod) {
+	authorized := h.isAuthorized(claims, originalURI, originalMethod)
+
+	if claims.ImpersonatorID != 0 {
+		status := fiber.StatusOK
+		if !authorized {
+			status = fiber.StatusForbidden
+		}
+		h.auditService.RecordImpersonatedRequest(c.Context(), claims, originalMethod, originalURI, status)
+	}
+
+	if !authorized {
 		fmt.Printf("RBAC: Access DENIED for user type '%s' (ID: %d) to %s %s\n",
 			claims.UserType, claims.UserID, originalMethod, originalURI)
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
@@ -179,22 +241,35 @@ func (h *ForwardAuthHandler) ForwardAuth(c *fiber.Ctx) error {
 	fmt.Printf("RBAC: Access GRANTED for user %d (%s) to %s %s\n",
 		claims.UserID, claims.UserType, originalMethod, originalURI)
 
-	if _, found := h.getCachedAuth(token); !found {
+	if !cached {
 		fmt.Printf("CACHE: Storing auth result for user %d\n", claims.UserID)
+		expiresAt := time.Now().Add(forwardAuthCacheTTL)
+		if claims.ExpiresAt != 0 && time.Unix(claims.ExpiresAt, 0).Before(expiresAt) {
+			expiresAt = time.Unix(claims.ExpiresAt, 0)
+		}
 		h.cacheMutex.Lock()
 		h.cache[token] = &CacheEntry{
-			Valid:        true,
-			UserID:       claims.UserID,
-			UserType:     claims.UserType,
-			Role:         claims.Role,
-			Email:        claims.Email,
-			Phone:        claims.Phone,
-			IdentityType: claims.IdentityType,
-			ExpiresAt:    time.Now().Add(24 * time.Hour),
+			Claims:    claims,
+			ExpiresAt: expiresAt,
 		}
 		h.cacheMutex.Unlock()
 	}
 
+	if claims.UserType == "service" {
+		c.Set("X-Service-Account-Id", fmt.Sprintf("%d", claims.ServiceAccountID))
+		c.Set("X-User-Type", claims.UserType)
+		c.Set("X-Client-Id", claims.ClientID)
+		c.Set("X-Scope", claims.Scope)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"authenticated":      true,
+			"service_account_id": claims.ServiceAccountID,
+			"user_type":          claims.UserType,
+			"client_id":          claims.ClientID,
+			"scope":              claims.Scope,
+		})
+	}
+
 	c.Set("X-User-Id", fmt.Sprintf("%d", claims.UserID))
 	c.Set("X-User-Type", claims.UserType)
 	c.Set("X-User-Role", claims.Role)
@@ -207,6 +282,12 @@ func (h *ForwardAuthHandler) ForwardAuth(c *fiber.Ctx) error {
 	if claims.IdentityType != "" {
 		c.Set("X-Identity-Type", claims.IdentityType)
 	}
+	if claims.ClientID != "" {
+		c.Set("X-Client-Id", claims.ClientID)
+	}
+	if claims.ImpersonatorID != 0 {
+		c.Set("X-Impersonator-Id", fmt.Sprintf("%d", claims.ImpersonatorID))
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"authenticated": true,
@@ -218,43 +299,11 @@ func (h *ForwardAuthHandler) ForwardAuth(c *fiber.Ctx) error {
 }
 
 func (h *ForwardAuthHandler) isPublicEndpoint(uri, method string) bool {
-	if strings.HasPrefix(uri, "/health") ||
-		strings.HasPrefix(uri, "/swagger") ||
-		strings.HasPrefix(uri, "/docs") ||
-		strings.HasPrefix(uri, "/version") ||
-		strings.HasPrefix(uri, "/info") ||
-		strings.HasPrefix(uri, "/favicon.ico") ||
-		uri == "/" {
-		return true
-	}
-
-	if method == "GET" {
-		if strings.HasPrefix(uri, "/public/products") && !strings.Contains(uri, "/admin") {
-			return true
-		}
-		if strings.HasPrefix(uri, "/public/categories") && !strings.Contains(uri, "/admin") {
-			return true
-		}
-		if strings.HasPrefix(uri, "/public/tags") && !strings.Contains(uri, "/admin") {
-			return true
-		}
-		if strings.HasPrefix(uri, "/public/feedback") && !strings.Contains(uri, "/admin") {
-			return true
-		}
-
-		if strings.HasPrefix(uri, "/public/products/") && strings.Contains(uri, "/images") {
-			return true
-		}
-		if strings.HasPrefix(uri, "/media/public-url/") || strings.HasPrefix(uri, "/media/download-url/") {
-			return true
-		}
-	}
-
-	return false
+	return h.policyService.IsPublic(method, uri)
 }
 
 func (h *ForwardAuthHandler) isValidUserType(userType string) bool {
-	validTypes := []string{"mobile_user", "superadmin", "admin", "partner"}
+	validTypes := []string{"mobile_user", "superadmin", "admin", "partner", "service"}
 	for _, validType := range validTypes {
 		if userType == validType {
 			return true
@@ -264,128 +313,54 @@ func (h *ForwardAuthHandler) isValidUserType(userType string) bool {
 }
 
 func (h *ForwardAuthHandler) isAuthorized(claims *output.Claims, uri, method string) bool {
-	if strings.HasPrefix(uri, "/health") ||
-		strings.HasPrefix(uri, "/swagger") ||
-		strings.HasPrefix(uri, "/docs") ||
-		strings.HasPrefix(uri, "/version") ||
-		strings.HasPrefix(uri, "/info") ||
-		strings.HasPrefix(uri, "/favicon.ico") ||
-		uri == "/" {
-		return true
-	}
-
-	if strings.HasPrefix(uri, "/public/") {
-		return true
-	}
-
-	return h.checkPathBasedAccess(uri, method, claims)
+	decision := h.decide(claims, uri, method)
+	fmt.Printf("RBAC: rule=%q allowed=%t reason=%s\n", decision.Rule, decision.Allowed, decision.Reason)
+	return decision.Allowed
 }
 
-func (h *ForwardAuthHandler) checkPathBasedAccess(uri, method string, claims *output.Claims) bool {
-	if strings.HasPrefix(uri, "/admin/") {
-		return claims.UserType == "superadmin" || claims.UserType == "admin"
+// decide applies the policy file first. Only a request that no rule matches
+// falls back to service scopes or role permissions; a matching rule that
+// denies is final, so its user-type and owner checks cannot be bypassed.
+func (h *ForwardAuthHandler) decide(claims *output.Claims, uri, method string) output.PolicyDecision {
+	decision := h.policyService.Evaluate(method, uri, claims)
+	if decision.Allowed || decision.Rule != "" || claims == nil {
+		return decision
 	}
 
-	if strings.HasPrefix(uri, "/user/") {
-		pathParts := strings.Split(strings.TrimPrefix(uri, "/user/"), "/")
-		if len(pathParts) > 0 {
-			requestedUserID := pathParts[0]
-			currentUserID := fmt.Sprintf("%d", claims.UserID)
-
-			if requestedUserID == currentUserID {
-				return claims.UserType == "mobile_user" || claims.UserType == "admin" || claims.UserType == "superadmin"
-			}
-
-			return claims.UserType == "admin" || claims.UserType == "superadmin"
+	if claims.UserType == "service" {
+		if h.checkServiceScope(uri, method, claims) {
+			decision.Allowed = true
+			decision.Reason = "service account scope"
 		}
-		return false
+		return decision
 	}
 
-	if strings.HasPrefix(uri, "/partner/") {
-		pathParts := strings.Split(strings.TrimPrefix(uri, "/partner/"), "/")
-		if len(pathParts) > 0 {
-
-			if claims.UserType == "partner" {
-				return true
-			}
-
-			return claims.UserType == "admin" || claims.UserType == "superadmin"
+	// Role permissions cover paths the policy leaves out, e.g. a partner
+	// role granted "invoices:read".
+	if resource, action := utils.ResourceAction(uri, method); resource != "" {
+		if allowed, err := utils.HasPermission(claims, resource+":"+action); err == nil && allowed {
+			decision.Allowed = true
+			decision.Reason = fmt.Sprintf("role %s has permission %s:%s", claims.Role, resource, action)
 		}
-		return false
 	}
 
-	if strings.HasPrefix(uri, "/partners/") {
-		pathParts := strings.Split(strings.TrimPrefix(uri, "/partners/"), "/")
-		if len(pathParts) > 0 {
-			requestedPartnerID := pathParts[0]
-			currentUserID := fmt.Sprintf("%d", claims.UserID)
-
-			fmt.Printf("RBAC Partner Check - URI: %s, UserType: %s, UserID: %d, RequestedPartnerID: %s, CurrentUserID: %s\n",
-				uri, claims.UserType, claims.UserID, requestedPartnerID, currentUserID)
-
-			if claims.UserType == "partner" && requestedPartnerID == currentUserID {
-				fmt.Printf("RBAC: Access GRANTED - Partner ID matches user ID\n")
-				return true
-			}
-
-			if claims.UserType == "admin" || claims.UserType == "superadmin" {
-				fmt.Printf("RBAC: Access GRANTED - Admin/SuperAdmin access\n")
-				return true
-			}
-
-			fmt.Printf("RBAC: Access DENIED - Partner ID mismatch or insufficient privileges\n")
-			return false
-		}
-		return false
-	}
-
-	if strings.HasPrefix(uri, "/vendors/") {
-		pathParts := strings.Split(strings.TrimPrefix(uri, "/vendors/"), "/")
-		if len(pathParts) > 0 {
-			requestedVendorID := pathParts[0]
-
-			fmt.Printf("RBAC Vendor Check - URI: %s, UserType: %s, UserID: %d, RequestedVendorID: %s\n",
-				uri, claims.UserType, claims.UserID, requestedVendorID)
-
-			if claims.UserType == "admin" || claims.UserType == "superadmin" {
-				fmt.Printf("RBAC: Access GRANTED - Admin/SuperAdmin access to vendor endpoint\n")
-				return true
-			}
+	return decision
+}
 
-			fmt.Printf("RBAC: Access DENIED - Only admin/superadmin can access vendor endpoints\n")
-			return false
-		}
+// checkServiceScope authorizes service accounts by scope instead of by path
+// rules: GET /customers/1 needs "customers:read", writes need "customers:write".
+func (h *ForwardAuthHandler) checkServiceScope(uri, method string, claims *output.Claims) bool {
+	resource, action := utils.ResourceAction(uri, method)
+	if resource == "" {
 		return false
 	}
 
-	if strings.HasPrefix(uri, "/customers/") {
-		pathParts := strings.Split(strings.TrimPrefix(uri, "/customers/"), "/")
-		if len(pathParts) > 0 {
-			requestedCustomerID := pathParts[0]
-			currentUserID := fmt.Sprintf("%d", claims.UserID)
-
-			fmt.Printf("RBAC Customer Check - URI: %s, UserType: %s, UserID: %d, RequestedCustomerID: %s, CurrentUserID: %s\n",
-				uri, claims.UserType, claims.UserID, requestedCustomerID, currentUserID)
-
-			if requestedCustomerID == currentUserID ||
-				(claims.FirebaseUID != "" && requestedCustomerID == claims.FirebaseUID) ||
-				(claims.GoogleID != "" && requestedCustomerID == claims.GoogleID) ||
-				(claims.AppleID != "" && requestedCustomerID == claims.AppleID) {
-				fmt.Printf("RBAC: Access GRANTED - Customer identifier matches claims (numeric or external)\n")
-				return claims.UserType == "mobile_user" || claims.UserType == "admin" || claims.UserType == "superadmin"
-			}
-
-			if claims.UserType == "admin" || claims.UserType == "superadmin" {
-				fmt.Printf("RBAC: Access GRANTED - Admin/Superadmin access\n")
-				return true
-			}
-
-			fmt.Printf("RBAC: Access DENIED - Customer ID %s does not match numeric ID %s nor external IDs\n", requestedCustomerID, currentUserID)
-			return false
-		}
-		return false
+	if utils.ScopeAllows(claims.Scope, resource, action) {
+		fmt.Printf("RBAC: Access GRANTED - Service %s has scope for %s:%s\n", claims.ClientID, resource, action)
+		return true
 	}
 
+	fmt.Printf("RBAC: Access DENIED - Service %s lacks scope %s:%s\n", claims.ClientID, resource, action)
 	return false
 }
 
@@ -411,3 +386,85 @@ func (h *ForwardAuthHandler) CustomerAuth(c *fiber.Ctx) error {
 
 	return result
 }
+
+// TestPolicy evaluates a method and path against the active policy without
+// forwarding anything, to debug why forward-auth allows or denies a request.
+func (h *ForwardAuthHandler) TestPolicy(c *fiber.Ctx) error {
+	var req input.PolicyTestRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	var claims *output.Claims
+	switch {
+	case req.Token != "":
+		var err error
+		claims, err = utils.ValidateJWT(req.Token)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+				Error:   true,
+				Message: "Invalid or expired token",
+			})
+		}
+	case req.Subject != nil:
+		claims = &output.Claims{
+			UserID:      req.Subject.UserID,
+			UserType:    req.Subject.UserType,
+			Role:        req.Subject.Role,
+			FirebaseUID: req.Subject.FirebaseUID,
+			GoogleID:    req.Subject.GoogleID,
+			AppleID:     req.Subject.AppleID,
+			Scope:       req.Subject.Scope,
+		}
+	}
+
+	method := strings.ToUpper(req.Method)
+	resp := output.PolicyTestResponse{
+		Method:        method,
+		Path:          req.Path,
+		Authenticated: claims != nil,
+		Subject:       claims,
+	}
+
+	if claims == nil {
+		resp.Decision = h.policyService.Evaluate(method, req.Path, nil)
+	} else if !h.isValidUserType(claims.UserType) {
+		resp.Decision = output.PolicyDecision{Reason: "invalid user type " + claims.UserType}
+	} else {
+		resp.Decision = h.decide(claims, req.Path, method)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *ForwardAuthHandler) GetStats(c *fiber.Ctx) error {
+	h.cacheMutex.RLock()
+	entries := len(h.cache)
+	h.cacheMutex.RUnlock()
+
+	hits, misses := h.cacheHits.Load(), h.cacheMisses.Load()
+	ratio := 0.0
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+
+	return c.JSON(output.ForwardAuthStats{
+		CacheEntries:     entries,
+		CacheHits:        hits,
+		CacheMisses:      misses,
+		CacheHitRatio:    ratio,
+		CacheRevocations: h.cacheRevocations.Load(),
+		Revocation:       h.revocationService.Stats(),
+	})
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestForwardAuthDecide(t *testing.T) {
+	policy, err := services.NewPolicyService(input.ForwardAuthConfig{})
+	if err != nil {
+		t.Fatalf("NewPolicyService: %v", err)
+	}
+	h := &ForwardAuthHandler{policyService: policy}
+
+	utils.SetPermissionResolver(func(role string) ([]string, error) {
+		if role == "mobile_user" {
+			return []string{"invoices:read", "customers:read", "vendors:read"}, nil
+		}
+		return nil, nil
+	})
+	t.Cleanup(func() { utils.SetPermissionResolver(nil) })
+
+	mobile := &output.Claims{UserID: 7, UserType: "mobile_user", Role: "mobile_user", FirebaseUID: "fb-7"}
+	service := &output.Claims{ServiceAccountID: 3, UserType: "service", Role: "service", ClientID: "svc_reports", Scope: "customers:read invoices:read"}
+
+	tests := []struct {
+		name   string
+		claims *output.Claims
+		method string
+		uri    string
+		want   bool
+	}{
+		{name: "role permission on unruled path", claims: mobile, method: "GET", uri: "/invoices/5", want: true},
+		{name: "role permission does not cover writes", claims: mobile, method: "POST", uri: "/invoices"},
+		{name: "own customer record", claims: mobile, method: "GET", uri: "/customers/fb-7/orders", want: true},
+		{name: "role permission cannot override owner rule", claims: mobile, method: "GET", uri: "/customers/fb-8/orders"},
+		{name: "role permission cannot override user-type rule", claims: mobile, method: "GET", uri: "/vendors/1"},
+		{name: "service scope on unruled path", claims: service, method: "GET", uri: "/invoices/5", want: true},
+		{name: "service scope cannot override a rule", claims: service, method: "GET", uri: "/customers/fb-8/orders"},
+		{name: "anonymous", method: "GET", uri: "/invoices/5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := h.decide(tt.claims, tt.uri, tt.method)
+			if decision.Allowed != tt.want {
+				t.Errorf("decide(%s %s) allowed = %v, want %v (rule %q: %s)", tt.method, tt.uri, decision.Allowed, tt.want, decision.Rule, decision.Reason)
+			}
+		})
+	}
+}
+
+func TestForwardAuthEvictRole(t *testing.T) {
+	h := &ForwardAuthHandler{cache: map[string]*CacheEntry{
+		"editor-1": {Claims: &output.Claims{Role: "editor"}},
+		"editor-2": {Claims: &output.Claims{Role: "editor"}},
+		"viewer":   {Claims: &output.Claims{Role: "viewer"}},
+	}}
+
+	h.evictRole("editor")
+
+	if len(h.cache) != 1 || h.cache["viewer"] == nil {
+		t.Errorf("cache after evicting editor = %v, want only viewer", h.cache)
+	}
+}
+
+func TestForwardAuthCacheRevocation(t *testing.T) {
+	policy, err := services.NewPolicyService(input.ForwardAuthConfig{})
+	if err != nil {
+		t.Fatalf("NewPolicyService: %v", err)
+	}
+
+	utils.SetPermissionResolver(func(role string) ([]string, error) {
+		return []string{"invoices:read"}, nil
+	})
+	t.Cleanup(func() { utils.SetPermissionResolver(nil) })
+
+	tests := []struct {
+		name string
+		// revoke runs between the first request, which caches the token,
+		// and the second.
+		revoke          func(revocation services.RevocationService, claims *output.Claims) error
+		wantStatus      int
+		wantRevocations uint64
+	}{
+		{
+			name:       "cached token",
+			revoke:     func(revocation services.RevocationService, claims *output.Claims) error { return nil },
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name: "token revoked by logout",
+			revoke: func(revocation services.RevocationService, claims *output.Claims) error {
+				return revocation.RevokeToken(claims.TokenID, claims.UserID, time.Unix(claims.ExpiresAt, 0), "logout")
+			},
+			wantStatus:      fiber.StatusUnauthorized,
+			wantRevocations: 1,
+		},
+		{
+			name: "user deactivated",
+			revoke: func(revocation services.RevocationService, claims *output.Claims) error {
+				return revocation.RevokeUser(claims.UserID, "status_changed")
+			},
+			wantStatus:      fiber.StatusUnauthorized,
+			wantRevocations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revocation := services.NewRevocationService(input.RevocationConfig{Backend: "memory"}, repo.NewMemoryTokenRevocationRepository())
+			utils.SetRevocationChecker(revocation.IsRevoked)
+			t.Cleanup(func() { utils.SetRevocationChecker(nil) })
+
+			h := &ForwardAuthHandler{policyService: policy, revocationService: revocation, cache: make(map[string]*CacheEntry)}
+			app := fiber.New()
+			app.Get("/verify", h.ForwardAuth)
+
+			token, err := utils.GenerateStepUpToken(output.Claims{UserID: 7, UserType: "admin", Role: "accountant"}, time.Minute)
+			if err != nil {
+				t.Fatalf("GenerateStepUpToken: %v", err)
+			}
+			claims, err := utils.ValidateJWT(token)
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+
+			verify := func() int {
+				req := httptest.NewRequest(fiber.MethodGet, "/verify", nil)
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+				req.Header.Set("X-Forwarded-Uri", "/invoices/5")
+				req.Header.Set("X-Forwarded-Method", fiber.MethodGet)
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatalf("app.Test: %v", err)
+				}
+				return resp.StatusCode
+			}
+
+			if got := verify(); got != fiber.StatusOK {
+				t.Fatalf("first request status = %d", got)
+			}
+			entry, ok := h.getCachedAuth(token)
+			if !ok {
+				t.Fatal("token not cached")
+			}
+			if !entry.ExpiresAt.Equal(time.Unix(claims.ExpiresAt, 0)) {
+				t.Errorf("cache entry expires at %v, want the token's exp %v", entry.ExpiresAt, time.Unix(claims.ExpiresAt, 0))
+			}
+
+			if err := tt.revoke(revocation, claims); err != nil {
+				t.Fatalf("revoke: %v", err)
+			}
+
+			if got := verify(); got != tt.wantStatus {
+				t.Errorf("second request status = %d, want %d", got, tt.wantStatus)
+			}
+			if hits, misses := h.cacheHits.Load(), h.cacheMisses.Load(); hits != 1 || misses != 1 {
+				t.Errorf("cache hits = %d, misses = %d, want 1 and 1", hits, misses)
+			}
+			if got := h.cacheRevocations.Load(); got != tt.wantRevocations {
+				t.Errorf("cache revocations = %d, want %d", got, tt.wantRevocations)
+			}
+			if _, ok := h.getCachedAuth(token); ok == (tt.wantRevocations > 0) {
+				t.Errorf("token cached = %v after the second request", ok)
+			}
+		})
+	}
+}
+
+// fakeImpersonationAudit records the impersonated requests it is given.
+type fakeImpersonationAudit struct {
+	services.AuditService
+	statuses []int
+}
+
+func (a *fakeImpersonationAudit) RecordImpersonatedRequest(ctx context.Context, claims *output.Claims, method, path string, status int) {
+	a.statuses = append(a.statuses, status)
+}
+
+func TestForwardAuthImpersonation(t *testing.T) {
+	policy, err := services.NewPolicyService(input.ForwardAuthConfig{})
+	if err != nil {
+		t.Fatalf("NewPolicyService: %v", err)
+	}
+
+	utils.SetPermissionResolver(func(role string) ([]string, error) {
+		return []string{"invoices:read"}, nil
+	})
+	t.Cleanup(func() { utils.SetPermissionResolver(nil) })
+
+	userToken, err := utils.GenerateJWT(output.Claims{UserID: 7, UserType: "partner", Role: "partner"})
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	impersonationToken, err := utils.GenerateImpersonationToken(output.Claims{UserID: 7, UserType: "partner", Role: "partner", ImpersonatorID: 1}, time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateImpersonationToken: %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		token            string
+		method           string
+		wantStatus       int
+		wantImpersonator string
+		wantAudited      []int
+	}{
+		{name: "user", token: userToken, method: fiber.MethodGet, wantStatus: fiber.StatusOK},
+		{name: "impersonated", token: impersonationToken, method: fiber.MethodGet, wantStatus: fiber.StatusOK, wantImpersonator: "1", wantAudited: []int{fiber.StatusOK}},
+		{name: "impersonated and denied", token: impersonationToken, method: fiber.MethodPost, wantStatus: fiber.StatusForbidden, wantAudited: []int{fiber.StatusForbidden}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &fakeImpersonationAudit{}
+			h := &ForwardAuthHandler{policyService: policy, auditService: audit, cache: make(map[string]*CacheEntry)}
+			app := fiber.New()
+			app.Get("/verify", h.ForwardAuth)
+
+			req := httptest.NewRequest(fiber.MethodGet, "/verify", nil)
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tt.token)
+			req.Header.Set("X-Forwarded-Uri", "/invoices/5")
+			req.Header.Set("X-Forwarded-Method", tt.method)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get("X-Impersonator-Id"); got != tt.wantImpersonator {
+				t.Errorf("X-Impersonator-Id = %q, want %q", got, tt.wantImpersonator)
+			}
+			if len(audit.statuses) != len(tt.wantAudited) || (len(audit.statuses) == 1 && audit.statuses[0] != tt.wantAudited[0]) {
+				t.Errorf("audited %v, want %v", audit.statuses, tt.wantAudited)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type IdentityHandler struct {
+	identityService services.IdentityService
+}
+
+func NewIdentityHandler(identityService services.IdentityService) *IdentityHandler {
+	return &IdentityHandler{
+		identityService: identityService,
+	}
+}
+
+func (h *IdentityHandler) handleError(c *fiber.Ctx, err error) error {
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *IdentityHandler) ListIdentities(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.identityService.ListIdentities(c.Context(), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *IdentityHandler) LinkIdentity(c *fiber.Ctx) error {
+	var req input.LinkIdentityRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.identityService.LinkIdentity(c.Context(), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *IdentityHandler) UnlinkIdentity(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.identityService.UnlinkIdentity(c.Context(), userID, models.IdentityProvider(c.Params("provider")))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *IdentityHandler) MergeAccount(c *fiber.Ctx) error {
+	var req input.MergeAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	if err := validator.New().Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.identityService.MergeAccount(c.Context(), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
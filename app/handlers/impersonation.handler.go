@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ImpersonationHandler struct {
+	impersonationService services.ImpersonationService
+}
+
+func NewImpersonationHandler(impersonationService services.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		impersonationService: impersonationService,
+	}
+}
+
+func (h *ImpersonationHandler) handleError(c *fiber.Ctx, err error) error {
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+// Impersonate issues the caller a token to act as the user. The body, with
+// the reason for impersonating, is optional.
+func (h *ImpersonationHandler) Impersonate(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid user ID",
+		})
+	}
+
+	var req input.ImpersonateRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+				Error:   true,
+				Message: "Invalid request body",
+			})
+		}
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	adminID := c.Locals("user_id").(uint)
+
+	resp, err := h.impersonationService.Impersonate(c.Context(), adminID, uint(userID), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type InvitationHandler struct {
+	invitationService services.InvitationService
+}
+
+func NewInvitationHandler(invitationService services.InvitationService) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+	}
+}
+
+func (h *InvitationHandler) handleError(c *fiber.Ctx, err error) error {
+	if validationErr, ok := err.(*utils.ValidationError); ok {
+		return validationErrorResponse(c, validationErr)
+	}
+
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *InvitationHandler) GetInvitation(c *fiber.Ctx) error {
+	resp, err := h.invitationService.GetInvitation(c.Context(), c.Params("token"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *InvitationHandler) AcceptInvitation(c *fiber.Ctx) error {
+	var req input.AcceptInvitationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.invitationService.AcceptInvitation(c.Context(), c.Params("token"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *InvitationHandler) ListInvitations(c *fiber.Ctx) error {
+	resp, err := h.invitationService.ListInvitations(c.Context())
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *InvitationHandler) ResendInvitation(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid invitation ID",
+		})
+	}
+
+	adminID := c.Locals("user_id").(uint)
+
+	if err := h.invitationService.ResendInvitation(c.Context(), uint(id), adminID); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "Invitation resent successfully",
+	})
+}
+
+func (h *InvitationHandler) RevokeInvitation(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid invitation ID",
+		})
+	}
+
+	if err := h.invitationService.RevokeInvitation(c.Context(), uint(id)); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "Invitation revoked successfully",
+	})
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type MFAHandler struct {
+	mfaService  services.MFAService
+	authService services.AuthService
+}
+
+func NewMFAHandler(mfaService services.MFAService, authService services.AuthService) *MFAHandler {
+	return &MFAHandler{
+		mfaService:  mfaService,
+		authService: authService,
+	}
+}
+
+func (h *MFAHandler) handleError(c *fiber.Ctx, err error) error {
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *MFAHandler) GetStatus(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.mfaService.GetStatus(c.Context(), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *MFAHandler) BeginEnrollment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.mfaService.BeginEnrollment(c.Context(), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *MFAHandler) ConfirmEnrollment(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req input.MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.mfaService.ConfirmEnrollment(c.Context(), userID, req.Code)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *MFAHandler) Disable(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req input.MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.mfaService.Disable(c.Context(), userID, req.Code); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "MFA disabled successfully",
+	})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req input.MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.mfaService.RegenerateRecoveryCodes(c.Context(), userID, req.Code)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+// LoginMFA completes a login that returned mfa_required.
+func (h *MFAHandler) LoginMFA(c *fiber.Ctx) error {
+	var req input.MFAVerifyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.authService.LoginMFA(c.Context(), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+// BeginLoginEnrollment starts the enrollment of a login that returned
+// mfa_enrollment_required.
+func (h *MFAHandler) BeginLoginEnrollment(c *fiber.Ctx) error {
+	var req input.MFAChallengeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.mfaService.BeginChallengeEnrollment(c.Context(), req.MFAToken)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *MFAHandler) ConfirmLoginEnrollment(c *fiber.Ctx) error {
+	var req input.MFAConfirmChallengeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.authService.ConfirmMFAEnrollment(c.Context(), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *MFAHandler) GetPolicy(c *fiber.Ctx) error {
+	resp, err := h.mfaService.GetPolicy(c.Context())
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *MFAHandler) UpdatePolicy(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req input.UpdateMFAPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	resp, err := h.mfaService.UpdatePolicy(c.Context(), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *MFAHandler) ResetUserMFA(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid user ID",
+		})
+	}
+
+	if err := h.mfaService.ResetUserMFA(c.Context(), uint(userID)); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "User MFA reset successfully",
+	})
+}
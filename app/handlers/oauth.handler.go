@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type OAuthHandler struct {
+	oauthService services.OAuthService
+	authService  services.AuthService
+	mfaService   services.MFAService
+}
+
+func NewOAuthHandler(oauthService services.OAuthService, authService services.AuthService, mfaService services.MFAService) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+		authService:  authService,
+		mfaService:   mfaService,
+	}
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in to {{.ClientName}}</title>
+<style>
+body { font-family: Arial, sans-serif; background: #f4f4f4; margin: 0; }
+main { max-width: 360px; margin: 64px auto; background: #fff; padding: 32px; border-radius: 8px; }
+h1 { font-size: 20px; margin-top: 0; }
+label { display: block; margin: 12px 0 4px; }
+input[type=email], input[type=text], input[type=password] { width: 100%; padding: 8px; box-sizing: border-box; }
+button { margin-top: 16px; width: 100%; padding: 10px; background: #007bff; color: #fff; border: 0; border-radius: 4px; }
+.error { color: #b00020; }
+hr { margin: 24px 0; }
+</style>
+</head>
+<body>
+<main>
+<h1>Sign in to {{.ClientName}}</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .MFAToken}}
+<form method="post" action="/oauth/authorize">
+{{template "params" .}}
+<input type="hidden" name="login_method" value="mfa">
+<input type="hidden" name="mfa_token" value="{{.MFAToken}}">
+<label for="code">Authentication code</label>
+<input id="code" type="text" name="code" inputmode="numeric" autocomplete="one-time-code" maxlength="6" required autofocus>
+<button type="submit">Verify</button>
+</form>
+</main>
+{{else}}
+<form method="post" action="/oauth/authorize">
+{{template "params" .}}
+<input type="hidden" name="login_method" value="password">
+<label for="email">Email</label>
+<input id="email" type="email" name="email" value="{{.Email}}" required>
+<label for="password">Password</label>
+<input id="password" type="password" name="password" required>
+<button type="submit">Sign in</button>
+</form>
+<hr>
+<form method="post" action="/oauth/authorize" id="otp-form">
+{{template "params" .}}
+<input type="hidden" name="login_method" value="otp">
+<label for="otp-email">Email for a one-time code</label>
+<input id="otp-email" type="email" name="email" value="{{.Email}}" required>
+<button type="button" id="send-otp">Send code</button>
+<label for="otp">Code</label>
+<input id="otp" type="text" name="otp" inputmode="numeric" maxlength="6" required>
+<button type="submit">Sign in with code</button>
+</form>
+</main>
+<script>
+document.getElementById("send-otp").addEventListener("click", function () {
+  fetch("/auth/login/email", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ email: document.getElementById("otp-email").value })
+  });
+});
+</script>
+{{end}}
+</body>
+</html>
+{{define "params"}}<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+{{end}}`))
+
+type loginPageData struct {
+	ClientName string
+	Request    *input.AuthorizeRequest
+	Email      string
+	Error      string
+	// MFAToken switches the page to the second-factor step.
+	MFAToken string
+}
+
+func (h *OAuthHandler) renderLogin(c *fiber.Ctx, status int, data loginPageData) error {
+	var page strings.Builder
+	if err := loginPage.Execute(&page, data); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Failed to render login page",
+		})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderXFrameOptions, "DENY")
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(status).SendString(page.String())
+}
+
+// authorizeError reports an invalid authorization request. Errors are only
+// redirected to the client once its redirect URI has been verified.
+func (h *OAuthHandler) authorizeError(c *fiber.Ctx, client *models.OAuthClient, req *input.AuthorizeRequest, err error) error {
+	var oauthErr *utils.OAuthError
+	if !errors.As(err, &oauthErr) {
+		oauthErr = utils.NewOAuthError(fiber.StatusInternalServerError, "server_error", err.Error())
+	}
+
+	if client == nil {
+		return c.Status(oauthErr.Status).JSON(oauthErr)
+	}
+
+	return c.Redirect(services.AuthorizeRedirectURL(req.RedirectURI, map[string]string{
+		"error":             oauthErr.Code,
+		"error_description": oauthErr.Description,
+		"state":             req.State,
+	}), fiber.StatusFound)
+}
+
+func (h *OAuthHandler) Authorize(c *fiber.Ctx) error {
+	var req input.AuthorizeRequest
+	if err := c.QueryParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.NewOAuthError(fiber.StatusBadRequest, "invalid_request", "malformed authorization request"))
+	}
+
+	client, err := h.oauthService.ValidateAuthorizeRequest(c.Context(), &req)
+	if err != nil {
+		return h.authorizeError(c, client, &req, err)
+	}
+
+	return h.renderLogin(c, fiber.StatusOK, loginPageData{
+		ClientName: client.Name,
+		Request:    &req,
+	})
+}
+
+// AuthorizeLogin handles the hosted login form, authenticating with the same
+// checks as the /auth/login endpoints before issuing an authorization code.
+func (h *OAuthHandler) AuthorizeLogin(c *fiber.Ctx) error {
+	var req input.AuthorizeRequest
+	var login input.AuthorizeLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.NewOAuthError(fiber.StatusBadRequest, "invalid_request", "malformed authorization request"))
+	}
+	if err := c.BodyParser(&login); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.NewOAuthError(fiber.StatusBadRequest, "invalid_request", "malformed login request"))
+	}
+
+	client, err := h.oauthService.ValidateAuthorizeRequest(c.Context(), &req)
+	if err != nil {
+		return h.authorizeError(c, client, &req, err)
+	}
+
+	var user *models.User
+	switch login.LoginMethod {
+	case "mfa":
+		user, err = h.authService.AuthenticateMFA(c.Context(), &input.MFAVerifyRequest{
+			MFAToken: login.MFAToken,
+			Code:     login.Code,
+		})
+		if err != nil {
+			return h.renderLogin(c, loginErrorStatus(c, err), loginPageData{
+				ClientName: client.Name,
+				Request:    &req,
+				Error:      err.Error(),
+				MFAToken:   login.MFAToken,
+			})
+		}
+	case "otp":
+		user, err = h.authService.AuthenticateOTP(c.Context(), &input.VerifyOTPRequest{
+			Email: login.Email,
+			Phone: login.Phone,
+			OTP:   login.OTP,
+		})
+	default:
+		user, err = h.authService.AuthenticatePassword(c.Context(), &input.LoginPasswordRequest{
+			Email:    login.Email,
+			Password: login.Password,
+		})
+	}
+	if err != nil {
+		return h.renderLogin(c, loginErrorStatus(c, err), loginPageData{
+			ClientName: client.Name,
+			Request:    &req,
+			Email:      login.Email,
+			Error:      err.Error(),
+		})
+	}
+
+	if login.LoginMethod != "mfa" {
+		challenge, err := h.mfaService.Challenge(c.Context(), user)
+		if err != nil {
+			return h.authorizeError(c, client, &req, err)
+		}
+		if challenge != nil && challenge.MFAEnrollmentRequired {
+			return h.renderLogin(c, fiber.StatusForbidden, loginPageData{
+				ClientName: client.Name,
+				Request:    &req,
+				Email:      login.Email,
+				Error:      "Multi-factor authentication must be set up for this account before signing in here",
+			})
+		}
+		if challenge != nil {
+			return h.renderLogin(c, fiber.StatusOK, loginPageData{
+				ClientName: client.Name,
+				Request:    &req,
+				MFAToken:   challenge.MFAToken,
+			})
+		}
+	}
+
+	redirectURL, err := h.oauthService.IssueAuthorizationCode(c.Context(), &req, user, loginAMR(login))
+	if err != nil {
+		return h.authorizeError(c, client, &req, err)
+	}
+
+	return c.Redirect(redirectURL, fiber.StatusFound)
+}
+
+func loginErrorStatus(c *fiber.Ctx, err error) int {
+	if rateLimitErr, ok := err.(*utils.RateLimitError); ok {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(rateLimitErr.RetryAfterSeconds()))
+		return fiber.StatusTooManyRequests
+	}
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return httpErr.Code
+	}
+	return fiber.StatusUnauthorized
+}
+
+// loginAMR reports how the login form authenticated the user.
+func loginAMR(login input.AuthorizeLoginRequest) []string {
+	switch login.LoginMethod {
+	case "mfa":
+		return []string{utils.AMRMFA, utils.AMROTP}
+	case "otp":
+		if login.Phone != "" {
+			return []string{utils.AMRSMS}
+		}
+		return []string{utils.AMROTP}
+	default:
+		return []string{utils.AMRPassword}
+	}
+}
+
+func (h *OAuthHandler) Token(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+
+	var req input.OAuthTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.NewOAuthError(fiber.StatusBadRequest, "invalid_request", "malformed token request"))
+	}
+
+	if clientID, clientSecret, ok := basicAuth(c.Get(fiber.HeaderAuthorization)); ok {
+		req.ClientID = clientID
+		req.ClientSecret = clientSecret
+	}
+
+	resp, err := h.oauthService.Token(c.Context(), &req)
+	if err != nil {
+		return oauthErrorResponse(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *OAuthHandler) Introspect(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	req, err := parseTokenActionRequest(c)
+	if err != nil {
+		return oauthErrorResponse(c, err)
+	}
+
+	resp, err := h.oauthService.Introspect(c.Context(), req)
+	if err != nil {
+		return oauthErrorResponse(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+// Revoke answers 200 with an empty body whether or not the token was
+// valid, as RFC 7009 requires.
+func (h *OAuthHandler) Revoke(c *fiber.Ctx) error {
+	req, err := parseTokenActionRequest(c)
+	if err != nil {
+		return oauthErrorResponse(c, err)
+	}
+
+	if err := h.oauthService.Revoke(c.Context(), req); err != nil {
+		return oauthErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).Send(nil)
+}
+
+func parseTokenActionRequest(c *fiber.Ctx) (*input.OAuthTokenActionRequest, error) {
+	var req input.OAuthTokenActionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, utils.NewOAuthError(fiber.StatusBadRequest, "invalid_request", "malformed request")
+	}
+
+	if clientID, clientSecret, ok := basicAuth(c.Get(fiber.HeaderAuthorization)); ok {
+		req.ClientID = clientID
+		req.ClientSecret = clientSecret
+	}
+
+	return &req, nil
+}
+
+// oauthErrorResponse writes err as an RFC 6749 error response, asking for
+// HTTP Basic client authentication when it failed.
+func oauthErrorResponse(c *fiber.Ctx, err error) error {
+	var oauthErr *utils.OAuthError
+	if !errors.As(err, &oauthErr) {
+		oauthErr = utils.NewOAuthError(fiber.StatusInternalServerError, "server_error", err.Error())
+	}
+	if oauthErr.Status == fiber.StatusUnauthorized {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+	return c.Status(oauthErr.Status).JSON(oauthErr)
+}
+
+// basicAuth decodes client credentials sent with HTTP Basic authentication,
+// which RFC 6749 requires to be form-encoded before base64 encoding.
+func basicAuth(header string) (string, string, bool) {
+	const prefix = "Basic "
+	if !strings.HasPrefix(header, prefix) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+
+	id, secret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", false
+	}
+
+	id, err = url.QueryUnescape(id)
+	if err != nil {
+		return "", "", false
+	}
+	secret, err = url.QueryUnescape(secret)
+	if err != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+func (h *OAuthHandler) handleError(c *fiber.Ctx, err error) error {
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *OAuthHandler) CreateClient(c *fiber.Ctx) error {
+	var req input.CreateOAuthClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	createdBy := c.Locals("user_id").(uint)
+
+	resp, err := h.oauthService.CreateClient(c.Context(), createdBy, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+func (h *OAuthHandler) GetClients(c *fiber.Ctx) error {
+	resp, err := h.oauthService.GetClients(c.Context())
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *OAuthHandler) UpdateClient(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid client ID",
+		})
+	}
+
+	var req input.UpdateOAuthClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.oauthService.UpdateClient(c.Context(), uint(id), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *OAuthHandler) DeleteClient(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid client ID",
+		})
+	}
+
+	if err := h.oauthService.DeleteClient(c.Context(), uint(id)); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Client deleted successfully",
+	})
+}
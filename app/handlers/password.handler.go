@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PasswordHandler struct {
+	passwordResetService services.PasswordResetService
+}
+
+func NewPasswordHandler(passwordResetService services.PasswordResetService) *PasswordHandler {
+	return &PasswordHandler{
+		passwordResetService: passwordResetService,
+	}
+}
+
+func (h *PasswordHandler) handleError(c *fiber.Ctx, err error) error {
+	if validationErr, ok := err.(*utils.ValidationError); ok {
+		return validationErrorResponse(c, validationErr)
+	}
+
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *PasswordHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req input.ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.passwordResetService.ForgotPassword(c.Context(), &req); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "If an account exists for this email, a password reset link has been sent",
+	})
+}
+
+func (h *PasswordHandler) ResetPassword(c *fiber.Ctx) error {
+	var req input.CompletePasswordResetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	if err := h.passwordResetService.ResetPassword(c.Context(), &req); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "Password reset successfully",
+	})
+}
+
+// validationErrorResponse renders a policy failure with each broken rule so
+// that clients can show them all at once.
+func validationErrorResponse(c *fiber.Ctx, err *utils.ValidationError) error {
+	return c.Status(fiber.StatusBadRequest).JSON(output.ValidationErrorResponse{
+		Error:      true,
+		Message:    err.Message,
+		Code:       fiber.StatusBadRequest,
+		Violations: err.Violations,
+	})
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type RoleHandler struct {
+	roleService services.RoleService
+}
+
+func NewRoleHandler(roleService services.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+	}
+}
+
+func (h *RoleHandler) handleError(c *fiber.Ctx, err error) error {
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *RoleHandler) parseID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return 0, utils.NewBadRequestError("Invalid role ID")
+	}
+	return uint(id), nil
+}
+
+func (h *RoleHandler) CreateRole(c *fiber.Ctx) error {
+	var req input.CreateRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.roleService.CreateRole(c.Context(), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+func (h *RoleHandler) GetRoles(c *fiber.Ctx) error {
+	resp, err := h.roleService.GetRoles(c.Context())
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *RoleHandler) GetRole(c *fiber.Ctx) error {
+	id, err := h.parseID(c)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	resp, err := h.roleService.GetRole(c.Context(), id)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *RoleHandler) UpdateRole(c *fiber.Ctx) error {
+	id, err := h.parseID(c)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	var req input.UpdateRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	resp, err := h.roleService.UpdateRole(c.Context(), id, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *RoleHandler) DeleteRole(c *fiber.Ctx) error {
+	id, err := h.parseID(c)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	if err := h.roleService.DeleteRole(c.Context(), id); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "Role deleted successfully",
+	})
+}
+
+func (h *RoleHandler) GetRoleUsers(c *fiber.Ctx) error {
+	id, err := h.parseID(c)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "10"))
+	if err != nil {
+		limit = 10
+	}
+
+	resp, err := h.roleService.GetRoleUsers(c.Context(), id, page, limit)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
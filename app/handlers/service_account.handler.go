@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccountService services.ServiceAccountService
+}
+
+func NewServiceAccountHandler(serviceAccountService services.ServiceAccountService) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccountService: serviceAccountService,
+	}
+}
+
+func (h *ServiceAccountHandler) handleError(c *fiber.Ctx, err error) error {
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *ServiceAccountHandler) CreateServiceAccount(c *fiber.Ctx) error {
+	var req input.CreateServiceAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	createdBy := c.Locals("user_id").(uint)
+
+	resp, err := h.serviceAccountService.CreateServiceAccount(c.Context(), createdBy, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+func (h *ServiceAccountHandler) GetServiceAccounts(c *fiber.Ctx) error {
+	resp, err := h.serviceAccountService.GetServiceAccounts(c.Context())
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *ServiceAccountHandler) RotateSecret(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid service account ID",
+		})
+	}
+
+	resp, err := h.serviceAccountService.RotateSecret(c.Context(), uint(id))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *ServiceAccountHandler) DeactivateServiceAccount(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid service account ID",
+		})
+	}
+
+	if err := h.serviceAccountService.DeactivateServiceAccount(c.Context(), uint(id)); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Service account deactivated successfully",
+	})
+}
+
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid service account ID",
+		})
+	}
+
+	if err := h.serviceAccountService.DeleteServiceAccount(c.Context(), uint(id)); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Service account deleted successfully",
+	})
+}
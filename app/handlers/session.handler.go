@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SessionHandler struct {
+	sessionService services.SessionService
+}
+
+func NewSessionHandler(sessionService services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+func (h *SessionHandler) handleError(c *fiber.Ctx, err error) error {
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *SessionHandler) GetSessions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var currentSessionID string
+	if claims, ok := c.Locals("user_claims").(*output.Claims); ok {
+		currentSessionID = claims.SessionID
+	}
+
+	resp, err := h.sessionService.GetSessions(c.Context(), userID, currentSessionID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *SessionHandler) RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	if err := h.sessionService.RevokeSession(c.Context(), userID, c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "Session revoked successfully",
+	})
+}
+
+func (h *SessionHandler) LogoutAll(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	if err := h.sessionService.LogoutAll(c.Context(), userID, "logout_all"); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "Logged out of all sessions",
+	})
+}
+
+func (h *SessionHandler) GetUserSessions(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid user ID",
+		})
+	}
+
+	resp, err := h.sessionService.GetSessions(c.Context(), uint(userID), "")
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *SessionHandler) ForceLogout(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid user ID",
+		})
+	}
+
+	if err := h.sessionService.ForceLogout(c.Context(), uint(userID)); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "User logged out of all sessions",
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/services"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebAuthnHandler struct {
+	webAuthnService services.WebAuthnService
+	authService     services.AuthService
+}
+
+func NewWebAuthnHandler(webAuthnService services.WebAuthnService, authService services.AuthService) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		webAuthnService: webAuthnService,
+		authService:     authService,
+	}
+}
+
+func (h *WebAuthnHandler) handleError(c *fiber.Ctx, err error) error {
+	if httpErr, ok := err.(*utils.HTTPError); ok {
+		return c.Status(httpErr.Code).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: httpErr.Message,
+			Code:    httpErr.Code,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(output.ErrorResponse{
+		Error:   true,
+		Message: err.Error(),
+	})
+}
+
+func (h *WebAuthnHandler) BeginRegistration(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.webAuthnService.BeginRegistration(c.Context(), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *WebAuthnHandler) FinishRegistration(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	var req input.WebAuthnRegistrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.webAuthnService.FinishRegistration(c.Context(), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resp)
+}
+
+func (h *WebAuthnHandler) BeginLogin(c *fiber.Ctx) error {
+	var req input.WebAuthnLoginBeginRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+				Error:   true,
+				Message: "Invalid request body",
+			})
+		}
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.webAuthnService.BeginLogin(c.Context(), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *WebAuthnHandler) FinishLogin(c *fiber.Ctx) error {
+	var req input.WebAuthnAssertionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid request body",
+		})
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: err.Error(),
+		})
+	}
+
+	resp, err := h.authService.LoginWebAuthn(c.Context(), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *WebAuthnHandler) GetCredentials(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	resp, err := h.webAuthnService.GetCredentials(c.Context(), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *WebAuthnHandler) DeleteCredential(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(output.ErrorResponse{
+			Error:   true,
+			Message: "Invalid passkey ID",
+		})
+	}
+
+	if err := h.webAuthnService.DeleteCredential(c.Context(), userID, uint(id)); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.JSON(output.SuccessResponse{
+		Success: true,
+		Message: "Passkey removed successfully",
+	})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WellKnownHandler struct {
+	publicURL string
+}
+
+func NewWellKnownHandler(publicURL string) *WellKnownHandler {
+	return &WellKnownHandler{
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+func (h *WellKnownHandler) baseURL(c *fiber.Ctx) string {
+	if h.publicURL != "" {
+		return h.publicURL
+	}
+	return c.BaseURL()
+}
+
+func (h *WellKnownHandler) JWKS(c *fiber.Ctx) error {
+	ks, err := utils.GetKeySet()
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(ks.JWKS())
+}
+
+func (h *WellKnownHandler) OpenIDConfiguration(c *fiber.Ctx) error {
+	ks, err := utils.GetKeySet()
+	if err != nil {
+		return err
+	}
+
+	base := h.baseURL(c)
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=3600")
+	return c.JSON(output.OpenIDConfiguration{
+		Issuer:                            utils.Issuer(),
+		AuthorizationEndpoint:             base + "/oauth/authorize",
+		TokenEndpoint:                     base + "/oauth/token",
+		IntrospectionEndpoint:             base + "/oauth/introspect",
+		RevocationEndpoint:                base + "/oauth/revoke",
+		UserinfoEndpoint:                  base + "/userinfo",
+		JWKSURI:                           base + "/.well-known/jwks.json",
+		ScopesSupported:                   []string{"openid", "email", "phone", "profile"},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{ks.SigningKey().Algorithm},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"email", "email_verified", "phone_number", "phone_number_verified", "name",
+		},
+	})
+}
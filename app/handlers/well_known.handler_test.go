@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestOpenIDConfiguration(t *testing.T) {
+	tests := []struct {
+		name      string
+		publicURL string
+		wantBase  string
+	}{
+		{name: "public URL", publicURL: "https://auth.example.com/", wantBase: "https://auth.example.com"},
+		{name: "request URL", wantBase: "http://example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/.well-known/openid-configuration", NewWellKnownHandler(tt.publicURL).OpenIDConfiguration)
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/.well-known/openid-configuration", nil))
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+
+			var doc output.OpenIDConfiguration
+			if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			for name, endpoint := range map[string]string{
+				"authorization": doc.AuthorizationEndpoint,
+				"token":         doc.TokenEndpoint,
+				"userinfo":      doc.UserinfoEndpoint,
+				"jwks":          doc.JWKSURI,
+			} {
+				if !strings.HasPrefix(endpoint, tt.wantBase+"/") {
+					t.Errorf("%s endpoint = %s, want it under %s", name, endpoint, tt.wantBase)
+				}
+			}
+			if doc.Issuer == "" || len(doc.IDTokenSigningAlgValuesSupported) != 1 {
+				t.Errorf("issuer = %q, signing algs = %v", doc.Issuer, doc.IDTokenSigningAlgValuesSupported)
+			}
+		})
+	}
+}
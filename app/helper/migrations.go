@@ -46,6 +46,23 @@ func RunMigrations(db *gorm.DB) error {
 		&models.User{},
 		&models.RefreshToken{},
 		&models.UserSession{},
+		&models.OTPCode{},
+		&models.OAuthClient{},
+		&models.OAuthAuthorizationCode{},
+		&models.ServiceAccount{},
+		&models.APIKey{},
+		&models.TokenRevocation{},
+		&models.UserMFA{},
+		&models.MFARecoveryCode{},
+		&models.MFAPolicy{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnChallenge{},
+		&models.PasswordResetToken{},
+		&models.PasswordHistory{},
+		&models.RateLimitCounter{},
+		&models.Invitation{},
+		&models.NotificationOutbox{},
+		&models.AuthEvent{},
 		&models.Support{},
 
 		&models.BusinessType{},
@@ -322,6 +339,23 @@ func DropAllTables(db *gorm.DB) error {
 		&models.BusinessType{},
 
 		&models.Support{},
+		&models.AuthEvent{},
+		&models.NotificationOutbox{},
+		&models.Invitation{},
+		&models.RateLimitCounter{},
+		&models.PasswordHistory{},
+		&models.PasswordResetToken{},
+		&models.WebAuthnChallenge{},
+		&models.WebAuthnCredential{},
+		&models.MFAPolicy{},
+		&models.MFARecoveryCode{},
+		&models.UserMFA{},
+		&models.TokenRevocation{},
+		&models.APIKey{},
+		&models.ServiceAccount{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthClient{},
+		&models.OTPCode{},
 		&models.UserSession{},
 		&models.RefreshToken{},
 		&models.User{},
@@ -1,14 +1,33 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/bbapp-org/auth-service/app/dto/output"
 	"github.com/bbapp-org/auth-service/app/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func AuthMiddleware() fiber.Handler {
+	return bearerAuth(false)
+}
+
+// UserAuthMiddleware is AuthMiddleware for routes that act on the signed-in
+// user's own account or need a human administrator. Service account tokens
+// have no user, so they are refused.
+func UserAuthMiddleware() fiber.Handler {
+	return bearerAuth(true)
+}
+
+func bearerAuth(usersOnly bool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if c.Method() == fiber.MethodOptions {
 			return c.Next()
@@ -40,20 +59,174 @@ func AuthMiddleware() fiber.Handler {
 			})
 		}
 
+		if usersOnly && claims.IdentityType == "client_credentials" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   true,
+				"message": "Service account tokens cannot be used here",
+			})
+		}
+
+		setClaimsLocals(c, claims)
+
+		return c.Next()
+	}
+}
+
+// APIKeyAuthenticator resolves an API key to the claims of its owner.
+type APIKeyAuthenticator func(key, ip string) (*output.Claims, error)
+
+// APIKeyOrAuthMiddleware accepts an X-API-Key header in place of a Bearer
+// token. Requests made with an API key are limited to the key's scopes.
+func APIKeyOrAuthMiddleware(authenticate APIKeyAuthenticator) fiber.Handler {
+	bearer := AuthMiddleware()
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get("X-API-Key")
+		if key == "" || c.Method() == fiber.MethodOptions {
+			return bearer(c)
+		}
+
+		ip, _ := c.Locals(utils.ClientIPKey).(string)
+		claims, err := authenticate(key, ip)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   true,
+				"message": err.Error(),
+			})
+		}
+
+		resource, action := utils.ResourceAction(c.Path(), c.Method())
+		if !utils.ScopeAllows(claims.Scope, resource, action) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   true,
+				"message": "API key does not grant " + resource + ":" + action,
+			})
+		}
+
+		setClaimsLocals(c, claims)
+
+		return c.Next()
+	}
+}
+
+func setClaimsLocals(c *fiber.Ctx, claims *output.Claims) {
+	// Service accounts have their own ID space, so their ID is never
+	// exposed as user_id where handlers would treat it as a user.
+	if claims.UserType == "service" {
+		c.Locals("user_id", uint(0))
+		c.Locals("service_account_id", claims.ServiceAccountID)
+	} else {
 		c.Locals("user_id", claims.UserID)
-		c.Locals("user_type", claims.UserType)
-		c.Locals("user_role", claims.Role)
-		c.Locals("user_email", claims.Email)
-		c.Locals("user_phone", claims.Phone)
-		c.Locals("user_claims", claims)
+	}
+	c.Locals("client_id", claims.ClientID)
+	c.Locals("token_scope", claims.Scope)
+	c.Locals("user_type", claims.UserType)
+	c.Locals("user_role", claims.Role)
+	c.Locals("user_email", claims.Email)
+	c.Locals("user_phone", claims.Phone)
+	c.Locals("user_claims", claims)
+}
 
+// NoImpersonation refuses requests made with an impersonation token, for
+// routes that change the user's credentials or security settings.
+func NoImpersonation() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if claims, ok := c.Locals("user_claims").(*output.Claims); ok && claims.ImpersonatorID != 0 {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   true,
+				"message": "Not allowed while impersonating a user",
+			})
+		}
 		return c.Next()
 	}
 }
 
+// RequireRecentAuth refuses tokens whose auth_time is older than maxAge or,
+// when methods are given, whose amr names none of them. Clients answer the
+// 401 by calling /auth/reauthenticate and retrying with the token it issues.
+func RequireRecentAuth(maxAge time.Duration, methods ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := c.Locals("user_claims").(*output.Claims)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   true,
+				"message": "Authentication required",
+			})
+		}
+
+		recent := claims.AuthTime != 0 && time.Since(time.Unix(claims.AuthTime, 0)) <= maxAge
+		if recent && usedMethod(claims.AMR, methods) {
+			return c.Next()
+		}
+
+		maxAgeSeconds := int(maxAge.Seconds())
+		body := fiber.Map{
+			"error":   true,
+			"message": "Reauthentication required",
+			"code":    "REAUTHENTICATION_REQUIRED",
+			"max_age": maxAgeSeconds,
+		}
+		if len(methods) > 0 {
+			body["methods"] = methods
+		}
+
+		c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error="insufficient_user_authentication", max_age=%d`, maxAgeSeconds))
+		return c.Status(fiber.StatusUnauthorized).JSON(body)
+	}
+}
+
+// usedMethod reports whether amr names one of methods. Any method will do
+// when none are given.
+func usedMethod(amr, methods []string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(amr, func(m string) bool {
+		return slices.Contains(methods, m)
+	})
+}
+
+// ImpersonationRecorder audits a request made with an impersonation token.
+type ImpersonationRecorder func(ctx context.Context, claims *output.Claims, method, path string, status int)
+
+// AuditImpersonation records every request that was authenticated with an
+// impersonation token once it has been handled. It is installed before the
+// routes, so it sees the claims set by whichever auth middleware ran.
+func AuditImpersonation(record ImpersonationRecorder) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		claims, ok := c.Locals("user_claims").(*output.Claims)
+		if !ok || claims.ImpersonatorID == 0 {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if fiberErr, ok := err.(*fiber.Error); ok {
+				status = fiberErr.Code
+			}
+		}
+		record(c.Context(), claims, c.Method(), c.Path(), status)
+
+		return err
+	}
+}
+
+// actingUserType is the user type the middlewares below authorize, as
+// resolved by utils.ActingUserType.
+func actingUserType(c *fiber.Ctx) string {
+	claims, ok := c.Locals("user_claims").(*output.Claims)
+	if !ok {
+		return ""
+	}
+	return utils.ActingUserType(claims)
+}
+
 func SuperAdminMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userType := c.Locals("user_type")
+		userType := actingUserType(c)
 		if userType != "superadmin" {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error":   true,
@@ -66,7 +239,7 @@ func SuperAdminMiddleware() fiber.Handler {
 
 func AdminMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userType := c.Locals("user_type")
+		userType := actingUserType(c)
 		if userType != "admin" && userType != "superadmin" {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error":   true,
@@ -79,7 +252,7 @@ func AdminMiddleware() fiber.Handler {
 
 func PartnerMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userType := c.Locals("user_type")
+		userType := actingUserType(c)
 		if userType != "partner" && userType != "admin" && userType != "superadmin" {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error":   true,
@@ -92,7 +265,7 @@ func PartnerMiddleware() fiber.Handler {
 
 func MobileUserMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userType := c.Locals("user_type")
+		userType := actingUserType(c)
 		if userType != "mobile_user" {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error":   true,
@@ -102,3 +275,117 @@ func MobileUserMiddleware() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// RequirePermission allows the request when the authenticated principal
+// holds permission, as resolved by utils.HasPermission. It must run after
+// AuthMiddleware or APIKeyOrAuthMiddleware.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() == fiber.MethodOptions {
+			return c.Next()
+		}
+
+		claims, ok := c.Locals("user_claims").(*output.Claims)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   true,
+				"message": "Authentication required",
+			})
+		}
+
+		allowed, err := utils.HasPermission(claims, permission)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   true,
+				"message": "Failed to resolve permissions",
+			})
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   true,
+				"message": "Permission " + permission + " required",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// ClientInfo records the caller's IP address and user agent for
+// utils.ClientInfo, and their preferred language for utils.ClientLanguage.
+// The IP address follows the app's ProxyHeader and TrustedProxies, see
+// clientIP.
+func ClientInfo() fiber.Handler {
+	var once sync.Once
+	var proxies trustedProxies
+	var header string
+
+	return func(c *fiber.Ctx) error {
+		once.Do(func() {
+			cfg := c.App().Config()
+			proxies, header = newTrustedProxies(cfg.TrustedProxies), cfg.ProxyHeader
+		})
+
+		c.Locals(utils.ClientIPKey, proxies.clientIP(c, header))
+		c.Locals(utils.UserAgentKey, c.Get(fiber.HeaderUserAgent))
+		c.Locals(utils.ClientLanguageKey, utils.PreferredLanguage(c.Get(fiber.HeaderAcceptLanguage)))
+		return c.Next()
+	}
+}
+
+// trustedProxies are the networks whose proxy header is believed.
+type trustedProxies []*net.IPNet
+
+func newTrustedProxies(entries []string) trustedProxies {
+	var proxies trustedProxies
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid trusted proxy %q: %v", entry, err)
+			continue
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies
+}
+
+func (p trustedProxies) contains(ip net.IP) bool {
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the peer address, unless the peer is a trusted proxy. Then it
+// is the right-most address in header that is not a trusted proxy itself:
+// each proxy appends the address it received the request from, so entries
+// further left were sent by the client and may be forged. c.IP() takes the
+// left-most entry, which is why it is not used here.
+func (p trustedProxies) clientIP(c *fiber.Ctx, header string) string {
+	peer := c.Context().RemoteIP()
+	if header == "" || !p.contains(peer) {
+		return peer.String()
+	}
+
+	hops := strings.Split(c.Get(header), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		peer = ip
+		if !p.contains(ip) {
+			break
+		}
+	}
+	return peer.String()
+}
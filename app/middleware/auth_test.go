@@ -0,0 +1,342 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// testStatus sends a request carrying token through handlers and returns the
+// response status.
+func testStatus(t *testing.T, token string, handlers ...fiber.Handler) int {
+	t.Helper()
+
+	app := fiber.New()
+	handlers = append(handlers, func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	app.Get("/", handlers...)
+
+	req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestUserAuthMiddlewareRefusesServiceTokens(t *testing.T) {
+	userToken, err := utils.GenerateJWT(output.Claims{UserID: 7, UserType: "admin", Role: "admin"})
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	serviceToken, err := utils.GenerateServiceToken(7, "svc_reports", "invoices:read", time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateServiceToken: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		handler fiber.Handler
+		want    int
+	}{
+		{name: "user on user route", token: userToken, handler: UserAuthMiddleware(), want: fiber.StatusNoContent},
+		{name: "service on user route", token: serviceToken, handler: UserAuthMiddleware(), want: fiber.StatusForbidden},
+		{name: "service on resource route", token: serviceToken, handler: AuthMiddleware(), want: fiber.StatusNoContent},
+		{name: "no token", handler: UserAuthMiddleware(), want: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testStatus(t, tt.token, tt.handler); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceTokenHasNoUserIDLocal(t *testing.T) {
+	serviceToken, err := utils.GenerateServiceToken(7, "svc_reports", "invoices:read", time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateServiceToken: %v", err)
+	}
+
+	status := testStatus(t, serviceToken, AuthMiddleware(), func(c *fiber.Ctx) error {
+		if userID := c.Locals("user_id").(uint); userID != 0 {
+			t.Errorf("user_id = %d, want 0", userID)
+		}
+		if accountID := c.Locals("service_account_id").(uint); accountID != 7 {
+			t.Errorf("service_account_id = %d, want 7", accountID)
+		}
+		return c.Next()
+	})
+	if status != fiber.StatusNoContent {
+		t.Errorf("status = %d", status)
+	}
+}
+
+func TestUserTypeMiddlewaresRequireAdminScopeForClients(t *testing.T) {
+	direct, err := utils.GenerateJWT(output.Claims{UserID: 1, UserType: "superadmin", Role: "superadmin"})
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	client, err := utils.GenerateJWT(output.Claims{UserID: 1, UserType: "superadmin", Role: "superadmin", ClientID: "reports", Scope: "openid"})
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	adminClient, err := utils.GenerateJWT(output.Claims{UserID: 1, UserType: "superadmin", Role: "superadmin", ClientID: "console", Scope: "openid admin"})
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		middleware fiber.Handler
+		want       int
+	}{
+		{name: "direct superadmin", token: direct, middleware: SuperAdminMiddleware(), want: fiber.StatusNoContent},
+		{name: "client without admin scope", token: client, middleware: SuperAdminMiddleware(), want: fiber.StatusForbidden},
+		{name: "client without admin scope on admin route", token: client, middleware: AdminMiddleware(), want: fiber.StatusForbidden},
+		{name: "client without admin scope on partner route", token: client, middleware: PartnerMiddleware(), want: fiber.StatusForbidden},
+		{name: "client with admin scope", token: adminClient, middleware: SuperAdminMiddleware(), want: fiber.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testStatus(t, tt.token, UserAuthMiddleware(), tt.middleware); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	utils.SetPermissionResolver(func(role string) ([]string, error) {
+		switch role {
+		case "superadmin":
+			return []string{"*"}, nil
+		case "accountant":
+			return []string{"invoices:*", "payments:read"}, nil
+		case "broken":
+			return nil, errors.New("role lookup failed")
+		}
+		return nil, nil
+	})
+	t.Cleanup(func() { utils.SetPermissionResolver(nil) })
+
+	token := func(claims output.Claims) string {
+		signed, err := utils.GenerateJWT(claims)
+		if err != nil {
+			t.Fatalf("GenerateJWT: %v", err)
+		}
+		return signed
+	}
+	serviceToken, err := utils.GenerateServiceToken(7, "svc_reports", "invoices:read", time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateServiceToken: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		permission string
+		want       int
+	}{
+		{name: "resource wildcard", token: token(output.Claims{UserID: 2, UserType: "admin", Role: "accountant"}), permission: "invoices:delete", want: fiber.StatusNoContent},
+		{name: "exact permission", token: token(output.Claims{UserID: 2, UserType: "admin", Role: "accountant"}), permission: "payments:read", want: fiber.StatusNoContent},
+		{name: "missing action", token: token(output.Claims{UserID: 2, UserType: "admin", Role: "accountant"}), permission: "payments:write", want: fiber.StatusForbidden},
+		{name: "global wildcard", token: token(output.Claims{UserID: 1, UserType: "superadmin", Role: "superadmin"}), permission: "users:delete", want: fiber.StatusNoContent},
+		{name: "role without permissions", token: token(output.Claims{UserID: 3, UserType: "partner", Role: "partner"}), permission: "invoices:read", want: fiber.StatusForbidden},
+		{name: "service scope", token: serviceToken, permission: "invoices:read", want: fiber.StatusNoContent},
+		{name: "service beyond scope", token: serviceToken, permission: "invoices:write", want: fiber.StatusForbidden},
+		{name: "resolver failure", token: token(output.Claims{UserID: 4, UserType: "admin", Role: "broken"}), permission: "invoices:read", want: fiber.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testStatus(t, tt.token, AuthMiddleware(), RequirePermission(tt.permission)); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if got := testStatus(t, "", RequirePermission("invoices:read")); got != fiber.StatusUnauthorized {
+		t.Errorf("without AuthMiddleware: status = %d, want %d", got, fiber.StatusUnauthorized)
+	}
+}
+
+func TestRequireRecentAuth(t *testing.T) {
+	token := func(authTime time.Time, amr ...string) string {
+		claims := output.Claims{UserID: 2, UserType: "admin", Role: "admin", AMR: amr}
+		if !authTime.IsZero() {
+			claims.AuthTime = authTime.Unix()
+		}
+		signed, err := utils.GenerateJWT(claims)
+		if err != nil {
+			t.Fatalf("GenerateJWT: %v", err)
+		}
+		return signed
+	}
+	recent := time.Now().Add(-time.Minute)
+	stale := time.Now().Add(-10 * time.Minute)
+
+	tests := []struct {
+		name    string
+		token   string
+		methods []string
+		want    int
+	}{
+		{name: "recent", token: token(recent, utils.AMRPassword), want: fiber.StatusNoContent},
+		{name: "stale", token: token(stale, utils.AMRPassword), want: fiber.StatusUnauthorized},
+		{name: "no auth_time", token: token(time.Time{}), want: fiber.StatusUnauthorized},
+		{name: "recent with a required method", token: token(recent, utils.AMRMFA, utils.AMROTP), methods: []string{utils.AMRMFA, utils.AMRHardwareKey}, want: fiber.StatusNoContent},
+		{name: "recent without a required method", token: token(recent, utils.AMRPassword), methods: []string{utils.AMRMFA, utils.AMRHardwareKey}, want: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", UserAuthMiddleware(), RequireRecentAuth(5*time.Minute, tt.methods...), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tt.token)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == fiber.StatusNoContent {
+				return
+			}
+
+			if got := resp.Header.Get(fiber.HeaderWWWAuthenticate); got != `Bearer error="insufficient_user_authentication", max_age=300` {
+				t.Errorf("WWW-Authenticate = %q", got)
+			}
+			var body struct {
+				Code    string   `json:"code"`
+				MaxAge  int      `json:"max_age"`
+				Methods []string `json:"methods"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Code != "REAUTHENTICATION_REQUIRED" || body.MaxAge != 300 || len(body.Methods) != len(tt.methods) {
+				t.Errorf("body = %+v", body)
+			}
+		})
+	}
+
+	if got := testStatus(t, "", RequireRecentAuth(5*time.Minute)); got != fiber.StatusUnauthorized {
+		t.Errorf("without UserAuthMiddleware: status = %d, want %d", got, fiber.StatusUnauthorized)
+	}
+}
+
+func TestImpersonationRequests(t *testing.T) {
+	userToken, err := utils.GenerateJWT(output.Claims{UserID: 7, UserType: "partner", Role: "partner"})
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	impersonationToken, err := utils.GenerateImpersonationToken(output.Claims{UserID: 7, UserType: "partner", Role: "partner", ImpersonatorID: 1}, time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateImpersonationToken: %v", err)
+	}
+	notFound := func(c *fiber.Ctx) error { return fiber.ErrNotFound }
+
+	tests := []struct {
+		name     string
+		token    string
+		handlers []fiber.Handler
+		want     int
+		// wantAudited is the status recorded for the request, 0 when it is
+		// not recorded.
+		wantAudited int
+	}{
+		{name: "user", token: userToken, want: fiber.StatusNoContent},
+		{name: "impersonated", token: impersonationToken, want: fiber.StatusNoContent, wantAudited: fiber.StatusNoContent},
+		{name: "user on credential route", token: userToken, handlers: []fiber.Handler{NoImpersonation()}, want: fiber.StatusNoContent},
+		{name: "impersonated on credential route", token: impersonationToken, handlers: []fiber.Handler{NoImpersonation()}, want: fiber.StatusForbidden, wantAudited: fiber.StatusForbidden},
+		{name: "impersonated request failing", token: impersonationToken, handlers: []fiber.Handler{notFound}, want: fiber.StatusNotFound, wantAudited: fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var audited []int
+			record := func(ctx context.Context, claims *output.Claims, method, path string, status int) {
+				if claims.UserID != 7 || claims.ImpersonatorID != 1 || method != fiber.MethodGet || path != "/" {
+					t.Errorf("recorded %s %s for %+v", method, path, claims)
+				}
+				audited = append(audited, status)
+			}
+
+			handlers := append([]fiber.Handler{AuditImpersonation(record), UserAuthMiddleware()}, tt.handlers...)
+			if got := testStatus(t, tt.token, handlers...); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+			if (len(audited) == 1) != (tt.wantAudited != 0) || (len(audited) == 1 && audited[0] != tt.wantAudited) {
+				t.Errorf("audited %v, want %d", audited, tt.wantAudited)
+			}
+		})
+	}
+}
+
+func TestClientInfoClientIP(t *testing.T) {
+	// app.Test connects from 0.0.0.0.
+	tests := []struct {
+		name    string
+		trusted []string
+		header  string
+		want    string
+	}{
+		{name: "no trusted proxies", header: "203.0.113.7", want: "0.0.0.0"},
+		{name: "untrusted peer", trusted: []string{"10.0.0.0/8"}, header: "203.0.113.7", want: "0.0.0.0"},
+		{name: "trusted peer", trusted: []string{"0.0.0.0"}, header: "203.0.113.7", want: "203.0.113.7"},
+		{name: "forged entry before the client", trusted: []string{"0.0.0.0"}, header: "198.51.100.1, 203.0.113.7", want: "203.0.113.7"},
+		{name: "chain of trusted proxies", trusted: []string{"0.0.0.0", "10.0.0.0/8"}, header: "198.51.100.1, 203.0.113.7, 10.1.2.3", want: "203.0.113.7"},
+		{name: "malformed entry", trusted: []string{"0.0.0.0", "10.0.0.0/8"}, header: "203.0.113.7, bogus, 10.1.2.3", want: "10.1.2.3"},
+		{name: "missing header", trusted: []string{"0.0.0.0"}, want: "0.0.0.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{
+				ProxyHeader:             fiber.HeaderXForwardedFor,
+				EnableTrustedProxyCheck: true,
+				TrustedProxies:          tt.trusted,
+			})
+			app.Get("/", ClientInfo(), func(c *fiber.Ctx) error {
+				return c.SendString(c.Locals(utils.ClientIPKey).(string))
+			})
+
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(fiber.HeaderXForwardedFor, tt.header)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if got := string(body); got != tt.want {
+				t.Errorf("client IP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
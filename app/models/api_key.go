@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// APIKey is a long-lived credential a partner or admin uses from backend
+// integrations. Only the hash of the key is stored; Prefix identifies the key
+// in listings and logs.
+type APIKey struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	UserID     uint        `gorm:"not null;index" json:"user_id"`
+	User       User        `gorm:"foreignKey:UserID;references:ID" json:"-"`
+	Name       string      `gorm:"type:varchar(255);not null" json:"name"`
+	Prefix     string      `gorm:"type:varchar(16);not null;index" json:"prefix"`
+	KeyHash    string      `gorm:"type:varchar(64);unique;not null" json:"-"`
+	Scopes     StringArray `gorm:"type:json" json:"scopes"`
+	ExpiresAt  time.Time   `gorm:"not null" json:"expires_at"`
+	LastUsedAt *time.Time  `json:"last_used_at,omitempty"`
+	LastUsedIP string      `gorm:"type:varchar(45)" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time  `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+type AuthEventType string
+
+const (
+	AuthEventLoginPassword  AuthEventType = "login.password"
+	AuthEventLoginOTP       AuthEventType = "login.otp"
+	AuthEventLoginGoogle    AuthEventType = "login.google"
+	AuthEventLoginApple     AuthEventType = "login.apple"
+	AuthEventLoginMFA       AuthEventType = "login.mfa"
+	AuthEventLoginWebAuthn  AuthEventType = "login.webauthn"
+	AuthEventRegister       AuthEventType = "register"
+	AuthEventLogout         AuthEventType = "logout"
+	AuthEventReauthenticate AuthEventType = "reauthenticate"
+	AuthEventTokenRevoke    AuthEventType = "token.revoke"
+
+	AuthEventPasswordChange       AuthEventType = "password.change"
+	AuthEventPasswordResetRequest AuthEventType = "password.reset_request"
+	AuthEventPasswordReset        AuthEventType = "password.reset"
+	AuthEventContactChange        AuthEventType = "contact.change"
+	AuthEventContactVerify        AuthEventType = "contact.verify"
+
+	AuthEventAdminUserCreate    AuthEventType = "admin.user_create"
+	AuthEventAdminUserUpdate    AuthEventType = "admin.user_update"
+	AuthEventAdminUserDelete    AuthEventType = "admin.user_delete"
+	AuthEventAdminStatusChange  AuthEventType = "admin.status_change"
+	AuthEventAdminRoleChange    AuthEventType = "admin.role_change"
+	AuthEventAdminPasswordReset AuthEventType = "admin.password_reset"
+	AuthEventAdminUnlock        AuthEventType = "admin.unlock"
+	AuthEventAdminImpersonate   AuthEventType = "admin.impersonate"
+
+	// AuthEventImpersonatedRequest is recorded for every request made with an
+	// impersonation token.
+	AuthEventImpersonatedRequest AuthEventType = "impersonation.request"
+)
+
+type AuthEventOutcome string
+
+const (
+	AuthEventSuccess AuthEventOutcome = "success"
+	AuthEventFailure AuthEventOutcome = "failure"
+)
+
+// AuthEvent is an entry in the append-only security audit log. ActorID is
+// whoever performed the action and SubjectID the account it was performed
+// on; Identifier is the email or phone given when no account matched.
+// ImpersonatorID is set when the actor was a superadmin impersonating them.
+//
+// Each entry's Hash covers its own fields and the previous entry's hash, so
+// editing or deleting an entry breaks the chain from that point on.
+type AuthEvent struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	Type       AuthEventType    `gorm:"type:varchar(64);not null;index" json:"type"`
+	ActorID    *uint            `gorm:"index" json:"actor_id,omitempty"`
+	SubjectID  *uint            `gorm:"index" json:"subject_id,omitempty"`
+	Identifier string           `gorm:"type:varchar(255)" json:"identifier,omitempty"`
+	IPAddress  string           `gorm:"type:varchar(64);index" json:"ip_address,omitempty"`
+	UserAgent  string           `gorm:"type:varchar(512)" json:"user_agent,omitempty"`
+	Outcome    AuthEventOutcome `gorm:"type:varchar(10);not null;index" json:"outcome"`
+	Reason     string           `gorm:"type:varchar(512)" json:"reason,omitempty"`
+	CreatedAt  time.Time        `gorm:"not null;index" json:"created_at"`
+	PrevHash   string           `gorm:"type:varchar(64);not null" json:"prev_hash"`
+	Hash       string           `gorm:"type:varchar(64);not null;uniqueIndex" json:"hash"`
+
+	ImpersonatorID *uint `gorm:"index" json:"impersonator_id,omitempty"`
+}
+
+func (AuthEvent) TableName() string {
+	return "auth_events"
+}
+
+// ComputeHash returns the SHA-256 of PrevHash and the event's fields.
+// CreatedAt is hashed at millisecond precision, which is what the database
+// keeps. ImpersonatorID is left out when unset so that events recorded
+// before it existed still verify.
+func (e *AuthEvent) ComputeHash() string {
+	payload, _ := json.Marshal(struct {
+		PrevHash       string           `json:"prev_hash"`
+		Type           AuthEventType    `json:"type"`
+		ActorID        *uint            `json:"actor_id"`
+		SubjectID      *uint            `json:"subject_id"`
+		Identifier     string           `json:"identifier"`
+		IPAddress      string           `json:"ip_address"`
+		UserAgent      string           `json:"user_agent"`
+		Outcome        AuthEventOutcome `json:"outcome"`
+		Reason         string           `json:"reason"`
+		CreatedAt      int64            `json:"created_at"`
+		ImpersonatorID *uint            `json:"impersonator_id,omitempty"`
+	}{e.PrevHash, e.Type, e.ActorID, e.SubjectID, e.Identifier, e.IPAddress, e.UserAgent, e.Outcome, e.Reason, e.CreatedAt.UnixMilli(), e.ImpersonatorID})
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Invitation is an emailed, single-use link with which an admin-created user
+// sets their own password. Only the hash of the token is stored.
+type Invitation struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID;references:ID" json:"-"`
+	InvitedBy  uint       `gorm:"not null;index" json:"invited_by"`
+	TokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (Invitation) TableName() string {
+	return "invitations"
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// UserMFA holds a user's TOTP authenticator. The secret is only usable once
+// Enabled is set by confirming a first code. LastUsedStep stops a code from
+// being replayed within its validity window.
+type UserMFA struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"uniqueIndex;not null" json:"user_id"`
+	User           User       `gorm:"foreignKey:UserID;references:ID" json:"-"`
+	TOTPSecret     string     `gorm:"type:varchar(64);not null" json:"-"`
+	Enabled        bool       `gorm:"default:false" json:"enabled"`
+	ConfirmedAt    *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep   int64      `gorm:"default:0" json:"-"`
+	FailedAttempts int        `gorm:"default:0" json:"-"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// MFARecoveryCode is a single-use code that stands in for a TOTP code when
+// the authenticator is lost. Only its hash is stored.
+type MFARecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:varchar(64);unique;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// MFAPolicy marks a user type as required to use MFA.
+type MFAPolicy struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserType  UserType  `gorm:"type:varchar(20);unique;not null" json:"user_type"`
+	Required  bool      `gorm:"default:false" json:"required"`
+	UpdatedBy *uint     `json:"updated_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (MFAPolicy) TableName() string {
+	return "mfa_policies"
+}
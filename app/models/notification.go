@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail NotificationChannel = "email"
+	NotificationChannelSMS   NotificationChannel = "sms"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationStatusPending NotificationStatus = "pending"
+	NotificationStatusSent    NotificationStatus = "sent"
+	NotificationStatusFailed  NotificationStatus = "failed"
+)
+
+type StringMap map[string]string
+
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+func (m *StringMap) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into StringMap", value)
+	}
+
+	return json.Unmarshal(bytes, m)
+}
+
+// NotificationOutbox is a rendered email or SMS waiting to be delivered.
+// Rows are written while handling the request that caused them and picked
+// up by a background worker, so a provider outage delays messages instead
+// of losing them. Bodies and params are cleared once the message is sent,
+// since they may hold one-time codes.
+type NotificationOutbox struct {
+	ID            uint                `gorm:"primaryKey" json:"id"`
+	Channel       NotificationChannel `gorm:"type:varchar(10);not null" json:"channel"`
+	Recipient     string              `gorm:"type:varchar(255);not null" json:"recipient"`
+	Template      string              `gorm:"type:varchar(64);not null" json:"template"`
+	Language      string              `gorm:"type:varchar(16)" json:"language"`
+	Subject       string              `gorm:"type:varchar(255)" json:"subject,omitempty"`
+	HTMLBody      string              `gorm:"type:text" json:"-"`
+	TextBody      string              `gorm:"type:text" json:"-"`
+	Params        StringMap           `gorm:"type:json" json:"-"`
+	Status        NotificationStatus  `gorm:"type:varchar(10);not null;index:idx_notification_due" json:"status"`
+	Attempts      int                 `gorm:"default:0" json:"attempts"`
+	NextAttemptAt time.Time           `gorm:"not null;index:idx_notification_due" json:"next_attempt_at"`
+	LastError     string              `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt        *time.Time          `gorm:"index" json:"sent_at,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+func (NotificationOutbox) TableName() string {
+	return "notification_outbox"
+}
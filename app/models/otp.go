@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+type OTPChannel string
+
+const (
+	OTPChannelEmail OTPChannel = "email"
+	OTPChannelPhone OTPChannel = "phone"
+)
+
+type OTPPurpose string
+
+const (
+	OTPPurposeRegister OTPPurpose = "register"
+	OTPPurposeLogin    OTPPurpose = "login"
+	// OTPPurposeVerify confirms the contact already on an account and
+	// OTPPurposeChange the new contact waiting to replace it. Both are bound
+	// to the user who requested them.
+	OTPPurposeVerify OTPPurpose = "verify"
+	OTPPurposeChange OTPPurpose = "change"
+	// OTPPurposeReauth lets a signed-in user step up their session.
+	OTPPurposeReauth OTPPurpose = "reauth"
+)
+
+type OTPCode struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Identifier  string     `gorm:"type:varchar(255);not null;index:idx_otp_lookup" json:"identifier"`
+	Channel     OTPChannel `gorm:"type:varchar(10);not null;index:idx_otp_lookup" json:"channel"`
+	Purpose     OTPPurpose `gorm:"type:varchar(20);not null" json:"purpose"`
+	UserID      *uint      `gorm:"index" json:"user_id,omitempty"`
+	CodeHash    string     `gorm:"type:varchar(64);not null" json:"-"`
+	Attempts    int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"default:5" json:"max_attempts"`
+	ExpiresAt   time.Time  `gorm:"not null;index" json:"expires_at"`
+	ConsumedAt  *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (OTPCode) TableName() string {
+	return "otp_codes"
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// PasswordResetToken is an emailed, single-use password reset link. Only the
+// hash of the token is stored.
+type PasswordResetToken struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	User        User       `gorm:"foreignKey:UserID;references:ID" json:"-"`
+	TokenHash   string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	RequestedIP string     `gorm:"type:varchar(45)" json:"requested_ip"`
+	ExpiresAt   time.Time  `gorm:"not null;index" json:"expires_at"`
+	ConsumedAt  *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+// PasswordHistory keeps the hashes of a user's recent passwords so they are
+// not reused.
+type PasswordHistory struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	User         User      `gorm:"foreignKey:UserID;references:ID" json:"-"`
+	PasswordHash string    `gorm:"type:varchar(255);not null" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
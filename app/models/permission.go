@@ -0,0 +1,54 @@
+package models
+
+// Permissions are "<resource>:<action>" strings checked by
+// middleware.RequirePermission. "*" grants everything and "<resource>:*"
+// grants every action on a resource.
+
+// DefaultRolePermissions is seeded for roles that have no permissions yet.
+// Edit the roles table to change what a role may do; these defaults mirror
+// the access each user type had before permissions were enforced.
+var DefaultRolePermissions = map[string][]string{
+	"superadmin": {"*"},
+	"admin": {
+		"items:read", "items:write",
+		"item-groups:read", "item-groups:write",
+		"inventory:*",
+		"salespersons:read", "salespersons:write",
+		"taxes:read", "taxes:write",
+		"invoices:*",
+		"payments:*",
+		"purchase-orders:*",
+		"sales-orders:*",
+		"packages:*",
+		"shipments:*",
+		"bills:*",
+		"production-orders:*",
+		"partners:read", "partners:write",
+	},
+	"partner": {
+		"items:read",
+		"item-groups:read",
+		"invoices:read",
+		"salespersons:read",
+		"taxes:read",
+		"payments:read",
+		"sales-orders:read",
+		"purchase-orders:read",
+		"packages:read",
+		"shipments:read",
+		"bills:read",
+		"production-orders:read",
+	},
+	"mobile_user": {
+		"invoices:read",
+		"salespersons:read",
+		"taxes:read",
+		"payments:read",
+		"purchase-orders:read",
+		"sales-orders:read",
+		"packages:read",
+		"shipments:read",
+		"bills:read",
+		"production-orders:read",
+	},
+}
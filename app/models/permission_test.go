@@ -0,0 +1,93 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+// Who could reach a route before permissions were enforced: any signed-in
+// user, admins and superadmins, or superadmins only.
+const (
+	anyUser = iota
+	adminOnly
+	superAdminOnly
+)
+
+// baselineRoutes maps each permission the routes now require onto the user
+// type middleware that guarded the same routes before.
+var baselineRoutes = map[string]int{
+	"manufacturers:write":      superAdminOnly,
+	"manufacturers:delete":     superAdminOnly,
+	"brands:write":             superAdminOnly,
+	"brands:delete":            superAdminOnly,
+	"banks:write":              superAdminOnly,
+	"banks:delete":             superAdminOnly,
+	"vendors:write":            superAdminOnly,
+	"vendors:delete":           superAdminOnly,
+	"customers:write":          superAdminOnly,
+	"customers:delete":         superAdminOnly,
+	"partners:read":            adminOnly,
+	"partners:write":           adminOnly,
+	"companies:delete":         superAdminOnly,
+	"items:write":              adminOnly,
+	"items:delete":             superAdminOnly,
+	"inventory:read":           adminOnly,
+	"inventory:write":          adminOnly,
+	"item-groups:write":        adminOnly,
+	"item-groups:delete":       superAdminOnly,
+	"invoices:read":            anyUser,
+	"invoices:write":           adminOnly,
+	"invoices:delete":          adminOnly,
+	"salespersons:read":        anyUser,
+	"salespersons:write":       adminOnly,
+	"salespersons:delete":      superAdminOnly,
+	"taxes:read":               anyUser,
+	"taxes:write":              adminOnly,
+	"taxes:delete":             superAdminOnly,
+	"payments:read":            anyUser,
+	"payments:write":           adminOnly,
+	"payments:delete":          adminOnly,
+	"purchase-orders:read":     anyUser,
+	"purchase-orders:write":    adminOnly,
+	"purchase-orders:delete":   adminOnly,
+	"sales-orders:read":        anyUser,
+	"sales-orders:write":       adminOnly,
+	"sales-orders:delete":      adminOnly,
+	"packages:read":            anyUser,
+	"packages:write":           adminOnly,
+	"packages:delete":          adminOnly,
+	"shipments:read":           anyUser,
+	"shipments:write":          adminOnly,
+	"shipments:delete":         adminOnly,
+	"bills:read":               anyUser,
+	"bills:write":              adminOnly,
+	"bills:delete":             adminOnly,
+	"production-orders:read":   anyUser,
+	"production-orders:write":  adminOnly,
+	"production-orders:delete": adminOnly,
+}
+
+func TestDefaultRolePermissionsMatchBaselineRoutes(t *testing.T) {
+	rank := map[string]int{
+		"mobile_user": anyUser,
+		"partner":     anyUser,
+		"admin":       adminOnly,
+		"superadmin":  superAdminOnly,
+	}
+
+	for role, level := range rank {
+		granted, ok := models.DefaultRolePermissions[role]
+		if !ok {
+			t.Fatalf("no default permissions for %s", role)
+		}
+
+		for permission, required := range baselineRoutes {
+			want := level >= required
+			if got := utils.PermissionAllows(granted, permission); got != want {
+				t.Errorf("%s %s = %v, baseline allowed %v", role, permission, got, want)
+			}
+		}
+	}
+}
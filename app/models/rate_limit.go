@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RateLimitCounter counts the attempts made under Key during the fixed window
+// starting at WindowStart. The limiter weighs the previous window against
+// the current one to approximate a sliding window.
+type RateLimitCounter struct {
+	Key         string    `gorm:"primaryKey;type:varchar(191)" json:"key"`
+	WindowStart time.Time `gorm:"primaryKey" json:"window_start"`
+	Count       int       `gorm:"not null;default:0" json:"count"`
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+func (RateLimitCounter) TableName() string {
+	return "rate_limit_counters"
+}
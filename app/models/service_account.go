@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ServiceAccount is a non-human principal that authenticates with the
+// client_credentials grant.
+type ServiceAccount struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	ClientID         string         `gorm:"type:varchar(64);unique;not null" json:"client_id"`
+	ClientSecretHash string         `gorm:"type:varchar(64);not null" json:"-"`
+	Name             string         `gorm:"type:varchar(255);not null" json:"name"`
+	Description      string         `gorm:"type:text" json:"description"`
+	AllowedScopes    StringArray    `gorm:"type:json" json:"allowed_scopes"`
+	IsActive         bool           `gorm:"default:true" json:"is_active"`
+	LastUsedAt       *time.Time     `json:"last_used_at,omitempty"`
+	CreatedBy        *uint          `gorm:"index" json:"created_by,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (a *ServiceAccount) AllowsScope(scope string) bool {
+	for _, allowed := range a.AllowedScopes {
+		if allowed == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (ServiceAccount) TableName() string {
+	return "service_accounts"
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// TokenRevocation invalidates access tokens before they expire. A row with a
+// TokenID revokes that single token (the JWT jti) and a row with a SessionID
+// every token of that session (the "sid" claim). A row with a
+// ServiceAccountID revokes every token that account was issued before
+// RevokedAt; a row with none of these does the same for UserID. A row with a
+// RoleName revokes no token; it tells every replica to drop what it cached
+// about that role's permissions. ExpiresAt is when the last affected token
+// expires, after which the row can be purged.
+type TokenRevocation struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	TokenID          string    `gorm:"type:varchar(36);index" json:"token_id,omitempty"`
+	SessionID        string    `gorm:"type:varchar(36);index" json:"session_id,omitempty"`
+	RoleName         string    `gorm:"type:varchar(50)" json:"role_name,omitempty"`
+	ServiceAccountID uint      `gorm:"index" json:"service_account_id,omitempty"`
+	UserID           uint      `gorm:"index" json:"user_id"`
+	Reason           string    `gorm:"type:varchar(50)" json:"reason"`
+	RevokedAt        time.Time `gorm:"not null" json:"revoked_at"`
+	ExpiresAt        time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt        time.Time `gorm:"index" json:"created_at"`
+}
+
+func (TokenRevocation) TableName() string {
+	return "token_revocations"
+}
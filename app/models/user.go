@@ -16,6 +16,10 @@ const (
 	UserTypeSuperAdmin UserType = "superadmin"
 	UserTypeAdmin      UserType = "admin"
 	UserTypePartner    UserType = "partner"
+
+	// UserTypeService is only used in tokens issued to service accounts;
+	// it never appears on a User row.
+	UserTypeService UserType = "service"
 )
 
 type UserStatus string
@@ -75,6 +79,84 @@ type User struct {
 	CreatedByUser     *User          `gorm:"foreignKey:CreatedBy;references:ID" json:"created_by_user,omitempty"`
 	LastLoginAt       *time.Time     `json:"last_login_at,omitempty"`
 	PasswordChangedAt *time.Time     `json:"password_changed_at,omitempty"`
+
+	// PendingEmail and PendingPhone hold a requested contact change until
+	// it is confirmed by OTP; Email and Phone keep the old values meanwhile.
+	PendingEmail *string `json:"pending_email,omitempty"`
+	PendingPhone *string `json:"pending_phone,omitempty"`
+
+	// FailedLoginAttempts counts wrong passwords since the last successful
+	// login or lockout. LockoutCount is how many times the account has been
+	// locked in a row, which makes each lockout longer than the last.
+	FailedLoginAttempts int        `gorm:"default:0" json:"-"`
+	LockoutCount        int        `gorm:"default:0" json:"-"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"`
+
+	// MergedIntoID points at the account this one was merged into. Merged
+	// accounts are inactive and have no sign-in methods left.
+	MergedIntoID *uint `gorm:"index" json:"merged_into_id,omitempty"`
+}
+
+// IsLocked reports whether password logins are refused until LockedUntil.
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && u.LockedUntil.After(now)
+}
+
+// AwaitsInvitation reports whether the account was created by an admin and
+// is pending until its invitation is accepted. Only a self-registered mobile
+// user may become active by proving control of a contact.
+func (u *User) AwaitsInvitation() bool {
+	return u.Status == UserStatusPending && (u.UserType != UserTypeMobile || u.CreatedBy != nil)
+}
+
+// IdentityProvider is a way of signing in to an account.
+type IdentityProvider string
+
+const (
+	IdentityProviderEmail  IdentityProvider = "email"
+	IdentityProviderPhone  IdentityProvider = "phone"
+	IdentityProviderGoogle IdentityProvider = "google"
+	IdentityProviderApple  IdentityProvider = "apple"
+)
+
+// IdentityProviders lists every provider in the order they are shown.
+var IdentityProviders = []IdentityProvider{
+	IdentityProviderEmail,
+	IdentityProviderPhone,
+	IdentityProviderGoogle,
+	IdentityProviderApple,
+}
+
+// Identity returns the user's identifier with provider and whether it has
+// been verified. The identifier is empty when provider is not linked.
+func (u *User) Identity(provider IdentityProvider) (string, bool) {
+	var value *string
+	verified := true
+	switch provider {
+	case IdentityProviderEmail:
+		value, verified = u.Email, u.EmailVerified
+	case IdentityProviderPhone:
+		value, verified = u.Phone, u.PhoneVerified
+	case IdentityProviderGoogle:
+		value = u.GoogleID
+	case IdentityProviderApple:
+		value = u.AppleID
+	}
+	if value == nil || *value == "" {
+		return "", false
+	}
+	return *value, verified
+}
+
+// LinkedProviders returns the providers the user can currently sign in with.
+func (u *User) LinkedProviders() []IdentityProvider {
+	var linked []IdentityProvider
+	for _, provider := range IdentityProviders {
+		if value, _ := u.Identity(provider); value != "" {
+			linked = append(linked, provider)
+		}
+	}
+	return linked
 }
 
 type Role struct {
@@ -89,26 +171,38 @@ type Role struct {
 }
 
 type RefreshToken struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	TokenID   string    `gorm:"unique;not null;index" json:"token_id"`
-	UserID    uint      `gorm:"not null;index" json:"user_id"`
-	User      User      `gorm:"foreignKey:UserID;references:ID" json:"user"`
-	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	IsRevoked bool      `gorm:"default:false" json:"is_revoked"`
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	TokenID    string     `gorm:"unique;not null;index" json:"token_id"`
+	FamilyID   string     `gorm:"type:varchar(36);index" json:"family_id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID;references:ID" json:"user"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	IsRevoked  bool       `gorm:"default:false" json:"is_revoked"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	AuthTime   *time.Time `json:"auth_time,omitempty"`
+	AMR        string     `gorm:"column:amr;type:varchar(64)" json:"amr,omitempty"`
+	ClientID   string     `gorm:"type:varchar(64)" json:"client_id,omitempty"`
+	Scope      string     `gorm:"type:varchar(500)" json:"scope,omitempty"`
+	ReplacedBy *string    `gorm:"type:varchar(36)" json:"replaced_by,omitempty"`
 }
 
+// UserSession is one signed-in device. It lives as long as the refresh token
+// family identified by FamilyID and is carried in access tokens as "sid".
 type UserSession struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null;index" json:"user_id"`
-	User      User      `gorm:"foreignKey:UserID;references:ID" json:"user"`
-	SessionID string    `gorm:"unique;not null;index" json:"session_id"`
-	IPAddress string    `json:"ip_address"`
-	UserAgent string    `json:"user_agent"`
-	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID;references:ID" json:"user"`
+	SessionID  string     `gorm:"unique;not null;index" json:"session_id"`
+	FamilyID   string     `gorm:"type:varchar(36);index" json:"family_id"`
+	ClientID   string     `gorm:"type:varchar(64)" json:"client_id,omitempty"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
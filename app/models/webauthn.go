@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// WebAuthnCredential is a passkey registered by a user. CredentialID is the
+// base64url credential ID and PublicKey the COSE_Key the authenticator
+// returned at registration.
+type WebAuthnCredential struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	User           User       `gorm:"foreignKey:UserID;references:ID" json:"-"`
+	CredentialID   string     `gorm:"type:varchar(255);uniqueIndex;not null" json:"credential_id"`
+	PublicKey      []byte     `gorm:"type:blob;not null" json:"-"`
+	Algorithm      int64      `gorm:"not null" json:"algorithm"`
+	SignCount      uint32     `gorm:"default:0" json:"sign_count"`
+	AAGUID         string     `gorm:"type:varchar(36)" json:"aaguid"`
+	Transports     string     `gorm:"type:varchar(255)" json:"transports"`
+	Name           string     `gorm:"type:varchar(100)" json:"name"`
+	BackupEligible bool       `gorm:"default:false" json:"backup_eligible"`
+	BackupState    bool       `gorm:"default:false" json:"backup_state"`
+	CloneDetected  bool       `gorm:"default:false" json:"clone_detected"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// WebAuthnChallenge is an outstanding registration or login ceremony. Each
+// challenge can be consumed once.
+type WebAuthnChallenge struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	ChallengeHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Ceremony      string     `gorm:"type:varchar(20);not null" json:"ceremony"`
+	UserID        *uint      `gorm:"index" json:"user_id,omitempty"`
+	ExpiresAt     time.Time  `gorm:"not null;index" json:"expires_at"`
+	ConsumedAt    *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (WebAuthnChallenge) TableName() string {
+	return "webauthn_challenges"
+}
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+)
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *apiKeyRepository) GetByID(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.First(&key, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) GetByKeyHash(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.Preload("User").Preload("User.Role").Where("key_hash = ?", keyHash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) ListByUserID(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepository) Revoke(id uint) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Rotate revokes old and stores next in one transaction so a key is never
+// left without a replacement.
+func (r *apiKeyRepository) Rotate(oldID uint, next *models.APIKey) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.APIKey{}).
+			Where("id = ?", oldID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(next).Error
+	})
+}
+
+func (r *apiKeyRepository) UpdateLastUsed(id uint, ip string) error {
+	return r.db.Model(&models.APIKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_used_at": time.Now(),
+		"last_used_ip": ip,
+	}).Error
+}
@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AuthEventFilter narrows audit events down; zero fields match everything.
+type AuthEventFilter struct {
+	Type      models.AuthEventType
+	ActorID   uint
+	SubjectID uint
+	Outcome   models.AuthEventOutcome
+	IPAddress string
+	From      *time.Time
+	To        *time.Time
+}
+
+type authEventRepository struct {
+	db *gorm.DB
+}
+
+func NewAuthEventRepository(db *gorm.DB) AuthEventRepository {
+	return &authEventRepository{db: db}
+}
+
+// Append locks the newest event while chaining onto it, so concurrent
+// appends, from this or another instance, line up one after the other.
+func (r *authEventRepository) Append(event *models.AuthEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var last models.AuthEvent
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "hash").
+			Order("id DESC").
+			Take(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		event.PrevHash = last.Hash
+		event.Hash = event.ComputeHash()
+		return tx.Create(event).Error
+	})
+}
+
+func (r *authEventRepository) List(filter AuthEventFilter, offset, limit int) ([]models.AuthEvent, int64, error) {
+	var events []models.AuthEvent
+	var total int64
+
+	query := r.filtered(filter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&events).Error
+	return events, total, err
+}
+
+// Each calls fn with the matching events in batches, oldest first.
+func (r *authEventRepository) Each(filter AuthEventFilter, batchSize int, fn func([]models.AuthEvent) error) error {
+	var batch []models.AuthEvent
+	return r.filtered(filter).FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+func (r *authEventRepository) filtered(filter AuthEventFilter) *gorm.DB {
+	query := r.db.Model(&models.AuthEvent{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.SubjectID != 0 {
+		query = query.Where("subject_id = ?", filter.SubjectID)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.IPAddress != "" {
+		query = query.Where("ip_address = ?", filter.IPAddress)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	return query
+}
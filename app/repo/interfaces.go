@@ -16,10 +16,20 @@ type UserRepository interface {
 	GetByAppleID(appleID string) (*models.User, error)
 	GetByUsername(username string) (*models.User, error)
 	Update(user *models.User) error
+	// Merge saves source and then target in one transaction, so identifiers
+	// moved from source to target are released before they are claimed.
+	Merge(source, target *models.User) error
 	Delete(id uint) error
 	List(offset, limit int, search string) ([]models.User, int64, error)
 	UpdateLastLogin(id uint) error
 	UpdatePasswordChangedAt(id uint) error
+	// RecordLoginFailure counts a wrong password. Once threshold is reached
+	// the account is locked for lockout doubled per previous consecutive
+	// lockout, capped at maxLockout, and the count starts over. It returns
+	// the account's lock expiry, if any.
+	RecordLoginFailure(id uint, threshold int, lockout, maxLockout time.Duration) (*time.Time, error)
+	// ClearLoginFailures resets the failure count and lifts any lockout.
+	ClearLoginFailures(id uint) error
 	GetDashboardStats(customerType *string, fromDate, toDate *time.Time) (map[string]interface{}, error)
 }
 
@@ -27,6 +37,9 @@ type RoleRepository interface {
 	GetByID(id uint) (*models.Role, error)
 	GetByName(name string) (*models.Role, error)
 	GetAll() ([]models.Role, error)
+	List() ([]models.Role, error)
+	CountUsers(roleID uint) (int64, error)
+	ListUsers(roleID uint, offset, limit int) ([]models.User, int64, error)
 	Create(role *models.Role) error
 	Update(role *models.Role) error
 	Delete(id uint) error
@@ -35,7 +48,11 @@ type RoleRepository interface {
 type RefreshTokenRepository interface {
 	Create(token *models.RefreshToken) error
 	GetByTokenID(tokenID string) (*models.RefreshToken, error)
+	FindByTokenID(tokenID string) (*models.RefreshToken, error)
 	GetByUserID(userID uint) ([]models.RefreshToken, error)
+	Rotate(tokenID string, next *models.RefreshToken) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeByUserID(userID uint) error
 	Delete(tokenID string) error
 	DeleteByUserID(userID uint) error
 	DeleteExpired() error
@@ -43,13 +60,153 @@ type RefreshTokenRepository interface {
 
 type UserSessionRepository interface {
 	Create(session *models.UserSession) error
+	Update(session *models.UserSession) error
 	GetBySessionID(sessionID string) (*models.UserSession, error)
+	GetByFamilyID(familyID string) (*models.UserSession, error)
 	GetByUserID(userID uint) ([]models.UserSession, error)
 	Delete(sessionID string) error
 	DeleteByUserID(userID uint) error
 	DeleteExpired() error
 }
 
+type OTPRepository interface {
+	Create(otp *models.OTPCode) error
+	GetActive(identifier string, channel models.OTPChannel) (*models.OTPCode, error)
+	// ClaimAttempt counts one verification attempt against an unconsumed
+	// OTP. It reports false when the OTP has no attempts left or was
+	// already consumed.
+	ClaimAttempt(id uint) (bool, error)
+	Consume(id uint) (bool, error)
+	DeleteExpired() error
+}
+
+type OAuthClientRepository interface {
+	Create(client *models.OAuthClient) error
+	GetByID(id uint) (*models.OAuthClient, error)
+	GetByClientID(clientID string) (*models.OAuthClient, error)
+	List() ([]models.OAuthClient, error)
+	Update(client *models.OAuthClient) error
+	Delete(id uint) error
+}
+
+type OAuthCodeRepository interface {
+	Create(code *models.OAuthAuthorizationCode) error
+	GetByCodeHash(codeHash string) (*models.OAuthAuthorizationCode, error)
+	Consume(id uint) (bool, error)
+	DeleteExpired() error
+}
+
+type ServiceAccountRepository interface {
+	Create(account *models.ServiceAccount) error
+	GetByID(id uint) (*models.ServiceAccount, error)
+	GetByClientID(clientID string) (*models.ServiceAccount, error)
+	List() ([]models.ServiceAccount, error)
+	Update(account *models.ServiceAccount) error
+	UpdateLastUsed(id uint) error
+	Delete(id uint) error
+}
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	GetByID(id uint) (*models.APIKey, error)
+	GetByKeyHash(keyHash string) (*models.APIKey, error)
+	ListByUserID(userID uint) ([]models.APIKey, error)
+	Revoke(id uint) error
+	Rotate(oldID uint, next *models.APIKey) error
+	UpdateLastUsed(id uint, ip string) error
+}
+
+type PasswordResetRepository interface {
+	Create(token *models.PasswordResetToken) error
+	GetActiveByHash(tokenHash string) (*models.PasswordResetToken, error)
+	Consume(id uint) (bool, error)
+	DeleteExpired() error
+}
+
+type PasswordHistoryRepository interface {
+	GetRecent(userID uint, limit int) ([]models.PasswordHistory, error)
+	Add(userID uint, passwordHash string, keep int) error
+}
+
+type InvitationRepository interface {
+	Create(invitation *models.Invitation) error
+	GetByID(id uint) (*models.Invitation, error)
+	GetActiveByHash(tokenHash string) (*models.Invitation, error)
+	ListOutstanding() ([]models.Invitation, error)
+	Accept(id uint) (bool, error)
+	Revoke(id uint) (bool, error)
+	ListExpiredUsers(now time.Time) ([]uint, error)
+}
+
+// AuthEventRepository is append-only: events are never updated or deleted.
+type AuthEventRepository interface {
+	// Append chains event onto the newest event and stores it.
+	Append(event *models.AuthEvent) error
+	// List returns matching events, newest first, and their total count.
+	List(filter AuthEventFilter, offset, limit int) ([]models.AuthEvent, int64, error)
+	Each(filter AuthEventFilter, batchSize int, fn func([]models.AuthEvent) error) error
+}
+
+type NotificationRepository interface {
+	Create(notification *models.NotificationOutbox) error
+	// ListDue returns pending notifications whose next attempt is due,
+	// oldest first.
+	ListDue(now time.Time, limit int) ([]models.NotificationOutbox, error)
+	// Claim reserves a notification for one delivery attempt. It reports
+	// false when another worker got there first.
+	Claim(id uint, attempts int, leaseUntil time.Time) (bool, error)
+	MarkSent(id uint, sentAt time.Time) error
+	MarkRetry(id uint, nextAttemptAt time.Time, lastError string) error
+	MarkFailed(id uint, lastError string) error
+	DeleteSentBefore(cutoff time.Time) error
+}
+
+type RateLimitRepository interface {
+	// Hit counts an attempt under key in the window starting at windowStart
+	// and returns the counts of the previous and current windows.
+	Hit(key string, windowStart time.Time, window time.Duration) (int, int, error)
+	DeleteExpired(now time.Time) error
+}
+
+type MFARepository interface {
+	GetByUserID(userID uint) (*models.UserMFA, error)
+	Save(mfa *models.UserMFA) error
+	DeleteByUserID(userID uint) error
+	RecordStep(id uint, step int64) (bool, error)
+	// ClaimAttempt counts an attempt against the factor before the code is
+	// compared. It reports false while the factor is locked.
+	ClaimAttempt(id uint, maxAttempts int, lockedUntil, now time.Time) (bool, error)
+	ResetFailures(id uint) error
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	ConsumeRecoveryCode(userID uint, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(userID uint) (int64, error)
+	GetPolicies() ([]models.MFAPolicy, error)
+	SetPolicy(policy *models.MFAPolicy) error
+}
+
+type WebAuthnRepository interface {
+	CreateCredential(credential *models.WebAuthnCredential) error
+	GetCredentialByCredentialID(credentialID string) (*models.WebAuthnCredential, error)
+	GetCredentialsByUserID(userID uint) ([]models.WebAuthnCredential, error)
+	RecordAssertion(id uint, previousCount, signCount uint32, backupState bool) (bool, error)
+	MarkCloneDetected(id uint) error
+	DeleteCredential(userID, id uint) (bool, error)
+	CreateChallenge(challenge *models.WebAuthnChallenge) error
+	ConsumeChallenge(challengeHash, ceremony string) (*models.WebAuthnChallenge, error)
+	DeleteExpiredChallenges() error
+}
+
+// TokenRevocationRepository is the backend replicas share revocations
+// through. ListActiveAfter returns unexpired revocations with an ID greater
+// than afterID, oldest first.
+type TokenRevocationRepository interface {
+	Create(revocation *models.TokenRevocation) error
+	// ListActiveSince returns the unexpired revocations created after since,
+	// or every unexpired revocation when since is zero.
+	ListActiveSince(since, now time.Time) ([]models.TokenRevocation, error)
+	DeleteExpired(now time.Time) error
+}
+
 type SupportRepository interface {
 	Create(support *models.Support) error
 	GetByID(id uint) (*models.Support, error)
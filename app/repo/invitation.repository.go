@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+)
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+// Create stores a new invitation and revokes the user's outstanding ones, so
+// only the most recent email works.
+func (r *invitationRepository) Create(invitation *models.Invitation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Invitation{}).
+			Where("user_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.UserID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(invitation).Error
+	})
+}
+
+func (r *invitationRepository) GetByID(id uint) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.Preload("User.Role").First(&invitation, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) GetActiveByHash(tokenHash string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.Preload("User.Role").
+		Where("token_hash = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ListOutstanding returns invitations that were neither accepted nor revoked,
+// including expired ones, newest first.
+func (r *invitationRepository) ListOutstanding() ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := r.db.Preload("User.Role").
+		Where("accepted_at IS NULL AND revoked_at IS NULL").
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *invitationRepository) Accept(id uint) (bool, error) {
+	result := r.db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).
+		Update("accepted_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *invitationRepository) Revoke(id uint) (bool, error) {
+	result := r.db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ListExpiredUsers returns the IDs of still-pending users whose latest
+// invitation expired without being accepted.
+func (r *invitationRepository) ListExpiredUsers(now time.Time) ([]uint, error) {
+	var userIDs []uint
+	err := r.db.Model(&models.Invitation{}).
+		Joins("JOIN users ON users.id = invitations.user_id").
+		Where("invitations.accepted_at IS NULL AND invitations.revoked_at IS NULL AND invitations.expires_at <= ?", now).
+		Where("users.status = ? AND users.deleted_at IS NULL", models.UserStatusPending).
+		Distinct().
+		Pluck("invitations.user_id", &userIDs).Error
+	return userIDs, err
+}
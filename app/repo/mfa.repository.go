@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) GetByUserID(userID uint) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	err := r.db.Where("user_id = ?", userID).First(&mfa).Error
+	if err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+func (r *mfaRepository) Save(mfa *models.UserMFA) error {
+	return r.db.Save(mfa).Error
+}
+
+func (r *mfaRepository) DeleteByUserID(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.UserMFA{}).Error
+	})
+}
+
+// RecordStep stores step as the last accepted TOTP step and clears failed
+// attempts. It reports false when step is not newer than the stored one,
+// which means the code was already used.
+func (r *mfaRepository) RecordStep(id uint, step int64) (bool, error) {
+	result := r.db.Model(&models.UserMFA{}).
+		Where("id = ? AND last_used_step < ?", id, step).
+		Updates(map[string]interface{}{
+			"last_used_step":  step,
+			"failed_attempts": 0,
+			"locked_until":    nil,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]models.MFARecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, models.MFARecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// ConsumeRecoveryCode marks a recovery code used and reports whether an
+// unused code matched.
+func (r *mfaRepository) ConsumeRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *mfaRepository) CountUnusedRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *mfaRepository) GetPolicies() ([]models.MFAPolicy, error) {
+	var policies []models.MFAPolicy
+	err := r.db.Order("user_type").Find(&policies).Error
+	return policies, err
+}
+
+func (r *mfaRepository) SetPolicy(policy *models.MFAPolicy) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"required", "updated_by", "updated_at"}),
+	}).Create(policy).Error
+}
+
+// ClaimAttempt counts a verification attempt before the code is compared,
+// and reports false while the factor is locked at now. The attempt that
+// reaches maxAttempts locks the factor until lockedUntil and starts the
+// count over; a successful verification resets it. MySQL applies
+// assignments left to right, so locked_until is set first while
+// failed_attempts still holds the previous count.
+func (r *mfaRepository) ClaimAttempt(id uint, maxAttempts int, lockedUntil, now time.Time) (bool, error) {
+	result := r.db.Exec(
+		"UPDATE user_mfa SET "+
+			"locked_until = CASE WHEN failed_attempts + 1 >= ? THEN ? ELSE locked_until END, "+
+			"failed_attempts = CASE WHEN failed_attempts + 1 >= ? THEN 0 ELSE failed_attempts + 1 END "+
+			"WHERE id = ? AND failed_attempts < ? AND (locked_until IS NULL OR locked_until <= ?)",
+		maxAttempts, lockedUntil, maxAttempts, id, maxAttempts, now,
+	)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *mfaRepository) ResetFailures(id uint) error {
+	return r.db.Model(&models.UserMFA{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"failed_attempts": 0,
+		"locked_until":    nil,
+	}).Error
+}
@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+)
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) Create(notification *models.NotificationOutbox) error {
+	return r.db.Create(notification).Error
+}
+
+func (r *notificationRepository) ListDue(now time.Time, limit int) ([]models.NotificationOutbox, error) {
+	var notifications []models.NotificationOutbox
+	err := r.db.
+		Where("status = ? AND next_attempt_at <= ?", models.NotificationStatusPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&notifications).Error
+	return notifications, err
+}
+
+// Claim counts a delivery attempt and pushes the next one back to
+// leaseUntil. The attempt count doubles as a version, so only one worker
+// claims a given attempt even when several read the same row.
+func (r *notificationRepository) Claim(id uint, attempts int, leaseUntil time.Time) (bool, error) {
+	result := r.db.Model(&models.NotificationOutbox{}).
+		Where("id = ? AND status = ? AND attempts = ?", id, models.NotificationStatusPending, attempts).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": leaseUntil,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *notificationRepository) MarkSent(id uint, sentAt time.Time) error {
+	return r.db.Model(&models.NotificationOutbox{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     models.NotificationStatusSent,
+			"sent_at":    sentAt,
+			"last_error": "",
+			"html_body":  "",
+			"text_body":  "",
+			"params":     nil,
+		}).Error
+}
+
+func (r *notificationRepository) MarkRetry(id uint, nextAttemptAt time.Time, lastError string) error {
+	return r.db.Model(&models.NotificationOutbox{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"next_attempt_at": nextAttemptAt,
+			"last_error":      lastError,
+		}).Error
+}
+
+func (r *notificationRepository) MarkFailed(id uint, lastError string) error {
+	return r.db.Model(&models.NotificationOutbox{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     models.NotificationStatusFailed,
+			"last_error": lastError,
+		}).Error
+}
+
+func (r *notificationRepository) DeleteSentBefore(cutoff time.Time) error {
+	return r.db.
+		Where("status = ? AND sent_at < ?", models.NotificationStatusSent, cutoff).
+		Delete(&models.NotificationOutbox{}).Error
+}
@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+)
+
+type oauthClientRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthClientRepository(db *gorm.DB) OAuthClientRepository {
+	return &oauthClientRepository{db: db}
+}
+
+func (r *oauthClientRepository) Create(client *models.OAuthClient) error {
+	return r.db.Create(client).Error
+}
+
+func (r *oauthClientRepository) GetByID(id uint) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.db.First(&client, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthClientRepository) GetByClientID(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.db.Where("client_id = ?", clientID).First(&client).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthClientRepository) List() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.db.Order("created_at DESC").Find(&clients).Error
+	return clients, err
+}
+
+func (r *oauthClientRepository) Update(client *models.OAuthClient) error {
+	return r.db.Save(client).Error
+}
+
+func (r *oauthClientRepository) Delete(id uint) error {
+	return r.db.Delete(&models.OAuthClient{}, id).Error
+}
+
+type oauthCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthCodeRepository(db *gorm.DB) OAuthCodeRepository {
+	return &oauthCodeRepository{db: db}
+}
+
+func (r *oauthCodeRepository) Create(code *models.OAuthAuthorizationCode) error {
+	return r.db.Create(code).Error
+}
+
+func (r *oauthCodeRepository) GetByCodeHash(codeHash string) (*models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+	err := r.db.Where("code_hash = ?", codeHash).First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *oauthCodeRepository) Consume(id uint) (bool, error) {
+	result := r.db.Model(&models.OAuthAuthorizationCode{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *oauthCodeRepository) DeleteExpired() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&models.OAuthAuthorizationCode{}).Error
+}
@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+)
+
+type otpRepository struct {
+	db *gorm.DB
+}
+
+func NewOTPRepository(db *gorm.DB) OTPRepository {
+	return &otpRepository{db: db}
+}
+
+func (r *otpRepository) Create(otp *models.OTPCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.OTPCode{}).
+			Where("identifier = ? AND channel = ? AND consumed_at IS NULL", otp.Identifier, otp.Channel).
+			Update("consumed_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(otp).Error
+	})
+}
+
+func (r *otpRepository) GetActive(identifier string, channel models.OTPChannel) (*models.OTPCode, error) {
+	var otp models.OTPCode
+	err := r.db.
+		Where("identifier = ? AND channel = ? AND consumed_at IS NULL AND expires_at > ?", identifier, channel, time.Now()).
+		Order("created_at DESC").
+		First(&otp).Error
+	if err != nil {
+		return nil, err
+	}
+	return &otp, nil
+}
+
+func (r *otpRepository) ClaimAttempt(id uint) (bool, error) {
+	result := r.db.Model(&models.OTPCode{}).
+		Where("id = ? AND attempts < max_attempts AND consumed_at IS NULL", id).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *otpRepository) Consume(id uint) (bool, error) {
+	result := r.db.Model(&models.OTPCode{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *otpRepository) DeleteExpired() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&models.OTPCode{}).Error
+}
@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+)
+
+type passwordHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) PasswordHistoryRepository {
+	return &passwordHistoryRepository{db: db}
+}
+
+func (r *passwordHistoryRepository) GetRecent(userID uint, limit int) ([]models.PasswordHistory, error) {
+	var history []models.PasswordHistory
+	err := r.db.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&history).Error
+	return history, err
+}
+
+// Add records a password and drops all but the newest keep entries.
+func (r *passwordHistoryRepository) Add(userID uint, passwordHash string, keep int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.PasswordHistory{UserID: userID, PasswordHash: passwordHash}).Error; err != nil {
+			return err
+		}
+
+		var kept []uint
+		if err := tx.Model(&models.PasswordHistory{}).
+			Where("user_id = ?", userID).
+			Order("created_at DESC, id DESC").
+			Limit(keep).
+			Pluck("id", &kept).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ? AND id NOT IN ?", userID, kept).Delete(&models.PasswordHistory{}).Error
+	})
+}
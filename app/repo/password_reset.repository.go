@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+)
+
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+// Create stores a new reset token and invalidates the user's outstanding
+// ones, so only the most recent email works.
+func (r *passwordResetRepository) Create(token *models.PasswordResetToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND consumed_at IS NULL", token.UserID).
+			Update("consumed_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+func (r *passwordResetRepository) GetActiveByHash(tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.
+		Where("token_hash = ? AND consumed_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *passwordResetRepository) Consume(id uint) (bool, error) {
+	result := r.db.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND consumed_at IS NULL", id).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *passwordResetRepository) DeleteExpired() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&models.PasswordResetToken{}).Error
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type rateLimitRepository struct {
+	db *gorm.DB
+}
+
+func NewRateLimitRepository(db *gorm.DB) RateLimitRepository {
+	return &rateLimitRepository{db: db}
+}
+
+func (r *rateLimitRepository) Hit(key string, windowStart time.Time, window time.Duration) (int, int, error) {
+	counter := &models.RateLimitCounter{
+		Key:         key,
+		WindowStart: windowStart,
+		Count:       1,
+		ExpiresAt:   windowStart.Add(2 * window),
+	}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}, {Name: "window_start"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("count + 1")}),
+	}).Create(counter).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var counters []models.RateLimitCounter
+	err = r.db.Where("`key` = ? AND window_start IN ?", key, []time.Time{windowStart.Add(-window), windowStart}).
+		Find(&counters).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var previous, current int
+	for _, c := range counters {
+		if c.WindowStart.Equal(windowStart) {
+			current = c.Count
+		} else {
+			previous = c.Count
+		}
+	}
+	return previous, current, nil
+}
+
+func (r *rateLimitRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&models.RateLimitCounter{}).Error
+}
+
+// memoryRateLimitRepository keeps counters in process. Each replica then
+// enforces its own limits, so it suits a single replica only.
+type memoryRateLimitRepository struct {
+	mu       sync.Mutex
+	counters map[string]map[time.Time]*models.RateLimitCounter
+}
+
+func NewMemoryRateLimitRepository() RateLimitRepository {
+	return &memoryRateLimitRepository{
+		counters: make(map[string]map[time.Time]*models.RateLimitCounter),
+	}
+}
+
+func (r *memoryRateLimitRepository) Hit(key string, windowStart time.Time, window time.Duration) (int, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	windows, ok := r.counters[key]
+	if !ok {
+		windows = make(map[time.Time]*models.RateLimitCounter)
+		r.counters[key] = windows
+	}
+
+	counter, ok := windows[windowStart]
+	if !ok {
+		counter = &models.RateLimitCounter{
+			Key:         key,
+			WindowStart: windowStart,
+			ExpiresAt:   windowStart.Add(2 * window),
+		}
+		windows[windowStart] = counter
+	}
+	counter.Count++
+
+	var previous int
+	if counter, ok := windows[windowStart.Add(-window)]; ok {
+		previous = counter.Count
+	}
+	return previous, counter.Count, nil
+}
+
+func (r *memoryRateLimitRepository) DeleteExpired(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, windows := range r.counters {
+		for start, counter := range windows {
+			if !counter.ExpiresAt.After(now) {
+				delete(windows, start)
+			}
+		}
+		if len(windows) == 0 {
+			delete(r.counters, key)
+		}
+	}
+	return nil
+}
@@ -38,6 +38,38 @@ func (r *roleRepository) GetAll() ([]models.Role, error) {
 	return roles, err
 }
 
+// List returns every role, including inactive ones.
+func (r *roleRepository) List() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Order("role_name").Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) CountUsers(roleID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("role_id = ?", roleID).Count(&count).Error
+	return count, err
+}
+
+func (r *roleRepository) ListUsers(roleID uint, offset, limit int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	query := r.db.Model(&models.User{}).Where("role_id = ?", roleID)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Role").
+		Order("id").
+		Offset(offset).
+		Limit(limit).
+		Find(&users).Error
+
+	return users, total, err
+}
+
 func (r *roleRepository) Create(role *models.Role) error {
 	return r.db.Create(role).Error
 }
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+)
+
+type serviceAccountRepository struct {
+	db *gorm.DB
+}
+
+func NewServiceAccountRepository(db *gorm.DB) ServiceAccountRepository {
+	return &serviceAccountRepository{db: db}
+}
+
+func (r *serviceAccountRepository) Create(account *models.ServiceAccount) error {
+	return r.db.Create(account).Error
+}
+
+func (r *serviceAccountRepository) GetByID(id uint) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	err := r.db.First(&account, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *serviceAccountRepository) GetByClientID(clientID string) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	err := r.db.Where("client_id = ?", clientID).First(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *serviceAccountRepository) List() ([]models.ServiceAccount, error) {
+	var accounts []models.ServiceAccount
+	err := r.db.Order("created_at DESC").Find(&accounts).Error
+	return accounts, err
+}
+
+func (r *serviceAccountRepository) Update(account *models.ServiceAccount) error {
+	return r.db.Save(account).Error
+}
+
+func (r *serviceAccountRepository) UpdateLastUsed(id uint) error {
+	return r.db.Model(&models.ServiceAccount{}).Where("id = ?", id).Update("last_used_at", time.Now()).Error
+}
+
+func (r *serviceAccountRepository) Delete(id uint) error {
+	return r.db.Delete(&models.ServiceAccount{}, id).Error
+}
@@ -31,12 +31,67 @@ func (r *refreshTokenRepository) GetByTokenID(tokenID string) (*models.RefreshTo
 	return &token, nil
 }
 
+func (r *refreshTokenRepository) FindByTokenID(tokenID string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_id = ?", tokenID).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
 func (r *refreshTokenRepository) GetByUserID(userID uint) ([]models.RefreshToken, error) {
 	var tokens []models.RefreshToken
 	err := r.db.Where("user_id = ? AND is_revoked = ?", userID, false).Find(&tokens).Error
 	return tokens, err
 }
 
+// Rotate revokes tokenID and stores next as its replacement in a single
+// transaction. It reports false when tokenID had already been revoked, which
+// callers must treat as reuse of a rotated token.
+func (r *refreshTokenRepository) Rotate(tokenID string, next *models.RefreshToken) (bool, error) {
+	rotated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("token_id = ? AND is_revoked = ?", tokenID, false).
+			Updates(map[string]interface{}{
+				"is_revoked":  true,
+				"revoked_at":  time.Now(),
+				"replaced_by": next.TokenID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		rotated = true
+		return nil
+	})
+	return rotated, err
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND is_revoked = ?", familyID, false).
+		Updates(map[string]interface{}{
+			"is_revoked": true,
+			"revoked_at": time.Now(),
+		}).Error
+}
+
+func (r *refreshTokenRepository) RevokeByUserID(userID uint) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND is_revoked = ?", userID, false).
+		Updates(map[string]interface{}{
+			"is_revoked": true,
+			"revoked_at": time.Now(),
+		}).Error
+}
+
 func (r *refreshTokenRepository) Delete(tokenID string) error {
 	return r.db.Where("token_id = ?", tokenID).Delete(&models.RefreshToken{}).Error
 }
@@ -61,6 +116,10 @@ func (r *userSessionRepository) Create(session *models.UserSession) error {
 	return r.db.Create(session).Error
 }
 
+func (r *userSessionRepository) Update(session *models.UserSession) error {
+	return r.db.Save(session).Error
+}
+
 func (r *userSessionRepository) GetBySessionID(sessionID string) (*models.UserSession, error) {
 	var session models.UserSession
 	err := r.db.Preload("User").
@@ -72,9 +131,21 @@ func (r *userSessionRepository) GetBySessionID(sessionID string) (*models.UserSe
 	return &session, nil
 }
 
+func (r *userSessionRepository) GetByFamilyID(familyID string) (*models.UserSession, error) {
+	var session models.UserSession
+	err := r.db.Where("family_id = ?", familyID).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetByUserID returns the user's unexpired sessions, most recent first.
 func (r *userSessionRepository) GetByUserID(userID uint) ([]models.UserSession, error) {
 	var sessions []models.UserSession
-	err := r.db.Where("user_id = ?", userID).Find(&sessions).Error
+	err := r.db.Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").
+		Find(&sessions).Error
 	return sessions, err
 }
 
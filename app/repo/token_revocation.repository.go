@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+)
+
+type tokenRevocationRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRevocationRepository(db *gorm.DB) TokenRevocationRepository {
+	return &tokenRevocationRepository{db: db}
+}
+
+func (r *tokenRevocationRepository) Create(revocation *models.TokenRevocation) error {
+	return r.db.Create(revocation).Error
+}
+
+func (r *tokenRevocationRepository) ListActiveSince(since, now time.Time) ([]models.TokenRevocation, error) {
+	var revocations []models.TokenRevocation
+	err := r.db.Where("created_at > ? AND expires_at > ?", since, now).
+		Order("id").
+		Find(&revocations).Error
+	return revocations, err
+}
+
+func (r *tokenRevocationRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&models.TokenRevocation{}).Error
+}
+
+// memoryTokenRevocationRepository keeps revocations in process. It suits a
+// single replica; revocations are lost on restart.
+type memoryTokenRevocationRepository struct {
+	mu          sync.Mutex
+	nextID      uint
+	revocations []models.TokenRevocation
+}
+
+func NewMemoryTokenRevocationRepository() TokenRevocationRepository {
+	return &memoryTokenRevocationRepository{}
+}
+
+func (r *memoryTokenRevocationRepository) Create(revocation *models.TokenRevocation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	revocation.ID = r.nextID
+	revocation.CreatedAt = time.Now()
+	r.revocations = append(r.revocations, *revocation)
+	return nil
+}
+
+func (r *memoryTokenRevocationRepository) ListActiveSince(since, now time.Time) ([]models.TokenRevocation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var revocations []models.TokenRevocation
+	for _, revocation := range r.revocations {
+		if revocation.CreatedAt.After(since) && revocation.ExpiresAt.After(now) {
+			revocations = append(revocations, revocation)
+		}
+	}
+	return revocations, nil
+}
+
+func (r *memoryTokenRevocationRepository) DeleteExpired(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	active := r.revocations[:0]
+	for _, revocation := range r.revocations {
+		if revocation.ExpiresAt.After(now) {
+			active = append(active, revocation)
+		}
+	}
+	r.revocations = active
+	return nil
+}
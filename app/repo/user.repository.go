@@ -83,6 +83,15 @@ func (r *userRepository) Update(user *models.User) error {
 	return r.db.Save(user).Error
 }
 
+func (r *userRepository) Merge(source, target *models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(source).Error; err != nil {
+			return err
+		}
+		return tx.Save(target).Error
+	})
+}
+
 func (r *userRepository) Delete(id uint) error {
 	return r.db.Delete(&models.User{}, id).Error
 }
@@ -124,6 +133,39 @@ func (r *userRepository) UpdatePasswordChangedAt(id uint) error {
 		Update("password_changed_at", now).Error
 }
 
+// RecordLoginFailure updates the counters in one statement so that
+// concurrent failures on different replicas are all counted. MySQL applies
+// assignments left to right, so locked_until and lockout_count are computed
+// from the previous failed_login_attempts and lockout_count.
+func (r *userRepository) RecordLoginFailure(id uint, threshold int, lockout, maxLockout time.Duration) (*time.Time, error) {
+	err := r.db.Exec(
+		"UPDATE users SET "+
+			"locked_until = CASE WHEN failed_login_attempts + 1 >= ? "+
+			"THEN DATE_ADD(?, INTERVAL LEAST(? * POW(2, lockout_count), ?) SECOND) ELSE locked_until END, "+
+			"lockout_count = CASE WHEN failed_login_attempts + 1 >= ? THEN lockout_count + 1 ELSE lockout_count END, "+
+			"failed_login_attempts = CASE WHEN failed_login_attempts + 1 >= ? THEN 0 ELSE failed_login_attempts + 1 END "+
+			"WHERE id = ?",
+		threshold, time.Now(), int64(lockout.Seconds()), int64(maxLockout.Seconds()), threshold, threshold, id,
+	).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := r.db.Select("id", "locked_until").First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return user.LockedUntil, nil
+}
+
+func (r *userRepository) ClearLoginFailures(id uint) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"lockout_count":         0,
+		"locked_until":          nil,
+	}).Error
+}
+
 func (r *userRepository) GetDashboardStats(customerType *string, fromDate, toDate *time.Time) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
 
@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+
+	"gorm.io/gorm"
+)
+
+type webAuthnRepository struct {
+	db *gorm.DB
+}
+
+func NewWebAuthnRepository(db *gorm.DB) WebAuthnRepository {
+	return &webAuthnRepository{db: db}
+}
+
+func (r *webAuthnRepository) CreateCredential(credential *models.WebAuthnCredential) error {
+	return r.db.Create(credential).Error
+}
+
+func (r *webAuthnRepository) GetCredentialByCredentialID(credentialID string) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.Where("credential_id = ?", credentialID).First(&credential).Error
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *webAuthnRepository) GetCredentialsByUserID(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&credentials).Error
+	return credentials, err
+}
+
+// RecordAssertion stores the sign count of a successful assertion. It reports
+// false when the stored count changed since previousCount was read, i.e. a
+// concurrent assertion with the same credential won.
+func (r *webAuthnRepository) RecordAssertion(id uint, previousCount, signCount uint32, backupState bool) (bool, error) {
+	result := r.db.Model(&models.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, previousCount).
+		Updates(map[string]interface{}{
+			"sign_count":   signCount,
+			"backup_state": backupState,
+			"last_used_at": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *webAuthnRepository) MarkCloneDetected(id uint) error {
+	return r.db.Model(&models.WebAuthnCredential{}).
+		Where("id = ?", id).
+		Update("clone_detected", true).Error
+}
+
+func (r *webAuthnRepository) DeleteCredential(userID, id uint) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *webAuthnRepository) CreateChallenge(challenge *models.WebAuthnChallenge) error {
+	return r.db.Create(challenge).Error
+}
+
+// ConsumeChallenge marks an unexpired challenge of the given ceremony used and
+// returns it. It returns gorm.ErrRecordNotFound when there is no such
+// challenge or it was already consumed.
+func (r *webAuthnRepository) ConsumeChallenge(challengeHash, ceremony string) (*models.WebAuthnChallenge, error) {
+	var challenge models.WebAuthnChallenge
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Where("challenge_hash = ? AND ceremony = ? AND consumed_at IS NULL AND expires_at > ?", challengeHash, ceremony, now).
+			First(&challenge).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.WebAuthnChallenge{}).
+			Where("id = ? AND consumed_at IS NULL", challenge.ID).
+			Update("consumed_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+		challenge.ConsumedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *webAuthnRepository) DeleteExpiredChallenges() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&models.WebAuthnChallenge{}).Error
+}
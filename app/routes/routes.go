@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"log"
 	"time"
 
 	"github.com/bbapp-org/auth-service/app/config"
@@ -18,12 +19,26 @@ import (
 func SetupRoutes(app *fiber.App, cfg *config.Config) {
 	db := database.GetDB()
 
+	app.Use(middleware.ClientInfo())
+
 	httpClient := utils.NewHTTPClient(cfg.Service.CustomerServiceURL, 10*time.Second)
 
 	userRepo := repo.NewUserRepository(db, httpClient)
 	roleRepo := repo.NewRoleRepository(db)
 	refreshTokenRepo := repo.NewRefreshTokenRepository(db)
 	sessionRepo := repo.NewUserSessionRepository(db)
+	mfaRepo := repo.NewMFARepository(db)
+	webAuthnRepo := repo.NewWebAuthnRepository(db)
+	passwordResetRepo := repo.NewPasswordResetRepository(db)
+	invitationRepo := repo.NewInvitationRepository(db)
+	notificationRepo := repo.NewNotificationRepository(db)
+	authEventRepo := repo.NewAuthEventRepository(db)
+	passwordHistoryRepo := repo.NewPasswordHistoryRepository(db)
+	otpRepo := repo.NewOTPRepository(db)
+	oauthClientRepo := repo.NewOAuthClientRepository(db)
+	oauthCodeRepo := repo.NewOAuthCodeRepository(db)
+	serviceAccountRepo := repo.NewServiceAccountRepository(db)
+	apiKeyRepo := repo.NewAPIKeyRepository(db)
 	supportRepo := repo.NewSupportRepository(db)
 	vendorRepo := repo.NewVendorRepository(db)
 	companyRepo := repo.NewCompanyRepository(db)
@@ -49,8 +64,52 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 	itemGroupRepo := repo.NewItemGroupRepository(db)
 	productionOrderRepo := repo.NewProductionOrderRepository(db)
 
-	authService := services.NewAuthService(userRepo, roleRepo, refreshTokenRepo, sessionRepo)
-	adminService := services.NewAdminService(userRepo, roleRepo)
+	var revocationRepo repo.TokenRevocationRepository
+	if cfg.Revocation.Backend == "memory" {
+		revocationRepo = repo.NewMemoryTokenRevocationRepository()
+	} else {
+		revocationRepo = repo.NewTokenRevocationRepository(db)
+	}
+	revocationService := services.NewRevocationService(cfg.Revocation, revocationRepo)
+	utils.SetRevocationChecker(revocationService.IsRevoked)
+	sessionService := services.NewSessionService(sessionRepo, refreshTokenRepo, userRepo, revocationService)
+
+	var rateLimitRepo repo.RateLimitRepository
+	if cfg.RateLimit.Backend == "memory" {
+		rateLimitRepo = repo.NewMemoryRateLimitRepository()
+	} else {
+		rateLimitRepo = repo.NewRateLimitRepository(db)
+	}
+	rateLimitService := services.NewRateLimitService(cfg.RateLimit, rateLimitRepo, userRepo)
+
+	passwordPolicy, err := utils.NewPasswordPolicy(cfg.PasswordPolicy)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+	passwordPolicyService := services.NewPasswordPolicyService(passwordPolicy, passwordHistoryRepo)
+
+	notifier, err := utils.NewNotifier(cfg.Notification)
+	if err != nil {
+		log.Fatalf("Failed to set up notifications: %v", err)
+	}
+	notificationService, err := services.NewNotificationService(cfg.Notification, notificationRepo, notifier)
+	if err != nil {
+		log.Fatalf("Failed to set up notifications: %v", err)
+	}
+
+	auditService := services.NewAuditService(authEventRepo)
+	mfaService := services.NewMFAService(mfaRepo, userRepo, cfg.MFA)
+	webAuthnService := services.NewWebAuthnService(webAuthnRepo, userRepo, cfg.WebAuthn)
+	passwordResetService := services.NewPasswordResetService(passwordResetRepo, userRepo, sessionService, passwordPolicyService, notificationService, auditService, cfg.PasswordReset)
+
+	authService := services.NewAuthService(userRepo, roleRepo, refreshTokenRepo, sessionService, otpRepo, revocationService, mfaService, webAuthnService, passwordPolicyService, rateLimitService, notificationService, auditService, cfg.StepUp)
+	invitationService := services.NewInvitationService(invitationRepo, userRepo, passwordPolicyService, mfaService, authService, revocationService, notificationService, cfg.Invitation)
+	identityService := services.NewIdentityService(userRepo, authService, revocationService)
+	impersonationService := services.NewImpersonationService(userRepo, auditService, cfg.Impersonation)
+	adminService := services.NewAdminService(userRepo, roleRepo, revocationService, passwordPolicyService, authService, invitationService, auditService)
+	serviceAccountService := services.NewServiceAccountService(serviceAccountRepo, revocationService)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userRepo)
+	oauthService := services.NewOAuthService(oauthClientRepo, oauthCodeRepo, authService, serviceAccountService)
 	supportService := services.NewSupportService(supportRepo)
 	businessTypeService := services.NewBusinessTypeService(businessTypeRepo)
 	locationService := services.NewLocationService(locationRepo)
@@ -76,10 +135,32 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 	inventoryService := services.NewInventoryService(itemRepo, itemGroupRepo, inventoryBalanceRepo, openStockRepo)
 	productionOrderService := services.NewProductionOrderService(productionOrderRepo, itemGroupRepo, itemRepo, inventoryService)
 
+	policyService, err := services.NewPolicyService(cfg.ForwardAuth)
+	if err != nil {
+		log.Fatalf("Failed to load forward-auth policy: %v", err)
+	}
+
+	permissionService := services.NewPermissionService(roleRepo, revocationService)
+	utils.SetPermissionResolver(permissionService.RolePermissions)
+	roleService := services.NewRoleService(roleRepo, permissionService)
+
 	authHandler := handlers.NewAuthHandler(authService)
 	adminHandler := handlers.NewAdminHandler(adminService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService, authService, mfaService)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(serviceAccountService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	roleHandler := handlers.NewRoleHandler(roleService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	mfaHandler := handlers.NewMFAHandler(mfaService, authService)
+	webAuthnHandler := handlers.NewWebAuthnHandler(webAuthnService, authService)
+	passwordHandler := handlers.NewPasswordHandler(passwordResetService)
+	invitationHandler := handlers.NewInvitationHandler(invitationService)
+	identityHandler := handlers.NewIdentityHandler(identityService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationService)
 	supportHandler := handlers.NewSupportHandler(supportService)
-	forwardAuthHandler := handlers.NewForwardAuthHandler()
+	forwardAuthHandler := handlers.NewForwardAuthHandler(policyService, revocationService, auditService)
+	wellKnownHandler := handlers.NewWellKnownHandler(cfg.App.PublicURL)
 	vendorHandler := handlers.NewVendorHandler(vendorService)
 	companyHandler := handlers.NewCompanyHandler(companyService, businessTypeService, locationService, taxTypeService)
 	helperHandler := handlers.NewHelperHandler(businessTypeService, locationService, taxTypeService)
@@ -101,8 +182,27 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 	itemGroupHandler := handlers.NewItemGroupHandler(itemGroupService)
 	productionOrderHandler := handlers.NewProductionOrderHandler(productionOrderService)
 
+	apiKeyAuth := middleware.APIKeyOrAuthMiddleware(apiKeyService.Authenticate)
+
+	app.Use(middleware.AuditImpersonation(auditService.RecordImpersonatedRequest))
+
 	app.Get("/docs/*", swagger.HandlerDefault)
 
+	app.Get("/.well-known/jwks.json", wellKnownHandler.JWKS)
+	app.Get("/.well-known/openid-configuration", wellKnownHandler.OpenIDConfiguration)
+
+	app.Get("/userinfo", middleware.UserAuthMiddleware(), authHandler.OIDCUserInfo)
+	app.Post("/userinfo", middleware.UserAuthMiddleware(), authHandler.OIDCUserInfo)
+
+	oauthGroup := app.Group("/oauth")
+	{
+		oauthGroup.Get("/authorize", oauthHandler.Authorize)
+		oauthGroup.Post("/authorize", oauthHandler.AuthorizeLogin)
+		oauthGroup.Post("/token", oauthHandler.Token)
+		oauthGroup.Post("/introspect", oauthHandler.Introspect)
+		oauthGroup.Post("/revoke", oauthHandler.Revoke)
+	}
+
 	authGroup := app.Group("/auth")
 	{
 		authGroup.Post("/register/email", authHandler.RegisterEmail)
@@ -114,49 +214,93 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 		authGroup.Post("/login/google", authHandler.LoginGoogle)
 		authGroup.Post("/login/apple", authHandler.LoginApple)
 		authGroup.Post("/login/password", authHandler.LoginPassword)
+		authGroup.Post("/login/mfa", mfaHandler.LoginMFA)
+		authGroup.Post("/login/mfa/enroll", mfaHandler.BeginLoginEnrollment)
+		authGroup.Post("/login/mfa/confirm", mfaHandler.ConfirmLoginEnrollment)
+		authGroup.Post("/webauthn/login/begin", webAuthnHandler.BeginLogin)
+		authGroup.Post("/webauthn/login/finish", webAuthnHandler.FinishLogin)
 
+		authGroup.Post("/verify/email", authHandler.VerifyEmail)
+		authGroup.Post("/verify/phone", authHandler.VerifyPhone)
+
+		authGroup.Post("/password/forgot", passwordHandler.ForgotPassword)
+		authGroup.Post("/password/reset", passwordHandler.ResetPassword)
+
+		authGroup.Get("/invitations/:token", invitationHandler.GetInvitation)
+		authGroup.Post("/invitations/:token/accept", invitationHandler.AcceptInvitation)
+
+		authGroup.Post("/refresh-token", authHandler.RefreshToken)
 		authGroup.Post("/validate-token", authHandler.ValidateToken)
 		authGroup.Post("/create-super-admin", adminHandler.CreateSuperAdmin)
 	}
 
+	// recentAuth guards operations that need the user to have signed in or
+	// reauthenticated within the last cfg.StepUp.MaxAge.
+	recentAuth := middleware.RequireRecentAuth(cfg.StepUp.MaxAge)
+
 	protectedAuthGroup := app.Group("/auth")
-	protectedAuthGroup.Use(middleware.AuthMiddleware())
+	protectedAuthGroup.Use(middleware.UserAuthMiddleware())
 	{
-		protectedAuthGroup.Post("/refresh-token", authHandler.RefreshToken)
 		protectedAuthGroup.Get("/user-info", authHandler.GetUserInfo)
-		protectedAuthGroup.Post("/change-password", authHandler.ChangePassword)
+		protectedAuthGroup.Post("/change-password", middleware.NoImpersonation(), authHandler.ChangePassword)
+		protectedAuthGroup.Post("/reauthenticate", middleware.NoImpersonation(), authHandler.Reauthenticate)
+		protectedAuthGroup.Post("/reauthenticate/otp", middleware.NoImpersonation(), authHandler.SendReauthenticationOTP)
+		protectedAuthGroup.Post("/contact/verify", authHandler.SendContactVerification)
+		protectedAuthGroup.Post("/contact/verify/confirm", authHandler.ConfirmContactVerification)
+		protectedAuthGroup.Post("/contact/change", middleware.NoImpersonation(), authHandler.ChangeContact)
+		protectedAuthGroup.Get("/identities", identityHandler.ListIdentities)
+		protectedAuthGroup.Post("/identities/link", middleware.NoImpersonation(), identityHandler.LinkIdentity)
+		protectedAuthGroup.Post("/identities/merge", middleware.NoImpersonation(), identityHandler.MergeAccount)
+		protectedAuthGroup.Delete("/identities/:provider", middleware.NoImpersonation(), identityHandler.UnlinkIdentity)
 		protectedAuthGroup.Post("/logout", authHandler.Logout)
+		protectedAuthGroup.Post("/logout-all", middleware.NoImpersonation(), sessionHandler.LogoutAll)
+		protectedAuthGroup.Get("/sessions", sessionHandler.GetSessions)
+		protectedAuthGroup.Delete("/sessions/:id", middleware.NoImpersonation(), sessionHandler.RevokeSession)
+		protectedAuthGroup.Get("/mfa", mfaHandler.GetStatus)
+		protectedAuthGroup.Post("/mfa/totp/enroll", middleware.NoImpersonation(), mfaHandler.BeginEnrollment)
+		protectedAuthGroup.Post("/mfa/totp/confirm", middleware.NoImpersonation(), mfaHandler.ConfirmEnrollment)
+		protectedAuthGroup.Post("/mfa/totp/disable", middleware.NoImpersonation(), mfaHandler.Disable)
+		protectedAuthGroup.Post("/mfa/recovery-codes", middleware.NoImpersonation(), mfaHandler.RegenerateRecoveryCodes)
+		protectedAuthGroup.Post("/webauthn/register/begin", middleware.NoImpersonation(), webAuthnHandler.BeginRegistration)
+		protectedAuthGroup.Post("/webauthn/register/finish", middleware.NoImpersonation(), webAuthnHandler.FinishRegistration)
+		protectedAuthGroup.Get("/webauthn/credentials", webAuthnHandler.GetCredentials)
+		protectedAuthGroup.Delete("/webauthn/credentials/:id", middleware.NoImpersonation(), webAuthnHandler.DeleteCredential)
+
+		protectedAuthGroup.Post("/api-keys", middleware.NoImpersonation(), middleware.PartnerMiddleware(), apiKeyHandler.CreateAPIKey)
+		protectedAuthGroup.Get("/api-keys", middleware.PartnerMiddleware(), apiKeyHandler.GetAPIKeys)
+		protectedAuthGroup.Post("/api-keys/:id/rotate", middleware.NoImpersonation(), middleware.PartnerMiddleware(), apiKeyHandler.RotateAPIKey)
+		protectedAuthGroup.Delete("/api-keys/:id", middleware.NoImpersonation(), middleware.PartnerMiddleware(), apiKeyHandler.RevokeAPIKey)
 	}
 
 	manufacturerGroup := app.Group("/manufacturers")
 	{
 		manufacturerGroup.Get("/", manufacturerHandler.GetAllManufacturers)
 		manufacturerGroup.Get("/:id", manufacturerHandler.GetManufacturerByID)
-		manufacturerGroup.Post("/", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), manufacturerHandler.CreateManufacturer)
-		manufacturerGroup.Put("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), manufacturerHandler.UpdateManufacturer)
-		manufacturerGroup.Delete("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), manufacturerHandler.DeleteManufacturer)
+		manufacturerGroup.Post("/", middleware.AuthMiddleware(), middleware.RequirePermission("manufacturers:write"), manufacturerHandler.CreateManufacturer)
+		manufacturerGroup.Put("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("manufacturers:write"), manufacturerHandler.UpdateManufacturer)
+		manufacturerGroup.Delete("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("manufacturers:delete"), manufacturerHandler.DeleteManufacturer)
 	}
 
 	brandGroup := app.Group("/brands")
 	{
 		brandGroup.Get("/", brandHandler.GetAllBrands)
 		brandGroup.Get("/:id", brandHandler.GetBrandByID)
-		brandGroup.Post("/", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), brandHandler.CreateBrand)
-		brandGroup.Put("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), brandHandler.UpdateBrand)
-		brandGroup.Delete("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), brandHandler.DeleteBrand)
+		brandGroup.Post("/", middleware.AuthMiddleware(), middleware.RequirePermission("brands:write"), brandHandler.CreateBrand)
+		brandGroup.Put("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("brands:write"), brandHandler.UpdateBrand)
+		brandGroup.Delete("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("brands:delete"), brandHandler.DeleteBrand)
 	}
 
 	bankGroup := app.Group("/banks")
 	{
 		bankGroup.Get("/", bankHandler.GetAllBanks)
 		bankGroup.Get("/:id", bankHandler.GetBankByID)
-		bankGroup.Post("/", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), bankHandler.CreateBank)
-		bankGroup.Put("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), bankHandler.UpdateBank)
-		bankGroup.Delete("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), bankHandler.DeleteBank)
+		bankGroup.Post("/", middleware.AuthMiddleware(), middleware.RequirePermission("banks:write"), bankHandler.CreateBank)
+		bankGroup.Put("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("banks:write"), bankHandler.UpdateBank)
+		bankGroup.Delete("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("banks:delete"), bankHandler.DeleteBank)
 	}
 
 	superAdminGroup := app.Group("/auth/admin")
-	superAdminGroup.Use(middleware.AuthMiddleware())
+	superAdminGroup.Use(middleware.UserAuthMiddleware())
 	superAdminGroup.Use(middleware.SuperAdminMiddleware())
 	{
 		superAdminGroup.Post("/create-user", adminHandler.CreateUser)
@@ -164,28 +308,59 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 		superAdminGroup.Get("/users", adminHandler.GetUsers)
 		superAdminGroup.Get("/users/:id", adminHandler.GetUser)
 		superAdminGroup.Put("/users/:id", adminHandler.UpdateUser)
-		superAdminGroup.Delete("/users/:id", adminHandler.DeleteUser)
+		superAdminGroup.Delete("/users/:id", recentAuth, adminHandler.DeleteUser)
 		superAdminGroup.Put("/users/:id/status", adminHandler.UpdateUserStatus)
-		superAdminGroup.Put("/users/:id/role", adminHandler.UpdateUserRole)
+		superAdminGroup.Put("/users/:id/role", recentAuth, adminHandler.UpdateUserRole)
+		superAdminGroup.Post("/users/:id/unlock", adminHandler.UnlockUser)
+		superAdminGroup.Post("/users/:id/impersonate", impersonationHandler.Impersonate)
+		superAdminGroup.Get("/invitations", invitationHandler.ListInvitations)
+		superAdminGroup.Post("/invitations/:id/resend", invitationHandler.ResendInvitation)
+		superAdminGroup.Delete("/invitations/:id", invitationHandler.RevokeInvitation)
 		superAdminGroup.Get("/dashboard/stats", adminHandler.GetDashboardStats)
+		superAdminGroup.Get("/users/:id/sessions", sessionHandler.GetUserSessions)
+		superAdminGroup.Post("/users/:id/logout", sessionHandler.ForceLogout)
+		superAdminGroup.Delete("/users/:id/mfa", mfaHandler.ResetUserMFA)
+		superAdminGroup.Get("/mfa/policy", mfaHandler.GetPolicy)
+		superAdminGroup.Put("/mfa/policy", mfaHandler.UpdatePolicy)
+		superAdminGroup.Get("/audit-events", auditHandler.ListEvents)
+		superAdminGroup.Get("/audit-events/export", auditHandler.ExportEvents)
+		superAdminGroup.Get("/audit-events/verify", auditHandler.VerifyChain)
+
+		superAdminGroup.Get("/roles", roleHandler.GetRoles)
+		superAdminGroup.Post("/roles", roleHandler.CreateRole)
+		superAdminGroup.Get("/roles/:id", roleHandler.GetRole)
+		superAdminGroup.Put("/roles/:id", roleHandler.UpdateRole)
+		superAdminGroup.Delete("/roles/:id", roleHandler.DeleteRole)
+		superAdminGroup.Get("/roles/:id/users", roleHandler.GetRoleUsers)
+
+		superAdminGroup.Post("/oauth-clients", oauthHandler.CreateClient)
+		superAdminGroup.Get("/oauth-clients", oauthHandler.GetClients)
+		superAdminGroup.Put("/oauth-clients/:id", oauthHandler.UpdateClient)
+		superAdminGroup.Delete("/oauth-clients/:id", oauthHandler.DeleteClient)
+
+		superAdminGroup.Post("/service-accounts", serviceAccountHandler.CreateServiceAccount)
+		superAdminGroup.Get("/service-accounts", serviceAccountHandler.GetServiceAccounts)
+		superAdminGroup.Post("/service-accounts/:id/rotate-secret", serviceAccountHandler.RotateSecret)
+		superAdminGroup.Post("/service-accounts/:id/deactivate", serviceAccountHandler.DeactivateServiceAccount)
+		superAdminGroup.Delete("/service-accounts/:id", serviceAccountHandler.DeleteServiceAccount)
 	}
 
 	vendorGroup := app.Group("/vendors")
 	{
 		vendorGroup.Get("/", vendorHandler.GetAllVendors)
 		vendorGroup.Get("/:id", vendorHandler.GetVendor)
-		vendorGroup.Post("/", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), vendorHandler.CreateVendor)
-		vendorGroup.Put("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), vendorHandler.UpdateVendor)
-		vendorGroup.Delete("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), vendorHandler.DeleteVendor)
+		vendorGroup.Post("/", middleware.AuthMiddleware(), middleware.RequirePermission("vendors:write"), vendorHandler.CreateVendor)
+		vendorGroup.Put("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("vendors:write"), vendorHandler.UpdateVendor)
+		vendorGroup.Delete("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("vendors:delete"), vendorHandler.DeleteVendor)
 	}
 
 	customerGroup := app.Group("/customers")
 	{
 		customerGroup.Get("/", customerHandler.GetAllCustomers)
 		customerGroup.Get("/:id", customerHandler.GetCustomerByID)
-		customerGroup.Post("/", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), customerHandler.CreateCustomer)
-		customerGroup.Put("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), customerHandler.UpdateCustomer)
-		customerGroup.Delete("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), customerHandler.DeleteCustomer)
+		customerGroup.Post("/", middleware.AuthMiddleware(), middleware.RequirePermission("customers:write"), customerHandler.CreateCustomer)
+		customerGroup.Put("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("customers:write"), customerHandler.UpdateCustomer)
+		customerGroup.Delete("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("customers:delete"), customerHandler.DeleteCustomer)
 	}
 
 	partners := app.Group("/partners")
@@ -194,11 +369,10 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 	}
 
 	adminGroup := app.Group("/auth/manage")
-	adminGroup.Use(middleware.AuthMiddleware())
-	adminGroup.Use(middleware.AdminMiddleware())
+	adminGroup.Use(middleware.UserAuthMiddleware())
 	{
-		adminGroup.Post("/create-partner", adminHandler.CreateUser)
-		adminGroup.Get("/partners", adminHandler.GetUsers)
+		adminGroup.Post("/create-partner", middleware.RequirePermission("partners:write"), adminHandler.CreateUser)
+		adminGroup.Get("/partners", middleware.RequirePermission("partners:read"), adminHandler.GetUsers)
 	}
 
 	forwardAuthGroup := app.Group("/forward-auth")
@@ -206,6 +380,8 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 		forwardAuthGroup.Get("/", forwardAuthHandler.ForwardAuth)
 		forwardAuthGroup.Get("/product", forwardAuthHandler.ProductAuth)
 		forwardAuthGroup.Get("/customer", forwardAuthHandler.CustomerAuth)
+		forwardAuthGroup.Post("/policies/test", middleware.UserAuthMiddleware(), middleware.SuperAdminMiddleware(), forwardAuthHandler.TestPolicy)
+		forwardAuthGroup.Get("/stats", middleware.UserAuthMiddleware(), middleware.SuperAdminMiddleware(), forwardAuthHandler.GetStats)
 	}
 
 	app.Post("/public/support", supportHandler.CreateSupport)
@@ -219,7 +395,7 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 	}
 
 	companyRoutes := app.Group("/companies")
-	companyRoutes.Use(middleware.AuthMiddleware())
+	companyRoutes.Use(middleware.UserAuthMiddleware())
 	{
 		companyRoutes.Post("/setup", companyHandler.CompleteCompanySetup)
 
@@ -227,7 +403,7 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 		companyRoutes.Post("/", companyHandler.CreateCompany)
 		companyRoutes.Get("/:id", companyHandler.GetCompany)
 		companyRoutes.Put("/:id", companyHandler.UpdateCompany)
-		companyRoutes.Delete("/:id", middleware.SuperAdminMiddleware(), companyHandler.DeleteCompany)
+		companyRoutes.Delete("/:id", middleware.RequirePermission("companies:delete"), companyHandler.DeleteCompany)
 
 		companyRoutes.Put("/:id/contact", companyHandler.UpsertContact)
 		companyRoutes.Get("/:id/contact", companyHandler.GetContact)
@@ -235,10 +411,10 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 		companyRoutes.Put("/:id/address", companyHandler.UpsertAddress)
 		companyRoutes.Get("/:id/address", companyHandler.GetAddress)
 
-		companyRoutes.Post("/:id/bank-details", companyHandler.CreateBankDetail)
+		companyRoutes.Post("/:id/bank-details", recentAuth, companyHandler.CreateBankDetail)
 		companyRoutes.Get("/:id/bank-details", companyHandler.GetBankDetails)
-		companyRoutes.Put("/bank-details/:id", companyHandler.UpdateBankDetail)
-		companyRoutes.Delete("/bank-details/:id", companyHandler.DeleteBankDetail)
+		companyRoutes.Put("/bank-details/:id", recentAuth, companyHandler.UpdateBankDetail)
+		companyRoutes.Delete("/bank-details/:id", recentAuth, companyHandler.DeleteBankDetail)
 
 		companyRoutes.Put("/:id/upi-details", companyHandler.UpsertUPIDetail)
 		companyRoutes.Get("/:id/upi-details", companyHandler.GetUPIDetail)
@@ -258,16 +434,16 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 		itemRoutes.Get("/", itemHandler.GetAllItems)
 		itemRoutes.Get("/:id", itemHandler.GetItem)
 
-		itemRoutes.Post("/", middleware.AuthMiddleware(), middleware.AdminMiddleware(), itemHandler.CreateItem)
-		itemRoutes.Put("/:id", middleware.AuthMiddleware(), middleware.AdminMiddleware(), itemHandler.UpdateItem)
-		itemRoutes.Delete("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), itemHandler.DeleteItem)
+		itemRoutes.Post("/", apiKeyAuth, middleware.RequirePermission("items:write"), itemHandler.CreateItem)
+		itemRoutes.Put("/:id", apiKeyAuth, middleware.RequirePermission("items:write"), itemHandler.UpdateItem)
+		itemRoutes.Delete("/:id", apiKeyAuth, middleware.RequirePermission("items:delete"), itemHandler.DeleteItem)
 
-		itemRoutes.Put("/:id/opening-stock", middleware.AuthMiddleware(), middleware.AdminMiddleware(), openStockHandler.UpdateOpeningStock)
-		itemRoutes.Get("/:id/opening-stock", middleware.AuthMiddleware(), middleware.AdminMiddleware(), openStockHandler.GetOpeningStock)
+		itemRoutes.Put("/:id/opening-stock", apiKeyAuth, middleware.RequirePermission("inventory:write"), openStockHandler.UpdateOpeningStock)
+		itemRoutes.Get("/:id/opening-stock", apiKeyAuth, middleware.RequirePermission("inventory:read"), openStockHandler.GetOpeningStock)
 
-		itemRoutes.Put("/:id/variants/opening-stock", middleware.AuthMiddleware(), middleware.AdminMiddleware(), openStockHandler.UpdateVariantsOpeningStock)
-		itemRoutes.Get("/:id/variants/opening-stock", middleware.AuthMiddleware(), middleware.AdminMiddleware(), openStockHandler.GetVariantsOpeningStock)
-		itemRoutes.Get("/:id/stock-summary", middleware.AuthMiddleware(), middleware.AdminMiddleware(), openStockHandler.GetStockSummary)
+		itemRoutes.Put("/:id/variants/opening-stock", apiKeyAuth, middleware.RequirePermission("inventory:write"), openStockHandler.UpdateVariantsOpeningStock)
+		itemRoutes.Get("/:id/variants/opening-stock", apiKeyAuth, middleware.RequirePermission("inventory:read"), openStockHandler.GetVariantsOpeningStock)
+		itemRoutes.Get("/:id/stock-summary", apiKeyAuth, middleware.RequirePermission("inventory:read"), openStockHandler.GetStockSummary)
 	}
 
 	itemGroupRoutes := app.Group("/item-groups")
@@ -275,146 +451,146 @@ func SetupRoutes(app *fiber.App, cfg *config.Config) {
 		itemGroupRoutes.Get("/", itemGroupHandler.GetAllItemGroups)
 		itemGroupRoutes.Get("/:id", itemGroupHandler.GetItemGroupByID)
 
-		itemGroupRoutes.Post("/", middleware.AuthMiddleware(), middleware.AdminMiddleware(), itemGroupHandler.CreateItemGroup)
-		itemGroupRoutes.Put("/:id", middleware.AuthMiddleware(), middleware.AdminMiddleware(), itemGroupHandler.UpdateItemGroup)
-		itemGroupRoutes.Delete("/:id", middleware.AuthMiddleware(), middleware.SuperAdminMiddleware(), itemGroupHandler.DeleteItemGroup)
+		itemGroupRoutes.Post("/", middleware.AuthMiddleware(), middleware.RequirePermission("item-groups:write"), itemGroupHandler.CreateItemGroup)
+		itemGroupRoutes.Put("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("item-groups:write"), itemGroupHandler.UpdateItemGroup)
+		itemGroupRoutes.Delete("/:id", middleware.AuthMiddleware(), middleware.RequirePermission("item-groups:delete"), itemGroupHandler.DeleteItemGroup)
 
 		itemGroupRoutes.Get("/search/by-name", itemGroupHandler.GetItemGroupByName)
 	}
 
 	invoiceRoutes := app.Group("/invoices")
-	invoiceRoutes.Use(middleware.AuthMiddleware())
+	invoiceRoutes.Use(apiKeyAuth)
 	{
-		invoiceRoutes.Post("/", middleware.AdminMiddleware(), invoiceHandler.CreateInvoice)
-		invoiceRoutes.Get("/", invoiceHandler.GetAllInvoices)
-		invoiceRoutes.Get("/:id", invoiceHandler.GetInvoice)
-		invoiceRoutes.Put("/:id", middleware.AdminMiddleware(), invoiceHandler.UpdateInvoice)
-		invoiceRoutes.Delete("/:id", middleware.AdminMiddleware(), invoiceHandler.DeleteInvoice)
+		invoiceRoutes.Post("/", middleware.RequirePermission("invoices:write"), invoiceHandler.CreateInvoice)
+		invoiceRoutes.Get("/", middleware.RequirePermission("invoices:read"), invoiceHandler.GetAllInvoices)
+		invoiceRoutes.Get("/:id", middleware.RequirePermission("invoices:read"), invoiceHandler.GetInvoice)
+		invoiceRoutes.Put("/:id", middleware.RequirePermission("invoices:write"), invoiceHandler.UpdateInvoice)
+		invoiceRoutes.Delete("/:id", middleware.RequirePermission("invoices:delete"), invoiceHandler.DeleteInvoice)
 
-		invoiceRoutes.Patch("/:id/status", middleware.AdminMiddleware(), invoiceHandler.UpdateInvoiceStatus)
+		invoiceRoutes.Patch("/:id/status", middleware.RequirePermission("invoices:write"), invoiceHandler.UpdateInvoiceStatus)
 
-		invoiceRoutes.Get("/:invoiceId/payments", paymentHandler.GetPaymentsByInvoice)
+		invoiceRoutes.Get("/:invoiceId/payments", middleware.RequirePermission("invoices:read"), paymentHandler.GetPaymentsByInvoice)
 	}
 
-	app.Get("/invoices/status/:status", middleware.AuthMiddleware(), invoiceHandler.GetInvoicesByStatus)
+	app.Get("/invoices/status/:status", apiKeyAuth, middleware.RequirePermission("invoices:read"), invoiceHandler.GetInvoicesByStatus)
 
-	customerGroup.Get("/:customerId/invoices", middleware.AuthMiddleware(), invoiceHandler.GetInvoicesByCustomer)
+	customerGroup.Get("/:customerId/invoices", middleware.AuthMiddleware(), middleware.RequirePermission("invoices:read"), invoiceHandler.GetInvoicesByCustomer)
 
 	salespersonRoutes := app.Group("/salespersons")
 	salespersonRoutes.Use(middleware.AuthMiddleware())
 	{
-		salespersonRoutes.Post("/", middleware.AdminMiddleware(), salespersonHandler.CreateSalesperson)
-		salespersonRoutes.Get("/", salespersonHandler.GetAllSalespersons)
-		salespersonRoutes.Get("/:id", salespersonHandler.GetSalesperson)
-		salespersonRoutes.Put("/:id", middleware.AdminMiddleware(), salespersonHandler.UpdateSalesperson)
-		salespersonRoutes.Delete("/:id", middleware.SuperAdminMiddleware(), salespersonHandler.DeleteSalesperson)
+		salespersonRoutes.Post("/", middleware.RequirePermission("salespersons:write"), salespersonHandler.CreateSalesperson)
+		salespersonRoutes.Get("/", middleware.RequirePermission("salespersons:read"), salespersonHandler.GetAllSalespersons)
+		salespersonRoutes.Get("/:id", middleware.RequirePermission("salespersons:read"), salespersonHandler.GetSalesperson)
+		salespersonRoutes.Put("/:id", middleware.RequirePermission("salespersons:write"), salespersonHandler.UpdateSalesperson)
+		salespersonRoutes.Delete("/:id", middleware.RequirePermission("salespersons:delete"), salespersonHandler.DeleteSalesperson)
 	}
 
 	taxRoutes := app.Group("/taxes")
 	taxRoutes.Use(middleware.AuthMiddleware())
 	{
-		taxRoutes.Post("/", middleware.AdminMiddleware(), taxHandler.CreateTax)
-		taxRoutes.Get("/", taxHandler.GetAllTaxes)
-		taxRoutes.Get("/:id", taxHandler.GetTax)
-		taxRoutes.Put("/:id", middleware.AdminMiddleware(), taxHandler.UpdateTax)
-		taxRoutes.Delete("/:id", middleware.SuperAdminMiddleware(), taxHandler.DeleteTax)
+		taxRoutes.Post("/", middleware.RequirePermission("taxes:write"), taxHandler.CreateTax)
+		taxRoutes.Get("/", middleware.RequirePermission("taxes:read"), taxHandler.GetAllTaxes)
+		taxRoutes.Get("/:id", middleware.RequirePermission("taxes:read"), taxHandler.GetTax)
+		taxRoutes.Put("/:id", middleware.RequirePermission("taxes:write"), taxHandler.UpdateTax)
+		taxRoutes.Delete("/:id", middleware.RequirePermission("taxes:delete"), taxHandler.DeleteTax)
 	}
 
 	paymentRoutes := app.Group("/payments")
 	paymentRoutes.Use(middleware.AuthMiddleware())
 	{
-		paymentRoutes.Post("/", middleware.AdminMiddleware(), paymentHandler.CreatePayment)
-		paymentRoutes.Get("/:id", paymentHandler.GetPayment)
-		paymentRoutes.Delete("/:id", middleware.AdminMiddleware(), paymentHandler.DeletePayment)
+		paymentRoutes.Post("/", middleware.RequirePermission("payments:write"), paymentHandler.CreatePayment)
+		paymentRoutes.Get("/:id", middleware.RequirePermission("payments:read"), paymentHandler.GetPayment)
+		paymentRoutes.Delete("/:id", middleware.RequirePermission("payments:delete"), recentAuth, paymentHandler.DeletePayment)
 	}
 	purchaseOrderRoutes := app.Group("/purchase-orders")
 	purchaseOrderRoutes.Use(middleware.AuthMiddleware())
 	{
-		purchaseOrderRoutes.Post("/", middleware.AdminMiddleware(), purchaseOrderHandler.CreatePurchaseOrder)
-		purchaseOrderRoutes.Get("/", purchaseOrderHandler.GetAllPurchaseOrders)
-		purchaseOrderRoutes.Get("/:id", purchaseOrderHandler.GetPurchaseOrder)
-		purchaseOrderRoutes.Put("/:id", middleware.AdminMiddleware(), purchaseOrderHandler.UpdatePurchaseOrder)
-		purchaseOrderRoutes.Delete("/:id", middleware.AdminMiddleware(), purchaseOrderHandler.DeletePurchaseOrder)
+		purchaseOrderRoutes.Post("/", middleware.RequirePermission("purchase-orders:write"), purchaseOrderHandler.CreatePurchaseOrder)
+		purchaseOrderRoutes.Get("/", middleware.RequirePermission("purchase-orders:read"), purchaseOrderHandler.GetAllPurchaseOrders)
+		purchaseOrderRoutes.Get("/:id", middleware.RequirePermission("purchase-orders:read"), purchaseOrderHandler.GetPurchaseOrder)
+		purchaseOrderRoutes.Put("/:id", middleware.RequirePermission("purchase-orders:write"), purchaseOrderHandler.UpdatePurchaseOrder)
+		purchaseOrderRoutes.Delete("/:id", middleware.RequirePermission("purchase-orders:delete"), purchaseOrderHandler.DeletePurchaseOrder)
 
-		purchaseOrderRoutes.Patch("/:id/status", middleware.AdminMiddleware(), purchaseOrderHandler.UpdatePurchaseOrderStatus)
+		purchaseOrderRoutes.Patch("/:id/status", middleware.RequirePermission("purchase-orders:write"), purchaseOrderHandler.UpdatePurchaseOrderStatus)
 
-		purchaseOrderRoutes.Get("/vendor/:vendorId", purchaseOrderHandler.GetPurchaseOrdersByVendor)
-		purchaseOrderRoutes.Get("/customer/:customerId", purchaseOrderHandler.GetPurchaseOrdersByCustomer)
-		purchaseOrderRoutes.Get("/status/:status", purchaseOrderHandler.GetPurchaseOrdersByStatus)
+		purchaseOrderRoutes.Get("/vendor/:vendorId", middleware.RequirePermission("purchase-orders:read"), purchaseOrderHandler.GetPurchaseOrdersByVendor)
+		purchaseOrderRoutes.Get("/customer/:customerId", middleware.RequirePermission("purchase-orders:read"), purchaseOrderHandler.GetPurchaseOrdersByCustomer)
+		purchaseOrderRoutes.Get("/status/:status", middleware.RequirePermission("purchase-orders:read"), purchaseOrderHandler.GetPurchaseOrdersByStatus)
 	}
 
 	salesOrderRoutes := app.Group("/sales-orders")
-	salesOrderRoutes.Use(middleware.AuthMiddleware())
+	salesOrderRoutes.Use(apiKeyAuth)
 	{
-		salesOrderRoutes.Post("/", middleware.AdminMiddleware(), salesOrderHandler.CreateSalesOrder)
-		salesOrderRoutes.Get("/", salesOrderHandler.GetAllSalesOrders)
-		salesOrderRoutes.Get("/:id", salesOrderHandler.GetSalesOrder)
-		salesOrderRoutes.Put("/:id", middleware.AdminMiddleware(), salesOrderHandler.UpdateSalesOrder)
-		salesOrderRoutes.Delete("/:id", middleware.AdminMiddleware(), salesOrderHandler.DeleteSalesOrder)
+		salesOrderRoutes.Post("/", middleware.RequirePermission("sales-orders:write"), salesOrderHandler.CreateSalesOrder)
+		salesOrderRoutes.Get("/", middleware.RequirePermission("sales-orders:read"), salesOrderHandler.GetAllSalesOrders)
+		salesOrderRoutes.Get("/:id", middleware.RequirePermission("sales-orders:read"), salesOrderHandler.GetSalesOrder)
+		salesOrderRoutes.Put("/:id", middleware.RequirePermission("sales-orders:write"), salesOrderHandler.UpdateSalesOrder)
+		salesOrderRoutes.Delete("/:id", middleware.RequirePermission("sales-orders:delete"), salesOrderHandler.DeleteSalesOrder)
 
-		salesOrderRoutes.Patch("/:id/status", middleware.AdminMiddleware(), salesOrderHandler.UpdateSalesOrderStatus)
+		salesOrderRoutes.Patch("/:id/status", middleware.RequirePermission("sales-orders:write"), salesOrderHandler.UpdateSalesOrderStatus)
 
-		salesOrderRoutes.Get("/customer/:customerId", salesOrderHandler.GetSalesOrdersByCustomer)
-		salesOrderRoutes.Get("/status/:status", salesOrderHandler.GetSalesOrdersByStatus)
+		salesOrderRoutes.Get("/customer/:customerId", middleware.RequirePermission("sales-orders:read"), salesOrderHandler.GetSalesOrdersByCustomer)
+		salesOrderRoutes.Get("/status/:status", middleware.RequirePermission("sales-orders:read"), salesOrderHandler.GetSalesOrdersByStatus)
 	}
 
 	packageRoutes := app.Group("/packages")
 	packageRoutes.Use(middleware.AuthMiddleware())
 	{
-		packageRoutes.Post("/", middleware.AdminMiddleware(), packageHandler.CreatePackage)
-		packageRoutes.Get("/", packageHandler.GetAllPackages)
-		packageRoutes.Get("/:id", packageHandler.GetPackage)
-		packageRoutes.Put("/:id", middleware.AdminMiddleware(), packageHandler.UpdatePackage)
-		packageRoutes.Delete("/:id", middleware.AdminMiddleware(), packageHandler.DeletePackage)
+		packageRoutes.Post("/", middleware.RequirePermission("packages:write"), packageHandler.CreatePackage)
+		packageRoutes.Get("/", middleware.RequirePermission("packages:read"), packageHandler.GetAllPackages)
+		packageRoutes.Get("/:id", middleware.RequirePermission("packages:read"), packageHandler.GetPackage)
+		packageRoutes.Put("/:id", middleware.RequirePermission("packages:write"), packageHandler.UpdatePackage)
+		packageRoutes.Delete("/:id", middleware.RequirePermission("packages:delete"), packageHandler.DeletePackage)
 
-		packageRoutes.Patch("/:id/status", middleware.AdminMiddleware(), packageHandler.UpdatePackageStatus)
+		packageRoutes.Patch("/:id/status", middleware.RequirePermission("packages:write"), packageHandler.UpdatePackageStatus)
 
-		packageRoutes.Get("/customer/:customer_id", packageHandler.GetPackagesByCustomer)
-		packageRoutes.Get("/sales-order/:sales_order_id", packageHandler.GetPackagesBySalesOrder)
-		packageRoutes.Get("/status/:status", packageHandler.GetPackagesByStatus)
+		packageRoutes.Get("/customer/:customer_id", middleware.RequirePermission("packages:read"), packageHandler.GetPackagesByCustomer)
+		packageRoutes.Get("/sales-order/:sales_order_id", middleware.RequirePermission("packages:read"), packageHandler.GetPackagesBySalesOrder)
+		packageRoutes.Get("/status/:status", middleware.RequirePermission("packages:read"), packageHandler.GetPackagesByStatus)
 	}
 
 	shipmentRoutes := app.Group("/shipments")
 	shipmentRoutes.Use(middleware.AuthMiddleware())
 	{
-		shipmentRoutes.Post("/", middleware.AdminMiddleware(), shipmentHandler.CreateShipment)
-		shipmentRoutes.Get("/", shipmentHandler.GetAllShipments)
-		shipmentRoutes.Get("/:id", shipmentHandler.GetShipment)
-		shipmentRoutes.Put("/:id", middleware.AdminMiddleware(), shipmentHandler.UpdateShipment)
-		shipmentRoutes.Delete("/:id", middleware.AdminMiddleware(), shipmentHandler.DeleteShipment)
-
-		shipmentRoutes.Patch("/:id/status", middleware.AdminMiddleware(), shipmentHandler.UpdateShipmentStatus)
-
-		shipmentRoutes.Get("/customer/:customer_id", shipmentHandler.GetShipmentsByCustomer)
-		shipmentRoutes.Get("/package/:package_id", shipmentHandler.GetShipmentsByPackage)
-		shipmentRoutes.Get("/sales-order/:sales_order_id", shipmentHandler.GetShipmentsBySalesOrder)
-		shipmentRoutes.Get("/status/:status", shipmentHandler.GetShipmentsByStatus)
+		shipmentRoutes.Post("/", middleware.RequirePermission("shipments:write"), shipmentHandler.CreateShipment)
+		shipmentRoutes.Get("/", middleware.RequirePermission("shipments:read"), shipmentHandler.GetAllShipments)
+		shipmentRoutes.Get("/:id", middleware.RequirePermission("shipments:read"), shipmentHandler.GetShipment)
+		shipmentRoutes.Put("/:id", middleware.RequirePermission("shipments:write"), shipmentHandler.UpdateShipment)
+		shipmentRoutes.Delete("/:id", middleware.RequirePermission("shipments:delete"), shipmentHandler.DeleteShipment)
+
+		shipmentRoutes.Patch("/:id/status", middleware.RequirePermission("shipments:write"), shipmentHandler.UpdateShipmentStatus)
+
+		shipmentRoutes.Get("/customer/:customer_id", middleware.RequirePermission("shipments:read"), shipmentHandler.GetShipmentsByCustomer)
+		shipmentRoutes.Get("/package/:package_id", middleware.RequirePermission("shipments:read"), shipmentHandler.GetShipmentsByPackage)
+		shipmentRoutes.Get("/sales-order/:sales_order_id", middleware.RequirePermission("shipments:read"), shipmentHandler.GetShipmentsBySalesOrder)
+		shipmentRoutes.Get("/status/:status", middleware.RequirePermission("shipments:read"), shipmentHandler.GetShipmentsByStatus)
 	}
 
 	billRoutes := app.Group("/bills")
 	billRoutes.Use(middleware.AuthMiddleware())
 	{
-		billRoutes.Post("/", middleware.AdminMiddleware(), billHandler.CreateBill)
-		billRoutes.Get("/", billHandler.GetAllBills)
-		billRoutes.Get("/:id", billHandler.GetBill)
-		billRoutes.Put("/:id", middleware.AdminMiddleware(), billHandler.UpdateBill)
-		billRoutes.Delete("/:id", middleware.AdminMiddleware(), billHandler.DeleteBill)
+		billRoutes.Post("/", middleware.RequirePermission("bills:write"), billHandler.CreateBill)
+		billRoutes.Get("/", middleware.RequirePermission("bills:read"), billHandler.GetAllBills)
+		billRoutes.Get("/:id", middleware.RequirePermission("bills:read"), billHandler.GetBill)
+		billRoutes.Put("/:id", middleware.RequirePermission("bills:write"), billHandler.UpdateBill)
+		billRoutes.Delete("/:id", middleware.RequirePermission("bills:delete"), billHandler.DeleteBill)
 
-		billRoutes.Patch("/:id/status", middleware.AdminMiddleware(), billHandler.UpdateBillStatus)
+		billRoutes.Patch("/:id/status", middleware.RequirePermission("bills:write"), billHandler.UpdateBillStatus)
 
-		billRoutes.Get("/vendor/:vendorId", billHandler.GetBillsByVendor)
-		billRoutes.Get("/status/:status", billHandler.GetBillsByStatus)
+		billRoutes.Get("/vendor/:vendorId", middleware.RequirePermission("bills:read"), billHandler.GetBillsByVendor)
+		billRoutes.Get("/status/:status", middleware.RequirePermission("bills:read"), billHandler.GetBillsByStatus)
 	}
 
 	productionOrderRoutes := app.Group("/production-orders")
 	productionOrderRoutes.Use(middleware.AuthMiddleware())
 	{
-		productionOrderRoutes.Post("/", middleware.AdminMiddleware(), productionOrderHandler.CreateProductionOrder)
-		productionOrderRoutes.Get("/", productionOrderHandler.GetAllProductionOrders)
-		productionOrderRoutes.Get("/:id", productionOrderHandler.GetProductionOrderByID)
-		productionOrderRoutes.Put("/:id", middleware.AdminMiddleware(), productionOrderHandler.UpdateProductionOrder)
-		productionOrderRoutes.Delete("/:id", middleware.AdminMiddleware(), productionOrderHandler.DeleteProductionOrder)
-		productionOrderRoutes.Post("/:id/consume-item", middleware.AdminMiddleware(), productionOrderHandler.ConsumeProductionOrderItem)
+		productionOrderRoutes.Post("/", middleware.RequirePermission("production-orders:write"), productionOrderHandler.CreateProductionOrder)
+		productionOrderRoutes.Get("/", middleware.RequirePermission("production-orders:read"), productionOrderHandler.GetAllProductionOrders)
+		productionOrderRoutes.Get("/:id", middleware.RequirePermission("production-orders:read"), productionOrderHandler.GetProductionOrderByID)
+		productionOrderRoutes.Put("/:id", middleware.RequirePermission("production-orders:write"), productionOrderHandler.UpdateProductionOrder)
+		productionOrderRoutes.Delete("/:id", middleware.RequirePermission("production-orders:delete"), productionOrderHandler.DeleteProductionOrder)
+		productionOrderRoutes.Post("/:id/consume-item", middleware.RequirePermission("production-orders:write"), productionOrderHandler.ConsumeProductionOrderItem)
 	}
 
 	app.Get("/health", func(c *fiber.Ctx) error {
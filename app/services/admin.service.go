@@ -14,21 +14,42 @@ import (
 )
 
 type adminService struct {
-	userRepo repo.UserRepository
-	roleRepo repo.RoleRepository
+	userRepo          repo.UserRepository
+	roleRepo          repo.RoleRepository
+	revocationService RevocationService
+	passwordPolicy    PasswordPolicyService
+	authService       AuthService
+	invitationService InvitationService
+	auditService      AuditService
 }
 
 func NewAdminService(
 	userRepo repo.UserRepository,
 	roleRepo repo.RoleRepository,
+	revocationService RevocationService,
+	passwordPolicy PasswordPolicyService,
+	authService AuthService,
+	invitationService InvitationService,
+	auditService AuditService,
 ) AdminService {
 	return &adminService{
-		userRepo: userRepo,
-		roleRepo: roleRepo,
+		userRepo:          userRepo,
+		roleRepo:          roleRepo,
+		revocationService: revocationService,
+		passwordPolicy:    passwordPolicy,
+		authService:       authService,
+		invitationService: invitationService,
+		auditService:      auditService,
 	}
 }
 
 func (s *adminService) CreateUser(ctx context.Context, createdBy uint, req *input.CreateUserRequest) (*output.UserInfo, error) {
+	info, err := s.createUser(ctx, createdBy, req)
+	s.recordCreate(ctx, req.Email, req.UserType, info, err)
+	return info, err
+}
+
+func (s *adminService) createUser(ctx context.Context, createdBy uint, req *input.CreateUserRequest) (*output.UserInfo, error) {
 	if req.UserType != "admin" && req.UserType != "partner" {
 		return nil, errors.New("invalid user type")
 	}
@@ -43,26 +64,26 @@ func (s *adminService) CreateUser(ctx context.Context, createdBy uint, req *inpu
 		return nil, errors.New("invalid role name")
 	}
 
-	passwordHash, err := utils.HashPassword(req.Password)
-	if err != nil {
-		return nil, err
-	}
-
+	// The account stays pending, without a password, until the invitee
+	// accepts the emailed invitation.
 	user := &models.User{
-		Email:        &req.Email,
-		Username:     &req.Username,
-		PasswordHash: &passwordHash,
-		UserType:     models.UserType(req.UserType),
-		RoleID:       role.ID,
-		Status:       models.UserStatusActive,
-		Phone:        &req.Phone,
-		CreatedBy:    &createdBy,
+		Email:     &req.Email,
+		Username:  &req.Username,
+		UserType:  models.UserType(req.UserType),
+		RoleID:    role.ID,
+		Status:    models.UserStatusPending,
+		Phone:     &req.Phone,
+		CreatedBy: &createdBy,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, err
 	}
 
+	if err := s.invitationService.Invite(ctx, user, createdBy); err != nil {
+		return nil, err
+	}
+
 	return &output.UserInfo{
 		ID:        user.ID,
 		Email:     user.Email,
@@ -76,6 +97,12 @@ func (s *adminService) CreateUser(ctx context.Context, createdBy uint, req *inpu
 }
 
 func (s *adminService) CreateSuperAdmin(ctx context.Context, createdBy *uint, req *input.CreateSuperAdminRequest) (*output.UserInfo, error) {
+	info, err := s.createSuperAdmin(ctx, createdBy, req)
+	s.recordCreate(ctx, req.Email, string(models.UserTypeSuperAdmin), info, err)
+	return info, err
+}
+
+func (s *adminService) createSuperAdmin(ctx context.Context, createdBy *uint, req *input.CreateSuperAdminRequest) (*output.UserInfo, error) {
 	existingUser, err := s.userRepo.GetByEmail(req.Email)
 	if err == nil && existingUser != nil {
 		return nil, errors.New("user already exists with this email")
@@ -86,7 +113,7 @@ func (s *adminService) CreateSuperAdmin(ctx context.Context, createdBy *uint, re
 		return nil, errors.New("invalid role name")
 	}
 
-	passwordHash, err := utils.HashPassword(req.Password)
+	passwordHash, err := s.passwordPolicy.HashPassword(nil, req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -105,6 +132,7 @@ func (s *adminService) CreateSuperAdmin(ctx context.Context, createdBy *uint, re
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, err
 	}
+	s.passwordPolicy.RecordPassword(user.ID, passwordHash)
 
 	return &output.UserInfo{
 		ID:        user.ID,
@@ -119,6 +147,12 @@ func (s *adminService) CreateSuperAdmin(ctx context.Context, createdBy *uint, re
 }
 
 func (s *adminService) ResetPassword(ctx context.Context, req *input.ResetPasswordRequest) error {
+	err := s.resetPassword(ctx, req)
+	s.record(ctx, models.AuthEventAdminPasswordReset, req.UserID, "", err)
+	return err
+}
+
+func (s *adminService) resetPassword(ctx context.Context, req *input.ResetPasswordRequest) error {
 	user, err := s.userRepo.GetByID(req.UserID)
 	if err != nil {
 		return errors.New("user not found")
@@ -128,7 +162,7 @@ func (s *adminService) ResetPassword(ctx context.Context, req *input.ResetPasswo
 		return errors.New("cannot reset password for mobile users")
 	}
 
-	passwordHash, err := utils.HashPassword(req.NewPassword)
+	passwordHash, err := s.passwordPolicy.HashPassword(user, req.NewPassword)
 	if err != nil {
 		return err
 	}
@@ -139,11 +173,18 @@ func (s *adminService) ResetPassword(ctx context.Context, req *input.ResetPasswo
 	}
 
 	s.userRepo.UpdatePasswordChangedAt(req.UserID)
+	s.passwordPolicy.RecordPassword(req.UserID, passwordHash)
 
 	return nil
 }
 
 func (s *adminService) ResetUserPassword(ctx context.Context, req *input.ResetUserPasswordRequest, userID uint64) error {
+	err := s.resetUserPassword(ctx, req, userID)
+	s.record(ctx, models.AuthEventAdminPasswordReset, uint(userID), "", err)
+	return err
+}
+
+func (s *adminService) resetUserPassword(ctx context.Context, req *input.ResetUserPasswordRequest, userID uint64) error {
 	user, err := s.userRepo.GetByID(uint(userID))
 	if err != nil {
 		return errors.New("user not found")
@@ -153,11 +194,11 @@ func (s *adminService) ResetUserPassword(ctx context.Context, req *input.ResetUs
 		return errors.New("cannot reset password for mobile users")
 	}
 
-	if !utils.CheckPassword(*user.PasswordHash, req.OldPassword) {
+	if user.PasswordHash == nil || !utils.CheckPassword(req.OldPassword, *user.PasswordHash) {
 		return errors.New("current password is incorrect")
 	}
 
-	passwordHash, err := utils.HashPassword(req.NewPassword)
+	passwordHash, err := s.passwordPolicy.HashPassword(user, req.NewPassword)
 	if err != nil {
 		return err
 	}
@@ -168,6 +209,7 @@ func (s *adminService) ResetUserPassword(ctx context.Context, req *input.ResetUs
 	}
 
 	s.userRepo.UpdatePasswordChangedAt(uint(userID))
+	s.passwordPolicy.RecordPassword(uint(userID), passwordHash)
 
 	return nil
 }
@@ -200,6 +242,7 @@ func (s *adminService) GetUsers(ctx context.Context, page, limit int, search str
 			CreatedAt:   user.CreatedAt,
 			CreatedBy:   user.CreatedBy,
 			LastLoginAt: user.LastLoginAt,
+			LockedUntil: user.LockedUntil,
 		}
 	}
 
@@ -224,30 +267,45 @@ func (s *adminService) GetUser(ctx context.Context, userID uint) (*output.UserIn
 	}
 
 	return &output.UserInfo{
-		ID:          user.ID,
-		Email:       user.Email,
-		Phone:       user.Phone,
-		Username:    user.Username,
-		UserType:    string(user.UserType),
-		Role:        user.Role.RoleName,
-		Status:      string(user.Status),
-		CreatedAt:   user.CreatedAt,
-		LastLoginAt: user.LastLoginAt,
+		ID:           user.ID,
+		Email:        user.Email,
+		Phone:        user.Phone,
+		Username:     user.Username,
+		UserType:     string(user.UserType),
+		Role:         user.Role.RoleName,
+		Status:       string(user.Status),
+		CreatedAt:    user.CreatedAt,
+		LastLoginAt:  user.LastLoginAt,
+		LockedUntil:  user.LockedUntil,
+		PendingEmail: user.PendingEmail,
+		PendingPhone: user.PendingPhone,
 	}, nil
 }
 
 func (s *adminService) UpdateUser(ctx context.Context, userID uint, req *input.UpdateUserRequest) (*output.UserInfo, error) {
+	info, err := s.updateUser(ctx, userID, req)
+	s.record(ctx, models.AuthEventAdminUserUpdate, userID, "", err)
+	return info, err
+}
+
+func (s *adminService) updateUser(ctx context.Context, userID uint, req *input.UpdateUserRequest) (*output.UserInfo, error) {
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, errors.New("user not found")
 	}
 
-	if req.Email != nil {
-		user.Email = req.Email
+	// Contact changes wait for the owner to confirm them by OTP.
+	var contactChanges []input.ChangeContactRequest
+	if req.Email != nil && (user.Email == nil || *user.Email != *req.Email) {
+		contactChanges = append(contactChanges, input.ChangeContactRequest{Email: *req.Email})
+	}
+	if req.Phone != nil && (user.Phone == nil || *user.Phone != *req.Phone) {
+		contactChanges = append(contactChanges, input.ChangeContactRequest{Phone: *req.Phone})
 	}
 	if req.Username != nil {
 		user.Username = req.Username
 	}
+	previousRoleID, previousStatus := user.RoleID, user.Status
 	if req.RoleName != nil {
 		role, err := s.roleRepo.GetByName(*req.RoleName)
 		if err != nil {
@@ -258,33 +316,51 @@ func (s *adminService) UpdateUser(ctx context.Context, userID uint, req *input.U
 	if req.Status != nil {
 		user.Status = models.UserStatus(*req.Status)
 	}
-	if req.Phone != nil {
-		user.Phone = req.Phone
-	}
 
 	if err := s.userRepo.Update(user); err != nil {
 		return nil, err
 	}
 
+	for _, change := range contactChanges {
+		if _, err := s.authService.RequestContactChange(ctx, userID, &change); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.RoleID != previousRoleID || user.Status != previousStatus {
+		if err := s.revocationService.RevokeUser(userID, "user_updated"); err != nil {
+			return nil, err
+		}
+	}
+
 	updatedUser, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, err
 	}
 
 	return &output.UserInfo{
-		ID:          updatedUser.ID,
-		Email:       updatedUser.Email,
-		Phone:       updatedUser.Phone,
-		Username:    updatedUser.Username,
-		UserType:    string(updatedUser.UserType),
-		Role:        updatedUser.Role.RoleName,
-		Status:      string(updatedUser.Status),
-		CreatedAt:   updatedUser.CreatedAt,
-		LastLoginAt: updatedUser.LastLoginAt,
+		ID:           updatedUser.ID,
+		Email:        updatedUser.Email,
+		Phone:        updatedUser.Phone,
+		Username:     updatedUser.Username,
+		UserType:     string(updatedUser.UserType),
+		Role:         updatedUser.Role.RoleName,
+		Status:       string(updatedUser.Status),
+		CreatedAt:    updatedUser.CreatedAt,
+		LastLoginAt:  updatedUser.LastLoginAt,
+		LockedUntil:  updatedUser.LockedUntil,
+		PendingEmail: updatedUser.PendingEmail,
+		PendingPhone: updatedUser.PendingPhone,
 	}, nil
 }
 
 func (s *adminService) DeleteUser(ctx context.Context, userID uint) error {
+	err := s.deleteUser(ctx, userID)
+	s.record(ctx, models.AuthEventAdminUserDelete, userID, "", err)
+	return err
+}
+
+func (s *adminService) deleteUser(ctx context.Context, userID uint) error {
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return errors.New("user not found")
@@ -294,20 +370,46 @@ func (s *adminService) DeleteUser(ctx context.Context, userID uint) error {
 		return errors.New("cannot delete superadmin user")
 	}
 
-	return s.userRepo.Delete(userID)
+	if err := s.userRepo.Delete(userID); err != nil {
+		return err
+	}
+
+	return s.revocationService.RevokeUser(userID, "user_deleted")
 }
 
 func (s *adminService) UpdateUserStatus(ctx context.Context, userID uint, status string) error {
+	err := s.updateUserStatus(ctx, userID, status)
+	s.record(ctx, models.AuthEventAdminStatusChange, userID, "status="+status, err)
+	return err
+}
+
+func (s *adminService) updateUserStatus(ctx context.Context, userID uint, status string) error {
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return errors.New("user not found")
 	}
 
+	if user.Status == models.UserStatus(status) {
+		return nil
+	}
+
 	user.Status = models.UserStatus(status)
-	return s.userRepo.Update(user)
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	// Tokens carry no status claim, so existing ones must be revoked for a
+	// deactivation to take effect before they expire.
+	return s.revocationService.RevokeUser(userID, "status_changed")
 }
 
 func (s *adminService) UpdateUserRole(ctx context.Context, userID uint, roleName string) error {
+	err := s.updateUserRole(ctx, userID, roleName)
+	s.record(ctx, models.AuthEventAdminRoleChange, userID, "role="+roleName, err)
+	return err
+}
+
+func (s *adminService) updateUserRole(ctx context.Context, userID uint, roleName string) error {
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return errors.New("user not found")
@@ -318,8 +420,51 @@ func (s *adminService) UpdateUserRole(ctx context.Context, userID uint, roleName
 		return errors.New("invalid role name")
 	}
 
+	if !role.IsActive {
+		return errors.New("role is inactive")
+	}
+
+	if user.RoleID == role.ID {
+		return nil
+	}
+
 	user.RoleID = role.ID
-	return s.userRepo.Update(user)
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	// Access tokens embed the role, so make the user pick up a fresh one.
+	return s.revocationService.RevokeUser(userID, "role_changed")
+}
+
+// UnlockUser lifts a lockout caused by failed logins before it expires.
+func (s *adminService) UnlockUser(ctx context.Context, userID uint) error {
+	err := s.unlockUser(userID)
+	s.record(ctx, models.AuthEventAdminUnlock, userID, "", err)
+	return err
+}
+
+func (s *adminService) unlockUser(userID uint) error {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return errors.New("user not found")
+	}
+
+	return s.userRepo.ClearLoginFailures(userID)
+}
+
+// record audits an admin action on the user userID.
+func (s *adminService) record(ctx context.Context, eventType models.AuthEventType, userID uint, reason string, err error) {
+	s.auditService.Record(ctx, models.AuthEvent{Type: eventType, SubjectID: &userID, Reason: reason}, err)
+}
+
+// recordCreate audits an account creation, against the new account when it
+// was created.
+func (s *adminService) recordCreate(ctx context.Context, email, userType string, info *output.UserInfo, err error) {
+	event := models.AuthEvent{Type: models.AuthEventAdminUserCreate, Identifier: email, Reason: "type=" + userType}
+	if info != nil {
+		event.SubjectID = &info.ID
+	}
+	s.auditService.Record(ctx, event, err)
 }
 
 func (s *adminService) GetDashboardStats(ctx context.Context, filter *input.DashboardStatsFilter) (*output.DashboardStatsResponse, error) {
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID uint, req *input.CreateAPIKeyRequest) (*output.APIKeyResponse, error)
+	GetAPIKeys(ctx context.Context, userID uint) ([]output.APIKeyResponse, error)
+	RotateAPIKey(ctx context.Context, userID, keyID uint) (*output.APIKeyResponse, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID uint) error
+	Authenticate(key, ip string) (*output.Claims, error)
+}
+
+type apiKeyService struct {
+	apiKeyRepo repo.APIKeyRepository
+	userRepo   repo.UserRepository
+}
+
+const (
+	apiKeyPrefix     = "bbk_"
+	apiKeyDefaultTTL = 90 * 24 * time.Hour
+	apiKeyMaxTTL     = 365 * 24 * time.Hour
+)
+
+// apiKeyResources are the APIs partners integrate against with API keys.
+var apiKeyResources = []string{"sales-orders", "items", "invoices"}
+
+func NewAPIKeyService(apiKeyRepo repo.APIKeyRepository, userRepo repo.UserRepository) APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+	}
+}
+
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, userID uint, req *input.CreateAPIKeyRequest) (*output.APIKeyResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	if err := validateAPIKeyScopes(user, req.Scopes); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(apiKeyDefaultTTL)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(time.Now()) {
+		return nil, utils.NewBadRequestError("expires_at must be in the future")
+	}
+	if expiresAt.After(time.Now().Add(apiKeyMaxTTL)) {
+		return nil, utils.NewBadRequestError("expires_at must be within one year")
+	}
+
+	record, key, err := newAPIKey(userID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.apiKeyRepo.Create(record); err != nil {
+		return nil, err
+	}
+
+	resp := newAPIKeyResponse(record)
+	resp.Key = key
+	return &resp, nil
+}
+
+func (s *apiKeyService) GetAPIKeys(ctx context.Context, userID uint) ([]output.APIKeyResponse, error) {
+	keys, err := s.apiKeyRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]output.APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		resp = append(resp, newAPIKeyResponse(&keys[i]))
+	}
+	return resp, nil
+}
+
+// RotateAPIKey replaces a key with a new secret carrying the same name, scopes
+// and expiry, and revokes the old one.
+func (s *apiKeyService) RotateAPIKey(ctx context.Context, userID, keyID uint) (*output.APIKeyResponse, error) {
+	existing, err := s.getOwnedKey(userID, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing.RevokedAt != nil {
+		return nil, utils.NewBadRequestError("API key has been revoked")
+	}
+	if time.Now().After(existing.ExpiresAt) {
+		return nil, utils.NewBadRequestError("API key has expired")
+	}
+
+	record, key, err := newAPIKey(userID, existing.Name, existing.Scopes, existing.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.apiKeyRepo.Rotate(existing.ID, record); err != nil {
+		return nil, err
+	}
+
+	resp := newAPIKeyResponse(record)
+	resp.Key = key
+	return &resp, nil
+}
+
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, userID, keyID uint) error {
+	if _, err := s.getOwnedKey(userID, keyID); err != nil {
+		return err
+	}
+
+	return s.apiKeyRepo.Revoke(keyID)
+}
+
+// Authenticate resolves an API key to its owner's claims, with the key's
+// scopes in place of the owner's full access.
+func (s *apiKeyService) Authenticate(key, ip string) (*output.Claims, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, utils.NewUnauthorizedError("invalid API key")
+	}
+
+	record, err := s.apiKeyRepo.GetByKeyHash(utils.HashToken(key))
+	if err != nil {
+		return nil, utils.NewUnauthorizedError("invalid API key")
+	}
+
+	if record.RevokedAt != nil {
+		return nil, utils.NewUnauthorizedError("API key has been revoked")
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, utils.NewUnauthorizedError("API key has expired")
+	}
+
+	user := record.User
+	if user.ID == 0 || user.Status != models.UserStatusActive {
+		return nil, utils.NewUnauthorizedError("API key owner is not active")
+	}
+
+	go func() {
+		if err := s.apiKeyRepo.UpdateLastUsed(record.ID, ip); err != nil {
+			log.Printf("Failed to record use of API key %s: %v", record.Prefix, err)
+		}
+	}()
+
+	claims := &output.Claims{
+		UserID:       user.ID,
+		UserType:     string(user.UserType),
+		Role:         user.Role.RoleName,
+		IdentityType: "api_key",
+		Scope:        strings.Join(record.Scopes, " "),
+	}
+	if user.Email != nil {
+		claims.Email = *user.Email
+	}
+	if user.Phone != nil {
+		claims.Phone = *user.Phone
+	}
+	return claims, nil
+}
+
+func (s *apiKeyService) getOwnedKey(userID, keyID uint) (*models.APIKey, error) {
+	key, err := s.apiKeyRepo.GetByID(keyID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewNotFoundError("API key not found")
+		}
+		return nil, err
+	}
+
+	if key.UserID != userID {
+		return nil, utils.NewNotFoundError("API key not found")
+	}
+
+	return key, nil
+}
+
+// newAPIKey generates a key of the form bbk_<prefix>_<secret>. The prefix is
+// stored in clear so keys can be told apart without revealing them.
+func newAPIKey(userID uint, name string, scopes []string, expiresAt time.Time) (*models.APIKey, string, error) {
+	id, err := utils.GenerateRandomString(8)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := utils.GenerateRandomString(40)
+	if err != nil {
+		return nil, "", err
+	}
+
+	prefix := apiKeyPrefix + id
+	key := prefix + "_" + secret
+
+	return &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   utils.HashToken(key),
+		Scopes:    models.StringArray(scopes),
+		ExpiresAt: expiresAt,
+	}, key, nil
+}
+
+// validateAPIKeyScopes accepts "<resource>:read|write|*" for the API key
+// resources. Partners can only read, matching what their user type may do.
+func validateAPIKeyScopes(user *models.User, scopes []string) error {
+	for _, scope := range scopes {
+		resource, action, ok := strings.Cut(scope, ":")
+		if !ok || !containsString(apiKeyResources, resource) {
+			return utils.NewBadRequestError("invalid scope: " + scope)
+		}
+
+		switch action {
+		case "read":
+		case "write", "*":
+			if user.UserType != models.UserTypeAdmin && user.UserType != models.UserTypeSuperAdmin {
+				return utils.NewForbiddenError("only admins can create API keys with write access")
+			}
+		default:
+			return utils.NewBadRequestError("invalid scope: " + scope)
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func newAPIKeyResponse(key *models.APIKey) output.APIKeyResponse {
+	return output.APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+
+	"gorm.io/gorm"
+)
+
+// fakeAPIKeyRepo keeps API keys in memory and preloads their owner from
+// users, as the real repository does. Uses are reported on used because
+// Authenticate records them in the background.
+type fakeAPIKeyRepo struct {
+	repo.APIKeyRepository
+	mu     sync.Mutex
+	keys   map[uint]*models.APIKey
+	users  map[uint]*models.User
+	nextID uint
+	used   chan uint
+}
+
+func (r *fakeAPIKeyRepo) Create(key *models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	key.ID = r.nextID
+	stored := *key
+	r.keys[key.ID] = &stored
+	return nil
+}
+
+func (r *fakeAPIKeyRepo) GetByID(id uint) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *key
+	return &found, nil
+}
+
+func (r *fakeAPIKeyRepo) GetByKeyHash(keyHash string) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.keys {
+		if key.KeyHash == keyHash {
+			found := *key
+			if owner, ok := r.users[key.UserID]; ok {
+				found.User = *owner
+			}
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeAPIKeyRepo) Revoke(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.keys[id].RevokedAt = &now
+	return nil
+}
+
+func (r *fakeAPIKeyRepo) Rotate(oldID uint, next *models.APIKey) error {
+	if err := r.Revoke(oldID); err != nil {
+		return err
+	}
+	return r.Create(next)
+}
+
+func (r *fakeAPIKeyRepo) UpdateLastUsed(id uint, ip string) error {
+	r.mu.Lock()
+	now := time.Now()
+	r.keys[id].LastUsedAt = &now
+	r.keys[id].LastUsedIP = ip
+	r.mu.Unlock()
+
+	r.used <- id
+	return nil
+}
+
+func newTestAPIKeyService(users map[uint]*models.User) (*apiKeyService, *fakeAPIKeyRepo) {
+	keys := &fakeAPIKeyRepo{keys: make(map[uint]*models.APIKey), users: users, used: make(chan uint, 8)}
+	return NewAPIKeyService(keys, &fakeUserRepo{users: users}).(*apiKeyService), keys
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	partner := &models.User{ID: 3, UserType: models.UserTypePartner, Status: models.UserStatusActive, Role: models.Role{RoleName: "partner"}}
+	inactive := &models.User{ID: 4, UserType: models.UserTypePartner, Status: models.UserStatusInactive}
+	users := map[uint]*models.User{partner.ID: partner, inactive.ID: inactive}
+
+	tests := []struct {
+		name string
+		// prepare returns the key to present after a key was created for
+		// owner.
+		owner      uint
+		prepare    func(keys *fakeAPIKeyRepo, id uint, key string) string
+		wantStatus int
+	}{
+		{
+			name:       "valid key",
+			owner:      partner.ID,
+			prepare:    func(keys *fakeAPIKeyRepo, id uint, key string) string { return key },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing prefix",
+			owner:      partner.ID,
+			prepare:    func(keys *fakeAPIKeyRepo, id uint, key string) string { return key[len(apiKeyPrefix):] },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unknown key",
+			owner:      partner.ID,
+			prepare:    func(keys *fakeAPIKeyRepo, id uint, key string) string { return key + "x" },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:  "revoked key",
+			owner: partner.ID,
+			prepare: func(keys *fakeAPIKeyRepo, id uint, key string) string {
+				keys.Revoke(id)
+				return key
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:  "expired key",
+			owner: partner.ID,
+			prepare: func(keys *fakeAPIKeyRepo, id uint, key string) string {
+				keys.keys[id].ExpiresAt = time.Now().Add(-time.Minute)
+				return key
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "inactive owner",
+			owner:      inactive.ID,
+			prepare:    func(keys *fakeAPIKeyRepo, id uint, key string) string { return key },
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, keys := newTestAPIKeyService(users)
+
+			created, err := s.CreateAPIKey(context.Background(), tt.owner, &input.CreateAPIKeyRequest{Name: "erp", Scopes: []string{"items:read"}})
+			if err != nil {
+				t.Fatalf("CreateAPIKey: %v", err)
+			}
+			if keys.keys[created.ID].KeyHash == created.Key {
+				t.Fatal("API key stored in plain text")
+			}
+
+			claims, err := s.Authenticate(tt.prepare(keys, created.ID, created.Key), "203.0.113.7")
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if claims.UserID != tt.owner || claims.IdentityType != "api_key" || claims.Scope != "items:read" {
+				t.Errorf("claims = %+v, want the owner's with scope items:read", claims)
+			}
+			select {
+			case <-keys.used:
+				if ip := keys.keys[created.ID].LastUsedIP; ip != "203.0.113.7" {
+					t.Errorf("last used IP = %q", ip)
+				}
+			case <-time.After(time.Second):
+				t.Error("use of the key was not recorded")
+			}
+		})
+	}
+}
+
+func TestCreateAPIKeyValidation(t *testing.T) {
+	admin := &models.User{ID: 1, UserType: models.UserTypeAdmin, Status: models.UserStatusActive}
+	partner := &models.User{ID: 3, UserType: models.UserTypePartner, Status: models.UserStatusActive}
+	users := map[uint]*models.User{admin.ID: admin, partner.ID: partner}
+
+	past := time.Now().Add(-time.Hour)
+	tooLate := time.Now().Add(2 * apiKeyMaxTTL)
+
+	tests := []struct {
+		name       string
+		userID     uint
+		scopes     []string
+		expiresAt  *time.Time
+		wantStatus int
+	}{
+		{name: "partner reads", userID: partner.ID, scopes: []string{"items:read", "invoices:read"}, wantStatus: http.StatusOK},
+		{name: "partner writes", userID: partner.ID, scopes: []string{"items:write"}, wantStatus: http.StatusForbidden},
+		{name: "partner wildcard", userID: partner.ID, scopes: []string{"items:*"}, wantStatus: http.StatusForbidden},
+		{name: "admin writes", userID: admin.ID, scopes: []string{"sales-orders:write", "items:*"}, wantStatus: http.StatusOK},
+		{name: "unknown resource", userID: admin.ID, scopes: []string{"users:read"}, wantStatus: http.StatusBadRequest},
+		{name: "unknown action", userID: admin.ID, scopes: []string{"items:delete"}, wantStatus: http.StatusBadRequest},
+		{name: "scope without action", userID: admin.ID, scopes: []string{"items"}, wantStatus: http.StatusBadRequest},
+		{name: "expiry in the past", userID: admin.ID, scopes: []string{"items:read"}, expiresAt: &past, wantStatus: http.StatusBadRequest},
+		{name: "expiry beyond a year", userID: admin.ID, scopes: []string{"items:read"}, expiresAt: &tooLate, wantStatus: http.StatusBadRequest},
+		{name: "unknown user", userID: 99, scopes: []string{"items:read"}, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestAPIKeyService(users)
+
+			_, err := s.CreateAPIKey(context.Background(), tt.userID, &input.CreateAPIKeyRequest{Name: "erp", Scopes: tt.scopes, ExpiresAt: tt.expiresAt})
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+		})
+	}
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	partner := &models.User{ID: 3, UserType: models.UserTypePartner, Status: models.UserStatusActive}
+	s, keys := newTestAPIKeyService(map[uint]*models.User{partner.ID: partner})
+
+	created, err := s.CreateAPIKey(context.Background(), partner.ID, &input.CreateAPIKeyRequest{Name: "erp", Scopes: []string{"items:read"}})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	if _, err := s.RotateAPIKey(context.Background(), 99, created.ID); statusOf(err) != http.StatusNotFound {
+		t.Errorf("rotating another user's key: %v, want 404", err)
+	}
+
+	rotated, err := s.RotateAPIKey(context.Background(), partner.ID, created.ID)
+	if err != nil {
+		t.Fatalf("RotateAPIKey: %v", err)
+	}
+	if rotated.Key == created.Key || !rotated.ExpiresAt.Equal(created.ExpiresAt) {
+		t.Errorf("rotated key = %+v, want a new secret with the same expiry", rotated)
+	}
+
+	if _, err := s.Authenticate(created.Key, ""); statusOf(err) != http.StatusUnauthorized {
+		t.Errorf("old key after rotation: %v, want 401", err)
+	}
+	if _, err := s.Authenticate(rotated.Key, ""); err != nil {
+		t.Errorf("new key after rotation: %v", err)
+	}
+	<-keys.used
+
+	if _, err := s.RotateAPIKey(context.Background(), partner.ID, created.ID); statusOf(err) != http.StatusBadRequest {
+		t.Errorf("rotating a revoked key: %v, want 400", err)
+	}
+}
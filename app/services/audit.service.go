@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditService interface {
+	// Record appends event to the audit log with the caller's IP address and
+	// user agent. Unless set, the actor is the signed-in caller, or the
+	// subject of a successful sign-in, and the impersonator is taken from the
+	// caller's token. A non-nil err marks the event failed and is added to
+	// its reason. Errors writing the log are only logged, so auditing never
+	// blocks the action being audited.
+	Record(ctx context.Context, event models.AuthEvent, err error)
+	// RecordImpersonatedRequest records a request made with the impersonation
+	// token claims and the status it was answered with.
+	RecordImpersonatedRequest(ctx context.Context, claims *output.Claims, method, path string, status int)
+	ListEvents(ctx context.Context, query *input.AuditEventQuery) (*output.PaginatedResponse, error)
+	// ExportEvents writes every matching event to w as NDJSON, oldest first.
+	ExportEvents(ctx context.Context, query *input.AuditEventQuery, w io.Writer) error
+	// VerifyChain recomputes every event's hash and checks it links to the
+	// previous event.
+	VerifyChain(ctx context.Context) (*output.AuditChainVerification, error)
+}
+
+type auditService struct {
+	repo repo.AuthEventRepository
+}
+
+// auditBatchSize is how many events are read at a time when exporting or
+// verifying the log.
+const auditBatchSize = 500
+
+func NewAuditService(authEventRepo repo.AuthEventRepository) AuditService {
+	return &auditService{repo: authEventRepo}
+}
+
+func (s *auditService) Record(ctx context.Context, event models.AuthEvent, err error) {
+	ip, userAgent := utils.ClientInfo(ctx)
+	event.IPAddress = truncate(ip, 64)
+	event.UserAgent = truncate(userAgent, 512)
+	event.Identifier = truncate(event.Identifier, 255)
+	event.CreatedAt = time.Now().Truncate(time.Millisecond)
+
+	if event.ActorID == nil {
+		if actorID, ok := ctx.Value("user_id").(uint); ok && actorID != 0 {
+			event.ActorID = &actorID
+		} else if err == nil {
+			event.ActorID = event.SubjectID
+		}
+	}
+
+	if claims, ok := ctx.Value("user_claims").(*output.Claims); ok && event.ImpersonatorID == nil && claims.ImpersonatorID != 0 {
+		event.ImpersonatorID = &claims.ImpersonatorID
+	}
+
+	event.Outcome = models.AuthEventSuccess
+	if err != nil {
+		event.Outcome = models.AuthEventFailure
+		if event.Reason != "" {
+			event.Reason += ": " + err.Error()
+		} else {
+			event.Reason = err.Error()
+		}
+	}
+	event.Reason = truncate(event.Reason, 512)
+
+	if err := s.repo.Append(&event); err != nil {
+		log.Printf("[AUDIT] Failed to record %s event: %v", event.Type, err)
+	}
+}
+
+func (s *auditService) RecordImpersonatedRequest(ctx context.Context, claims *output.Claims, method, path string, status int) {
+	var err error
+	if status >= fiber.StatusBadRequest {
+		err = fmt.Errorf("status %d", status)
+	}
+
+	s.Record(ctx, models.AuthEvent{
+		Type:           models.AuthEventImpersonatedRequest,
+		ActorID:        &claims.UserID,
+		SubjectID:      &claims.UserID,
+		ImpersonatorID: &claims.ImpersonatorID,
+		Reason:         method + " " + path,
+	}, err)
+}
+
+func (s *auditService) ListEvents(ctx context.Context, query *input.AuditEventQuery) (*output.PaginatedResponse, error) {
+	filter, err := auditFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page, limit := query.Page, query.Limit
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	events, total, err := s.repo.List(filter, (page-1)*limit, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &output.PaginatedResponse{
+		Success: true,
+		Data:    events,
+		Meta: output.PaginationMeta{
+			CurrentPage: page,
+			PerPage:     limit,
+			Total:       int(total),
+			TotalPages:  int(math.Ceil(float64(total) / float64(limit))),
+		},
+	}, nil
+}
+
+func (s *auditService) ExportEvents(ctx context.Context, query *input.AuditEventQuery, w io.Writer) error {
+	filter, err := auditFilter(query)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	return s.repo.Each(filter, auditBatchSize, func(events []models.AuthEvent) error {
+		for i := range events {
+			if err := encoder.Encode(&events[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// errChainBroken stops VerifyChain at the first bad event.
+var errChainBroken = errors.New("audit chain broken")
+
+func (s *auditService) VerifyChain(ctx context.Context) (*output.AuditChainVerification, error) {
+	result := &output.AuditChainVerification{Valid: true}
+	previous := ""
+
+	err := s.repo.Each(repo.AuthEventFilter{}, auditBatchSize, func(events []models.AuthEvent) error {
+		for i := range events {
+			event := &events[i]
+			if event.PrevHash != previous || event.ComputeHash() != event.Hash {
+				result.Valid = false
+				result.BrokenAt = &event.ID
+				return errChainBroken
+			}
+			previous = event.Hash
+			result.Checked++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return nil, err
+	}
+
+	if !result.Valid {
+		log.Printf("[AUDIT] Hash chain broken at event %d", *result.BrokenAt)
+	}
+
+	return result, nil
+}
+
+func auditFilter(query *input.AuditEventQuery) (repo.AuthEventFilter, error) {
+	filter := repo.AuthEventFilter{
+		Type:      models.AuthEventType(query.Type),
+		ActorID:   query.ActorID,
+		SubjectID: query.SubjectID,
+		Outcome:   models.AuthEventOutcome(query.Outcome),
+		IPAddress: query.IPAddress,
+	}
+
+	var err error
+	if filter.From, err = parseAuditTime(query.From); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseAuditTime(query.To); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func parseAuditTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, utils.NewBadRequestError("from and to must be RFC 3339 times")
+	}
+	return &t, nil
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+)
+
+// fakeAuthEventRepo chains events in memory the way the database
+// repository does.
+type fakeAuthEventRepo struct {
+	repo.AuthEventRepository
+	events []models.AuthEvent
+}
+
+func (r *fakeAuthEventRepo) Append(event *models.AuthEvent) error {
+	if len(r.events) > 0 {
+		event.PrevHash = r.events[len(r.events)-1].Hash
+	}
+	event.ID = uint(len(r.events) + 1)
+	event.Hash = event.ComputeHash()
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func (r *fakeAuthEventRepo) Each(filter repo.AuthEventFilter, batchSize int, fn func([]models.AuthEvent) error) error {
+	for start := 0; start < len(r.events); start += batchSize {
+		end := min(start+batchSize, len(r.events))
+		batch := append([]models.AuthEvent(nil), r.events[start:end]...)
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordTestEvents records a small log through the audit service.
+func recordTestEvents(t *testing.T) (*fakeAuthEventRepo, AuditService) {
+	t.Helper()
+
+	events := &fakeAuthEventRepo{}
+	audit := NewAuditService(events)
+	ctx := context.Background()
+
+	userID, adminID := uint(7), uint(1)
+	audit.Record(ctx, models.AuthEvent{Type: models.AuthEventLoginPassword, SubjectID: &userID}, nil)
+	audit.Record(ctx, models.AuthEvent{Type: models.AuthEventLoginPassword, Identifier: "nobody@example.com"}, errors.New("invalid credentials"))
+	audit.Record(ctx, models.AuthEvent{Type: models.AuthEventAdminImpersonate, ActorID: &adminID, SubjectID: &userID}, nil)
+	audit.Record(ctx, models.AuthEvent{Type: models.AuthEventImpersonatedRequest, ActorID: &userID, SubjectID: &userID, ImpersonatorID: &adminID, Reason: "GET /users/7"}, nil)
+	audit.Record(ctx, models.AuthEvent{Type: models.AuthEventLogout, SubjectID: &userID}, nil)
+
+	if len(events.events) != 5 {
+		t.Fatalf("recorded %d events, want 5", len(events.events))
+	}
+	return events, audit
+}
+
+func TestAuditVerifyChain(t *testing.T) {
+	_, audit := recordTestEvents(t)
+
+	result, err := audit.VerifyChain(context.Background())
+	if err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+	if !result.Valid || result.Checked != 5 || result.BrokenAt != nil {
+		t.Errorf("VerifyChain = %+v, want a valid chain of 5", result)
+	}
+}
+
+func TestAuditVerifyChainDetectsTampering(t *testing.T) {
+	otherID := uint(99)
+
+	tests := []struct {
+		name   string
+		tamper func(events []models.AuthEvent) []models.AuthEvent
+		want   uint
+	}{
+		{
+			name: "edited reason",
+			tamper: func(events []models.AuthEvent) []models.AuthEvent {
+				events[1].Reason = "ok"
+				return events
+			},
+			want: 2,
+		},
+		{
+			name: "edited subject",
+			tamper: func(events []models.AuthEvent) []models.AuthEvent {
+				events[2].SubjectID = &otherID
+				return events
+			},
+			want: 3,
+		},
+		{
+			name: "removed impersonator",
+			tamper: func(events []models.AuthEvent) []models.AuthEvent {
+				events[3].ImpersonatorID = nil
+				return events
+			},
+			want: 4,
+		},
+		{
+			name: "shifted timestamp",
+			tamper: func(events []models.AuthEvent) []models.AuthEvent {
+				events[0].CreatedAt = events[0].CreatedAt.Add(-time.Hour)
+				return events
+			},
+			want: 1,
+		},
+		{
+			name: "rehashed after edit",
+			tamper: func(events []models.AuthEvent) []models.AuthEvent {
+				events[1].Reason = "ok"
+				events[1].Hash = events[1].ComputeHash()
+				return events
+			},
+			want: 3,
+		},
+		{
+			name: "deleted event",
+			tamper: func(events []models.AuthEvent) []models.AuthEvent {
+				return append(events[:2], events[3:]...)
+			},
+			want: 4,
+		},
+		{
+			name: "swapped events",
+			tamper: func(events []models.AuthEvent) []models.AuthEvent {
+				events[2], events[3] = events[3], events[2]
+				return events
+			},
+			want: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, audit := recordTestEvents(t)
+			events.events = tt.tamper(events.events)
+
+			result, err := audit.VerifyChain(context.Background())
+			if err != nil {
+				t.Fatalf("VerifyChain: %v", err)
+			}
+			if result.Valid || result.BrokenAt == nil || *result.BrokenAt != tt.want {
+				t.Errorf("VerifyChain = %+v, want broken at %d", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthEventComputeHash(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	event := models.AuthEvent{Type: models.AuthEventLogout, Outcome: models.AuthEventSuccess, CreatedAt: created, PrevHash: "abc"}
+	hash := event.ComputeHash()
+
+	if len(hash) != 64 {
+		t.Fatalf("hash %q is not hex SHA-256", hash)
+	}
+
+	// The database keeps milliseconds, so a reloaded event must hash the same.
+	reloaded := event
+	reloaded.CreatedAt = created.Truncate(time.Millisecond)
+	if reloaded.ComputeHash() != hash {
+		t.Error("hash depends on sub-millisecond precision")
+	}
+
+	// A stored Hash or ID is not part of the hash.
+	stored := event
+	stored.ID = 42
+	stored.Hash = hash
+	if stored.ComputeHash() != hash {
+		t.Error("hash depends on ID or Hash")
+	}
+
+	chained := event
+	chained.PrevHash = "abd"
+	if chained.ComputeHash() == hash {
+		t.Error("hash ignores PrevHash")
+	}
+
+	adminID := uint(0)
+	impersonated := event
+	impersonated.ImpersonatorID = &adminID
+	if impersonated.ComputeHash() == hash {
+		t.Error("hash ignores ImpersonatorID")
+	}
+}
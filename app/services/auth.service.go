@@ -2,11 +2,12 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bbapp-org/auth-service/app/dto/input"
@@ -17,7 +18,6 @@ import (
 
 	firebase "firebase.google.com/go/v4"
 	fbAuth "firebase.google.com/go/v4/auth"
-	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"google.golang.org/api/idtoken"
 	"gorm.io/gorm"
@@ -32,11 +32,46 @@ type AuthService interface {
 	LoginGoogle(ctx context.Context, req *input.LoginGoogleRequest) (*output.AuthResponse, error)
 	LoginApple(ctx context.Context, req *input.LoginAppleRequest) (*output.AuthResponse, error)
 	LoginPassword(ctx context.Context, req *input.LoginPasswordRequest) (*output.AuthResponse, error)
+	VerifyEmail(ctx context.Context, req *input.VerifyOTPRequest) (*output.AuthResponse, error)
+	VerifyPhone(ctx context.Context, req *input.VerifyOTPRequest) (*output.AuthResponse, error)
+	AuthenticatePassword(ctx context.Context, req *input.LoginPasswordRequest) (*models.User, error)
+	AuthenticateOTP(ctx context.Context, req *input.VerifyOTPRequest) (*models.User, error)
+	LoginMFA(ctx context.Context, req *input.MFAVerifyRequest) (*output.AuthResponse, error)
+	AuthenticateMFA(ctx context.Context, req *input.MFAVerifyRequest) (*models.User, error)
+	ConfirmMFAEnrollment(ctx context.Context, req *input.MFAConfirmChallengeRequest) (*output.AuthResponse, error)
+	LoginWebAuthn(ctx context.Context, req *input.WebAuthnAssertionRequest) (*output.AuthResponse, error)
+	// VerifyProviderToken checks a Google or Apple ID token and returns the
+	// provider's subject for it.
+	VerifyProviderToken(ctx context.Context, provider models.IdentityProvider, token string) (string, error)
+	IssueTokens(ctx context.Context, userID uint, opts TokenOptions) (*output.AuthResponse, error)
 	RefreshToken(ctx context.Context, req *input.RefreshTokenRequest) (*output.AuthResponse, error)
+	RefreshClientToken(ctx context.Context, refreshToken, clientID string) (*output.AuthResponse, error)
 	ChangePassword(ctx context.Context, userID uint, req *input.ChangePasswordRequest) error
+	// SendContactVerification sends an OTP to the user's pending email or
+	// phone, or to the current one while it is unverified.
+	SendContactVerification(ctx context.Context, userID uint, channel models.OTPChannel) (*output.OTPResponse, error)
+	// ConfirmContactVerification marks the contact verified, first swapping
+	// in a pending change. Pending self-registered accounts become active.
+	ConfirmContactVerification(ctx context.Context, userID uint, req *input.ConfirmContactRequest) (*output.UserInfo, error)
+	// RequestContactChange records a new email or phone as pending and
+	// sends it an OTP. The current value stays in use until confirmed.
+	RequestContactChange(ctx context.Context, userID uint, req *input.ChangeContactRequest) (*output.OTPResponse, error)
+	// SendReauthenticationOTP sends a code for Reauthenticate to the
+	// user's verified email or phone.
+	SendReauthenticationOTP(ctx context.Context, userID uint, channel models.OTPChannel) (*output.OTPResponse, error)
+	// Reauthenticate checks the caller's credentials again and issues a
+	// short-lived token for the same session with a fresh auth_time.
+	Reauthenticate(ctx context.Context, claims *output.Claims, req *input.ReauthenticateRequest) (*output.ReauthenticationResponse, error)
 	GetUserInfo(ctx context.Context, userID uint) (*output.UserInfo, error)
+	GetOIDCUserInfo(ctx context.Context, userID uint) (*output.OIDCUserInfo, error)
 	ValidateToken(ctx context.Context, tokenString string) (*output.TokenValidationResponse, error)
-	Logout(ctx context.Context, userID uint, tokenID string) error
+	// IntrospectToken reports whether an access or refresh token is still
+	// active, with its claims when it is.
+	IntrospectToken(ctx context.Context, token string) (*output.TokenIntrospectionResponse, error)
+	// RevokeToken revokes an access or refresh token on behalf of the client
+	// revokedBy. Tokens that are invalid or already expired are ignored.
+	RevokeToken(ctx context.Context, token, revokedBy string) error
+	Logout(ctx context.Context, claims *output.Claims, refreshToken string) error
 }
 
 type AdminService interface {
@@ -50,29 +85,81 @@ type AdminService interface {
 	DeleteUser(ctx context.Context, userID uint) error
 	UpdateUserStatus(ctx context.Context, userID uint, status string) error
 	UpdateUserRole(ctx context.Context, userID uint, roleName string) error
+	UnlockUser(ctx context.Context, userID uint) error
 	GetDashboardStats(ctx context.Context, filter *input.DashboardStatsFilter) (*output.DashboardStatsResponse, error)
 }
 
+// TokenOptions binds tokens issued through the OAuth flow to the client that
+// requested them.
+type TokenOptions struct {
+	ClientID string
+	Scope    string
+	Nonce    string
+	AuthTime time.Time
+	// AMR lists how the user authenticated, as RFC 8176 method references.
+	AMR []string
+}
+
+// mfaAMR is the AMR of a login completed with a second factor. The first
+// factor is not carried through the challenge.
+var mfaAMR = []string{utils.AMRMFA, utils.AMROTP}
+
 type authService struct {
-	userRepo         repo.UserRepository
-	roleRepo         repo.RoleRepository
-	refreshTokenRepo repo.RefreshTokenRepository
-	sessionRepo      repo.UserSessionRepository
-	oauthConfig      input.OAuthConfig
-	firebaseAuth     *fbAuth.Client
+	userRepo            repo.UserRepository
+	roleRepo            repo.RoleRepository
+	refreshTokenRepo    repo.RefreshTokenRepository
+	sessionService      SessionService
+	otpRepo             repo.OTPRepository
+	revocationService   RevocationService
+	mfaService          MFAService
+	webAuthnService     WebAuthnService
+	passwordPolicy      PasswordPolicyService
+	rateLimitService    RateLimitService
+	notificationService NotificationService
+	auditService        AuditService
+	stepUpConfig        input.StepUpConfig
+	oauthConfig         input.OAuthConfig
+	firebaseAuth        *fbAuth.Client
 }
 
+const (
+	otpTTL         = 300 * time.Second
+	otpMaxAttempts = 5
+)
+
+// errAwaitsInvitation refuses sign-in to an invited account: only accepting
+// the invitation activates it.
+var errAwaitsInvitation = utils.NewForbiddenError("user account is pending, accept the invitation sent to your email to sign in")
+
 func NewAuthService(
 	userRepo repo.UserRepository,
 	roleRepo repo.RoleRepository,
 	refreshTokenRepo repo.RefreshTokenRepository,
-	sessionRepo repo.UserSessionRepository,
+	sessionService SessionService,
+	otpRepo repo.OTPRepository,
+	revocationService RevocationService,
+	mfaService MFAService,
+	webAuthnService WebAuthnService,
+	passwordPolicy PasswordPolicyService,
+	rateLimitService RateLimitService,
+	notificationService NotificationService,
+	auditService AuditService,
+	stepUpConfig input.StepUpConfig,
 ) AuthService {
 	return &authService{
-		userRepo:         userRepo,
-		roleRepo:         roleRepo,
-		refreshTokenRepo: refreshTokenRepo,
-		sessionRepo:      sessionRepo,
+		userRepo:            userRepo,
+		roleRepo:            roleRepo,
+		refreshTokenRepo:    refreshTokenRepo,
+		sessionService:      sessionService,
+		otpRepo:             otpRepo,
+		revocationService:   revocationService,
+		mfaService:          mfaService,
+		webAuthnService:     webAuthnService,
+		passwordPolicy:      passwordPolicy,
+		rateLimitService:    rateLimitService,
+		notificationService: notificationService,
+		auditService:        auditService,
+		stepUpConfig:        stepUpConfig,
 	}
 }
 
@@ -104,20 +191,23 @@ func (s *authService) RegisterEmail(ctx context.Context, req *input.RegisterEmai
 		return nil, errors.New("user already exists with this email")
 	}
 
-	otp, err := utils.GenerateOTP()
+	if err := s.rateLimitService.LimitOTPSend(ctx, req.Email, 0); err != nil {
+		return nil, err
+	}
+
+	otp, err := s.issueOTP(req.Email, models.OTPChannelEmail, models.OTPPurposeRegister, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	go func() {
-		if err := s.sendOTPEmail(context.Background(), req.Email, otp); err != nil {
-			log.Printf("Failed to send OTP email to %s: %v", req.Email, err)
-		}
-	}()
+	if err := s.sendOTP(ctx, models.OTPChannelEmail, req.Email, otp); err != nil {
+		log.Printf("Failed to queue OTP email to %s: %v", req.Email, err)
+		return nil, utils.NewInternalServerError("failed to send OTP")
+	}
 
 	return &output.OTPResponse{
 		Message:   "OTP sent to email successfully",
-		ExpiresIn: 300,
+		ExpiresIn: int(otpTTL.Seconds()),
 	}, nil
 }
 
@@ -127,20 +217,23 @@ func (s *authService) RegisterPhone(ctx context.Context, req *input.RegisterPhon
 		return nil, errors.New("user already exists with this phone")
 	}
 
-	otp, err := utils.GenerateOTP()
+	if err := s.rateLimitService.LimitOTPSend(ctx, req.Phone, 0); err != nil {
+		return nil, err
+	}
+
+	otp, err := s.issueOTP(req.Phone, models.OTPChannelPhone, models.OTPPurposeRegister, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	go func() {
-		if err := s.sendOTPSMS(context.Background(), req.Phone, otp); err != nil {
-			log.Printf("Failed to send OTP SMS to %s: %v", req.Phone, err)
-		}
-	}()
+	if err := s.sendOTP(ctx, models.OTPChannelPhone, req.Phone, otp); err != nil {
+		log.Printf("Failed to queue OTP SMS to %s: %v", req.Phone, err)
+		return nil, utils.NewInternalServerError("failed to send OTP")
+	}
 
 	return &output.OTPResponse{
 		Message:   "OTP sent to phone successfully",
-		ExpiresIn: 300,
+		ExpiresIn: int(otpTTL.Seconds()),
 	}, nil
 }
 
@@ -196,19 +289,28 @@ func (s *authService) validateGoogleToken(ctx context.Context, tokenString strin
 }
 
 func (s *authService) RegisterGoogle(ctx context.Context, req *input.RegisterGoogleRequest) (*output.AuthResponse, error) {
-	googleUserInfo, err := s.validateGoogleToken(ctx, req.GoogleToken)
+	user, err := s.registerGoogle(ctx, req)
+	s.recordLogin(ctx, models.AuthEventRegister, "", user, err)
 	if err != nil {
 		return nil, err
 	}
 
+	return s.generateTokens(ctx, user, utils.AMRFederated)
+}
+
+func (s *authService) registerGoogle(ctx context.Context, req *input.RegisterGoogleRequest) (*models.User, error) {
+	googleUserInfo, err := s.validateGoogleToken(ctx, req.GoogleToken)
+	if err != nil {
+		return nil, utils.NewUnauthorizedError("invalid Google token")
+	}
+
 	existingUser, err := s.userRepo.GetByGoogleID(googleUserInfo.ID)
 	if err == nil && existingUser != nil {
-		return nil, errors.New("user already exists with this Google account")
+		return nil, utils.NewConflictError("this Google account is already registered, sign in instead")
 	}
 
-	existingEmailUser, err := s.userRepo.GetByEmail(googleUserInfo.Email)
-	if err == nil && existingEmailUser != nil {
-		return nil, errors.New("user already exists with this email")
+	if err := s.checkEmailUnclaimed(googleUserInfo.Email, models.IdentityProviderGoogle); err != nil {
+		return nil, err
 	}
 
 	role, err := s.roleRepo.GetByName("mobile_user")
@@ -231,415 +333,1366 @@ func (s *authService) RegisterGoogle(ctx context.Context, req *input.RegisterGoo
 
 	s.userRepo.UpdateLastLogin(user.ID)
 
-	return s.generateTokens(user)
+	return user, nil
+}
+
+// checkEmailUnclaimed fails with a conflict when an account that is not
+// linked to provider already uses email. Such accounts are never linked
+// silently; the owner has to sign in and link provider themselves.
+func (s *authService) checkEmailUnclaimed(email string, provider models.IdentityProvider) error {
+	if email == "" {
+		return nil
+	}
+
+	existing, err := s.userRepo.GetByEmail(email)
+	if err == nil && existing != nil {
+		return utils.NewConflictError(fmt.Sprintf("an account with this email already exists, sign in to it and link %s from your account settings", provider))
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
 }
 
 func (s *authService) LoginEmail(ctx context.Context, req *input.LoginEmailRequest) (*output.OTPResponse, error) {
 	user, err := s.userRepo.GetByEmail(req.Email)
+
+	var userID uint
+	if err == nil {
+		userID = user.ID
+	}
+	if err := s.rateLimitService.LimitOTPSend(ctx, req.Email, userID); err != nil {
+		return nil, err
+	}
+
 	if err != nil {
 		return nil, utils.NewNotFoundError("user not found")
 	}
 
-	if user.Status != models.UserStatusActive {
+	// Pending accounts sign in by OTP to verify their contact, unless they
+	// are waiting for an invitation to be accepted.
+	if user.Status == models.UserStatusInactive {
 		return nil, utils.NewForbiddenError("user account is not active")
 	}
+	if user.AwaitsInvitation() {
+		return nil, errAwaitsInvitation
+	}
 
-	otp, err := utils.GenerateOTP()
+	otp, err := s.issueOTP(req.Email, models.OTPChannelEmail, models.OTPPurposeLogin, 0)
 	if err != nil {
 		return nil, utils.NewInternalServerError("failed to generate OTP")
 	}
 
-	go func() {
-		if err := s.sendOTPEmail(context.Background(), req.Email, otp); err != nil {
-			log.Printf("Failed to send OTP email to %s: %v", req.Email, err)
-		}
-	}()
+	if err := s.sendOTP(ctx, models.OTPChannelEmail, req.Email, otp); err != nil {
+		log.Printf("Failed to queue OTP email to %s: %v", req.Email, err)
+		return nil, utils.NewInternalServerError("failed to send OTP")
+	}
 
 	return &output.OTPResponse{
 		Message:   "OTP sent to email successfully",
-		ExpiresIn: 300,
+		ExpiresIn: int(otpTTL.Seconds()),
 	}, nil
 }
 
 func (s *authService) LoginPhone(ctx context.Context, req *input.LoginPhoneRequest) (*output.OTPResponse, error) {
 	user, err := s.userRepo.GetByPhone(req.Phone)
+
+	var userID uint
+	if err == nil {
+		userID = user.ID
+	}
+	if err := s.rateLimitService.LimitOTPSend(ctx, req.Phone, userID); err != nil {
+		return nil, err
+	}
+
 	if err != nil {
 		return nil, utils.NewNotFoundError("user not found")
 	}
 
-	if user.Status != models.UserStatusActive {
+	// Pending accounts sign in by OTP to verify their contact, unless they
+	// are waiting for an invitation to be accepted.
+	if user.Status == models.UserStatusInactive {
 		return nil, utils.NewForbiddenError("user account is not active")
 	}
+	if user.AwaitsInvitation() {
+		return nil, errAwaitsInvitation
+	}
 
-	otp, err := utils.GenerateOTP()
+	otp, err := s.issueOTP(req.Phone, models.OTPChannelPhone, models.OTPPurposeLogin, 0)
 	if err != nil {
 		return nil, utils.NewInternalServerError("failed to generate OTP")
 	}
 
-	go func() {
-		if err := s.sendOTPSMS(context.Background(), req.Phone, otp); err != nil {
-			log.Printf("Failed to send OTP SMS to %s: %v", req.Phone, err)
-		}
-	}()
+	if err := s.sendOTP(ctx, models.OTPChannelPhone, req.Phone, otp); err != nil {
+		log.Printf("Failed to queue OTP SMS to %s: %v", req.Phone, err)
+		return nil, utils.NewInternalServerError("failed to send OTP")
+	}
 
 	return &output.OTPResponse{
 		Message:   "OTP sent to phone successfully",
-		ExpiresIn: 300,
+		ExpiresIn: int(otpTTL.Seconds()),
 	}, nil
 }
 
 func (s *authService) LoginGoogle(ctx context.Context, req *input.LoginGoogleRequest) (*output.AuthResponse, error) {
-	googleUserInfo, err := s.validateGoogleToken(ctx, req.GoogleToken)
+	user, err := s.authenticateGoogle(ctx, req.GoogleToken)
+	s.recordLogin(ctx, models.AuthEventLoginGoogle, "", user, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.generateTokens(ctx, user, utils.AMRFederated)
+}
+
+func (s *authService) authenticateGoogle(ctx context.Context, token string) (*models.User, error) {
+	googleUserInfo, err := s.validateGoogleToken(ctx, token)
 	if err != nil {
 		return nil, utils.NewUnauthorizedError("invalid Google token")
 	}
 
-	user, err := s.userRepo.GetByEmail(googleUserInfo.Email)
+	user, err := s.userRepo.GetByGoogleID(googleUserInfo.ID)
 	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewInternalServerError("failed to fetch user")
+		}
+		if err := s.checkEmailUnclaimed(googleUserInfo.Email, models.IdentityProviderGoogle); err != nil {
+			return nil, err
+		}
 		return nil, utils.NewNotFoundError("user not found")
 	}
 
 	if user.Status != models.UserStatusActive {
-		return nil, utils.NewForbiddenError("user account is not active")
+		return user, utils.NewForbiddenError("user account is not active")
 	}
 
 	s.userRepo.UpdateLastLogin(user.ID)
 
-	return s.generateTokens(user)
+	return user, nil
 }
 
-func (s *authService) LoginApple(ctx context.Context, req *input.LoginAppleRequest) (*output.AuthResponse, error) {
+type AppleUserInfo struct {
+	ID    string
+	Email string
+	Name  string
+}
+
+func (s *authService) validateAppleToken(ctx context.Context, tokenString string) (*AppleUserInfo, error) {
 	fbClient, err := s.getFirebaseAuth(ctx)
 	if err != nil {
 		return nil, utils.NewInternalServerError("apple login is not configured: " + err.Error())
 	}
 
-	idToken, err := fbClient.VerifyIDToken(ctx, req.AppleToken)
+	idToken, err := fbClient.VerifyIDToken(ctx, tokenString)
 	if err != nil {
-		log.Printf("LoginApple: VerifyIDToken failed: %v", err)
+		log.Printf("validateAppleToken: VerifyIDToken failed: %v", err)
 		return nil, utils.NewUnauthorizedError("invalid apple token")
 	}
 
-	uid := idToken.UID
 	if firebaseObj, ok := idToken.Claims["firebase"].(map[string]interface{}); ok {
 		if provider, ok2 := firebaseObj["sign_in_provider"].(string); ok2 && provider != "apple.com" {
 			return nil, utils.NewUnauthorizedError("invalid apple token")
 		}
 	}
-	var emailPtr *string
-	var namePtr *string
-	if v, ok := idToken.Claims["email"].(string); ok && v != "" {
-		emailPtr = &v
+
+	userInfo := &AppleUserInfo{ID: idToken.UID}
+	if v, ok := idToken.Claims["email"].(string); ok {
+		userInfo.Email = v
 	}
-	if v, ok := idToken.Claims["name"].(string); ok && v != "" {
-		namePtr = &v
+	if v, ok := idToken.Claims["name"].(string); ok {
+		userInfo.Name = v
 	}
+	return userInfo, nil
+}
 
-	if emailPtr == nil {
-		return nil, utils.NewBadRequestError("email not available from apple token")
+func (s *authService) VerifyProviderToken(ctx context.Context, provider models.IdentityProvider, token string) (string, error) {
+	switch provider {
+	case models.IdentityProviderGoogle:
+		googleUserInfo, err := s.validateGoogleToken(ctx, token)
+		if err != nil {
+			return "", utils.NewUnauthorizedError("invalid Google token")
+		}
+		return googleUserInfo.ID, nil
+	case models.IdentityProviderApple:
+		appleUserInfo, err := s.validateAppleToken(ctx, token)
+		if err != nil {
+			return "", err
+		}
+		return appleUserInfo.ID, nil
 	}
+	return "", utils.NewBadRequestError(fmt.Sprintf("%s is not verified by token", provider))
+}
 
-	user, err := s.userRepo.GetByEmail(*emailPtr)
+func (s *authService) LoginApple(ctx context.Context, req *input.LoginAppleRequest) (*output.AuthResponse, error) {
+	user, err := s.authenticateApple(ctx, req.AppleToken)
+	s.recordLogin(ctx, models.AuthEventLoginApple, "", user, err)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			role, rErr := s.roleRepo.GetByName("mobile_user")
-			if rErr != nil {
-				return nil, rErr
-			}
-			user = &models.User{
-				Email:         emailPtr,
-				Username:      namePtr,
-				AppleID:       &uid,
-				UserType:      models.UserTypeMobile,
-				RoleID:        role.ID,
-				Status:        models.UserStatusActive,
-				EmailVerified: true,
-			}
-			if cErr := s.userRepo.Create(user); cErr != nil {
-				return nil, cErr
-			}
-		} else {
+		return nil, err
+	}
+
+	return s.generateTokens(ctx, user, utils.AMRFederated)
+}
+
+// authenticateApple signs in the account linked to the Apple ID, creating
+// one when the token's email is not already in use.
+func (s *authService) authenticateApple(ctx context.Context, token string) (*models.User, error) {
+	appleUserInfo, err := s.validateAppleToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByAppleID(appleUserInfo.ID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, utils.NewInternalServerError("failed to fetch user")
 		}
-	}
 
-	if user != nil && user.AppleID == nil {
-		user.AppleID = &uid
-		if uErr := s.userRepo.Update(user); uErr != nil {
-			log.Printf("LoginApple: failed to link AppleID to user %d: %v", user.ID, uErr)
+		if appleUserInfo.Email == "" {
+			return nil, utils.NewBadRequestError("email not available from apple token")
+		}
+		if err := s.checkEmailUnclaimed(appleUserInfo.Email, models.IdentityProviderApple); err != nil {
+			return nil, err
+		}
+
+		role, rErr := s.roleRepo.GetByName("mobile_user")
+		if rErr != nil {
+			return nil, rErr
 		}
+		user = &models.User{
+			Email:         &appleUserInfo.Email,
+			AppleID:       &appleUserInfo.ID,
+			UserType:      models.UserTypeMobile,
+			RoleID:        role.ID,
+			Status:        models.UserStatusActive,
+			EmailVerified: true,
+		}
+		if appleUserInfo.Name != "" {
+			user.Username = &appleUserInfo.Name
+		}
+		if cErr := s.userRepo.Create(user); cErr != nil {
+			return nil, cErr
+		}
+	}
+
+	if user.Status != models.UserStatusActive {
+		return user, utils.NewForbiddenError("user account is not active")
 	}
 
 	s.userRepo.UpdateLastLogin(user.ID)
 
-	return s.generateTokens(user)
+	return user, nil
 }
 
 func (s *authService) LoginPassword(ctx context.Context, req *input.LoginPasswordRequest) (*output.AuthResponse, error) {
-	user, err := s.userRepo.GetByEmail(req.Email)
+	user, err := s.AuthenticatePassword(ctx, req)
 	if err != nil {
-		return nil, utils.NewUnauthorizedError("invalid credentials")
+		return nil, err
 	}
 
-	if user.Status != models.UserStatusActive {
-		return nil, utils.NewForbiddenError("user account is not active")
-	}
+	return s.generateTokens(ctx, user, utils.AMRPassword)
+}
 
-	if user.PasswordHash == nil || !utils.CheckPassword(req.Password, *user.PasswordHash) {
-		return nil, utils.NewUnauthorizedError("invalid credentials")
+// AuthenticatePassword checks email/password credentials without issuing
+// tokens, so that other flows can decide what to hand out.
+func (s *authService) AuthenticatePassword(ctx context.Context, req *input.LoginPasswordRequest) (*models.User, error) {
+	user, err := s.authenticatePassword(ctx, req)
+	s.recordLogin(ctx, models.AuthEventLoginPassword, req.Email, user, err)
+	if err != nil {
+		return nil, err
 	}
 
-	s.userRepo.UpdateLastLogin(user.ID)
-
-	return s.generateTokens(user)
+	return user, nil
 }
 
-func (s *authService) RefreshToken(ctx context.Context, req *input.RefreshTokenRequest) (*output.AuthResponse, error) {
-	userID, err := utils.ValidateRefreshToken(req.RefreshToken)
-	if err != nil {
-		return nil, errors.New("invalid refresh token")
+// authenticatePassword returns the account the email matched even when the
+// check fails, so the failure is audited against it.
+func (s *authService) authenticatePassword(ctx context.Context, req *input.LoginPasswordRequest) (*models.User, error) {
+	user, err := s.userRepo.GetByEmail(req.Email)
+
+	var userID uint
+	if err == nil {
+		userID = user.ID
+	}
+	if err := s.rateLimitService.LimitLogin(ctx, req.Email, userID); err != nil {
+		return nil, err
 	}
 
-	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, utils.NewUnauthorizedError("invalid credentials")
 	}
 
-	if user.Status != models.UserStatusActive {
-		return nil, errors.New("user account is not active")
+	if user.AwaitsInvitation() {
+		return user, errAwaitsInvitation
 	}
 
-	return s.generateTokens(user)
-}
+	if user.Status == models.UserStatusPending {
+		return user, utils.NewForbiddenError("user account is pending verification, sign in with the code sent to your email")
+	}
 
-func (s *authService) ChangePassword(ctx context.Context, userID uint, req *input.ChangePasswordRequest) error {
-	user, err := s.userRepo.GetByID(userID)
-	if err != nil {
-		return errors.New("user not found")
+	if user.Status != models.UserStatusActive {
+		return user, utils.NewForbiddenError("user account is not active")
 	}
 
-	if user.PasswordHash == nil || !utils.CheckPassword(req.CurrentPassword, *user.PasswordHash) {
-		return errors.New("current password is incorrect")
+	if err := s.rateLimitService.CheckLockout(user); err != nil {
+		return user, err
 	}
 
-	newPasswordHash, err := utils.HashPassword(req.NewPassword)
-	if err != nil {
-		return err
+	if user.PasswordHash == nil || !utils.CheckPassword(req.Password, *user.PasswordHash) {
+		if err := s.rateLimitService.RecordLoginFailure(user); err != nil {
+			return user, err
+		}
+		return user, utils.NewUnauthorizedError("invalid credentials")
 	}
 
-	user.PasswordHash = &newPasswordHash
-	if err := s.userRepo.Update(user); err != nil {
-		return err
+	s.rateLimitService.RecordLoginSuccess(user)
+
+	if s.passwordPolicy.IsExpired(user) {
+		return user, utils.NewForbiddenError("password has expired, reset it through /auth/password/forgot")
 	}
 
-	s.userRepo.UpdatePasswordChangedAt(userID)
+	s.userRepo.UpdateLastLogin(user.ID)
 
-	return nil
+	return user, nil
 }
 
-func (s *authService) GetUserInfo(ctx context.Context, userID uint) (*output.UserInfo, error) {
-	user, err := s.userRepo.GetByID(userID)
+// LoginMFA completes a login that was answered with an MFA challenge.
+func (s *authService) LoginMFA(ctx context.Context, req *input.MFAVerifyRequest) (*output.AuthResponse, error) {
+	user, err := s.AuthenticateMFA(ctx, req)
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, err
 	}
 
-	return &output.UserInfo{
-		ID:          user.ID,
-		Email:       user.Email,
-		Phone:       user.Phone,
-		Username:    user.Username,
-		UserType:    string(user.UserType),
-		Role:        user.Role.RoleName,
-		Status:      string(user.Status),
-		CreatedAt:   user.CreatedAt,
-		LastLoginAt: user.LastLoginAt,
-	}, nil
+	return s.issueTokens(ctx, user, nil, TokenOptions{AMR: mfaAMR})
 }
 
-func (s *authService) ValidateToken(ctx context.Context, tokenString string) (*output.TokenValidationResponse, error) {
-	claims, err := utils.ValidateJWT(tokenString)
+// AuthenticateMFA checks the second factor of a challenged login without
+// issuing tokens.
+func (s *authService) AuthenticateMFA(ctx context.Context, req *input.MFAVerifyRequest) (*models.User, error) {
+	user, err := s.mfaService.VerifyChallenge(ctx, req)
+	s.recordLogin(ctx, models.AuthEventLoginMFA, "", user, err)
+	return user, err
+}
+
+// ConfirmMFAEnrollment finishes the enrollment forced by an MFA policy and
+// completes the login. The recovery codes are returned alongside the tokens
+// as this is the only time they are shown.
+func (s *authService) ConfirmMFAEnrollment(ctx context.Context, req *input.MFAConfirmChallengeRequest) (*output.AuthResponse, error) {
+	user, codes, err := s.mfaService.ConfirmChallengeEnrollment(ctx, req.MFAToken, req.Code)
+	s.recordLogin(ctx, models.AuthEventLoginMFA, "", user, err)
 	if err != nil {
-		return &output.TokenValidationResponse{Valid: false}, nil
+		return nil, err
 	}
 
-	return &output.TokenValidationResponse{
-		Valid:    true,
-		UserID:   claims.UserID,
-		UserType: claims.UserType,
-		Role:     claims.Role,
-		Claims:   *claims,
-	}, nil
+	resp, err := s.issueTokens(ctx, user, nil, TokenOptions{AMR: mfaAMR})
+	if err != nil {
+		return nil, err
+	}
+	resp.RecoveryCodes = codes
+
+	return resp, nil
 }
 
-func (s *authService) Logout(ctx context.Context, userID uint, tokenID string) error {
-	s.refreshTokenRepo.Delete(tokenID)
+// LoginWebAuthn signs a user in with a passkey. A user-verified assertion
+// already combines possession with a PIN or biometric, so it is not followed
+// by an MFA challenge.
+func (s *authService) LoginWebAuthn(ctx context.Context, req *input.WebAuthnAssertionRequest) (*output.AuthResponse, error) {
+	user, userVerified, err := s.webAuthnService.FinishLogin(ctx, req)
+	s.recordLogin(ctx, models.AuthEventLoginWebAuthn, "", user, err)
+	if err != nil {
+		return nil, err
+	}
 
-	s.sessionRepo.DeleteByUserID(userID)
+	if userVerified {
+		return s.issueTokens(ctx, user, nil, TokenOptions{AMR: []string{utils.AMRHardwareKey, utils.AMRMFA}})
+	}
 
-	return nil
+	return s.generateTokens(ctx, user, utils.AMRHardwareKey)
 }
 
-func (s *authService) generateTokens(user *models.User) (*output.AuthResponse, error) {
-	var email, phone, googleID string
-	if user.Email != nil {
-		email = *user.Email
-	}
-	if user.Phone != nil {
-		phone = *user.Phone
-	}
-	if user.GoogleID != nil {
-		googleID = *user.GoogleID
+func (s *authService) VerifyEmail(ctx context.Context, req *input.VerifyOTPRequest) (*output.AuthResponse, error) {
+	if req.Email == "" {
+		return nil, utils.NewBadRequestError("email is required")
 	}
 
-	claims := output.Claims{
-		UserID:       user.ID,
-		UserType:     string(user.UserType),
-		Role:         user.Role.RoleName,
-		IdentityType: utils.GetIdentityType(email, phone, googleID),
+	user, err := s.verifyOTPUser(ctx, models.OTPChannelEmail, req.Email, req.OTP)
+	if err != nil {
+		return nil, err
 	}
 
-	if user.Email != nil {
-		claims.Email = *user.Email
-	}
-	if user.Phone != nil {
-		claims.Phone = *user.Phone
-	}
-	if user.GoogleID != nil {
-		claims.GoogleID = *user.GoogleID
+	return s.generateTokens(ctx, user, otpAMR(models.OTPChannelEmail))
+}
+
+func (s *authService) VerifyPhone(ctx context.Context, req *input.VerifyOTPRequest) (*output.AuthResponse, error) {
+	if req.Phone == "" {
+		return nil, utils.NewBadRequestError("phone is required")
 	}
-	if user.AppleID != nil {
-		claims.AppleID = *user.AppleID
+
+	user, err := s.verifyOTPUser(ctx, models.OTPChannelPhone, req.Phone, req.OTP)
+	if err != nil {
+		return nil, err
 	}
-	if user.AppleID != nil {
-		claims.FirebaseUID = *user.AppleID
-	} else if user.GoogleID != nil {
-		claims.FirebaseUID = *user.GoogleID
+
+	return s.generateTokens(ctx, user, otpAMR(models.OTPChannelPhone))
+}
+
+// AuthenticateOTP verifies an email or phone OTP without issuing tokens.
+func (s *authService) AuthenticateOTP(ctx context.Context, req *input.VerifyOTPRequest) (*models.User, error) {
+	switch {
+	case req.Email != "":
+		return s.verifyOTPUser(ctx, models.OTPChannelEmail, req.Email, req.OTP)
+	case req.Phone != "":
+		return s.verifyOTPUser(ctx, models.OTPChannelPhone, req.Phone, req.OTP)
+	default:
+		return nil, utils.NewBadRequestError("email or phone is required")
 	}
+}
 
-	accessToken, err := utils.GenerateJWT(claims)
+// verifyOTPUser consumes an OTP and returns the user it was issued for,
+// creating the account when the OTP was issued for registration.
+func (s *authService) verifyOTPUser(ctx context.Context, channel models.OTPChannel, identifier, code string) (*models.User, error) {
+	user, err := s.consumeOTPUser(channel, identifier, code)
+	s.recordLogin(ctx, models.AuthEventLoginOTP, identifier, user, err)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID)
+	return user, nil
+}
+
+func (s *authService) consumeOTPUser(channel models.OTPChannel, identifier, code string) (*models.User, error) {
+	otp, err := s.consumeOTP(identifier, channel, code, models.OTPPurposeRegister, models.OTPPurposeLogin, models.OTPPurposeVerify)
 	if err != nil {
 		return nil, err
 	}
 
-	tokenRecord := &models.RefreshToken{
-		TokenID:   uuid.New().String(),
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 90),
+	getUser := s.userRepo.GetByEmail
+	if channel == models.OTPChannelPhone {
+		getUser = s.userRepo.GetByPhone
 	}
-	s.refreshTokenRepo.Create(tokenRecord)
 
-	return &output.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    7 * 24 * 60 * 60,
-		User: output.UserInfo{
-			ID:          user.ID,
-			Email:       user.Email,
-			Phone:       user.Phone,
-			Username:    user.Username,
-			UserType:    string(user.UserType),
-			Role:        user.Role.RoleName,
-			Status:      string(user.Status),
-			CreatedAt:   user.CreatedAt,
-			LastLoginAt: user.LastLoginAt,
-		},
-	}, nil
-}
+	if otp.Purpose == models.OTPPurposeRegister {
+		if existingUser, err := getUser(identifier); err == nil && existingUser != nil {
+			return nil, utils.NewBadRequestError(fmt.Sprintf("user already exists with this %s", channel))
+		}
 
-type EmailRequest struct {
-	ToAddress string `json:"to_address"`
-	Subject   string `json:"subject"`
-	HtmlBody  string `json:"html_body"`
-}
+		role, err := s.roleRepo.GetByName("mobile_user")
+		if err != nil {
+			return nil, err
+		}
 
-type SMSRequest struct {
-	ToPhone string `json:"to_phone"`
-	OTP     string `json:"otp"`
-}
+		value := identifier
+		user := &models.User{
+			UserType: models.UserTypeMobile,
+			RoleID:   role.ID,
+			Role:     *role,
+			Status:   models.UserStatusActive,
+		}
+		if channel == models.OTPChannelPhone {
+			user.Phone = &value
+			user.PhoneVerified = true
+		} else {
+			user.Email = &value
+			user.EmailVerified = true
+		}
+		if err := s.userRepo.Create(user); err != nil {
+			return nil, err
+		}
 
-func (s *authService) sendOTPSMS(ctx context.Context, phone, otp string) error {
-	notificationServiceURL := os.Getenv("NOTIFICATION_SERVICE_URL")
-	if notificationServiceURL == "" {
-		notificationServiceURL = "http://notification-service"
-	}
+		s.userRepo.UpdateLastLogin(user.ID)
 
-	smsReq := SMSRequest{
-		ToPhone: phone,
-		OTP:     otp,
+		return user, nil
 	}
 
-	jsonData, err := json.Marshal(smsReq)
+	user, err := getUser(identifier)
 	if err != nil {
-		return fmt.Errorf("failed to marshal SMS request: %v", err)
+		return nil, utils.NewNotFoundError("user not found")
 	}
 
-	client := fiber.AcquireClient()
-	defer fiber.ReleaseClient(client)
-
-	req := client.Post(notificationServiceURL + "/sms/send")
-	req.Body(jsonData)
-	req.Set("Content-Type", "application/json")
+	if otp.UserID != nil && *otp.UserID != user.ID {
+		return user, utils.NewUnauthorizedError("OTP is invalid or has expired")
+	}
 
-	status, body, errs := req.Bytes()
-	if len(errs) > 0 {
-		return fmt.Errorf("failed to send SMS request: %v", errs[0])
+	if user.Status == models.UserStatusInactive {
+		return user, utils.NewForbiddenError("user account is not active")
+	}
+	if user.AwaitsInvitation() {
+		return user, errAwaitsInvitation
 	}
 
-	if status != 200 {
-		return fmt.Errorf("notification service returned status %d: %s", status, string(body))
+	verified := &user.EmailVerified
+	if channel == models.OTPChannelPhone {
+		verified = &user.PhoneVerified
+	}
+	if !*verified || user.Status == models.UserStatusPending {
+		*verified = true
+		// The code proves control of the contact, which is all a pending
+		// self-registered account is waiting for.
+		user.Status = models.UserStatusActive
+		if err := s.userRepo.Update(user); err != nil {
+			log.Printf("consumeOTPUser: failed to mark %s verified for user %d: %v", channel, user.ID, err)
+		}
 	}
 
-	return nil
+	s.userRepo.UpdateLastLogin(user.ID)
+
+	return user, nil
 }
 
-func (s *authService) sendOTPEmail(ctx context.Context, email, otp string) error {
-	notificationServiceURL := os.Getenv("NOTIFICATION_SERVICE_URL")
-	if notificationServiceURL == "" {
-		notificationServiceURL = "http://notification-service"
-	}
+func (s *authService) RefreshToken(ctx context.Context, req *input.RefreshTokenRequest) (*output.AuthResponse, error) {
+	return s.refresh(ctx, req.RefreshToken, "")
+}
 
-	emailReq := EmailRequest{
-		ToAddress: email,
-		Subject:   "Varthagan OTP Verification",
-		HtmlBody: fmt.Sprintf(`
-			<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
-				<h2 style="color: #333;">Varthagan OTP Verification</h2>
-				<p>Your OTP code is:</p>
-				<div style="background-color: #f4f4f4; padding: 20px; text-align: center; margin: 20px 0;">
-					<h1 style="color: #007bff; font-size: 32px; margin: 0;">%s</h1>
-				</div>
-				<p>This code will expire in 5 minutes.</p>
-				<p>Best regards,<br/>The Varthagan Team</p>
-			</div>
-		`, otp),
-	}
+// RefreshClientToken rotates a refresh token that was issued to an OAuth
+// client; tokens belonging to other clients are rejected.
+func (s *authService) RefreshClientToken(ctx context.Context, refreshToken, clientID string) (*output.AuthResponse, error) {
+	return s.refresh(ctx, refreshToken, clientID)
+}
 
-	jsonData, err := json.Marshal(emailReq)
+func (s *authService) refresh(ctx context.Context, refreshToken, clientID string) (*output.AuthResponse, error) {
+	userID, tokenID, err := utils.ValidateRefreshToken(refreshToken)
 	if err != nil {
-		return fmt.Errorf("failed to marshal email request: %v", err)
+		return nil, utils.NewUnauthorizedError("invalid refresh token")
 	}
 
-	client := fiber.AcquireClient()
-	defer fiber.ReleaseClient(client)
+	record, err := s.refreshTokenRepo.FindByTokenID(tokenID)
+	if err != nil || record.UserID != userID || record.ClientID != clientID {
+		return nil, utils.NewUnauthorizedError("invalid refresh token")
+	}
+
+	if record.IsRevoked {
+		if record.ReplacedBy != nil {
+			s.revokeTokenFamily(ctx, record, "reuse of rotated refresh token")
+			return nil, utils.NewUnauthorizedError("refresh token reuse detected, please log in again")
+		}
+		return nil, utils.NewUnauthorizedError("refresh token has been revoked")
+	}
 
-	req := client.Post(notificationServiceURL + "/email/send")
-	req.Body(jsonData)
-	req.Set("Content-Type", "application/json")
+	if time.Now().After(record.ExpiresAt) {
+		return nil, utils.NewUnauthorizedError("refresh token has expired")
+	}
 
-	status, body, errs := req.Bytes()
-	if len(errs) > 0 {
-		return fmt.Errorf("failed to send email request: %v", errs[0])
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewUnauthorizedError("user not found")
 	}
 
-	if status != 200 {
-		return fmt.Errorf("notification service returned status %d: %s", status, string(body))
+	if user.Status != models.UserStatusActive {
+		return nil, utils.NewForbiddenError("user account is not active")
 	}
 
-	return nil
+	return s.issueTokens(ctx, user, record, TokenOptions{})
+}
+
+// revokeTokenFamily revokes every refresh token descended from the same login.
+func (s *authService) revokeTokenFamily(ctx context.Context, record *models.RefreshToken, reason string) {
+	log.Printf("Revoking refresh token family %s for user %d: %s", tokenFamilyID(record), record.UserID, reason)
+	if err := s.refreshTokenRepo.RevokeFamily(tokenFamilyID(record)); err != nil {
+		log.Printf("Failed to revoke refresh token family %s: %v", tokenFamilyID(record), err)
+	}
+	if err := s.sessionService.EndFamilySession(ctx, tokenFamilyID(record), "refresh_token_reuse"); err != nil {
+		log.Printf("Failed to end session of refresh token family %s: %v", tokenFamilyID(record), err)
+	}
+}
+
+// tokenFamilyID falls back to the token's own ID for rows created before
+// families were tracked.
+func tokenFamilyID(record *models.RefreshToken) string {
+	if record.FamilyID != "" {
+		return record.FamilyID
+	}
+	return record.TokenID
+}
+
+func (s *authService) ChangePassword(ctx context.Context, userID uint, req *input.ChangePasswordRequest) error {
+	err := s.changePassword(ctx, userID, req)
+	s.auditService.Record(ctx, models.AuthEvent{Type: models.AuthEventPasswordChange, SubjectID: &userID}, err)
+	return err
+}
+
+func (s *authService) changePassword(ctx context.Context, userID uint, req *input.ChangePasswordRequest) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	if user.PasswordHash == nil || !utils.CheckPassword(req.CurrentPassword, *user.PasswordHash) {
+		return errors.New("current password is incorrect")
+	}
+
+	newPasswordHash, err := s.passwordPolicy.HashPassword(user, req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = &newPasswordHash
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	s.userRepo.UpdatePasswordChangedAt(userID)
+	s.passwordPolicy.RecordPassword(userID, newPasswordHash)
+
+	return nil
+}
+
+func (s *authService) SendReauthenticationOTP(ctx context.Context, userID uint, channel models.OTPChannel) (*output.OTPResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	target := verifiedContact(user, channel)
+	if target == "" {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("no verified %s on this account", channel))
+	}
+
+	if err := s.rateLimitService.LimitOTPSend(ctx, target, user.ID); err != nil {
+		return nil, err
+	}
+
+	otp, err := s.issueOTP(target, channel, models.OTPPurposeReauth, user.ID)
+	if err != nil {
+		return nil, utils.NewInternalServerError("failed to generate OTP")
+	}
+
+	if err := s.sendOTP(ctx, channel, target, otp); err != nil {
+		log.Printf("Failed to queue reauthentication OTP to %s: %v", target, err)
+		return nil, utils.NewInternalServerError("failed to send OTP")
+	}
+
+	return &output.OTPResponse{
+		Message:   fmt.Sprintf("OTP sent to %s successfully", channel),
+		ExpiresIn: int(otpTTL.Seconds()),
+	}, nil
+}
+
+// verifiedContact returns the user's email or phone for channel, or an empty
+// string unless it is set and verified.
+func verifiedContact(user *models.User, channel models.OTPChannel) string {
+	current, verified := user.Email, user.EmailVerified
+	if channel == models.OTPChannelPhone {
+		current, verified = user.Phone, user.PhoneVerified
+	}
+
+	if current == nil || !verified {
+		return ""
+	}
+	return *current
+}
+
+func (s *authService) Reauthenticate(ctx context.Context, claims *output.Claims, req *input.ReauthenticateRequest) (*output.ReauthenticationResponse, error) {
+	resp, err := s.reauthenticate(ctx, claims, req)
+	s.auditService.Record(ctx, models.AuthEvent{Type: models.AuthEventReauthenticate, SubjectID: &claims.UserID, Reason: req.Method}, err)
+	return resp, err
+}
+
+func (s *authService) reauthenticate(ctx context.Context, claims *output.Claims, req *input.ReauthenticateRequest) (*output.ReauthenticationResponse, error) {
+	user, err := s.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return nil, utils.NewUnauthorizedError("user not found")
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, utils.NewForbiddenError("user account is not active")
+	}
+
+	var amr []string
+	switch req.Method {
+	case "password":
+		if err := s.rateLimitService.CheckLockout(user); err != nil {
+			return nil, err
+		}
+		if user.PasswordHash == nil || !utils.CheckPassword(req.Password, *user.PasswordHash) {
+			if err := s.rateLimitService.RecordLoginFailure(user); err != nil {
+				return nil, err
+			}
+			return nil, utils.NewUnauthorizedError("invalid credentials")
+		}
+		s.rateLimitService.RecordLoginSuccess(user)
+		amr = []string{utils.AMRPassword}
+	case "totp":
+		if err := s.mfaService.VerifyCode(ctx, user.ID, req.Code); err != nil {
+			return nil, err
+		}
+		amr = []string{utils.AMRMFA, utils.AMROTP}
+	case "otp":
+		channel := models.OTPChannel(req.Channel)
+		target := verifiedContact(user, channel)
+		if target == "" {
+			return nil, utils.NewBadRequestError(fmt.Sprintf("no verified %s on this account", channel))
+		}
+		otp, err := s.consumeOTP(target, channel, req.Code, models.OTPPurposeReauth)
+		if err != nil {
+			return nil, err
+		}
+		if otp.UserID == nil || *otp.UserID != user.ID {
+			return nil, utils.NewUnauthorizedError("OTP is invalid or has expired")
+		}
+		amr = []string{otpAMR(channel)}
+	default:
+		return nil, utils.NewBadRequestError("unsupported reauthentication method")
+	}
+
+	authTime := time.Now()
+	stepUp := newAccessClaims(user)
+	stepUp.ClientID = claims.ClientID
+	stepUp.Scope = claims.Scope
+	stepUp.SessionID = claims.SessionID
+	stepUp.AuthTime = authTime.Unix()
+	stepUp.AMR = amr
+
+	accessToken, err := utils.GenerateStepUpToken(stepUp, s.stepUpConfig.TokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &output.ReauthenticationResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.stepUpConfig.TokenTTL.Seconds()),
+		AuthTime:    authTime.Unix(),
+	}, nil
+}
+
+func (s *authService) SendContactVerification(ctx context.Context, userID uint, channel models.OTPChannel) (*output.OTPResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	target, purpose := contactToVerify(user, channel)
+	if target == "" {
+		if purpose == models.OTPPurposeVerify {
+			return nil, utils.NewBadRequestError(fmt.Sprintf("%s is already verified", channel))
+		}
+		return nil, utils.NewBadRequestError(fmt.Sprintf("no %s on this account", channel))
+	}
+
+	if err := s.rateLimitService.LimitOTPSend(ctx, target, user.ID); err != nil {
+		return nil, err
+	}
+
+	otp, err := s.issueOTP(target, channel, purpose, user.ID)
+	if err != nil {
+		return nil, utils.NewInternalServerError("failed to generate OTP")
+	}
+
+	if err := s.sendOTP(ctx, channel, target, otp); err != nil {
+		log.Printf("Failed to queue verification OTP to %s: %v", target, err)
+		return nil, utils.NewInternalServerError("failed to send OTP")
+	}
+
+	return &output.OTPResponse{
+		Message:   fmt.Sprintf("OTP sent to %s successfully", channel),
+		ExpiresIn: int(otpTTL.Seconds()),
+	}, nil
+}
+
+// contactToVerify returns the pending value for channel with
+// OTPPurposeChange, otherwise the current unverified value with
+// OTPPurposeVerify. The value is empty when there is nothing to verify.
+func contactToVerify(user *models.User, channel models.OTPChannel) (string, models.OTPPurpose) {
+	current, pending, verified := user.Email, user.PendingEmail, user.EmailVerified
+	if channel == models.OTPChannelPhone {
+		current, pending, verified = user.Phone, user.PendingPhone, user.PhoneVerified
+	}
+
+	if pending != nil && *pending != "" {
+		return *pending, models.OTPPurposeChange
+	}
+	if current == nil || *current == "" {
+		return "", models.OTPPurposeChange
+	}
+	if verified {
+		return "", models.OTPPurposeVerify
+	}
+	return *current, models.OTPPurposeVerify
+}
+
+func (s *authService) ConfirmContactVerification(ctx context.Context, userID uint, req *input.ConfirmContactRequest) (*output.UserInfo, error) {
+	info, err := s.confirmContactVerification(ctx, userID, req)
+	s.auditService.Record(ctx, models.AuthEvent{Type: models.AuthEventContactVerify, SubjectID: &userID, Reason: req.Channel}, err)
+	return info, err
+}
+
+func (s *authService) confirmContactVerification(ctx context.Context, userID uint, req *input.ConfirmContactRequest) (*output.UserInfo, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	channel := models.OTPChannel(req.Channel)
+	target, purpose := contactToVerify(user, channel)
+	if target == "" {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("no %s waiting for verification", channel))
+	}
+
+	otp, err := s.consumeOTP(target, channel, req.OTP, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if otp.UserID == nil || *otp.UserID != user.ID {
+		return nil, utils.NewUnauthorizedError("OTP is invalid or has expired")
+	}
+
+	if purpose == models.OTPPurposeChange {
+		if err := s.checkContactAvailable(user.ID, channel, target); err != nil {
+			return nil, err
+		}
+		value := target
+		if channel == models.OTPChannelPhone {
+			user.Phone, user.PendingPhone = &value, nil
+		} else {
+			user.Email, user.PendingEmail = &value, nil
+		}
+	}
+
+	if channel == models.OTPChannelPhone {
+		user.PhoneVerified = true
+	} else {
+		user.EmailVerified = true
+	}
+	if user.Status == models.UserStatusPending && !user.AwaitsInvitation() {
+		user.Status = models.UserStatusActive
+	}
+
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[CONTACT] User %d verified %s", user.ID, channel)
+
+	info := newUserInfo(user)
+	return &info, nil
+}
+
+// RequestContactChange is audited with the new email or phone as the
+// identifier.
+func (s *authService) RequestContactChange(ctx context.Context, userID uint, req *input.ChangeContactRequest) (*output.OTPResponse, error) {
+	resp, err := s.requestContactChange(ctx, userID, req)
+	identifier := req.Email
+	if req.Phone != "" {
+		identifier = req.Phone
+	}
+	s.auditService.Record(ctx, models.AuthEvent{Type: models.AuthEventContactChange, SubjectID: &userID, Identifier: identifier}, err)
+	return resp, err
+}
+
+func (s *authService) requestContactChange(ctx context.Context, userID uint, req *input.ChangeContactRequest) (*output.OTPResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	channel, value, current := models.OTPChannelEmail, req.Email, user.Email
+	if req.Phone != "" {
+		channel, value, current = models.OTPChannelPhone, req.Phone, user.Phone
+	}
+	if value == "" {
+		return nil, utils.NewBadRequestError("email or phone is required")
+	}
+
+	if current != nil && *current == value {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("%s is unchanged", channel))
+	}
+	if err := s.checkContactAvailable(user.ID, channel, value); err != nil {
+		return nil, err
+	}
+
+	if channel == models.OTPChannelPhone {
+		user.PendingPhone = &value
+	} else {
+		user.PendingEmail = &value
+	}
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return s.SendContactVerification(ctx, user.ID, channel)
+}
+
+// checkContactAvailable fails when another account already uses value.
+func (s *authService) checkContactAvailable(userID uint, channel models.OTPChannel, value string) error {
+	getUser := s.userRepo.GetByEmail
+	if channel == models.OTPChannelPhone {
+		getUser = s.userRepo.GetByPhone
+	}
+
+	existing, err := getUser(value)
+	if err == nil && existing.ID != userID {
+		return utils.NewConflictError(fmt.Sprintf("%s is already in use", channel))
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (s *authService) GetUserInfo(ctx context.Context, userID uint) (*output.UserInfo, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	info := newUserInfo(user)
+	return &info, nil
+}
+
+func (s *authService) GetOIDCUserInfo(ctx context.Context, userID uint) (*output.OIDCUserInfo, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewUnauthorizedError("user not found")
+	}
+
+	info := output.NewOIDCUserInfo(newUserInfo(user))
+	return &info, nil
+}
+
+func newUserInfo(user *models.User) output.UserInfo {
+	return output.UserInfo{
+		ID:            user.ID,
+		Email:         user.Email,
+		Phone:         user.Phone,
+		Username:      user.Username,
+		UserType:      string(user.UserType),
+		Role:          user.Role.RoleName,
+		Status:        string(user.Status),
+		EmailVerified: user.EmailVerified,
+		PhoneVerified: user.PhoneVerified,
+		CreatedAt:     user.CreatedAt,
+		LastLoginAt:   user.LastLoginAt,
+		LockedUntil:   user.LockedUntil,
+		PendingEmail:  user.PendingEmail,
+		PendingPhone:  user.PendingPhone,
+	}
+}
+
+func (s *authService) ValidateToken(ctx context.Context, tokenString string) (*output.TokenValidationResponse, error) {
+	claims, err := utils.ValidateJWT(tokenString)
+	if err != nil {
+		return &output.TokenValidationResponse{Valid: false}, nil
+	}
+
+	return &output.TokenValidationResponse{
+		Valid:    true,
+		UserID:   claims.UserID,
+		UserType: claims.UserType,
+		Role:     claims.Role,
+		Claims:   *claims,
+	}, nil
+}
+
+// IntrospectToken needs no type hint: access and refresh tokens are both
+// signed JWTs that say which they are.
+func (s *authService) IntrospectToken(ctx context.Context, token string) (*output.TokenIntrospectionResponse, error) {
+	if _, _, err := utils.ValidateRefreshToken(token); err == nil {
+		return s.introspectRefreshToken(token)
+	}
+
+	// ValidateJWT rejects revoked access tokens.
+	claims, err := utils.ValidateJWT(token)
+	if err != nil {
+		return &output.TokenIntrospectionResponse{Active: false}, nil
+	}
+
+	return &output.TokenIntrospectionResponse{
+		Active:    true,
+		TokenType: "Bearer",
+		TokenUse:  "access_token",
+		Sub:       tokenSubject(claims),
+		Iss:       utils.Issuer(),
+		Claims:    claims,
+	}, nil
+}
+
+// introspectRefreshToken checks the token against its refresh_tokens row and
+// describes it with the claims an access token issued from it would carry.
+func (s *authService) introspectRefreshToken(token string) (*output.TokenIntrospectionResponse, error) {
+	inactive := &output.TokenIntrospectionResponse{Active: false}
+
+	userID, tokenID, err := utils.ValidateRefreshToken(token)
+	if err != nil {
+		return inactive, nil
+	}
+
+	record, err := s.refreshTokenRepo.FindByTokenID(tokenID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return inactive, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.UserID != userID || record.IsRevoked || time.Now().After(record.ExpiresAt) {
+		return inactive, nil
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil || user.Status != models.UserStatusActive {
+		return inactive, nil
+	}
+
+	claims := newAccessClaims(user)
+	claims.ClientID = record.ClientID
+	claims.Scope = record.Scope
+	claims.TokenID = record.TokenID
+	claims.IssuedAt = record.CreatedAt.Unix()
+	claims.ExpiresAt = record.ExpiresAt.Unix()
+	claims.AMR = strings.Fields(record.AMR)
+	if record.AuthTime != nil {
+		claims.AuthTime = record.AuthTime.Unix()
+	}
+
+	return &output.TokenIntrospectionResponse{
+		Active:   true,
+		TokenUse: "refresh_token",
+		Sub:      tokenSubject(&claims),
+		Iss:      utils.Issuer(),
+		Claims:   &claims,
+	}, nil
+}
+
+// tokenSubject returns the "sub" the token was issued with.
+func tokenSubject(claims *output.Claims) string {
+	if claims.UserType == string(models.UserTypeService) {
+		return "service:" + claims.ClientID
+	}
+	return strconv.FormatUint(uint64(claims.UserID), 10)
+}
+
+// RevokeToken is only audited when it revoked something, against the
+// token's user.
+func (s *authService) RevokeToken(ctx context.Context, token, revokedBy string) error {
+	userID, revoked, err := s.revokeToken(ctx, token)
+	if revoked || err != nil {
+		event := models.AuthEvent{Type: models.AuthEventTokenRevoke, Identifier: revokedBy}
+		if userID != 0 {
+			event.SubjectID = &userID
+		}
+		s.auditService.Record(ctx, event, err)
+	}
+	return err
+}
+
+// revokeToken reports whether there was a token to revoke and, unless it
+// belongs to a service account, its user. Revoking a refresh token ends its
+// session, which revokes the access tokens issued from it as well.
+func (s *authService) revokeToken(ctx context.Context, token string) (uint, bool, error) {
+	if userID, tokenID, err := utils.ValidateRefreshToken(token); err == nil {
+		record, err := s.refreshTokenRepo.FindByTokenID(tokenID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		if record.UserID != userID || record.IsRevoked {
+			return 0, false, nil
+		}
+
+		if err := s.refreshTokenRepo.RevokeFamily(tokenFamilyID(record)); err != nil {
+			return userID, true, err
+		}
+		return userID, true, s.sessionService.EndFamilySession(ctx, tokenFamilyID(record), "oauth_revoke")
+	}
+
+	claims, err := utils.ValidateJWT(token)
+	if err != nil {
+		return 0, false, nil
+	}
+
+	// Service tokens carry no user ID, so this is zero for them.
+	return claims.UserID, true, s.revocationService.RevokeToken(claims.TokenID, claims.UserID, time.Unix(claims.ExpiresAt, 0), "oauth_revoke")
+}
+
+// Logout ends the session of the presented refresh token and revokes its
+// access tokens. Without a refresh token the user is logged out everywhere.
+// An impersonation token only revokes itself.
+func (s *authService) Logout(ctx context.Context, claims *output.Claims, refreshToken string) error {
+	err := s.logout(ctx, claims, refreshToken)
+	s.auditService.Record(ctx, models.AuthEvent{Type: models.AuthEventLogout, SubjectID: &claims.UserID}, err)
+	return err
+}
+
+func (s *authService) logout(ctx context.Context, claims *output.Claims, refreshToken string) error {
+	userID := claims.UserID
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+
+	// Ending an impersonation must not sign the user themselves out.
+	if claims.ImpersonatorID != 0 {
+		return s.revocationService.RevokeToken(claims.TokenID, userID, expiresAt, "impersonation_ended")
+	}
+
+	if refreshToken == "" {
+		return s.sessionService.LogoutAll(ctx, userID, "logout_all")
+	}
+
+	tokenUserID, tokenID, err := utils.ValidateRefreshToken(refreshToken)
+	if err != nil || tokenUserID != userID {
+		return utils.NewUnauthorizedError("invalid refresh token")
+	}
+
+	record, err := s.refreshTokenRepo.FindByTokenID(tokenID)
+	if err != nil || record.UserID != userID {
+		return utils.NewUnauthorizedError("invalid refresh token")
+	}
+
+	if err := s.refreshTokenRepo.RevokeFamily(tokenFamilyID(record)); err != nil {
+		return err
+	}
+
+	if err := s.sessionService.EndFamilySession(ctx, tokenFamilyID(record), "logout"); err != nil {
+		return err
+	}
+
+	// Tokens issued before sessions were tracked carry no sid.
+	return s.revocationService.RevokeToken(claims.TokenID, userID, expiresAt, "logout")
+}
+
+// issueOTP creates an OTP for identifier, bound to userID unless it is zero.
+func (s *authService) issueOTP(identifier string, channel models.OTPChannel, purpose models.OTPPurpose, userID uint) (string, error) {
+	otp, err := utils.GenerateOTP()
+	if err != nil {
+		return "", err
+	}
+
+	record := &models.OTPCode{
+		Identifier:  identifier,
+		Channel:     channel,
+		Purpose:     purpose,
+		CodeHash:    utils.HashToken(identifier + ":" + otp),
+		MaxAttempts: otpMaxAttempts,
+		ExpiresAt:   time.Now().Add(otpTTL),
+	}
+	if userID != 0 {
+		record.UserID = &userID
+	}
+	if err := s.otpRepo.Create(record); err != nil {
+		return "", err
+	}
+
+	return otp, nil
+}
+
+// consumeOTP checks a code against the latest outstanding OTP for the
+// identifier, provided it was issued for one of purposes. Every wrong guess
+// counts against the OTP, and a matching code can only be consumed once.
+func (s *authService) consumeOTP(identifier string, channel models.OTPChannel, code string, purposes ...models.OTPPurpose) (*models.OTPCode, error) {
+	otp, err := s.otpRepo.GetActive(identifier, channel)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewUnauthorizedError("OTP is invalid or has expired")
+		}
+		return nil, utils.NewInternalServerError("failed to verify OTP")
+	}
+
+	if !slices.Contains(purposes, otp.Purpose) {
+		return nil, utils.NewUnauthorizedError("OTP is invalid or has expired")
+	}
+
+	// Claim the attempt before comparing so concurrent guesses cannot all
+	// pass the attempts check against the same stale count.
+	claimed, err := s.otpRepo.ClaimAttempt(otp.ID)
+	if err != nil {
+		return nil, utils.NewInternalServerError("failed to verify OTP")
+	}
+	if !claimed {
+		return nil, utils.NewUnauthorizedError("too many invalid attempts, request a new OTP")
+	}
+
+	if !utils.CompareTokenHash(identifier+":"+code, otp.CodeHash) {
+		return nil, utils.NewUnauthorizedError("OTP is invalid or has expired")
+	}
+
+	consumed, err := s.otpRepo.Consume(otp.ID)
+	if err != nil {
+		return nil, utils.NewInternalServerError("failed to verify OTP")
+	}
+	if !consumed {
+		return nil, utils.NewUnauthorizedError("OTP is invalid or has expired")
+	}
+
+	return otp, nil
+}
+
+// generateTokens completes a first-factor login made with the methods in
+// amr. Users who need a second factor get an MFA challenge instead of tokens.
+func (s *authService) generateTokens(ctx context.Context, user *models.User, amr ...string) (*output.AuthResponse, error) {
+	challenge, err := s.mfaService.Challenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+
+	return s.issueTokens(ctx, user, nil, TokenOptions{AMR: amr})
+}
+
+func (s *authService) IssueTokens(ctx context.Context, userID uint, opts TokenOptions) (*output.AuthResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewUnauthorizedError("user not found")
+	}
+
+	if user.Status != models.UserStatusActive {
+		return nil, utils.NewForbiddenError("user account is not active")
+	}
+
+	return s.issueTokens(ctx, user, nil, opts)
+}
+
+// issueTokens signs a new access/refresh pair. When previous is set the new
+// refresh token joins its family, inherits its client binding and previous is
+// revoked in the same step.
+func (s *authService) issueTokens(ctx context.Context, user *models.User, previous *models.RefreshToken, opts TokenOptions) (*output.AuthResponse, error) {
+	if previous != nil {
+		opts.ClientID = previous.ClientID
+		opts.Scope = previous.Scope
+		// auth_time is the original login, so it is carried across rotations.
+		if previous.AuthTime != nil {
+			opts.AuthTime = *previous.AuthTime
+		}
+		opts.AMR = strings.Fields(previous.AMR)
+	}
+	if opts.AuthTime.IsZero() {
+		opts.AuthTime = time.Now()
+	}
+
+	claims := newAccessClaims(user)
+	claims.ClientID = opts.ClientID
+	claims.Scope = opts.Scope
+	claims.AuthTime = opts.AuthTime.Unix()
+	claims.AMR = opts.AMR
+
+	authTime := opts.AuthTime
+	tokenRecord := &models.RefreshToken{
+		TokenID:   uuid.New().String(),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(time.Hour * 24 * 90),
+		AuthTime:  &authTime,
+		AMR:       strings.Join(opts.AMR, " "),
+		ClientID:  opts.ClientID,
+		Scope:     opts.Scope,
+	}
+	tokenRecord.FamilyID = tokenRecord.TokenID
+	if previous != nil {
+		tokenRecord.FamilyID = tokenFamilyID(previous)
+	}
+
+	var sessionID string
+	var err error
+	if previous != nil {
+		sessionID, err = s.sessionService.RenewSession(ctx, user.ID, tokenRecord.FamilyID, opts.ClientID, tokenRecord.ExpiresAt)
+	} else {
+		sessionID, err = s.sessionService.StartSession(ctx, user.ID, tokenRecord.FamilyID, opts.ClientID, tokenRecord.ExpiresAt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	claims.SessionID = sessionID
+
+	accessToken, err := utils.GenerateJWT(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateRefreshToken(user.ID, tokenRecord.TokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != nil {
+		rotated, err := s.refreshTokenRepo.Rotate(previous.TokenID, tokenRecord)
+		if err != nil {
+			return nil, err
+		}
+		if !rotated {
+			s.revokeTokenFamily(ctx, previous, "concurrent reuse of rotated refresh token")
+			return nil, utils.NewUnauthorizedError("refresh token reuse detected, please log in again")
+		}
+	} else if err := s.refreshTokenRepo.Create(tokenRecord); err != nil {
+		return nil, err
+	}
+
+	userInfo := newUserInfo(user)
+
+	audience := utils.DefaultIDTokenAudience()
+	if opts.ClientID != "" {
+		audience = opts.ClientID
+	}
+
+	idToken, err := utils.GenerateIDToken(output.NewOIDCUserInfo(userInfo), audience, opts.Nonce, authTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &output.AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+		IDToken:      idToken,
+		Scope:        opts.Scope,
+		User:         userInfo,
+	}, nil
+}
+
+// newAccessClaims returns the access token claims describing user.
+func newAccessClaims(user *models.User) output.Claims {
+	var email, phone, googleID string
+	if user.Email != nil {
+		email = *user.Email
+	}
+	if user.Phone != nil {
+		phone = *user.Phone
+	}
+	if user.GoogleID != nil {
+		googleID = *user.GoogleID
+	}
+
+	claims := output.Claims{
+		UserID:       user.ID,
+		UserType:     string(user.UserType),
+		Role:         user.Role.RoleName,
+		Email:        email,
+		Phone:        phone,
+		GoogleID:     googleID,
+		IdentityType: utils.GetIdentityType(email, phone, googleID),
+	}
+
+	if user.AppleID != nil {
+		claims.AppleID = *user.AppleID
+		claims.FirebaseUID = *user.AppleID
+	} else if user.GoogleID != nil {
+		claims.FirebaseUID = *user.GoogleID
+	}
+
+	return claims
+}
+
+// otpAMR is the method reference of a code sent over channel.
+func otpAMR(channel models.OTPChannel) string {
+	if channel == models.OTPChannelPhone {
+		return utils.AMRSMS
+	}
+	return utils.AMROTP
+}
+
+// recordLogin audits a sign-in attempt, against user when it is known.
+func (s *authService) recordLogin(ctx context.Context, eventType models.AuthEventType, identifier string, user *models.User, err error) {
+	event := models.AuthEvent{Type: eventType, Identifier: identifier}
+	if user != nil {
+		event.SubjectID = &user.ID
+	}
+	s.auditService.Record(ctx, event, err)
+}
+
+// sendOTP queues otp for delivery to identifier over channel.
+func (s *authService) sendOTP(ctx context.Context, channel models.OTPChannel, identifier, otp string) error {
+	data := map[string]string{
+		"otp":                otp,
+		"expires_in_minutes": strconv.Itoa(int(otpTTL.Minutes())),
+	}
+	if channel == models.OTPChannelPhone {
+		return s.notificationService.SendSMS(ctx, identifier, "otp", data)
+	}
+	return s.notificationService.SendEmail(ctx, identifier, "otp", data)
 }
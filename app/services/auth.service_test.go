@@ -0,0 +1,921 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+// fakeOTPRepo keeps the latest OTP per identifier.
+type fakeOTPRepo struct {
+	repo.OTPRepository
+	codes  map[string]*models.OTPCode
+	nextID uint
+}
+
+func (r *fakeOTPRepo) Create(otp *models.OTPCode) error {
+	r.nextID++
+	otp.ID = r.nextID
+	if r.codes == nil {
+		r.codes = make(map[string]*models.OTPCode)
+	}
+	r.codes[otp.Identifier] = otp
+	return nil
+}
+
+func (r *fakeOTPRepo) GetActive(identifier string, channel models.OTPChannel) (*models.OTPCode, error) {
+	otp, ok := r.codes[identifier]
+	if !ok || otp.Channel != channel || otp.ConsumedAt != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return otp, nil
+}
+
+func (r *fakeOTPRepo) ClaimAttempt(id uint) (bool, error) {
+	for _, otp := range r.codes {
+		if otp.ID == id && otp.ConsumedAt == nil && otp.Attempts < otp.MaxAttempts {
+			otp.Attempts++
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeOTPRepo) Consume(id uint) (bool, error) {
+	for _, otp := range r.codes {
+		if otp.ID == id && otp.ConsumedAt == nil {
+			now := time.Now()
+			otp.ConsumedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestConsumeOTPUserPendingAccounts(t *testing.T) {
+	admin := uint(1)
+
+	tests := []struct {
+		name       string
+		user       models.User
+		wantErr    bool
+		wantStatus models.UserStatus
+	}{
+		{
+			name:       "self-registered mobile user",
+			user:       models.User{UserType: models.UserTypeMobile, Status: models.UserStatusPending},
+			wantStatus: models.UserStatusActive,
+		},
+		{
+			name:       "invited partner",
+			user:       models.User{UserType: models.UserTypePartner, Status: models.UserStatusPending, CreatedBy: &admin},
+			wantErr:    true,
+			wantStatus: models.UserStatusPending,
+		},
+		{
+			name:       "invited admin",
+			user:       models.User{UserType: models.UserTypeAdmin, Status: models.UserStatusPending, CreatedBy: &admin},
+			wantErr:    true,
+			wantStatus: models.UserStatusPending,
+		},
+		{
+			name:       "mobile user created by an admin",
+			user:       models.User{UserType: models.UserTypeMobile, Status: models.UserStatusPending, CreatedBy: &admin},
+			wantErr:    true,
+			wantStatus: models.UserStatusPending,
+		},
+		{
+			name:       "active partner",
+			user:       models.User{UserType: models.UserTypePartner, Status: models.UserStatusActive, CreatedBy: &admin},
+			wantStatus: models.UserStatusActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := "user@example.com"
+			user := tt.user
+			user.ID = 2
+			user.Email = &email
+
+			users := &fakeUserRepo{users: map[uint]*models.User{user.ID: &user}}
+			s := &authService{userRepo: users, otpRepo: &fakeOTPRepo{}}
+
+			code, err := s.issueOTP(email, models.OTPChannelEmail, models.OTPPurposeLogin, 0)
+			if err != nil {
+				t.Fatalf("issueOTP: %v", err)
+			}
+
+			_, err = s.consumeOTPUser(models.OTPChannelEmail, email, code)
+			if tt.wantErr && statusOf(err) != http.StatusForbidden {
+				t.Errorf("consumeOTPUser error = %v, want 403", err)
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("consumeOTPUser: %v", err)
+			}
+
+			if got := users.users[user.ID].Status; got != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestConsumeOTP(t *testing.T) {
+	const email = "user@example.com"
+
+	tests := []struct {
+		name       string
+		purpose    models.OTPPurpose
+		wrong      int
+		identifier string
+		reuse      bool
+		wantStatus int
+	}{
+		{name: "valid code", purpose: models.OTPPurposeLogin, wantStatus: http.StatusOK},
+		{name: "valid after wrong guesses", purpose: models.OTPPurposeLogin, wrong: otpMaxAttempts - 1, wantStatus: http.StatusOK},
+		{name: "attempts exhausted", purpose: models.OTPPurposeLogin, wrong: otpMaxAttempts, wantStatus: http.StatusUnauthorized},
+		{name: "code reused", purpose: models.OTPPurposeLogin, reuse: true, wantStatus: http.StatusUnauthorized},
+		{name: "other purpose", purpose: models.OTPPurposeReauth, wantStatus: http.StatusUnauthorized},
+		{name: "other identifier", purpose: models.OTPPurposeLogin, identifier: "other@example.com", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otpRepo := &fakeOTPRepo{}
+			s := &authService{otpRepo: otpRepo}
+
+			code, err := s.issueOTP(email, models.OTPChannelEmail, tt.purpose, 0)
+			if err != nil {
+				t.Fatalf("issueOTP: %v", err)
+			}
+			if otpRepo.codes[email].CodeHash == code {
+				t.Fatal("OTP stored in plain text")
+			}
+
+			for i := 0; i < tt.wrong; i++ {
+				if _, err := s.consumeOTP(email, models.OTPChannelEmail, "wrong", models.OTPPurposeLogin); statusOf(err) != http.StatusUnauthorized {
+					t.Fatalf("wrong guess %d: %v", i+1, err)
+				}
+			}
+
+			identifier := email
+			if tt.identifier != "" {
+				identifier = tt.identifier
+			}
+			if tt.reuse {
+				if _, err := s.consumeOTP(identifier, models.OTPChannelEmail, code, models.OTPPurposeLogin); err != nil {
+					t.Fatalf("first use: %v", err)
+				}
+			}
+
+			_, err = s.consumeOTP(identifier, models.OTPChannelEmail, code, models.OTPPurposeLogin)
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+		})
+	}
+}
+
+// fakeRefreshTokenRepo keeps refresh tokens in memory, keyed by token ID.
+type fakeRefreshTokenRepo struct {
+	repo.RefreshTokenRepository
+	tokens map[string]*models.RefreshToken
+}
+
+func (r *fakeRefreshTokenRepo) Create(token *models.RefreshToken) error {
+	stored := *token
+	r.tokens[token.TokenID] = &stored
+	return nil
+}
+
+func (r *fakeRefreshTokenRepo) FindByTokenID(tokenID string) (*models.RefreshToken, error) {
+	token, ok := r.tokens[tokenID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *token
+	return &found, nil
+}
+
+func (r *fakeRefreshTokenRepo) Rotate(tokenID string, next *models.RefreshToken) (bool, error) {
+	token, ok := r.tokens[tokenID]
+	if !ok || token.IsRevoked {
+		return false, nil
+	}
+	token.IsRevoked = true
+	token.ReplacedBy = &next.TokenID
+	return true, r.Create(next)
+}
+
+func (r *fakeRefreshTokenRepo) RevokeFamily(familyID string) error {
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID {
+			token.IsRevoked = true
+		}
+	}
+	return nil
+}
+
+func (r *fakeRefreshTokenRepo) RevokeByUserID(userID uint) error {
+	for _, token := range r.tokens {
+		if token.UserID == userID {
+			token.IsRevoked = true
+		}
+	}
+	return nil
+}
+
+// fakeSessionService records the refresh token families whose sessions
+// were ended and the users signed out everywhere.
+type fakeSessionService struct {
+	SessionService
+	ended     []string
+	loggedOut []uint
+}
+
+func (s *fakeSessionService) StartSession(ctx context.Context, userID uint, familyID, clientID string, expiresAt time.Time) (string, error) {
+	return familyID, nil
+}
+
+func (s *fakeSessionService) RenewSession(ctx context.Context, userID uint, familyID, clientID string, expiresAt time.Time) (string, error) {
+	return familyID, nil
+}
+
+func (s *fakeSessionService) EndFamilySession(ctx context.Context, familyID string, reason string) error {
+	s.ended = append(s.ended, familyID)
+	return nil
+}
+
+func (s *fakeSessionService) LogoutAll(ctx context.Context, userID uint, reason string) error {
+	s.loggedOut = append(s.loggedOut, userID)
+	return nil
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	user := &models.User{ID: 7, UserType: models.UserTypeMobile, Status: models.UserStatusActive}
+
+	tests := []struct {
+		name string
+		// prepare returns the refresh token to present after a login that
+		// issued first.
+		prepare     func(t *testing.T, s *authService, first string) string
+		clientID    string
+		wantStatus  int
+		wantRevoked bool
+	}{
+		{
+			name:       "current token",
+			prepare:    func(t *testing.T, s *authService, first string) string { return first },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "rotated token reused",
+			prepare: func(t *testing.T, s *authService, first string) string {
+				if _, err := s.refresh(context.Background(), first, ""); err != nil {
+					t.Fatalf("first refresh: %v", err)
+				}
+				return first
+			},
+			wantStatus:  http.StatusUnauthorized,
+			wantRevoked: true,
+		},
+		{
+			name: "revoked by logout",
+			prepare: func(t *testing.T, s *authService, first string) string {
+				_, tokenID, _ := utils.ValidateRefreshToken(first)
+				s.refreshTokenRepo.(*fakeRefreshTokenRepo).tokens[tokenID].IsRevoked = true
+				return first
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "expired",
+			prepare: func(t *testing.T, s *authService, first string) string {
+				_, tokenID, _ := utils.ValidateRefreshToken(first)
+				s.refreshTokenRepo.(*fakeRefreshTokenRepo).tokens[tokenID].ExpiresAt = time.Now().Add(-time.Minute)
+				return first
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "presented by another client",
+			prepare:    func(t *testing.T, s *authService, first string) string { return first },
+			clientID:   "web",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refreshTokens := &fakeRefreshTokenRepo{tokens: make(map[string]*models.RefreshToken)}
+			sessions := &fakeSessionService{}
+			s := &authService{
+				userRepo:         &fakeUserRepo{users: map[uint]*models.User{user.ID: user}},
+				refreshTokenRepo: refreshTokens,
+				sessionService:   sessions,
+			}
+
+			login, err := s.issueTokens(context.Background(), user, nil, TokenOptions{})
+			if err != nil {
+				t.Fatalf("issueTokens: %v", err)
+			}
+			_, familyID, _ := utils.ValidateRefreshToken(login.RefreshToken)
+
+			_, err = s.refresh(context.Background(), tt.prepare(t, s, login.RefreshToken), tt.clientID)
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+
+			live := 0
+			for _, token := range refreshTokens.tokens {
+				if !token.IsRevoked {
+					live++
+				}
+			}
+			if tt.wantRevoked {
+				if live != 0 {
+					t.Errorf("%d refresh tokens of the family are still live", live)
+				}
+				if len(sessions.ended) != 1 || sessions.ended[0] != familyID {
+					t.Errorf("ended sessions = %v, want [%s]", sessions.ended, familyID)
+				}
+			} else if len(sessions.ended) != 0 {
+				t.Errorf("ended sessions = %v, want none", sessions.ended)
+			}
+		})
+	}
+}
+
+func TestContactToVerify(t *testing.T) {
+	value := func(s string) *string { return &s }
+
+	tests := []struct {
+		name        string
+		user        models.User
+		channel     models.OTPChannel
+		wantTarget  string
+		wantPurpose models.OTPPurpose
+	}{
+		{name: "unverified email", user: models.User{Email: value("a@example.com")}, channel: models.OTPChannelEmail, wantTarget: "a@example.com", wantPurpose: models.OTPPurposeVerify},
+		{name: "verified email", user: models.User{Email: value("a@example.com"), EmailVerified: true}, channel: models.OTPChannelEmail, wantPurpose: models.OTPPurposeVerify},
+		{name: "pending email change", user: models.User{Email: value("a@example.com"), EmailVerified: true, PendingEmail: value("b@example.com")}, channel: models.OTPChannelEmail, wantTarget: "b@example.com", wantPurpose: models.OTPPurposeChange},
+		{name: "no email", user: models.User{Phone: value("+15550100")}, channel: models.OTPChannelEmail, wantPurpose: models.OTPPurposeChange},
+		{name: "unverified phone", user: models.User{Email: value("a@example.com"), Phone: value("+15550100")}, channel: models.OTPChannelPhone, wantTarget: "+15550100", wantPurpose: models.OTPPurposeVerify},
+		{name: "pending phone change", user: models.User{Phone: value("+15550100"), PendingPhone: value("+15550101")}, channel: models.OTPChannelPhone, wantTarget: "+15550101", wantPurpose: models.OTPPurposeChange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, purpose := contactToVerify(&tt.user, tt.channel)
+			if target != tt.wantTarget || purpose != tt.wantPurpose {
+				t.Errorf("contactToVerify = %q, %s, want %q, %s", target, purpose, tt.wantTarget, tt.wantPurpose)
+			}
+		})
+	}
+}
+
+// newContactTestService returns an authService whose users are alice (7),
+// with a verified email and phone, and bob (8).
+func newContactTestService() (*authService, *fakeUserRepo, *fakeNotificationService) {
+	alice, alicePhone, bob := "alice@example.com", "+15550100", "bob@example.com"
+	users := &fakeUserRepo{users: map[uint]*models.User{
+		7: {ID: 7, Email: &alice, EmailVerified: true, Phone: &alicePhone, PhoneVerified: true, Status: models.UserStatusActive},
+		8: {ID: 8, Email: &bob, Status: models.UserStatusActive},
+	}}
+	notifications := &fakeNotificationService{}
+	s := &authService{
+		userRepo:            users,
+		otpRepo:             &fakeOTPRepo{},
+		rateLimitService:    &rateLimitService{},
+		notificationService: notifications,
+		auditService:        &fakeAuditService{},
+	}
+	return s, users, notifications
+}
+
+func TestContactChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		request input.ChangeContactRequest
+		// beforeConfirm runs between the request and its confirmation.
+		beforeConfirm     func(users *fakeUserRepo)
+		wrongCode         bool
+		wantRequestStatus int
+		wantConfirmStatus int
+		wantEmail         string
+		wantPhone         string
+	}{
+		{
+			name:              "email confirmed",
+			request:           input.ChangeContactRequest{Email: "alice@new.example.com"},
+			wantRequestStatus: http.StatusOK,
+			wantConfirmStatus: http.StatusOK,
+			wantEmail:         "alice@new.example.com",
+			wantPhone:         "+15550100",
+		},
+		{
+			name:              "phone confirmed",
+			request:           input.ChangeContactRequest{Phone: "+15550199"},
+			wantRequestStatus: http.StatusOK,
+			wantConfirmStatus: http.StatusOK,
+			wantEmail:         "alice@example.com",
+			wantPhone:         "+15550199",
+		},
+		{
+			name:              "wrong code",
+			request:           input.ChangeContactRequest{Email: "alice@new.example.com"},
+			wrongCode:         true,
+			wantRequestStatus: http.StatusOK,
+			wantConfirmStatus: http.StatusUnauthorized,
+			wantEmail:         "alice@example.com",
+			wantPhone:         "+15550100",
+		},
+		{
+			name:              "unchanged email",
+			request:           input.ChangeContactRequest{Email: "alice@example.com"},
+			wantRequestStatus: http.StatusBadRequest,
+			wantEmail:         "alice@example.com",
+			wantPhone:         "+15550100",
+		},
+		{
+			name:              "email of another account",
+			request:           input.ChangeContactRequest{Email: "bob@example.com"},
+			wantRequestStatus: http.StatusConflict,
+			wantEmail:         "alice@example.com",
+			wantPhone:         "+15550100",
+		},
+		{
+			name:    "email taken before confirmation",
+			request: input.ChangeContactRequest{Email: "alice@new.example.com"},
+			beforeConfirm: func(users *fakeUserRepo) {
+				taken := "alice@new.example.com"
+				users.users[8].Email = &taken
+			},
+			wantRequestStatus: http.StatusOK,
+			wantConfirmStatus: http.StatusConflict,
+			wantEmail:         "alice@example.com",
+			wantPhone:         "+15550100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, users, notifications := newContactTestService()
+
+			_, err := s.RequestContactChange(context.Background(), 7, &tt.request)
+			if got := statusOf(err); got != tt.wantRequestStatus {
+				t.Fatalf("request status = %d, want %d (%v)", got, tt.wantRequestStatus, err)
+			}
+
+			alice := users.users[7]
+			if err == nil {
+				// Until confirmed, the account keeps its old contact.
+				if *alice.Email != "alice@example.com" || *alice.Phone != "+15550100" {
+					t.Errorf("contact changed before confirmation: %s, %s", *alice.Email, *alice.Phone)
+				}
+				if len(notifications.sent) != 1 || notifications.sent[0].address != tt.request.Email+tt.request.Phone {
+					t.Fatalf("sent %+v, want one OTP to the new contact", notifications.sent)
+				}
+
+				if tt.beforeConfirm != nil {
+					tt.beforeConfirm(users)
+				}
+
+				channel, code := "email", notifications.sent[0].data["otp"]
+				if tt.request.Phone != "" {
+					channel = "phone"
+				}
+				if tt.wrongCode {
+					code = "000000"
+				}
+				_, err = s.ConfirmContactVerification(context.Background(), 7, &input.ConfirmContactRequest{Channel: channel, OTP: code})
+				if got := statusOf(err); got != tt.wantConfirmStatus {
+					t.Fatalf("confirm status = %d, want %d (%v)", got, tt.wantConfirmStatus, err)
+				}
+			}
+
+			if *alice.Email != tt.wantEmail || *alice.Phone != tt.wantPhone {
+				t.Errorf("contact = %s, %s, want %s, %s", *alice.Email, *alice.Phone, tt.wantEmail, tt.wantPhone)
+			}
+			if !alice.EmailVerified || !alice.PhoneVerified {
+				t.Error("contact change left a contact unverified")
+			}
+			if err == nil && (alice.PendingEmail != nil || alice.PendingPhone != nil) {
+				t.Error("confirmed change is still pending")
+			}
+		})
+	}
+}
+
+func TestContactVerification(t *testing.T) {
+	admin := uint(1)
+
+	tests := []struct {
+		name           string
+		user           models.User
+		wantSendStatus int
+		wantStatus     models.UserStatus
+	}{
+		{
+			name:           "unverified email",
+			user:           models.User{Status: models.UserStatusActive},
+			wantSendStatus: http.StatusOK,
+			wantStatus:     models.UserStatusActive,
+		},
+		{
+			name:           "self-registered pending account",
+			user:           models.User{UserType: models.UserTypeMobile, Status: models.UserStatusPending},
+			wantSendStatus: http.StatusOK,
+			wantStatus:     models.UserStatusActive,
+		},
+		{
+			name:           "invited account",
+			user:           models.User{UserType: models.UserTypePartner, Status: models.UserStatusPending, CreatedBy: &admin},
+			wantSendStatus: http.StatusOK,
+			wantStatus:     models.UserStatusPending,
+		},
+		{
+			name:           "already verified",
+			user:           models.User{Status: models.UserStatusActive, EmailVerified: true},
+			wantSendStatus: http.StatusBadRequest,
+			wantStatus:     models.UserStatusActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, users, notifications := newContactTestService()
+			email := "carol@example.com"
+			user := tt.user
+			user.ID, user.Email = 9, &email
+			users.users[user.ID] = &user
+
+			_, err := s.SendContactVerification(context.Background(), user.ID, models.OTPChannelEmail)
+			if got := statusOf(err); got != tt.wantSendStatus {
+				t.Fatalf("send status = %d, want %d (%v)", got, tt.wantSendStatus, err)
+			}
+			if err != nil {
+				return
+			}
+
+			// Another user cannot confirm the code sent to this one.
+			code := notifications.sent[0].data["otp"]
+			if _, err := s.ConfirmContactVerification(context.Background(), 7, &input.ConfirmContactRequest{Channel: "email", OTP: code}); err == nil {
+				t.Error("another user confirmed the verification")
+			}
+
+			if _, err := s.ConfirmContactVerification(context.Background(), user.ID, &input.ConfirmContactRequest{Channel: "email", OTP: code}); err != nil {
+				t.Fatalf("ConfirmContactVerification: %v", err)
+			}
+			if !users.users[user.ID].EmailVerified {
+				t.Error("email not verified")
+			}
+			if got := users.users[user.ID].Status; got != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// fakeMFACode accepts code as the user's current TOTP code.
+type fakeMFACode struct {
+	MFAService
+	code string
+}
+
+func (s *fakeMFACode) VerifyCode(ctx context.Context, userID uint, code string) error {
+	if code != s.code {
+		return utils.NewUnauthorizedError("invalid MFA code")
+	}
+	return nil
+}
+
+func TestReauthenticate(t *testing.T) {
+	passwordHash, err := utils.HashPassword("password-1")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		// prepare alters user 7 before reauthenticating.
+		prepare func(user *models.User)
+		// req is sent as is, except that an OTP request without a code
+		// gets the one sent through SendReauthenticationOTP.
+		req        input.ReauthenticateRequest
+		wantStatus int
+		wantAMR    []string
+	}{
+		{name: "password", req: input.ReauthenticateRequest{Method: "password", Password: "password-1"}, wantStatus: http.StatusOK, wantAMR: []string{utils.AMRPassword}},
+		{name: "wrong password", req: input.ReauthenticateRequest{Method: "password", Password: "password-2"}, wantStatus: http.StatusUnauthorized},
+		{name: "totp", req: input.ReauthenticateRequest{Method: "totp", Code: "123456"}, wantStatus: http.StatusOK, wantAMR: []string{utils.AMRMFA, utils.AMROTP}},
+		{name: "wrong totp", req: input.ReauthenticateRequest{Method: "totp", Code: "654321"}, wantStatus: http.StatusUnauthorized},
+		{name: "email otp", req: input.ReauthenticateRequest{Method: "otp", Channel: "email"}, wantStatus: http.StatusOK, wantAMR: []string{utils.AMROTP}},
+		{name: "sms otp", req: input.ReauthenticateRequest{Method: "otp", Channel: "phone"}, wantStatus: http.StatusOK, wantAMR: []string{utils.AMRSMS}},
+		{name: "wrong otp", req: input.ReauthenticateRequest{Method: "otp", Channel: "email", Code: "000000"}, wantStatus: http.StatusUnauthorized},
+		{
+			name:       "otp to an unverified phone",
+			prepare:    func(user *models.User) { user.PhoneVerified = false },
+			req:        input.ReauthenticateRequest{Method: "otp", Channel: "phone", Code: "000000"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "inactive user",
+			prepare:    func(user *models.User) { user.Status = models.UserStatusInactive },
+			req:        input.ReauthenticateRequest{Method: "password", Password: "password-1"},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, users, notifications := newContactTestService()
+			audit := &fakeAuditService{}
+			s.auditService = audit
+			s.mfaService = &fakeMFACode{code: "123456"}
+			s.rateLimitService = &rateLimitService{userRepo: users}
+			s.stepUpConfig = input.StepUpConfig{MaxAge: 5 * time.Minute, TokenTTL: 10 * time.Minute}
+
+			user := users.users[7]
+			user.PasswordHash = &passwordHash
+			user.Role = models.Role{RoleName: "mobile_user"}
+			if tt.prepare != nil {
+				tt.prepare(user)
+			}
+
+			req := tt.req
+			if req.Method == "otp" {
+				_, err := s.SendReauthenticationOTP(context.Background(), 7, models.OTPChannel(req.Channel))
+				if err == nil && req.Code == "" {
+					req.Code = notifications.sent[0].data["otp"]
+				}
+			}
+
+			claims := &output.Claims{UserID: 7, ClientID: "web", SessionID: "session-7", AuthTime: time.Now().Add(-time.Hour).Unix()}
+			resp, err := s.Reauthenticate(context.Background(), claims, &req)
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+			if len(audit.events) != 1 || audit.events[0].Type != models.AuthEventReauthenticate || audit.failed[0] != (err != nil) {
+				t.Errorf("audit events = %+v, failed = %v", audit.events, audit.failed)
+			}
+			if err != nil {
+				return
+			}
+
+			stepUp, err := utils.ValidateJWT(resp.AccessToken)
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+			if time.Since(time.Unix(stepUp.AuthTime, 0)) > time.Minute || stepUp.AuthTime != resp.AuthTime {
+				t.Errorf("auth_time = %d, response auth_time = %d, want now", stepUp.AuthTime, resp.AuthTime)
+			}
+			if !reflect.DeepEqual(stepUp.AMR, tt.wantAMR) {
+				t.Errorf("amr = %v, want %v", stepUp.AMR, tt.wantAMR)
+			}
+			if stepUp.UserID != 7 || stepUp.SessionID != "session-7" || stepUp.ClientID != "web" {
+				t.Errorf("claims = %+v, want the caller's user, session and client", stepUp)
+			}
+			if lifetime := stepUp.ExpiresAt - stepUp.IssuedAt; lifetime != 600 || resp.ExpiresIn != 600 {
+				t.Errorf("token lives %ds, expires_in %d, want 600", lifetime, resp.ExpiresIn)
+			}
+		})
+	}
+}
+
+// newTokenTestService returns an authService for user 7, active, and user
+// 8, inactive, each holding a refresh token issued to the "web" client.
+// Access tokens are checked against its revocation service.
+func newTokenTestService(t *testing.T) (*authService, map[uint]string) {
+	t.Helper()
+
+	revocation := newTestRevocationService(repo.NewMemoryTokenRevocationRepository())
+	utils.SetRevocationChecker(revocation.IsRevoked)
+	t.Cleanup(func() { utils.SetRevocationChecker(nil) })
+
+	s := &authService{
+		userRepo: &fakeUserRepo{users: map[uint]*models.User{
+			7: {ID: 7, UserType: models.UserTypeMobile, Status: models.UserStatusActive, Role: models.Role{RoleName: "mobile_user"}},
+			8: {ID: 8, UserType: models.UserTypeMobile, Status: models.UserStatusInactive},
+		}},
+		refreshTokenRepo:  &fakeRefreshTokenRepo{tokens: make(map[string]*models.RefreshToken)},
+		sessionService:    &fakeSessionService{},
+		revocationService: revocation,
+		auditService:      &fakeAuditService{},
+	}
+
+	refreshTokens := make(map[uint]string)
+	authTime := time.Now().Add(-time.Hour)
+	for _, userID := range []uint{7, 8} {
+		tokenID := fmt.Sprintf("token-%d", userID)
+		token, err := utils.GenerateRefreshToken(userID, tokenID)
+		if err != nil {
+			t.Fatalf("GenerateRefreshToken: %v", err)
+		}
+		s.refreshTokenRepo.Create(&models.RefreshToken{
+			TokenID:   tokenID,
+			FamilyID:  fmt.Sprintf("family-%d", userID),
+			UserID:    userID,
+			ClientID:  "web",
+			Scope:     "openid profile",
+			AMR:       utils.AMRPassword,
+			AuthTime:  &authTime,
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+		refreshTokens[userID] = token
+	}
+	return s, refreshTokens
+}
+
+func TestIntrospectToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the token to introspect.
+		token        func(t *testing.T, s *authService, refreshTokens map[uint]string) string
+		wantActive   bool
+		wantUse      string
+		wantSub      string
+		wantClientID string
+	}{
+		{
+			name: "access token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				return generateTestToken(t, output.Claims{UserID: 7, UserType: "mobile_user", Role: "mobile_user", ClientID: "web"})
+			},
+			wantActive:   true,
+			wantUse:      "access_token",
+			wantSub:      "7",
+			wantClientID: "web",
+		},
+		{
+			name: "service token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				token, err := utils.GenerateServiceToken(3, "svc_reports", "invoices:read", time.Minute)
+				if err != nil {
+					t.Fatalf("GenerateServiceToken: %v", err)
+				}
+				return token
+			},
+			wantActive:   true,
+			wantUse:      "access_token",
+			wantSub:      "service:svc_reports",
+			wantClientID: "svc_reports",
+		},
+		{
+			name: "revoked access token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				token := generateTestToken(t, output.Claims{UserID: 7, UserType: "mobile_user", Role: "mobile_user"})
+				if err := s.RevokeToken(context.Background(), token, "web"); err != nil {
+					t.Fatalf("RevokeToken: %v", err)
+				}
+				return token
+			},
+		},
+		{
+			name: "refresh token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				return refreshTokens[7]
+			},
+			wantActive:   true,
+			wantUse:      "refresh_token",
+			wantSub:      "7",
+			wantClientID: "web",
+		},
+		{
+			name: "revoked refresh token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				s.refreshTokenRepo.RevokeFamily("family-7")
+				return refreshTokens[7]
+			},
+		},
+		{
+			name: "expired refresh token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				s.refreshTokenRepo.(*fakeRefreshTokenRepo).tokens["token-7"].ExpiresAt = time.Now().Add(-time.Second)
+				return refreshTokens[7]
+			},
+		},
+		{
+			name: "refresh token of an inactive user",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				return refreshTokens[8]
+			},
+		},
+		{
+			name: "refresh token without a record",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				token, err := utils.GenerateRefreshToken(7, "unknown")
+				if err != nil {
+					t.Fatalf("GenerateRefreshToken: %v", err)
+				}
+				return token
+			},
+		},
+		{
+			name:  "malformed token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string { return "not-a-token" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, refreshTokens := newTokenTestService(t)
+
+			resp, err := s.IntrospectToken(context.Background(), tt.token(t, s, refreshTokens))
+			if err != nil {
+				t.Fatalf("IntrospectToken: %v", err)
+			}
+			if resp.Active != tt.wantActive {
+				t.Fatalf("active = %v, want %v", resp.Active, tt.wantActive)
+			}
+			if !resp.Active {
+				if resp.Claims != nil || resp.Sub != "" {
+					t.Errorf("inactive token described: %+v", resp)
+				}
+				return
+			}
+
+			if resp.TokenUse != tt.wantUse || resp.Sub != tt.wantSub || resp.ClientID != tt.wantClientID || resp.Iss != utils.Issuer() {
+				t.Errorf("introspection = %+v, want %s for %s issued to %s", resp, tt.wantUse, tt.wantSub, tt.wantClientID)
+			}
+			if tt.wantUse == "refresh_token" && (resp.Scope != "openid profile" || resp.Role != "mobile_user" || resp.AuthTime == 0 || len(resp.AMR) != 1) {
+				t.Errorf("refresh token claims = %+v, want those of its record and user", resp.Claims)
+			}
+		})
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// token returns the token to revoke.
+		token func(t *testing.T, s *authService, refreshTokens map[uint]string) string
+		// wantAudited is whether there was a token to revoke.
+		wantAudited   bool
+		wantFamilyEnd string
+	}{
+		{
+			name: "access token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				return generateTestToken(t, output.Claims{UserID: 7, UserType: "mobile_user", Role: "mobile_user"})
+			},
+			wantAudited: true,
+		},
+		{
+			name: "refresh token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				return refreshTokens[7]
+			},
+			wantAudited:   true,
+			wantFamilyEnd: "family-7",
+		},
+		{
+			name: "revoked refresh token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string {
+				s.refreshTokenRepo.RevokeFamily("family-7")
+				return refreshTokens[7]
+			},
+		},
+		{
+			name:  "malformed token",
+			token: func(t *testing.T, s *authService, refreshTokens map[uint]string) string { return "not-a-token" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, refreshTokens := newTokenTestService(t)
+			token := tt.token(t, s, refreshTokens)
+
+			if err := s.RevokeToken(context.Background(), token, "web"); err != nil {
+				t.Fatalf("RevokeToken: %v", err)
+			}
+
+			if resp, _ := s.IntrospectToken(context.Background(), token); resp.Active {
+				t.Error("token still active after revocation")
+			}
+			audit := s.auditService.(*fakeAuditService)
+			if audited := len(audit.events) == 1; audited != tt.wantAudited {
+				t.Fatalf("audit events = %+v, want audited %v", audit.events, tt.wantAudited)
+			}
+			if tt.wantAudited && (audit.events[0].Type != models.AuthEventTokenRevoke || audit.events[0].Identifier != "web" || *audit.events[0].SubjectID != 7) {
+				t.Errorf("audit event = %+v", audit.events[0])
+			}
+			if ended := s.sessionService.(*fakeSessionService).ended; (len(ended) == 1 && ended[0] == tt.wantFamilyEnd) != (tt.wantFamilyEnd != "") {
+				t.Errorf("sessions ended = %v, want %q", ended, tt.wantFamilyEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+	"gorm.io/gorm"
+)
+
+type IdentityService interface {
+	ListIdentities(ctx context.Context, userID uint) (*output.IdentitiesResponse, error)
+	// LinkIdentity links the Google or Apple account the request's token was
+	// issued for. An account linked elsewhere is reported as a conflict and
+	// has to be merged instead.
+	LinkIdentity(ctx context.Context, userID uint, req *input.LinkIdentityRequest) (*output.IdentitiesResponse, error)
+	// UnlinkIdentity removes a sign-in method, refusing to remove the last.
+	UnlinkIdentity(ctx context.Context, userID uint, provider models.IdentityProvider) (*output.IdentitiesResponse, error)
+	// MergeAccount moves every sign-in method of the account the request's
+	// access token belongs to onto the user and deactivates that account.
+	// Nothing else the other account owns is moved.
+	MergeAccount(ctx context.Context, userID uint, req *input.MergeAccountRequest) (*output.IdentitiesResponse, error)
+}
+
+type identityService struct {
+	userRepo          repo.UserRepository
+	authService       AuthService
+	revocationService RevocationService
+}
+
+func NewIdentityService(userRepo repo.UserRepository, authService AuthService, revocationService RevocationService) IdentityService {
+	return &identityService{
+		userRepo:          userRepo,
+		authService:       authService,
+		revocationService: revocationService,
+	}
+}
+
+func (s *identityService) ListIdentities(ctx context.Context, userID uint) (*output.IdentitiesResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	return newIdentitiesResponse(user), nil
+}
+
+func (s *identityService) LinkIdentity(ctx context.Context, userID uint, req *input.LinkIdentityRequest) (*output.IdentitiesResponse, error) {
+	provider := models.IdentityProvider(req.Provider)
+
+	subject, err := s.authService.VerifyProviderToken(ctx, provider, req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	switch current, _ := user.Identity(provider); current {
+	case subject:
+		return nil, utils.NewBadRequestError(fmt.Sprintf("this %s account is already linked", provider))
+	case "":
+	default:
+		return nil, utils.NewConflictError(fmt.Sprintf("a different %s account is already linked, unlink it first", provider))
+	}
+
+	getOwner := s.userRepo.GetByGoogleID
+	if provider == models.IdentityProviderApple {
+		getOwner = s.userRepo.GetByAppleID
+	}
+	owner, err := getOwner(subject)
+	if err == nil && owner.ID != user.ID {
+		return nil, utils.NewConflictError(fmt.Sprintf("this %s account belongs to another account, sign in to that account and merge it into this one", provider))
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	setIdentity(user, provider, &subject, true)
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[IDENTITY] User %d linked %s", user.ID, provider)
+
+	return newIdentitiesResponse(user), nil
+}
+
+func (s *identityService) UnlinkIdentity(ctx context.Context, userID uint, provider models.IdentityProvider) (*output.IdentitiesResponse, error) {
+	if !slices.Contains(models.IdentityProviders, provider) {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("unknown provider %q", provider))
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	if value, _ := user.Identity(provider); value == "" {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("%s is not linked", provider))
+	}
+	if provider == models.IdentityProviderEmail && user.UserType != models.UserTypeMobile {
+		return nil, utils.NewBadRequestError("staff accounts sign in by email, it cannot be unlinked")
+	}
+	if len(user.LinkedProviders()) <= 1 {
+		return nil, utils.NewBadRequestError("cannot unlink the last sign-in method")
+	}
+
+	setIdentity(user, provider, nil, false)
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[IDENTITY] User %d unlinked %s", user.ID, provider)
+
+	return newIdentitiesResponse(user), nil
+}
+
+func (s *identityService) MergeAccount(ctx context.Context, userID uint, req *input.MergeAccountRequest) (*output.IdentitiesResponse, error) {
+	claims, err := utils.ValidateJWT(req.AccessToken)
+	if err != nil || claims.UserID == 0 || claims.ImpersonatorID != 0 || s.revocationService.IsRevoked(claims) {
+		return nil, utils.NewUnauthorizedError("the other account's access token is invalid or has expired")
+	}
+	if claims.UserID == userID {
+		return nil, utils.NewBadRequestError("cannot merge an account into itself")
+	}
+
+	target, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+	source, err := s.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("the other account no longer exists")
+	}
+
+	if source.Status == models.UserStatusInactive {
+		return nil, utils.NewForbiddenError("the other account is not active")
+	}
+	if source.UserType != target.UserType || target.UserType == models.UserTypeSuperAdmin {
+		return nil, utils.NewForbiddenError("only accounts of the same type can be merged")
+	}
+
+	for _, provider := range models.IdentityProviders {
+		sourceValue, _ := source.Identity(provider)
+		targetValue, _ := target.Identity(provider)
+		if sourceValue != "" && targetValue != "" {
+			return nil, utils.NewConflictError(fmt.Sprintf("both accounts have a %s, unlink one of them before merging", provider))
+		}
+	}
+
+	for _, provider := range source.LinkedProviders() {
+		value, verified := source.Identity(provider)
+		setIdentity(target, provider, &value, verified)
+		setIdentity(source, provider, nil, false)
+	}
+	if target.PasswordHash == nil {
+		target.PasswordHash = source.PasswordHash
+		target.PasswordChangedAt = source.PasswordChangedAt
+	}
+
+	source.PasswordHash = nil
+	source.PendingEmail = nil
+	source.PendingPhone = nil
+	source.Status = models.UserStatusInactive
+	source.MergedIntoID = &target.ID
+
+	if err := s.userRepo.Merge(source, target); err != nil {
+		return nil, err
+	}
+
+	if err := s.revocationService.RevokeUser(source.ID, "account merged"); err != nil {
+		log.Printf("[IDENTITY] Failed to revoke tokens of merged user %d: %v", source.ID, err)
+	}
+
+	log.Printf("[IDENTITY] User %d merged into user %d", source.ID, target.ID)
+
+	return newIdentitiesResponse(target), nil
+}
+
+// setIdentity links value for provider, or unlinks provider when value is nil.
+func setIdentity(user *models.User, provider models.IdentityProvider, value *string, verified bool) {
+	switch provider {
+	case models.IdentityProviderEmail:
+		user.Email, user.EmailVerified = value, verified
+	case models.IdentityProviderPhone:
+		user.Phone, user.PhoneVerified = value, verified
+	case models.IdentityProviderGoogle:
+		user.GoogleID = value
+	case models.IdentityProviderApple:
+		user.AppleID = value
+	}
+}
+
+func newIdentitiesResponse(user *models.User) *output.IdentitiesResponse {
+	resp := &output.IdentitiesResponse{Identities: []output.IdentityResponse{}}
+	for _, provider := range user.LinkedProviders() {
+		identifier, verified := user.Identity(provider)
+		resp.Identities = append(resp.Identities, output.IdentityResponse{
+			Provider:   string(provider),
+			Identifier: identifier,
+			Verified:   verified,
+		})
+	}
+	return resp
+}
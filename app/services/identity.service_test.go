@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+// fakeProviderAuth accepts any provider token but "invalid" and treats it as
+// the subject it was issued for.
+type fakeProviderAuth struct {
+	AuthService
+}
+
+func (f *fakeProviderAuth) VerifyProviderToken(ctx context.Context, provider models.IdentityProvider, token string) (string, error) {
+	if token == "invalid" {
+		return "", utils.NewUnauthorizedError("invalid token")
+	}
+	return token, nil
+}
+
+func linkedProviders(resp *output.IdentitiesResponse) []string {
+	var providers []string
+	for _, identity := range resp.Identities {
+		providers = append(providers, identity.Provider)
+	}
+	return providers
+}
+
+func generateTestToken(t *testing.T, claims output.Claims) string {
+	t.Helper()
+
+	token, err := utils.GenerateJWT(claims)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	return token
+}
+
+func TestLinkIdentity(t *testing.T) {
+	value := func(s string) *string { return &s }
+
+	tests := []struct {
+		name          string
+		user          models.User
+		req           input.LinkIdentityRequest
+		wantStatus    int
+		wantProviders []string
+	}{
+		{
+			name:          "link google",
+			user:          models.User{Phone: value("+15550100"), PhoneVerified: true},
+			req:           input.LinkIdentityRequest{Provider: "google", Token: "google-7"},
+			wantStatus:    http.StatusOK,
+			wantProviders: []string{"phone", "google"},
+		},
+		{
+			name:          "link apple",
+			user:          models.User{Phone: value("+15550100"), PhoneVerified: true},
+			req:           input.LinkIdentityRequest{Provider: "apple", Token: "apple-7"},
+			wantStatus:    http.StatusOK,
+			wantProviders: []string{"phone", "apple"},
+		},
+		{
+			name:       "invalid token",
+			user:       models.User{Phone: value("+15550100")},
+			req:        input.LinkIdentityRequest{Provider: "google", Token: "invalid"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "same account already linked",
+			user:       models.User{Phone: value("+15550100"), GoogleID: value("google-7")},
+			req:        input.LinkIdentityRequest{Provider: "google", Token: "google-7"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "different account already linked",
+			user:       models.User{Phone: value("+15550100"), GoogleID: value("google-old")},
+			req:        input.LinkIdentityRequest{Provider: "google", Token: "google-7"},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "account linked to another user",
+			user:       models.User{Phone: value("+15550100")},
+			req:        input.LinkIdentityRequest{Provider: "google", Token: "google-8"},
+			wantStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			user.ID, user.UserType = 7, models.UserTypeMobile
+			users := &fakeUserRepo{users: map[uint]*models.User{
+				7: &user,
+				8: {ID: 8, UserType: models.UserTypeMobile, GoogleID: value("google-8")},
+			}}
+			s := NewIdentityService(users, &fakeProviderAuth{}, nil)
+
+			resp, err := s.LinkIdentity(context.Background(), 7, &tt.req)
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+			if err != nil {
+				return
+			}
+			if got := linkedProviders(resp); !reflect.DeepEqual(got, tt.wantProviders) {
+				t.Errorf("identities = %v, want %v", got, tt.wantProviders)
+			}
+			if got, _ := users.users[7].Identity(models.IdentityProvider(tt.req.Provider)); got != tt.req.Token {
+				t.Errorf("%s identity = %q, want %q", tt.req.Provider, got, tt.req.Token)
+			}
+		})
+	}
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	value := func(s string) *string { return &s }
+
+	tests := []struct {
+		name          string
+		user          models.User
+		provider      models.IdentityProvider
+		wantStatus    int
+		wantProviders []string
+	}{
+		{
+			name:          "unlink google",
+			user:          models.User{UserType: models.UserTypeMobile, Phone: value("+15550100"), GoogleID: value("google-7")},
+			provider:      models.IdentityProviderGoogle,
+			wantStatus:    http.StatusOK,
+			wantProviders: []string{"phone"},
+		},
+		{
+			name:          "unlink a mobile user's email",
+			user:          models.User{UserType: models.UserTypeMobile, Email: value("a@example.com"), AppleID: value("apple-7")},
+			provider:      models.IdentityProviderEmail,
+			wantStatus:    http.StatusOK,
+			wantProviders: []string{"apple"},
+		},
+		{
+			name:       "last sign-in method",
+			user:       models.User{UserType: models.UserTypeMobile, Phone: value("+15550100")},
+			provider:   models.IdentityProviderPhone,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "staff email",
+			user:       models.User{UserType: models.UserTypeAdmin, Email: value("a@example.com"), GoogleID: value("google-7")},
+			provider:   models.IdentityProviderEmail,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not linked",
+			user:       models.User{UserType: models.UserTypeMobile, Phone: value("+15550100"), GoogleID: value("google-7")},
+			provider:   models.IdentityProviderApple,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown provider",
+			user:       models.User{UserType: models.UserTypeMobile, Phone: value("+15550100"), GoogleID: value("google-7")},
+			provider:   "facebook",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			user.ID = 7
+			users := &fakeUserRepo{users: map[uint]*models.User{7: &user}}
+			s := NewIdentityService(users, nil, nil)
+			before := users.users[7].LinkedProviders()
+
+			resp, err := s.UnlinkIdentity(context.Background(), 7, tt.provider)
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+			if err != nil {
+				if got := users.users[7].LinkedProviders(); !reflect.DeepEqual(got, before) {
+					t.Errorf("failed unlink changed identities to %v", got)
+				}
+				return
+			}
+			if got := linkedProviders(resp); !reflect.DeepEqual(got, tt.wantProviders) {
+				t.Errorf("identities = %v, want %v", got, tt.wantProviders)
+			}
+		})
+	}
+}
+
+func TestMergeAccount(t *testing.T) {
+	value := func(s string) *string { return &s }
+	passwordHash := "hash-8"
+
+	tests := []struct {
+		name string
+		// source is user 8, whose token is presented to merge it into
+		// user 7, a mobile user signed in by phone.
+		source models.User
+		// token returns the access token presented, user 8's unless set.
+		token         func(t *testing.T) string
+		wantStatus    int
+		wantProviders []string
+	}{
+		{
+			name:          "merge",
+			source:        models.User{UserType: models.UserTypeMobile, Email: value("a@example.com"), EmailVerified: true, GoogleID: value("google-8"), PasswordHash: &passwordHash},
+			wantStatus:    http.StatusOK,
+			wantProviders: []string{"email", "phone", "google"},
+		},
+		{
+			name:       "both accounts have a phone",
+			source:     models.User{UserType: models.UserTypeMobile, Phone: value("+15550199")},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "different user types",
+			source:     models.User{UserType: models.UserTypePartner, Email: value("a@example.com")},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "inactive account",
+			source:     models.User{UserType: models.UserTypeMobile, Email: value("a@example.com"), Status: models.UserStatusInactive},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "into itself",
+			source: models.User{UserType: models.UserTypeMobile, Email: value("a@example.com")},
+			token: func(t *testing.T) string {
+				return generateTestToken(t, output.Claims{UserID: 7, UserType: "mobile"})
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "impersonation token",
+			source: models.User{UserType: models.UserTypeMobile, Email: value("a@example.com")},
+			token: func(t *testing.T) string {
+				token, err := utils.GenerateImpersonationToken(output.Claims{UserID: 8, UserType: "mobile", ImpersonatorID: 1}, time.Minute)
+				if err != nil {
+					t.Fatalf("GenerateImpersonationToken: %v", err)
+				}
+				return token
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid token",
+			source:     models.User{UserType: models.UserTypeMobile, Email: value("a@example.com")},
+			token:      func(t *testing.T) string { return "invalid" },
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source
+			source.ID = 8
+			if source.Status == "" {
+				source.Status = models.UserStatusActive
+			}
+			users := &fakeUserRepo{users: map[uint]*models.User{
+				7: {ID: 7, UserType: models.UserTypeMobile, Status: models.UserStatusActive, Phone: value("+15550100"), PhoneVerified: true},
+				8: &source,
+			}}
+			revocation := newTestRevocationService(repo.NewMemoryTokenRevocationRepository())
+			s := NewIdentityService(users, nil, revocation)
+
+			token := generateTestToken(t, output.Claims{UserID: 8, UserType: "mobile"})
+			if tt.token != nil {
+				token = tt.token(t)
+			}
+			issuedAt := time.Now().Add(-time.Minute).Unix()
+
+			resp, err := s.MergeAccount(context.Background(), 7, &input.MergeAccountRequest{AccessToken: token})
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+
+			merged := users.users[8]
+			if revoked := revocation.IsRevoked(&output.Claims{UserID: 8, IssuedAt: issuedAt}); revoked != (err == nil) {
+				t.Errorf("merged account's tokens revoked = %v, want %v", revoked, err == nil)
+			}
+			if err != nil {
+				if merged.MergedIntoID != nil || users.users[7].GoogleID != nil {
+					t.Error("failed merge moved identities")
+				}
+				return
+			}
+
+			if got := linkedProviders(resp); !reflect.DeepEqual(got, tt.wantProviders) {
+				t.Errorf("identities = %v, want %v", got, tt.wantProviders)
+			}
+			if verified := users.users[7].EmailVerified; !verified {
+				t.Error("merged email lost its verification")
+			}
+			if hash := users.users[7].PasswordHash; hash == nil || *hash != passwordHash {
+				t.Error("password of the merged account not moved to an account without one")
+			}
+			if merged.Status != models.UserStatusInactive || merged.MergedIntoID == nil || *merged.MergedIntoID != 7 ||
+				len(merged.LinkedProviders()) != 0 || merged.PasswordHash != nil {
+				t.Errorf("merged account = %+v, want inactive, merged into 7 and without sign-in methods", merged)
+			}
+		})
+	}
+}
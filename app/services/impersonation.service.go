@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+type ImpersonationService interface {
+	// Impersonate issues a short-lived access token for userID naming
+	// impersonatorID as its actor. Superadmins cannot be impersonated.
+	Impersonate(ctx context.Context, impersonatorID, userID uint, req *input.ImpersonateRequest) (*output.ImpersonationResponse, error)
+}
+
+type impersonationService struct {
+	userRepo     repo.UserRepository
+	auditService AuditService
+	config       input.ImpersonationConfig
+}
+
+func NewImpersonationService(userRepo repo.UserRepository, auditService AuditService, cfg input.ImpersonationConfig) ImpersonationService {
+	return &impersonationService{
+		userRepo:     userRepo,
+		auditService: auditService,
+		config:       cfg,
+	}
+}
+
+func (s *impersonationService) Impersonate(ctx context.Context, impersonatorID, userID uint, req *input.ImpersonateRequest) (*output.ImpersonationResponse, error) {
+	resp, err := s.impersonate(impersonatorID, userID)
+	s.auditService.Record(ctx, models.AuthEvent{
+		Type:      models.AuthEventAdminImpersonate,
+		SubjectID: &userID,
+		Reason:    req.Reason,
+	}, err)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[IMPERSONATION] User %d is impersonating user %d", impersonatorID, userID)
+
+	return resp, nil
+}
+
+func (s *impersonationService) impersonate(impersonatorID, userID uint) (*output.ImpersonationResponse, error) {
+	if userID == impersonatorID {
+		return nil, utils.NewBadRequestError("cannot impersonate yourself")
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	if user.UserType == models.UserTypeSuperAdmin {
+		return nil, utils.NewForbiddenError("superadmins cannot be impersonated")
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, utils.NewForbiddenError("user account is not active")
+	}
+
+	claims := newAccessClaims(user)
+	claims.ImpersonatorID = impersonatorID
+
+	accessToken, err := utils.GenerateImpersonationToken(claims, s.config.TokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &output.ImpersonationResponse{
+		AccessToken:    accessToken,
+		TokenType:      "Bearer",
+		ExpiresIn:      int(s.config.TokenTTL.Seconds()),
+		ImpersonatorID: impersonatorID,
+		User:           newUserInfo(user),
+	}, nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+func TestImpersonate(t *testing.T) {
+	users := &fakeUserRepo{users: map[uint]*models.User{
+		1: {ID: 1, UserType: models.UserTypeSuperAdmin, Status: models.UserStatusActive},
+		2: {ID: 2, UserType: models.UserTypeSuperAdmin, Status: models.UserStatusActive},
+		7: {ID: 7, UserType: models.UserTypePartner, Status: models.UserStatusActive, Role: models.Role{RoleName: "partner"}},
+		8: {ID: 8, UserType: models.UserTypePartner, Status: models.UserStatusInactive},
+	}}
+
+	tests := []struct {
+		name       string
+		userID     uint
+		wantStatus int
+	}{
+		{name: "partner", userID: 7, wantStatus: http.StatusOK},
+		{name: "self", userID: 1, wantStatus: http.StatusBadRequest},
+		{name: "another superadmin", userID: 2, wantStatus: http.StatusForbidden},
+		{name: "inactive user", userID: 8, wantStatus: http.StatusForbidden},
+		{name: "unknown user", userID: 99, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &fakeAuditService{}
+			s := NewImpersonationService(users, audit, input.ImpersonationConfig{TokenTTL: 15 * time.Minute})
+
+			resp, err := s.Impersonate(context.Background(), 1, tt.userID, &input.ImpersonateRequest{Reason: "ticket 42"})
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+
+			// Refused attempts are audited too.
+			if len(audit.events) != 1 || audit.failed[0] != (err != nil) {
+				t.Fatalf("audit events = %+v, failed = %v, want one failed %v", audit.events, audit.failed, err != nil)
+			}
+			event := audit.events[0]
+			if event.Type != models.AuthEventAdminImpersonate || event.SubjectID == nil || *event.SubjectID != tt.userID || event.Reason != "ticket 42" {
+				t.Errorf("audit event = %+v", event)
+			}
+			if err != nil {
+				return
+			}
+
+			claims, err := utils.ValidateJWT(resp.AccessToken)
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+			if claims.UserID != tt.userID || claims.ImpersonatorID != 1 || claims.Role != "partner" {
+				t.Errorf("claims = %+v, want user %d impersonated by 1", claims, tt.userID)
+			}
+			if lifetime := time.Unix(claims.ExpiresAt, 0).Sub(time.Unix(claims.IssuedAt, 0)); lifetime != 15*time.Minute || resp.ExpiresIn != 900 {
+				t.Errorf("token lives %v, expires_in %d, want 15m", lifetime, resp.ExpiresIn)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+type InvitationService interface {
+	// Invite emails a pending user a link to set their password.
+	Invite(ctx context.Context, user *models.User, invitedBy uint) error
+	// GetInvitation describes an active invitation to the invitee.
+	GetInvitation(ctx context.Context, token string) (*output.InvitationResponse, error)
+	// AcceptInvitation sets the invitee's password, activates the account
+	// and signs them in, subject to the MFA policy.
+	AcceptInvitation(ctx context.Context, token string, req *input.AcceptInvitationRequest) (*output.AuthResponse, error)
+	ListInvitations(ctx context.Context) ([]output.InvitationResponse, error)
+	// ResendInvitation replaces an invitation with a fresh link.
+	ResendInvitation(ctx context.Context, id uint, invitedBy uint) error
+	// RevokeInvitation withdraws an invitation and deactivates the user.
+	RevokeInvitation(ctx context.Context, id uint) error
+}
+
+type invitationService struct {
+	invitationRepo      repo.InvitationRepository
+	userRepo            repo.UserRepository
+	passwordPolicy      PasswordPolicyService
+	mfaService          MFAService
+	authService         AuthService
+	revocationService   RevocationService
+	notificationService NotificationService
+	config              input.InvitationConfig
+}
+
+// invitationExpiryInterval is how often accounts with expired invitations
+// are deactivated.
+const invitationExpiryInterval = 15 * time.Minute
+
+func NewInvitationService(
+	invitationRepo repo.InvitationRepository,
+	userRepo repo.UserRepository,
+	passwordPolicy PasswordPolicyService,
+	mfaService MFAService,
+	authService AuthService,
+	revocationService RevocationService,
+	notificationService NotificationService,
+	cfg input.InvitationConfig,
+) InvitationService {
+	s := &invitationService{
+		invitationRepo:      invitationRepo,
+		userRepo:            userRepo,
+		passwordPolicy:      passwordPolicy,
+		mfaService:          mfaService,
+		authService:         authService,
+		revocationService:   revocationService,
+		notificationService: notificationService,
+		config:              cfg,
+	}
+
+	go s.expire()
+
+	return s
+}
+
+// expire deactivates pending accounts whose invitation ran out, so they can
+// no longer be activated by other means. Resending the invitation makes them
+// pending again.
+func (s *invitationService) expire() {
+	ticker := time.NewTicker(invitationExpiryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		userIDs, err := s.invitationRepo.ListExpiredUsers(time.Now())
+		if err != nil {
+			log.Printf("[INVITATION] Failed to list expired invitations: %v", err)
+			continue
+		}
+
+		for _, userID := range userIDs {
+			if err := s.setStatus(userID, models.UserStatusInactive); err != nil {
+				log.Printf("[INVITATION] Failed to deactivate user %d: %v", userID, err)
+				continue
+			}
+			log.Printf("[INVITATION] Invitation for user %d expired", userID)
+		}
+	}
+}
+
+func (s *invitationService) setStatus(userID uint, status models.UserStatus) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	user.Status = status
+	return s.userRepo.Update(user)
+}
+
+func (s *invitationService) Invite(ctx context.Context, user *models.User, invitedBy uint) error {
+	if user.Email == nil || *user.Email == "" {
+		return utils.NewBadRequestError("an invitation needs an email address")
+	}
+
+	token, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return err
+	}
+
+	invitation := &models.Invitation{
+		UserID:    user.ID,
+		InvitedBy: invitedBy,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(s.config.TokenTTL),
+	}
+	if err := s.invitationRepo.Create(invitation); err != nil {
+		return err
+	}
+
+	if err := s.sendInvitationEmail(ctx, *user.Email, token); err != nil {
+		log.Printf("[INVITATION] Failed to queue invitation email for user %d: %v", user.ID, err)
+		return err
+	}
+
+	log.Printf("[INVITATION] User %d invited by %d", user.ID, invitedBy)
+	return nil
+}
+
+func (s *invitationService) getActive(token string) (*models.Invitation, error) {
+	invitation, err := s.invitationRepo.GetActiveByHash(utils.HashToken(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.NewNotFoundError("invitation is invalid or has expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if invitation.User.ID == 0 || invitation.User.Status != models.UserStatusPending {
+		return nil, utils.NewNotFoundError("invitation is invalid or has expired")
+	}
+
+	return invitation, nil
+}
+
+func (s *invitationService) GetInvitation(ctx context.Context, token string) (*output.InvitationResponse, error) {
+	invitation, err := s.getActive(token)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := newInvitationResponse(invitation, time.Now())
+	return &resp, nil
+}
+
+func (s *invitationService) AcceptInvitation(ctx context.Context, token string, req *input.AcceptInvitationRequest) (*output.AuthResponse, error) {
+	invitation, err := s.getActive(token)
+	if err != nil {
+		return nil, err
+	}
+	user := &invitation.User
+
+	// The password is checked first so that a rejected password does not
+	// use up the invitation.
+	passwordHash, err := s.passwordPolicy.HashPassword(user, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted, err := s.invitationRepo.Accept(invitation.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, utils.NewNotFoundError("invitation is invalid or has expired")
+	}
+
+	user.PasswordHash = &passwordHash
+	user.EmailVerified = true
+	user.Status = models.UserStatusActive
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdatePasswordChangedAt(user.ID); err != nil {
+		return nil, err
+	}
+	s.passwordPolicy.RecordPassword(user.ID, passwordHash)
+	s.userRepo.UpdateLastLogin(user.ID)
+
+	log.Printf("[INVITATION] User %d accepted invitation %d", user.ID, invitation.ID)
+
+	// Accepting is the first login. Where the MFA policy applies, the
+	// invitee enrolls a factor before receiving tokens.
+	challenge, err := s.mfaService.Challenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+
+	return s.authService.IssueTokens(ctx, user.ID, TokenOptions{})
+}
+
+func (s *invitationService) ListInvitations(ctx context.Context) ([]output.InvitationResponse, error) {
+	invitations, err := s.invitationRepo.ListOutstanding()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	resp := make([]output.InvitationResponse, 0, len(invitations))
+	for i := range invitations {
+		resp = append(resp, newInvitationResponse(&invitations[i], now))
+	}
+	return resp, nil
+}
+
+func (s *invitationService) ResendInvitation(ctx context.Context, id uint, invitedBy uint) error {
+	invitation, err := s.getOutstanding(id)
+	if err != nil {
+		return err
+	}
+	user := &invitation.User
+
+	if user.Status == models.UserStatusInactive {
+		if err := s.setStatus(user.ID, models.UserStatusPending); err != nil {
+			return err
+		}
+	}
+
+	return s.Invite(ctx, user, invitedBy)
+}
+
+func (s *invitationService) RevokeInvitation(ctx context.Context, id uint) error {
+	invitation, err := s.getOutstanding(id)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := s.invitationRepo.Revoke(invitation.ID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return utils.NewNotFoundError("invitation not found")
+	}
+
+	if err := s.setStatus(invitation.UserID, models.UserStatusInactive); err != nil {
+		return err
+	}
+
+	log.Printf("[INVITATION] Invitation %d for user %d revoked", invitation.ID, invitation.UserID)
+	return s.revocationService.RevokeUser(invitation.UserID, "invitation_revoked")
+}
+
+// getOutstanding returns an invitation that was neither accepted nor revoked
+// and whose user has not signed in by other means.
+func (s *invitationService) getOutstanding(id uint) (*models.Invitation, error) {
+	invitation, err := s.invitationRepo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.NewNotFoundError("invitation not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if invitation.AcceptedAt != nil || invitation.RevokedAt != nil || invitation.User.ID == 0 {
+		return nil, utils.NewNotFoundError("invitation not found")
+	}
+	if invitation.User.Status == models.UserStatusActive {
+		return nil, utils.NewBadRequestError("user has already signed in")
+	}
+
+	return invitation, nil
+}
+
+func newInvitationResponse(invitation *models.Invitation, now time.Time) output.InvitationResponse {
+	resp := output.InvitationResponse{
+		ID:        invitation.ID,
+		UserID:    invitation.UserID,
+		UserType:  string(invitation.User.UserType),
+		Role:      invitation.User.Role.RoleName,
+		InvitedBy: invitation.InvitedBy,
+		Expired:   !invitation.ExpiresAt.After(now),
+		ExpiresAt: invitation.ExpiresAt,
+		CreatedAt: invitation.CreatedAt,
+	}
+	if invitation.User.Email != nil {
+		resp.Email = *invitation.User.Email
+	}
+	return resp
+}
+
+// sendInvitationEmail sends the invitation link, or the bare token when no
+// invitation page is configured.
+func (s *invitationService) sendInvitationEmail(ctx context.Context, email, token string) error {
+	data := map[string]string{
+		"token":            token,
+		"expires_in_hours": strconv.Itoa(int(s.config.TokenTTL.Hours())),
+	}
+
+	if s.config.AcceptURL != "" {
+		link, err := url.Parse(s.config.AcceptURL)
+		if err != nil {
+			return fmt.Errorf("invalid invitation URL: %w", err)
+		}
+		query := link.Query()
+		query.Set("token", token)
+		link.RawQuery = query.Encode()
+		data["link"] = link.String()
+	}
+
+	return s.notificationService.SendEmail(ctx, email, "invitation", data)
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+// fakeInvitationRepo keeps invitations in memory and preloads their user
+// from users, as the real repository does.
+type fakeInvitationRepo struct {
+	repo.InvitationRepository
+	invitations []*models.Invitation
+	users       map[uint]*models.User
+}
+
+func (r *fakeInvitationRepo) outstanding(invitation *models.Invitation) bool {
+	return invitation.AcceptedAt == nil && invitation.RevokedAt == nil
+}
+
+func (r *fakeInvitationRepo) load(invitation *models.Invitation) *models.Invitation {
+	found := *invitation
+	if user, ok := r.users[invitation.UserID]; ok {
+		found.User = *user
+	}
+	return &found
+}
+
+func (r *fakeInvitationRepo) Create(invitation *models.Invitation) error {
+	now := time.Now()
+	for _, earlier := range r.invitations {
+		if earlier.UserID == invitation.UserID && r.outstanding(earlier) {
+			earlier.RevokedAt = &now
+		}
+	}
+	invitation.ID = uint(len(r.invitations) + 1)
+	invitation.CreatedAt = now
+	stored := *invitation
+	r.invitations = append(r.invitations, &stored)
+	return nil
+}
+
+func (r *fakeInvitationRepo) GetByID(id uint) (*models.Invitation, error) {
+	for _, invitation := range r.invitations {
+		if invitation.ID == id {
+			return r.load(invitation), nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeInvitationRepo) GetActiveByHash(tokenHash string) (*models.Invitation, error) {
+	for _, invitation := range r.invitations {
+		if invitation.TokenHash == tokenHash && r.outstanding(invitation) && time.Now().Before(invitation.ExpiresAt) {
+			return r.load(invitation), nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeInvitationRepo) Accept(id uint) (bool, error) {
+	for _, invitation := range r.invitations {
+		if invitation.ID == id && r.outstanding(invitation) && time.Now().Before(invitation.ExpiresAt) {
+			now := time.Now()
+			invitation.AcceptedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeInvitationRepo) Revoke(id uint) (bool, error) {
+	for _, invitation := range r.invitations {
+		if invitation.ID == id && r.outstanding(invitation) {
+			now := time.Now()
+			invitation.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeMFAChallenge asks every user for a second factor when required is set.
+type fakeMFAChallenge struct {
+	MFAService
+	required bool
+}
+
+func (s *fakeMFAChallenge) Challenge(ctx context.Context, user *models.User) (*output.AuthResponse, error) {
+	if !s.required {
+		return nil, nil
+	}
+	return &output.AuthResponse{MFAEnrollmentRequired: true, MFAToken: "mfa"}, nil
+}
+
+// fakeTokenIssuer records the users it issued tokens to.
+type fakeTokenIssuer struct {
+	AuthService
+	issued []uint
+}
+
+func (s *fakeTokenIssuer) IssueTokens(ctx context.Context, userID uint, opts TokenOptions) (*output.AuthResponse, error) {
+	s.issued = append(s.issued, userID)
+	return &output.AuthResponse{AccessToken: "access", RefreshToken: "refresh"}, nil
+}
+
+type invitationTest struct {
+	s             *invitationService
+	users         *fakeUserRepo
+	invitations   *fakeInvitationRepo
+	mfa           *fakeMFAChallenge
+	tokens        *fakeTokenIssuer
+	revocation    *revocationService
+	notifications *fakeNotificationService
+}
+
+// newInvitationTest invites user 7, pending, and returns the emailed token.
+func newInvitationTest(t *testing.T) (*invitationTest, string) {
+	t.Helper()
+
+	policy, err := utils.NewPasswordPolicy(input.PasswordPolicyConfig{MinLength: 10, RequireDigit: true})
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy: %v", err)
+	}
+
+	email, admin := "partner@example.com", uint(1)
+	users := map[uint]*models.User{
+		7: {ID: 7, Email: &email, UserType: models.UserTypePartner, Status: models.UserStatusPending, CreatedBy: &admin},
+	}
+	it := &invitationTest{
+		users:         &fakeUserRepo{users: users},
+		invitations:   &fakeInvitationRepo{users: users},
+		mfa:           &fakeMFAChallenge{},
+		tokens:        &fakeTokenIssuer{},
+		revocation:    newTestRevocationService(repo.NewMemoryTokenRevocationRepository()),
+		notifications: &fakeNotificationService{},
+	}
+	it.s = &invitationService{
+		invitationRepo:      it.invitations,
+		userRepo:            it.users,
+		passwordPolicy:      NewPasswordPolicyService(policy, nil),
+		mfaService:          it.mfa,
+		authService:         it.tokens,
+		revocationService:   it.revocation,
+		notificationService: it.notifications,
+		config:              input.InvitationConfig{AcceptURL: "https://app.example.com/invite", TokenTTL: 72 * time.Hour},
+	}
+
+	if err := it.s.Invite(context.Background(), users[7], admin); err != nil {
+		t.Fatalf("Invite: %v", err)
+	}
+	return it, it.lastToken(t)
+}
+
+func (it *invitationTest) lastToken(t *testing.T) string {
+	t.Helper()
+
+	if len(it.notifications.sent) == 0 {
+		t.Fatal("no invitation email sent")
+	}
+	sent := it.notifications.sent[len(it.notifications.sent)-1]
+	if sent.template != "invitation" || sent.data["token"] == "" {
+		t.Fatalf("sent %+v, want an invitation", sent)
+	}
+	return sent.data["token"]
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	const goodPassword = "new-password-2"
+
+	tests := []struct {
+		name string
+		// prepare alters the invitation or its user before it is accepted.
+		prepare    func(it *invitationTest)
+		password   string
+		wantStatus int
+		wantMFA    bool
+		// wantUsable is whether the invitation can still be accepted after
+		// the attempt.
+		wantUsable bool
+	}{
+		{name: "valid invitation", password: goodPassword, wantStatus: http.StatusOK},
+		{
+			name:       "MFA required",
+			prepare:    func(it *invitationTest) { it.mfa.required = true },
+			password:   goodPassword,
+			wantStatus: http.StatusOK,
+			wantMFA:    true,
+		},
+		{name: "password breaks the policy", password: "short", wantStatus: http.StatusBadRequest, wantUsable: true},
+		{
+			name:       "expired invitation",
+			prepare:    func(it *invitationTest) { it.invitations.invitations[0].ExpiresAt = time.Now().Add(-time.Second) },
+			password:   goodPassword,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "already accepted",
+			prepare:    func(it *invitationTest) { it.invitations.Accept(1) },
+			password:   goodPassword,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "user deactivated",
+			prepare:    func(it *invitationTest) { it.users.users[7].Status = models.UserStatusInactive },
+			password:   goodPassword,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it, token := newInvitationTest(t)
+			if tt.prepare != nil {
+				tt.prepare(it)
+			}
+			before := it.users.users[7].Status
+
+			resp, err := it.s.AcceptInvitation(context.Background(), token, &input.AcceptInvitationRequest{Password: tt.password})
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+
+			user := it.users.users[7]
+			if err != nil {
+				if user.Status != before || user.PasswordHash != nil {
+					t.Errorf("failed acceptance changed the user: status %s, password set %v", user.Status, user.PasswordHash != nil)
+				}
+			} else {
+				if user.Status != models.UserStatusActive || !user.EmailVerified {
+					t.Errorf("user status = %s, email verified = %v, want active and verified", user.Status, user.EmailVerified)
+				}
+				if user.PasswordHash == nil || !utils.CheckPassword(tt.password, *user.PasswordHash) {
+					t.Error("password not set")
+				}
+				if tt.wantMFA != resp.MFAEnrollmentRequired || tt.wantMFA != (len(it.tokens.issued) == 0) {
+					t.Errorf("response = %+v, tokens issued to %v, want MFA %v", resp, it.tokens.issued, tt.wantMFA)
+				}
+			}
+
+			if _, err := it.s.GetInvitation(context.Background(), token); (err == nil) != tt.wantUsable {
+				t.Errorf("invitation usable after the attempt = %v, want %v", err == nil, tt.wantUsable)
+			}
+		})
+	}
+}
+
+func TestManageInvitation(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare alters the invitation or its user before the change.
+		prepare    func(it *invitationTest)
+		revoke     bool
+		wantStatus int
+		wantUser   models.UserStatus
+		// wantNewToken is whether a fresh invitation was emailed.
+		wantNewToken bool
+	}{
+		{name: "resend", wantStatus: http.StatusOK, wantUser: models.UserStatusPending, wantNewToken: true},
+		{
+			name: "resend expired invitation",
+			prepare: func(it *invitationTest) {
+				it.invitations.invitations[0].ExpiresAt = time.Now().Add(-time.Second)
+				it.users.users[7].Status = models.UserStatusInactive
+			},
+			wantStatus:   http.StatusOK,
+			wantUser:     models.UserStatusPending,
+			wantNewToken: true,
+		},
+		{
+			name:       "resend to a user who signed in",
+			prepare:    func(it *invitationTest) { it.users.users[7].Status = models.UserStatusActive },
+			wantStatus: http.StatusBadRequest,
+			wantUser:   models.UserStatusActive,
+		},
+		{
+			name:       "resend accepted invitation",
+			prepare:    func(it *invitationTest) { it.invitations.Accept(1) },
+			wantStatus: http.StatusNotFound,
+			wantUser:   models.UserStatusPending,
+		},
+		{name: "revoke", revoke: true, wantStatus: http.StatusOK, wantUser: models.UserStatusInactive},
+		{
+			name:       "revoke revoked invitation",
+			prepare:    func(it *invitationTest) { it.invitations.Revoke(1) },
+			revoke:     true,
+			wantStatus: http.StatusNotFound,
+			wantUser:   models.UserStatusPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it, token := newInvitationTest(t)
+			if tt.prepare != nil {
+				tt.prepare(it)
+			}
+			issuedAt := time.Now().Add(-time.Minute).Unix()
+
+			var err error
+			if tt.revoke {
+				err = it.s.RevokeInvitation(context.Background(), 1)
+			} else {
+				err = it.s.ResendInvitation(context.Background(), 1, 2)
+			}
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+
+			if got := it.users.users[7].Status; got != tt.wantUser {
+				t.Errorf("user status = %s, want %s", got, tt.wantUser)
+			}
+			if revoked := it.revocation.IsRevoked(&output.Claims{UserID: 7, IssuedAt: issuedAt}); revoked != (tt.revoke && err == nil) {
+				t.Errorf("user's tokens revoked = %v", revoked)
+			}
+
+			if sent := len(it.notifications.sent); (sent == 2) != tt.wantNewToken {
+				t.Fatalf("sent %d invitation emails, want a new one %v", sent, tt.wantNewToken)
+			}
+			if !tt.wantNewToken {
+				return
+			}
+
+			// Only the most recent email works.
+			if _, err := it.s.GetInvitation(context.Background(), token); statusOf(err) != http.StatusNotFound {
+				t.Errorf("earlier invitation after resend: %v, want 404", err)
+			}
+			if _, err := it.s.GetInvitation(context.Background(), it.lastToken(t)); err != nil {
+				t.Errorf("resent invitation: %v", err)
+			}
+		})
+	}
+}
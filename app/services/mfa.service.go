@@ -0,0 +1,550 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+type MFAService interface {
+	GetStatus(ctx context.Context, userID uint) (*output.MFAStatusResponse, error)
+	// BeginEnrollment creates a new TOTP secret for the user. It only takes
+	// effect once ConfirmEnrollment accepts a code generated from it.
+	BeginEnrollment(ctx context.Context, userID uint) (*output.MFAEnrollmentResponse, error)
+	ConfirmEnrollment(ctx context.Context, userID uint, code string) (*output.MFARecoveryCodesResponse, error)
+	Disable(ctx context.Context, userID uint, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) (*output.MFARecoveryCodesResponse, error)
+	// VerifyCode checks a TOTP code for a user who is already signed in.
+	VerifyCode(ctx context.Context, userID uint, code string) error
+
+	// Challenge decides whether a user who passed the first factor needs a
+	// second one. It returns nil when tokens may be issued right away.
+	Challenge(ctx context.Context, user *models.User) (*output.AuthResponse, error)
+	VerifyChallenge(ctx context.Context, req *input.MFAVerifyRequest) (*models.User, error)
+	BeginChallengeEnrollment(ctx context.Context, mfaToken string) (*output.MFAEnrollmentResponse, error)
+	ConfirmChallengeEnrollment(ctx context.Context, mfaToken, code string) (*models.User, []string, error)
+
+	GetPolicy(ctx context.Context) (*output.MFAPolicyResponse, error)
+	UpdatePolicy(ctx context.Context, updatedBy uint, req *input.UpdateMFAPolicyRequest) (*output.MFAPolicyResponse, error)
+	ResetUserMFA(ctx context.Context, userID uint) error
+}
+
+type mfaService struct {
+	mfaRepo  repo.MFARepository
+	userRepo repo.UserRepository
+	config   input.MFAConfig
+}
+
+const (
+	mfaMaxAttempts       = 5
+	mfaLockoutDuration   = 15 * time.Minute
+	mfaRecoveryCodeCount = 10
+)
+
+func NewMFAService(mfaRepo repo.MFARepository, userRepo repo.UserRepository, cfg input.MFAConfig) MFAService {
+	return &mfaService{
+		mfaRepo:  mfaRepo,
+		userRepo: userRepo,
+		config:   cfg,
+	}
+}
+
+func (s *mfaService) GetStatus(ctx context.Context, userID uint) (*output.MFAStatusResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	required, err := s.isRequired(user.UserType)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &output.MFAStatusResponse{Required: required}
+
+	mfa, err := s.getEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return status, nil
+	}
+
+	remaining, err := s.mfaRepo.CountUnusedRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Enabled = true
+	status.ConfirmedAt = mfa.ConfirmedAt
+	status.RecoveryCodesRemaining = remaining
+	if mfa.LockedUntil != nil && time.Now().Before(*mfa.LockedUntil) {
+		status.LockedUntil = mfa.LockedUntil
+	}
+
+	return status, nil
+}
+
+func (s *mfaService) BeginEnrollment(ctx context.Context, userID uint) (*output.MFAEnrollmentResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	return s.beginEnrollment(user)
+}
+
+func (s *mfaService) beginEnrollment(user *models.User) (*output.MFAEnrollmentResponse, error) {
+	mfa, err := s.mfaRepo.GetByUserID(user.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, utils.NewBadRequestError("MFA is already enabled")
+	}
+	if mfa == nil {
+		mfa = &models.UserMFA{UserID: user.ID}
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	mfa.TOTPSecret = secret
+	mfa.LastUsedStep = 0
+	mfa.FailedAttempts = 0
+	mfa.LockedUntil = nil
+	if err := s.mfaRepo.Save(mfa); err != nil {
+		return nil, err
+	}
+
+	account := mfaAccountName(user)
+	return &output.MFAEnrollmentResponse{
+		Secret:    secret,
+		URI:       utils.TOTPURI(s.config.Issuer, account, secret),
+		Issuer:    s.config.Issuer,
+		Account:   account,
+		Digits:    utils.TOTPDigits,
+		Period:    utils.TOTPPeriod,
+		Algorithm: "SHA1",
+	}, nil
+}
+
+func (s *mfaService) ConfirmEnrollment(ctx context.Context, userID uint, code string) (*output.MFARecoveryCodesResponse, error) {
+	codes, err := s.confirmEnrollment(userID, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &output.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *mfaService) confirmEnrollment(userID uint, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.NewBadRequestError("MFA enrollment has not been started")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, utils.NewBadRequestError("MFA is already enabled")
+	}
+
+	if err := s.verifyTOTP(mfa, code); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	mfa.Enabled = true
+	mfa.ConfirmedAt = &now
+	if err := s.mfaRepo.Save(mfa); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.issueRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[MFA] TOTP enabled for user %d", userID)
+	return codes, nil
+}
+
+func (s *mfaService) VerifyCode(ctx context.Context, userID uint, code string) error {
+	mfa, err := s.requireEnabled(userID)
+	if err != nil {
+		return err
+	}
+
+	return s.verifyTOTP(mfa, code)
+}
+
+func (s *mfaService) Disable(ctx context.Context, userID uint, code string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return utils.NewNotFoundError("user not found")
+	}
+
+	required, err := s.isRequired(user.UserType)
+	if err != nil {
+		return err
+	}
+	if required {
+		return utils.NewForbiddenError("MFA is required for your account type")
+	}
+
+	mfa, err := s.requireEnabled(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyTOTP(mfa, code); err != nil {
+		return err
+	}
+
+	if err := s.mfaRepo.DeleteByUserID(userID); err != nil {
+		return err
+	}
+
+	log.Printf("[MFA] TOTP disabled by user %d", userID)
+	return nil
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) (*output.MFARecoveryCodesResponse, error) {
+	mfa, err := s.requireEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyTOTP(mfa, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.issueRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &output.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *mfaService) Challenge(ctx context.Context, user *models.User) (*output.AuthResponse, error) {
+	mfa, err := s.getEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	purpose := utils.MFAPurposeVerify
+	if mfa == nil {
+		required, err := s.isRequired(user.UserType)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+		purpose = utils.MFAPurposeEnroll
+	}
+
+	token, err := utils.GenerateMFAChallengeToken(user.ID, purpose, s.config.ChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &output.AuthResponse{
+		ExpiresIn:             int(s.config.ChallengeTTL.Seconds()),
+		User:                  newUserInfo(user),
+		MFARequired:           purpose == utils.MFAPurposeVerify,
+		MFAEnrollmentRequired: purpose == utils.MFAPurposeEnroll,
+		MFAToken:              token,
+	}, nil
+}
+
+func (s *mfaService) VerifyChallenge(ctx context.Context, req *input.MFAVerifyRequest) (*models.User, error) {
+	user, err := s.challengeUser(req.MFAToken, utils.MFAPurposeVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.requireEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.RecoveryCode != "":
+		err = s.verifyRecoveryCode(mfa, req.RecoveryCode)
+	case req.Code != "":
+		err = s.verifyTOTP(mfa, req.Code)
+	default:
+		err = utils.NewBadRequestError("code or recovery_code is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *mfaService) BeginChallengeEnrollment(ctx context.Context, mfaToken string) (*output.MFAEnrollmentResponse, error) {
+	user, err := s.challengeUser(mfaToken, utils.MFAPurposeEnroll)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.beginEnrollment(user)
+}
+
+func (s *mfaService) ConfirmChallengeEnrollment(ctx context.Context, mfaToken, code string) (*models.User, []string, error) {
+	user, err := s.challengeUser(mfaToken, utils.MFAPurposeEnroll)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codes, err := s.confirmEnrollment(user.ID, code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, codes, nil
+}
+
+func (s *mfaService) GetPolicy(ctx context.Context) (*output.MFAPolicyResponse, error) {
+	policies, err := s.mfaRepo.GetPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	userTypes := []string{}
+	for _, policy := range policies {
+		if policy.Required {
+			userTypes = append(userTypes, string(policy.UserType))
+		}
+	}
+
+	return &output.MFAPolicyResponse{RequiredUserTypes: userTypes}, nil
+}
+
+// UpdatePolicy replaces the set of user types that must use MFA. Users of a
+// newly required type without MFA are sent through enrollment at their next
+// login.
+func (s *mfaService) UpdatePolicy(ctx context.Context, updatedBy uint, req *input.UpdateMFAPolicyRequest) (*output.MFAPolicyResponse, error) {
+	required := map[models.UserType]bool{}
+	for _, userType := range req.RequiredUserTypes {
+		switch models.UserType(userType) {
+		case models.UserTypeSuperAdmin, models.UserTypeAdmin, models.UserTypePartner, models.UserTypeMobile:
+			required[models.UserType(userType)] = true
+		default:
+			return nil, utils.NewBadRequestError(fmt.Sprintf("invalid user type %q", userType))
+		}
+	}
+
+	for _, userType := range []models.UserType{
+		models.UserTypeSuperAdmin,
+		models.UserTypeAdmin,
+		models.UserTypePartner,
+		models.UserTypeMobile,
+	} {
+		policy := &models.MFAPolicy{
+			UserType:  userType,
+			Required:  required[userType],
+			UpdatedBy: &updatedBy,
+		}
+		if err := s.mfaRepo.SetPolicy(policy); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("[MFA] Policy updated by user %d: required for %v", updatedBy, req.RequiredUserTypes)
+	return s.GetPolicy(ctx)
+}
+
+// ResetUserMFA removes a user's authenticator and recovery codes, e.g. after
+// the device was lost. If MFA is required for the user they enroll again at
+// their next login.
+func (s *mfaService) ResetUserMFA(ctx context.Context, userID uint) error {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return utils.NewNotFoundError("user not found")
+	}
+
+	if err := s.mfaRepo.DeleteByUserID(userID); err != nil {
+		return err
+	}
+
+	log.Printf("[MFA] MFA reset for user %d", userID)
+	return nil
+}
+
+func (s *mfaService) challengeUser(mfaToken, purpose string) (*models.User, error) {
+	userID, tokenPurpose, err := utils.ValidateMFAChallengeToken(mfaToken)
+	if err != nil || tokenPurpose != purpose {
+		return nil, utils.NewUnauthorizedError("invalid or expired MFA token")
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewUnauthorizedError("user not found")
+	}
+
+	if user.Status != models.UserStatusActive {
+		return nil, utils.NewForbiddenError("user account is not active")
+	}
+
+	return user, nil
+}
+
+func (s *mfaService) isRequired(userType models.UserType) (bool, error) {
+	policies, err := s.mfaRepo.GetPolicies()
+	if err != nil {
+		return false, err
+	}
+
+	for _, policy := range policies {
+		if policy.UserType == userType {
+			return policy.Required, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getEnabled returns the user's confirmed authenticator, or nil if there is
+// none.
+func (s *mfaService) getEnabled(userID uint) (*models.UserMFA, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !mfa.Enabled {
+		return nil, nil
+	}
+
+	return mfa, nil
+}
+
+func (s *mfaService) requireEnabled(userID uint) (*models.UserMFA, error) {
+	mfa, err := s.getEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, utils.NewBadRequestError("MFA is not enabled")
+	}
+
+	return mfa, nil
+}
+
+// claimAttempt counts an attempt before the code is compared, so concurrent
+// guesses cannot all pass a lockout check made against the same stale count.
+// Successful verifications reset the count.
+func (s *mfaService) claimAttempt(mfa *models.UserMFA) error {
+	now := time.Now()
+	claimed, err := s.mfaRepo.ClaimAttempt(mfa.ID, mfaMaxAttempts, now.Add(mfaLockoutDuration), now)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return utils.NewForbiddenError("too many invalid MFA codes, try again later")
+	}
+	return nil
+}
+
+// verifyTOTP accepts a code once: the step it was generated for must be
+// newer than the last one accepted.
+func (s *mfaService) verifyTOTP(mfa *models.UserMFA, code string) error {
+	if err := s.claimAttempt(mfa); err != nil {
+		return err
+	}
+
+	step, ok := utils.VerifyTOTP(mfa.TOTPSecret, code, time.Now())
+	if !ok {
+		return utils.NewUnauthorizedError("invalid MFA code")
+	}
+
+	recorded, err := s.mfaRepo.RecordStep(mfa.ID, step)
+	if err != nil {
+		return err
+	}
+	if !recorded {
+		return utils.NewUnauthorizedError("MFA code has already been used")
+	}
+
+	return nil
+}
+
+func (s *mfaService) verifyRecoveryCode(mfa *models.UserMFA, code string) error {
+	if err := s.claimAttempt(mfa); err != nil {
+		return err
+	}
+
+	consumed, err := s.mfaRepo.ConsumeRecoveryCode(mfa.UserID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return utils.NewUnauthorizedError("invalid recovery code")
+	}
+
+	if err := s.mfaRepo.ResetFailures(mfa.ID); err != nil {
+		log.Printf("[MFA] Failed to reset failed attempts for user %d: %v", mfa.UserID, err)
+	}
+
+	log.Printf("[MFA] Recovery code used by user %d", mfa.UserID)
+	return nil
+}
+
+// issueRecoveryCodes replaces the user's recovery codes and returns the new
+// ones in the form "xxxxx-xxxxx".
+func (s *mfaService) issueRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, mfaRecoveryCodeCount)
+	hashes := make([]string, 0, mfaRecoveryCodeCount)
+
+	for i := 0; i < mfaRecoveryCodeCount; i++ {
+		random, err := utils.GenerateRandomString(10)
+		if err != nil {
+			return nil, err
+		}
+		random = strings.ToLower(random)
+		codes = append(codes, random[:5]+"-"+random[5:])
+		hashes = append(hashes, utils.HashToken(random))
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// normalizeRecoveryCode accepts codes typed with or without the dash and in
+// any case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func mfaAccountName(user *models.User) string {
+	if user.Email != nil {
+		return *user.Email
+	}
+	if user.Phone != nil {
+		return *user.Phone
+	}
+	return fmt.Sprintf("user-%d", user.ID)
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+// fakeMFARepo holds a single factor and applies ClaimAttempt and RecordStep
+// atomically, as the conditional updates of the real repository do.
+type fakeMFARepo struct {
+	repo.MFARepository
+	mu  sync.Mutex
+	mfa models.UserMFA
+}
+
+func (r *fakeMFARepo) ClaimAttempt(id uint, maxAttempts int, lockedUntil, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mfa.FailedAttempts >= maxAttempts || (r.mfa.LockedUntil != nil && r.mfa.LockedUntil.After(now)) {
+		return false, nil
+	}
+	if r.mfa.FailedAttempts+1 >= maxAttempts {
+		r.mfa.LockedUntil = &lockedUntil
+		r.mfa.FailedAttempts = 0
+	} else {
+		r.mfa.FailedAttempts++
+	}
+	return true, nil
+}
+
+func (r *fakeMFARepo) RecordStep(id uint, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mfa.LastUsedStep >= step {
+		return false, nil
+	}
+	r.mfa.LastUsedStep = step
+	r.mfa.FailedAttempts = 0
+	r.mfa.LockedUntil = nil
+	return true, nil
+}
+
+// statusOf maps err to the status the handlers respond with.
+func statusOf(err error) int {
+	var httpErr *utils.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	var oauthErr *utils.OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Status
+	}
+	var rateLimitErr *utils.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return http.StatusTooManyRequests
+	}
+	var validationErr *utils.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest
+	}
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+func TestVerifyTOTPConcurrentGuessesAreLimited(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	mfaRepo := &fakeMFARepo{mfa: models.UserMFA{ID: 1, UserID: 7, TOTPSecret: secret, Enabled: true}}
+	s := &mfaService{mfaRepo: mfaRepo}
+
+	stored := mfaRepo.mfa
+
+	const guesses = 50
+	statuses := make(chan int, guesses)
+	var wg sync.WaitGroup
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mfa := stored // each request loads the factor on its own
+			statuses <- statusOf(s.verifyTOTP(&mfa, "not-a-code"))
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusUnauthorized] != mfaMaxAttempts {
+		t.Errorf("%d guesses were compared, want %d", counts[http.StatusUnauthorized], mfaMaxAttempts)
+	}
+	if counts[http.StatusForbidden] != guesses-mfaMaxAttempts {
+		t.Errorf("%d guesses were refused as locked, want %d", counts[http.StatusForbidden], guesses-mfaMaxAttempts)
+	}
+}
+
+func TestVerifyTOTPAttempts(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	step := time.Now().Unix() / utils.TOTPPeriod
+	valid, err := utils.TOTPCode(secret, step)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name       string
+		mfa        models.UserMFA
+		code       string
+		wantStatus int
+		wantFailed int
+		wantLocked bool
+	}{
+		{name: "valid code", mfa: models.UserMFA{FailedAttempts: 3}, code: valid, wantStatus: http.StatusOK},
+		{name: "wrong code", code: "000000", wantStatus: http.StatusUnauthorized, wantFailed: 1},
+		{name: "last attempt locks", mfa: models.UserMFA{FailedAttempts: mfaMaxAttempts - 1}, code: "000000", wantStatus: http.StatusUnauthorized, wantLocked: true},
+		{name: "valid code while locked", mfa: models.UserMFA{LockedUntil: &future}, code: valid, wantStatus: http.StatusForbidden, wantLocked: true},
+		{name: "lock expired", mfa: models.UserMFA{LockedUntil: &past}, code: valid, wantStatus: http.StatusOK},
+		{name: "replayed code", mfa: models.UserMFA{LastUsedStep: step}, code: valid, wantStatus: http.StatusUnauthorized, wantFailed: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mfa := tt.mfa
+			mfa.ID, mfa.UserID, mfa.TOTPSecret = 1, 7, secret
+			mfaRepo := &fakeMFARepo{mfa: mfa}
+			s := &mfaService{mfaRepo: mfaRepo}
+
+			if got := statusOf(s.verifyTOTP(&mfa, tt.code)); got != tt.wantStatus {
+				t.Errorf("status = %d, want %d", got, tt.wantStatus)
+			}
+			if got := mfaRepo.mfa.FailedAttempts; got != tt.wantFailed {
+				t.Errorf("failed attempts = %d, want %d", got, tt.wantFailed)
+			}
+			if locked := mfaRepo.mfa.LockedUntil != nil && mfaRepo.mfa.LockedUntil.After(time.Now()); locked != tt.wantLocked {
+				t.Errorf("locked = %v, want %v", locked, tt.wantLocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/config"
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+type NotificationService interface {
+	// SendEmail renders template in the caller's language and queues it for
+	// delivery to address. It only fails if the message could not be queued.
+	SendEmail(ctx context.Context, address, template string, data map[string]string) error
+	// SendSMS is SendEmail for a text message to phone.
+	SendSMS(ctx context.Context, phone, template string, data map[string]string) error
+}
+
+type notificationService struct {
+	repo      repo.NotificationRepository
+	notifier  utils.Notifier
+	templates *utils.NotificationTemplates
+	config    input.NotificationConfig
+	wake      chan struct{}
+}
+
+const (
+	// notificationLease is how long a claimed notification is left to its
+	// worker before another one may retry it.
+	notificationLease = 2 * time.Minute
+	// notificationSendTimeout bounds a single delivery attempt.
+	notificationSendTimeout = 30 * time.Second
+	// notificationRetention is how long sent notifications are kept.
+	notificationRetention = 7 * 24 * time.Hour
+	// notificationPurgeInterval is how often old notifications are deleted.
+	notificationPurgeInterval = time.Hour
+)
+
+// NewNotificationService loads the templates from cfg.TemplateDir, or the
+// built-in ones, and starts the outbox worker.
+func NewNotificationService(cfg input.NotificationConfig, notificationRepo repo.NotificationRepository, notifier utils.Notifier) (NotificationService, error) {
+	var templateFS fs.FS
+	if cfg.TemplateDir != "" {
+		templateFS = os.DirFS(cfg.TemplateDir)
+	} else {
+		sub, err := fs.Sub(config.DefaultNotificationTemplates, "templates")
+		if err != nil {
+			return nil, err
+		}
+		templateFS = sub
+	}
+
+	templates, err := utils.LoadNotificationTemplates(templateFS, cfg.DefaultLanguage)
+	if err != nil {
+		return nil, fmt.Errorf("notification templates: %w", err)
+	}
+
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+
+	s := &notificationService{
+		repo:      notificationRepo,
+		notifier:  notifier,
+		templates: templates,
+		config:    cfg,
+		wake:      make(chan struct{}, 1),
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+func (s *notificationService) SendEmail(ctx context.Context, address, template string, data map[string]string) error {
+	return s.enqueue(ctx, models.NotificationChannelEmail, address, template, data)
+}
+
+func (s *notificationService) SendSMS(ctx context.Context, phone, template string, data map[string]string) error {
+	return s.enqueue(ctx, models.NotificationChannelSMS, phone, template, data)
+}
+
+func (s *notificationService) enqueue(ctx context.Context, channel models.NotificationChannel, to, template string, data map[string]string) error {
+	rendered, err := s.templates.Render(template, channel, utils.ClientLanguage(ctx), data)
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %w", template, err)
+	}
+
+	notification := &models.NotificationOutbox{
+		Channel:       channel,
+		Recipient:     to,
+		Template:      template,
+		Language:      rendered.Language,
+		Subject:       rendered.Subject,
+		HTMLBody:      rendered.HTMLBody,
+		TextBody:      rendered.TextBody,
+		Params:        data,
+		Status:        models.NotificationStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := s.repo.Create(notification); err != nil {
+		return fmt.Errorf("failed to queue %s: %w", template, err)
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// run delivers due notifications every PollInterval, and straight away when
+// one is queued by this instance.
+func (s *notificationService) run() {
+	poll := time.NewTicker(s.config.PollInterval)
+	defer poll.Stop()
+	purge := time.NewTicker(notificationPurgeInterval)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-poll.C:
+		case <-s.wake:
+		case <-purge.C:
+			if err := s.repo.DeleteSentBefore(time.Now().Add(-notificationRetention)); err != nil {
+				log.Printf("[NOTIFY] Failed to delete old notifications: %v", err)
+			}
+			continue
+		}
+		s.deliverDue()
+	}
+}
+
+func (s *notificationService) deliverDue() {
+	due, err := s.repo.ListDue(time.Now(), s.config.BatchSize)
+	if err != nil {
+		log.Printf("[NOTIFY] Failed to list due notifications: %v", err)
+		return
+	}
+
+	for i := range due {
+		s.deliver(&due[i])
+	}
+}
+
+func (s *notificationService) deliver(notification *models.NotificationOutbox) {
+	claimed, err := s.repo.Claim(notification.ID, notification.Attempts, time.Now().Add(notificationLease))
+	if err != nil {
+		log.Printf("[NOTIFY] Failed to claim notification %d: %v", notification.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	attempts := notification.Attempts + 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), notificationSendTimeout)
+	defer cancel()
+
+	err = s.notifier.Send(ctx, &utils.Notification{
+		Channel:  notification.Channel,
+		To:       notification.Recipient,
+		Template: notification.Template,
+		Subject:  notification.Subject,
+		HTMLBody: notification.HTMLBody,
+		TextBody: notification.TextBody,
+		Params:   notification.Params,
+	})
+	if err == nil {
+		if err := s.repo.MarkSent(notification.ID, time.Now()); err != nil {
+			log.Printf("[NOTIFY] Failed to mark notification %d sent: %v", notification.ID, err)
+		}
+		return
+	}
+
+	if attempts >= s.config.MaxAttempts {
+		log.Printf("[NOTIFY] Giving up on %s %s to %s after %d attempts: %v", notification.Channel, notification.Template, notification.Recipient, attempts, err)
+		if err := s.repo.MarkFailed(notification.ID, err.Error()); err != nil {
+			log.Printf("[NOTIFY] Failed to mark notification %d failed: %v", notification.ID, err)
+		}
+		return
+	}
+
+	retryAt := time.Now().Add(s.backoff(attempts))
+	log.Printf("[NOTIFY] Failed to send %s %s to %s, retrying at %s: %v", notification.Channel, notification.Template, notification.Recipient, retryAt.Format(time.RFC3339), err)
+	if err := s.repo.MarkRetry(notification.ID, retryAt, err.Error()); err != nil {
+		log.Printf("[NOTIFY] Failed to reschedule notification %d: %v", notification.ID, err)
+	}
+}
+
+// backoff doubles RetryBackoff for every attempt after the first, up to
+// MaxRetryBackoff.
+func (s *notificationService) backoff(attempts int) time.Duration {
+	delay := s.config.RetryBackoff
+	for i := 1; i < attempts && delay < s.config.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.config.MaxRetryBackoff {
+		delay = s.config.MaxRetryBackoff
+	}
+	return delay
+}
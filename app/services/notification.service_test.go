@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+// fakeNotificationRepo keeps the outbox in memory and claims notifications
+// on their attempt count, as the conditional update of the real repository
+// does.
+type fakeNotificationRepo struct {
+	repo.NotificationRepository
+	outbox []*models.NotificationOutbox
+}
+
+func (r *fakeNotificationRepo) Create(notification *models.NotificationOutbox) error {
+	notification.ID = uint(len(r.outbox) + 1)
+	stored := *notification
+	r.outbox = append(r.outbox, &stored)
+	return nil
+}
+
+func (r *fakeNotificationRepo) ListDue(now time.Time, limit int) ([]models.NotificationOutbox, error) {
+	var due []models.NotificationOutbox
+	for _, notification := range r.outbox {
+		if notification.Status == models.NotificationStatusPending && !notification.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, *notification)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeNotificationRepo) Claim(id uint, attempts int, leaseUntil time.Time) (bool, error) {
+	notification := r.outbox[id-1]
+	if notification.Status != models.NotificationStatusPending || notification.Attempts != attempts {
+		return false, nil
+	}
+	notification.Attempts++
+	notification.NextAttemptAt = leaseUntil
+	return true, nil
+}
+
+func (r *fakeNotificationRepo) MarkSent(id uint, sentAt time.Time) error {
+	notification := r.outbox[id-1]
+	notification.Status, notification.SentAt, notification.LastError = models.NotificationStatusSent, &sentAt, ""
+	return nil
+}
+
+func (r *fakeNotificationRepo) MarkRetry(id uint, nextAttemptAt time.Time, lastError string) error {
+	notification := r.outbox[id-1]
+	notification.NextAttemptAt, notification.LastError = nextAttemptAt, lastError
+	return nil
+}
+
+func (r *fakeNotificationRepo) MarkFailed(id uint, lastError string) error {
+	notification := r.outbox[id-1]
+	notification.Status, notification.LastError = models.NotificationStatusFailed, lastError
+	return nil
+}
+
+// flakyNotifier fails the first failures sends and records the rest.
+type flakyNotifier struct {
+	failures int
+	attempts int
+	sent     []utils.Notification
+}
+
+func (n *flakyNotifier) Send(ctx context.Context, notification *utils.Notification) error {
+	n.attempts++
+	if n.attempts <= n.failures {
+		return errors.New("provider unavailable")
+	}
+	n.sent = append(n.sent, *notification)
+	return nil
+}
+
+// newTestNotificationService returns a notificationService without its
+// worker, so tests deliver by calling deliverDue.
+func newTestNotificationService(t *testing.T, notifier utils.Notifier) (*notificationService, *fakeNotificationRepo) {
+	t.Helper()
+
+	templates, err := utils.LoadNotificationTemplates(fstest.MapFS{
+		"otp/en.subject.tmpl": {Data: []byte("Your code")},
+		"otp/en.html.tmpl":    {Data: []byte("<p>{{.otp}}</p>")},
+		"otp/en.txt.tmpl":     {Data: []byte("Your code is {{.otp}}.")},
+		"otp/de.txt.tmpl":     {Data: []byte("Ihr Code ist {{.otp}}.")},
+	}, "en")
+	if err != nil {
+		t.Fatalf("LoadNotificationTemplates: %v", err)
+	}
+
+	outbox := &fakeNotificationRepo{}
+	return &notificationService{
+		repo:      outbox,
+		notifier:  notifier,
+		templates: templates,
+		config:    input.NotificationConfig{BatchSize: 10, MaxAttempts: 3, RetryBackoff: time.Minute, MaxRetryBackoff: 90 * time.Second},
+		wake:      make(chan struct{}, 1),
+	}, outbox
+}
+
+func TestNotificationEnqueue(t *testing.T) {
+	tests := []struct {
+		name         string
+		send         func(s NotificationService, ctx context.Context) error
+		language     string
+		wantErr      bool
+		wantLanguage string
+		wantText     string
+	}{
+		{
+			name: "email",
+			send: func(s NotificationService, ctx context.Context) error {
+				return s.SendEmail(ctx, "a@example.com", "otp", map[string]string{"otp": "123456"})
+			},
+			wantLanguage: "en",
+			wantText:     "Your code is 123456.",
+		},
+		{
+			name: "sms in the caller's language",
+			send: func(s NotificationService, ctx context.Context) error {
+				return s.SendSMS(ctx, "+15550100", "otp", map[string]string{"otp": "123456"})
+			},
+			language:     "de-AT",
+			wantLanguage: "de",
+			wantText:     "Ihr Code ist 123456.",
+		},
+		{
+			name: "email missing in the caller's language",
+			send: func(s NotificationService, ctx context.Context) error {
+				return s.SendEmail(ctx, "a@example.com", "otp", map[string]string{"otp": "123456"})
+			},
+			language:     "de",
+			wantLanguage: "en",
+			wantText:     "Your code is 123456.",
+		},
+		{
+			name: "unknown template",
+			send: func(s NotificationService, ctx context.Context) error {
+				return s.SendEmail(ctx, "a@example.com", "welcome", nil)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, outbox := newTestNotificationService(t, &flakyNotifier{})
+			ctx := context.WithValue(context.Background(), utils.ClientLanguageKey, tt.language)
+
+			err := tt.send(s, ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("send error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(outbox.outbox) != 0 {
+					t.Error("a notification that failed to render was queued")
+				}
+				return
+			}
+
+			if len(outbox.outbox) != 1 {
+				t.Fatalf("queued %d notifications, want 1", len(outbox.outbox))
+			}
+			queued := outbox.outbox[0]
+			if queued.Status != models.NotificationStatusPending || queued.Language != tt.wantLanguage || queued.TextBody != tt.wantText {
+				t.Errorf("queued %+v, want pending in %s with text %q", queued, tt.wantLanguage, tt.wantText)
+			}
+			select {
+			case <-s.wake:
+			default:
+				t.Error("queueing did not wake the worker")
+			}
+		})
+	}
+}
+
+func TestNotificationDelivery(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		rounds     int
+		wantStatus models.NotificationStatus
+		wantSent   int
+		// wantRetryIn is the delay before the next attempt of a notification
+		// still pending.
+		wantRetryIn time.Duration
+	}{
+		{name: "delivered", rounds: 1, wantStatus: models.NotificationStatusSent, wantSent: 1},
+		{name: "first attempt fails", failures: 1, rounds: 1, wantStatus: models.NotificationStatusPending, wantRetryIn: time.Minute},
+		{name: "second attempt fails", failures: 2, rounds: 2, wantStatus: models.NotificationStatusPending, wantRetryIn: 90 * time.Second},
+		{name: "delivered on retry", failures: 2, rounds: 3, wantStatus: models.NotificationStatusSent, wantSent: 1},
+		{name: "gives up after the last attempt", failures: 3, rounds: 4, wantStatus: models.NotificationStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &flakyNotifier{failures: tt.failures}
+			s, outbox := newTestNotificationService(t, notifier)
+			if err := s.SendSMS(context.Background(), "+15550100", "otp", map[string]string{"otp": "123456"}); err != nil {
+				t.Fatalf("SendSMS: %v", err)
+			}
+			queued := outbox.outbox[0]
+
+			for round := 0; round < tt.rounds; round++ {
+				if round > 0 && queued.Status == models.NotificationStatusPending {
+					// Skip the backoff.
+					queued.NextAttemptAt = time.Now()
+				}
+				s.deliverDue()
+			}
+
+			if queued.Status != tt.wantStatus || len(notifier.sent) != tt.wantSent {
+				t.Fatalf("status = %s after %d sends, want %s after %d", queued.Status, len(notifier.sent), tt.wantStatus, tt.wantSent)
+			}
+			if queued.Attempts > s.config.MaxAttempts {
+				t.Errorf("attempted %d times, want at most %d", queued.Attempts, s.config.MaxAttempts)
+			}
+			if tt.wantStatus != models.NotificationStatusSent && queued.LastError == "" {
+				t.Error("failure not recorded")
+			}
+			if tt.wantSent == 1 && (notifier.sent[0].To != "+15550100" || notifier.sent[0].TextBody != "Your code is 123456.") {
+				t.Errorf("sent %+v", notifier.sent[0])
+			}
+			if tt.wantRetryIn != 0 {
+				if retryIn := time.Until(queued.NextAttemptAt); retryIn <= tt.wantRetryIn-time.Second || retryIn > tt.wantRetryIn {
+					t.Errorf("next attempt in %v, want %v", retryIn, tt.wantRetryIn)
+				}
+			}
+		})
+	}
+}
+
+func TestNotificationDeliveredOnce(t *testing.T) {
+	notifier := &flakyNotifier{}
+	s, outbox := newTestNotificationService(t, notifier)
+	if err := s.SendEmail(context.Background(), "a@example.com", "otp", map[string]string{"otp": "123456"}); err != nil {
+		t.Fatalf("SendEmail: %v", err)
+	}
+
+	// Two workers list the notification before either claims it.
+	due, _ := outbox.ListDue(time.Now(), 10)
+	stale, _ := outbox.ListDue(time.Now(), 10)
+	s.deliver(&due[0])
+	s.deliver(&stale[0])
+
+	if len(notifier.sent) != 1 {
+		t.Errorf("notification sent %d times, want once", len(notifier.sent))
+	}
+}
@@ -0,0 +1,490 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+type OAuthService interface {
+	ValidateAuthorizeRequest(ctx context.Context, req *input.AuthorizeRequest) (*models.OAuthClient, error)
+	IssueAuthorizationCode(ctx context.Context, req *input.AuthorizeRequest, user *models.User, amr []string) (string, error)
+	Token(ctx context.Context, req *input.OAuthTokenRequest) (*output.OAuthTokenResponse, error)
+	// Introspect implements RFC 7662 token introspection for service
+	// accounts.
+	Introspect(ctx context.Context, req *input.OAuthTokenActionRequest) (*output.TokenIntrospectionResponse, error)
+	// Revoke implements RFC 7009 token revocation. Clients may revoke only
+	// the tokens issued to them, unless they are service accounts allowed
+	// the tokens:revoke scope.
+	Revoke(ctx context.Context, req *input.OAuthTokenActionRequest) error
+	CreateClient(ctx context.Context, createdBy uint, req *input.CreateOAuthClientRequest) (*output.OAuthClientResponse, error)
+	GetClients(ctx context.Context) ([]output.OAuthClientResponse, error)
+	UpdateClient(ctx context.Context, id uint, req *input.UpdateOAuthClientRequest) (*output.OAuthClientResponse, error)
+	DeleteClient(ctx context.Context, id uint) error
+}
+
+type oauthService struct {
+	clientRepo            repo.OAuthClientRepository
+	codeRepo              repo.OAuthCodeRepository
+	authService           AuthService
+	serviceAccountService ServiceAccountService
+}
+
+const (
+	authorizationCodeTTL    = 60 * time.Second
+	authorizationCodeLength = 43
+)
+
+var defaultOAuthScopes = []string{"openid", "email", "phone", "profile"}
+
+func NewOAuthService(
+	clientRepo repo.OAuthClientRepository,
+	codeRepo repo.OAuthCodeRepository,
+	authService AuthService,
+	serviceAccountService ServiceAccountService,
+) OAuthService {
+	return &oauthService{
+		clientRepo:            clientRepo,
+		codeRepo:              codeRepo,
+		authService:           authService,
+		serviceAccountService: serviceAccountService,
+	}
+}
+
+// ValidateAuthorizeRequest checks an authorization request. The client is
+// only returned once its redirect URI has been verified, so callers must not
+// redirect errors back to the client when it is nil.
+func (s *oauthService) ValidateAuthorizeRequest(ctx context.Context, req *input.AuthorizeRequest) (*models.OAuthClient, error) {
+	client, err := s.clientRepo.GetByClientID(req.ClientID)
+	if err != nil || !client.IsActive {
+		return nil, utils.NewOAuthError(http.StatusBadRequest, "invalid_client", "unknown client")
+	}
+
+	if !client.HasRedirectURI(req.RedirectURI) {
+		return nil, utils.NewOAuthError(http.StatusBadRequest, "invalid_request", "redirect_uri is not registered for this client")
+	}
+
+	if req.ResponseType != "code" {
+		return client, utils.NewOAuthError(http.StatusBadRequest, "unsupported_response_type", "only response_type=code is supported")
+	}
+
+	if req.CodeChallenge == "" {
+		return client, utils.NewOAuthError(http.StatusBadRequest, "invalid_request", "code_challenge is required")
+	}
+	if req.CodeChallengeMethod != "S256" {
+		return client, utils.NewOAuthError(http.StatusBadRequest, "invalid_request", "code_challenge_method must be S256")
+	}
+	if len(req.CodeChallenge) < 43 || len(req.CodeChallenge) > 128 {
+		return client, utils.NewOAuthError(http.StatusBadRequest, "invalid_request", "code_challenge is malformed")
+	}
+
+	scope, err := resolveScope(client, req.Scope)
+	if err != nil {
+		return client, err
+	}
+	req.Scope = scope
+
+	return client, nil
+}
+
+// IssueAuthorizationCode stores a single-use code for an authenticated user
+// and returns the redirect URI that delivers it to the client. amr lists the
+// methods the user signed in with.
+func (s *oauthService) IssueAuthorizationCode(ctx context.Context, req *input.AuthorizeRequest, user *models.User, amr []string) (string, error) {
+	client, err := s.ValidateAuthorizeRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := utils.GenerateRandomString(authorizationCodeLength)
+	if err != nil {
+		return "", utils.NewOAuthError(http.StatusInternalServerError, "server_error", "failed to generate authorization code")
+	}
+
+	record := &models.OAuthAuthorizationCode{
+		CodeHash:            utils.HashToken(code),
+		ClientID:            client.ClientID,
+		UserID:              user.ID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AuthTime:            time.Now(),
+		AMR:                 strings.Join(amr, " "),
+		ExpiresAt:           time.Now().Add(authorizationCodeTTL),
+	}
+	if err := s.codeRepo.Create(record); err != nil {
+		return "", utils.NewOAuthError(http.StatusInternalServerError, "server_error", "failed to store authorization code")
+	}
+
+	return AuthorizeRedirectURL(req.RedirectURI, map[string]string{
+		"code":  code,
+		"state": req.State,
+	}), nil
+}
+
+func (s *oauthService) Token(ctx context.Context, req *input.OAuthTokenRequest) (*output.OAuthTokenResponse, error) {
+	// Service accounts are a separate principal from OAuth clients acting on
+	// behalf of users, so client_credentials is handled before client lookup.
+	if req.GrantType == "client_credentials" {
+		return s.serviceAccountService.ClientCredentials(ctx, req.ClientID, req.ClientSecret, req.Scope)
+	}
+
+	client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case "authorization_code":
+		return s.exchangeCode(ctx, client, req)
+	case "refresh_token":
+		if req.RefreshToken == "" {
+			return nil, utils.NewOAuthError(http.StatusBadRequest, "invalid_request", "refresh_token is required")
+		}
+		resp, err := s.authService.RefreshClientToken(ctx, req.RefreshToken, client.ClientID)
+		if err != nil {
+			return nil, tokenGrantError(err)
+		}
+		return newOAuthTokenResponse(resp), nil
+	default:
+		return nil, utils.NewOAuthError(http.StatusBadRequest, "unsupported_grant_type", "grant_type must be authorization_code, refresh_token or client_credentials")
+	}
+}
+
+func (s *oauthService) exchangeCode(ctx context.Context, client *models.OAuthClient, req *input.OAuthTokenRequest) (*output.OAuthTokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, utils.NewOAuthError(http.StatusBadRequest, "invalid_request", "code and code_verifier are required")
+	}
+
+	invalidGrant := utils.NewOAuthError(http.StatusBadRequest, "invalid_grant", "authorization code is invalid or has expired")
+
+	code, err := s.codeRepo.GetByCodeHash(utils.HashToken(req.Code))
+	if err != nil {
+		return nil, invalidGrant
+	}
+
+	if code.ClientID != client.ClientID || code.RedirectURI != req.RedirectURI {
+		return nil, invalidGrant
+	}
+	if code.ConsumedAt != nil || time.Now().After(code.ExpiresAt) {
+		return nil, invalidGrant
+	}
+	if !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		return nil, utils.NewOAuthError(http.StatusBadRequest, "invalid_grant", "code_verifier does not match code_challenge")
+	}
+
+	consumed, err := s.codeRepo.Consume(code.ID)
+	if err != nil {
+		return nil, utils.NewOAuthError(http.StatusInternalServerError, "server_error", "failed to redeem authorization code")
+	}
+	if !consumed {
+		return nil, invalidGrant
+	}
+
+	resp, err := s.authService.IssueTokens(ctx, code.UserID, TokenOptions{
+		ClientID: client.ClientID,
+		Scope:    code.Scope,
+		Nonce:    code.Nonce,
+		AuthTime: code.AuthTime,
+		AMR:      strings.Fields(code.AMR),
+	})
+	if err != nil {
+		return nil, tokenGrantError(err)
+	}
+
+	return newOAuthTokenResponse(resp), nil
+}
+
+func (s *oauthService) Introspect(ctx context.Context, req *input.OAuthTokenActionRequest) (*output.TokenIntrospectionResponse, error) {
+	if _, err := s.serviceAccountService.Authenticate(ctx, req.ClientID, req.ClientSecret); err != nil {
+		return nil, err
+	}
+
+	if req.Token == "" {
+		return nil, utils.NewOAuthError(http.StatusBadRequest, "invalid_request", "token is required")
+	}
+
+	resp, err := s.authService.IntrospectToken(ctx, req.Token)
+	if err != nil {
+		log.Printf("oauth introspection failed: %v", err)
+		return nil, utils.NewOAuthError(http.StatusInternalServerError, "server_error", "failed to introspect token")
+	}
+	return resp, nil
+}
+
+// Revoke silently ignores tokens that belong to another client, as it does
+// invalid ones, so that clients cannot probe for tokens they do not hold.
+func (s *oauthService) Revoke(ctx context.Context, req *input.OAuthTokenActionRequest) error {
+	ownClientID := ""
+	if account, err := s.serviceAccountService.Authenticate(ctx, req.ClientID, req.ClientSecret); err == nil {
+		if !account.AllowsScope(utils.TokenRevokeScope) {
+			ownClientID = account.ClientID
+		}
+	} else {
+		client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+		if err != nil {
+			return err
+		}
+		ownClientID = client.ClientID
+	}
+
+	if req.Token == "" {
+		return utils.NewOAuthError(http.StatusBadRequest, "invalid_request", "token is required")
+	}
+
+	if ownClientID != "" {
+		token, err := s.authService.IntrospectToken(ctx, req.Token)
+		if err != nil {
+			log.Printf("oauth revocation failed: %v", err)
+			return utils.NewOAuthError(http.StatusInternalServerError, "server_error", "failed to revoke token")
+		}
+		if !token.Active || token.ClientID != ownClientID {
+			return nil
+		}
+	}
+
+	if err := s.authService.RevokeToken(ctx, req.Token, req.ClientID); err != nil {
+		log.Printf("oauth revocation failed: %v", err)
+		return utils.NewOAuthError(http.StatusInternalServerError, "server_error", "failed to revoke token")
+	}
+	return nil
+}
+
+// authenticateClient checks client credentials. Public clients authenticate
+// with their client_id alone and rely on PKCE instead of a secret.
+func (s *oauthService) authenticateClient(clientID, clientSecret string) (*models.OAuthClient, error) {
+	invalidClient := utils.NewOAuthError(http.StatusUnauthorized, "invalid_client", "client authentication failed")
+
+	if clientID == "" {
+		return nil, invalidClient
+	}
+
+	client, err := s.clientRepo.GetByClientID(clientID)
+	if err != nil || !client.IsActive {
+		return nil, invalidClient
+	}
+
+	if client.IsPublic {
+		return client, nil
+	}
+
+	if clientSecret == "" || client.ClientSecretHash == nil || !utils.CompareTokenHash(clientSecret, *client.ClientSecretHash) {
+		return nil, invalidClient
+	}
+
+	return client, nil
+}
+
+func (s *oauthService) CreateClient(ctx context.Context, createdBy uint, req *input.CreateOAuthClientRequest) (*output.OAuthClientResponse, error) {
+	if err := validateRedirectURIs(req.RedirectURIs); err != nil {
+		return nil, err
+	}
+
+	clientID, err := utils.GenerateRandomString(24)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := req.AllowedScopes
+	if len(scopes) == 0 {
+		scopes = defaultOAuthScopes
+	}
+
+	client := &models.OAuthClient{
+		ClientID:      clientID,
+		Name:          req.Name,
+		RedirectURIs:  models.StringArray(req.RedirectURIs),
+		AllowedScopes: models.StringArray(scopes),
+		IsPublic:      req.IsPublic,
+		IsActive:      true,
+		CreatedBy:     &createdBy,
+	}
+
+	var secret string
+	if !req.IsPublic {
+		secret, err = utils.GenerateRandomString(48)
+		if err != nil {
+			return nil, err
+		}
+		secretHash := utils.HashToken(secret)
+		client.ClientSecretHash = &secretHash
+	}
+
+	if err := s.clientRepo.Create(client); err != nil {
+		return nil, err
+	}
+
+	resp := newOAuthClientResponse(client)
+	resp.ClientSecret = secret
+	return &resp, nil
+}
+
+func (s *oauthService) GetClients(ctx context.Context) ([]output.OAuthClientResponse, error) {
+	clients, err := s.clientRepo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]output.OAuthClientResponse, 0, len(clients))
+	for i := range clients {
+		resp = append(resp, newOAuthClientResponse(&clients[i]))
+	}
+	return resp, nil
+}
+
+func (s *oauthService) UpdateClient(ctx context.Context, id uint, req *input.UpdateOAuthClientRequest) (*output.OAuthClientResponse, error) {
+	client, err := s.clientRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewNotFoundError("client not found")
+		}
+		return nil, err
+	}
+
+	if req.Name != nil {
+		client.Name = *req.Name
+	}
+	if req.RedirectURIs != nil {
+		if err := validateRedirectURIs(req.RedirectURIs); err != nil {
+			return nil, err
+		}
+		client.RedirectURIs = models.StringArray(req.RedirectURIs)
+	}
+	if req.AllowedScopes != nil {
+		client.AllowedScopes = models.StringArray(req.AllowedScopes)
+	}
+	if req.IsActive != nil {
+		client.IsActive = *req.IsActive
+	}
+
+	if err := s.clientRepo.Update(client); err != nil {
+		return nil, err
+	}
+
+	resp := newOAuthClientResponse(client)
+	return &resp, nil
+}
+
+func (s *oauthService) DeleteClient(ctx context.Context, id uint) error {
+	if _, err := s.clientRepo.GetByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewNotFoundError("client not found")
+		}
+		return err
+	}
+
+	return s.clientRepo.Delete(id)
+}
+
+// AuthorizeRedirectURL appends the given parameters to a client redirect
+// URI, skipping empty values.
+func AuthorizeRedirectURL(redirectURI string, params map[string]string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	q := u.Query()
+	for key, value := range params {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// resolveScope defaults an empty request to every scope the client may use
+// and rejects scopes it was not registered for.
+func resolveScope(client *models.OAuthClient, requested string) (string, error) {
+	if strings.TrimSpace(requested) == "" {
+		return strings.Join(client.AllowedScopes, " "), nil
+	}
+
+	scopes := strings.Fields(requested)
+	for _, scope := range scopes {
+		if !client.AllowsScope(scope) {
+			return "", utils.NewOAuthError(http.StatusBadRequest, "invalid_scope", "scope "+scope+" is not allowed for this client")
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func validateRedirectURIs(uris []string) error {
+	if len(uris) == 0 {
+		return utils.NewBadRequestError("at least one redirect_uri is required")
+	}
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+			return utils.NewBadRequestError("invalid redirect_uri: " + uri)
+		}
+	}
+	return nil
+}
+
+// tokenGrantError maps AuthService errors onto OAuth error codes.
+func tokenGrantError(err error) error {
+	var httpErr *utils.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code < http.StatusInternalServerError {
+		return utils.NewOAuthError(http.StatusBadRequest, "invalid_grant", httpErr.Message)
+	}
+	log.Printf("oauth token grant failed: %v", err)
+	return utils.NewOAuthError(http.StatusInternalServerError, "server_error", "failed to issue tokens")
+}
+
+func newOAuthTokenResponse(resp *output.AuthResponse) *output.OAuthTokenResponse {
+	token := &output.OAuthTokenResponse{
+		AccessToken:  resp.AccessToken,
+		TokenType:    resp.TokenType,
+		ExpiresIn:    resp.ExpiresIn,
+		RefreshToken: resp.RefreshToken,
+		Scope:        resp.Scope,
+	}
+
+	for _, scope := range strings.Fields(token.Scope) {
+		if scope == "openid" {
+			token.IDToken = resp.IDToken
+		}
+	}
+	return token
+}
+
+func newOAuthClientResponse(client *models.OAuthClient) output.OAuthClientResponse {
+	return output.OAuthClientResponse{
+		ID:            client.ID,
+		ClientID:      client.ClientID,
+		Name:          client.Name,
+		RedirectURIs:  client.RedirectURIs,
+		AllowedScopes: client.AllowedScopes,
+		IsPublic:      client.IsPublic,
+		IsActive:      client.IsActive,
+		CreatedAt:     client.CreatedAt,
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B.
+	const (
+		verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{name: "rfc 7636 vector", verifier: verifier, challenge: challenge, want: true},
+		{name: "wrong verifier", verifier: strings.Replace(verifier, "d", "e", 1), challenge: challenge},
+		{name: "plain challenge", verifier: verifier, challenge: verifier},
+		{name: "padded challenge", verifier: verifier, challenge: challenge + "="},
+		{name: "empty challenge", verifier: verifier, challenge: ""},
+		{name: "verifier too short", verifier: verifier[:42], challenge: challenge},
+		{name: "verifier too long", verifier: strings.Repeat("a", 129), challenge: challenge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyCodeChallenge(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("verifyCodeChallenge = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeServiceAccounts authenticates service accounts by client ID alone.
+type fakeServiceAccounts struct {
+	ServiceAccountService
+	accounts map[string]*models.ServiceAccount
+}
+
+func (f *fakeServiceAccounts) Authenticate(ctx context.Context, clientID, clientSecret string) (*models.ServiceAccount, error) {
+	account, ok := f.accounts[clientID]
+	if !ok {
+		return nil, utils.NewOAuthError(http.StatusUnauthorized, "invalid_client", "client authentication failed")
+	}
+	return account, nil
+}
+
+type fakeOAuthClientRepo struct {
+	repo.OAuthClientRepository
+	clients map[string]*models.OAuthClient
+}
+
+func (r *fakeOAuthClientRepo) GetByClientID(clientID string) (*models.OAuthClient, error) {
+	client, ok := r.clients[clientID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return client, nil
+}
+
+// fakeTokenAuth treats a token as the client ID it was issued to and
+// records the tokens revoked.
+type fakeTokenAuth struct {
+	AuthService
+	revoked []string
+}
+
+func (f *fakeTokenAuth) IntrospectToken(ctx context.Context, token string) (*output.TokenIntrospectionResponse, error) {
+	return &output.TokenIntrospectionResponse{Active: true, Claims: &output.Claims{ClientID: token}}, nil
+}
+
+func (f *fakeTokenAuth) RevokeToken(ctx context.Context, token, revokedBy string) error {
+	f.revoked = append(f.revoked, token)
+	return nil
+}
+
+func TestRevokeLimitsClientsToTheirOwnTokens(t *testing.T) {
+	serviceAccounts := &fakeServiceAccounts{accounts: map[string]*models.ServiceAccount{
+		"svc_reports":  {ClientID: "svc_reports", AllowedScopes: models.StringArray{"invoices:read"}},
+		"svc_wildcard": {ClientID: "svc_wildcard", AllowedScopes: models.StringArray{"*"}},
+		"svc_security": {ClientID: "svc_security", AllowedScopes: models.StringArray{utils.TokenRevokeScope}},
+	}}
+	clients := &fakeOAuthClientRepo{clients: map[string]*models.OAuthClient{
+		"web": {ClientID: "web", IsActive: true, IsPublic: true},
+	}}
+
+	tests := []struct {
+		name        string
+		clientID    string
+		token       string
+		wantRevoked bool
+	}{
+		{name: "oauth client, own token", clientID: "web", token: "web", wantRevoked: true},
+		{name: "oauth client, other client's token", clientID: "web", token: "mobile"},
+		{name: "service account, own token", clientID: "svc_reports", token: "svc_reports", wantRevoked: true},
+		{name: "service account, user's token", clientID: "svc_reports", token: "web"},
+		{name: "wildcard service account, user's token", clientID: "svc_wildcard", token: "web"},
+		{name: "service account with tokens:revoke, user's token", clientID: "svc_security", token: "web", wantRevoked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &fakeTokenAuth{}
+			s := &oauthService{clientRepo: clients, authService: auth, serviceAccountService: serviceAccounts}
+
+			err := s.Revoke(context.Background(), &input.OAuthTokenActionRequest{Token: tt.token, ClientID: tt.clientID})
+			if err != nil {
+				t.Fatalf("Revoke: %v", err)
+			}
+			if revoked := len(auth.revoked) == 1; revoked != tt.wantRevoked {
+				t.Errorf("revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+func TestIntrospectAuthenticatesServiceClients(t *testing.T) {
+	serviceAccounts := &fakeServiceAccounts{accounts: map[string]*models.ServiceAccount{
+		"svc_gateway": {ClientID: "svc_gateway"},
+	}}
+	clients := &fakeOAuthClientRepo{clients: map[string]*models.OAuthClient{
+		"web": {ClientID: "web", IsActive: true, IsPublic: true},
+	}}
+
+	tests := []struct {
+		name       string
+		clientID   string
+		token      string
+		wantStatus int
+	}{
+		{name: "service account", clientID: "svc_gateway", token: "web", wantStatus: http.StatusOK},
+		{name: "oauth client", clientID: "web", token: "web", wantStatus: http.StatusUnauthorized},
+		{name: "no client", token: "web", wantStatus: http.StatusUnauthorized},
+		{name: "no token", clientID: "svc_gateway", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &oauthService{clientRepo: clients, authService: &fakeTokenAuth{}, serviceAccountService: serviceAccounts}
+
+			resp, err := s.Introspect(context.Background(), &input.OAuthTokenActionRequest{Token: tt.token, ClientID: tt.clientID})
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+			if err == nil && (!resp.Active || resp.ClientID != tt.token) {
+				t.Errorf("introspection = %+v", resp)
+			}
+		})
+	}
+}
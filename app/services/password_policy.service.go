@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+type PasswordPolicyService interface {
+	// HashPassword checks a new password against the policy and, for an
+	// existing user, their recent passwords, and returns its hash. user is
+	// nil for accounts that do not exist yet. Violations are returned as a
+	// *utils.ValidationError.
+	HashPassword(user *models.User, password string) (string, error)
+	// RecordPassword adds a saved password to the user's history.
+	RecordPassword(userID uint, passwordHash string) error
+	// IsExpired reports whether the user's password is older than the
+	// maximum age. Accounts that never changed their password count from
+	// their creation.
+	IsExpired(user *models.User) bool
+}
+
+type passwordPolicyService struct {
+	policy              *utils.PasswordPolicy
+	passwordHistoryRepo repo.PasswordHistoryRepository
+}
+
+func NewPasswordPolicyService(policy *utils.PasswordPolicy, passwordHistoryRepo repo.PasswordHistoryRepository) PasswordPolicyService {
+	return &passwordPolicyService{
+		policy:              policy,
+		passwordHistoryRepo: passwordHistoryRepo,
+	}
+}
+
+func (s *passwordPolicyService) HashPassword(user *models.User, password string) (string, error) {
+	violations := s.policy.Check(password)
+
+	if user != nil && s.policy.HistorySize() > 0 {
+		reused, err := s.isReused(user, password)
+		if err != nil {
+			return "", err
+		}
+		if reused {
+			violations = append(violations, output.Violation{
+				Rule:    "reused",
+				Message: fmt.Sprintf("password must not match any of your last %d passwords", s.policy.HistorySize()),
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return "", utils.NewValidationError("password does not meet the password policy", violations)
+	}
+
+	return utils.HashPassword(password)
+}
+
+func (s *passwordPolicyService) isReused(user *models.User, password string) (bool, error) {
+	if user.PasswordHash != nil && utils.CheckPassword(password, *user.PasswordHash) {
+		return true, nil
+	}
+
+	history, err := s.passwordHistoryRepo.GetRecent(user.ID, s.policy.HistorySize())
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range history {
+		if utils.CheckPassword(password, entry.PasswordHash) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *passwordPolicyService) RecordPassword(userID uint, passwordHash string) error {
+	if s.policy.HistorySize() == 0 {
+		return nil
+	}
+
+	if err := s.passwordHistoryRepo.Add(userID, passwordHash, s.policy.HistorySize()); err != nil {
+		log.Printf("[PASSWORD_POLICY] Failed to record password history for user %d: %v", userID, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *passwordPolicyService) IsExpired(user *models.User) bool {
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+
+	return s.policy.IsExpired(changedAt, time.Now())
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+// fakePasswordHistoryRepo keeps each user's password hashes, newest first.
+type fakePasswordHistoryRepo struct {
+	repo.PasswordHistoryRepository
+	hashes map[uint][]string
+}
+
+func (r *fakePasswordHistoryRepo) GetRecent(userID uint, limit int) ([]models.PasswordHistory, error) {
+	var history []models.PasswordHistory
+	for i, hash := range r.hashes[userID] {
+		if i == limit {
+			break
+		}
+		history = append(history, models.PasswordHistory{UserID: userID, PasswordHash: hash})
+	}
+	return history, nil
+}
+
+func (r *fakePasswordHistoryRepo) Add(userID uint, passwordHash string, keep int) error {
+	hashes := append([]string{passwordHash}, r.hashes[userID]...)
+	if len(hashes) > keep {
+		hashes = hashes[:keep]
+	}
+	r.hashes[userID] = hashes
+	return nil
+}
+
+func TestHashPasswordHistory(t *testing.T) {
+	policy, err := utils.NewPasswordPolicy(input.PasswordPolicyConfig{MinLength: 10, RequireDigit: true, HistorySize: 2})
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy: %v", err)
+	}
+
+	history := &fakePasswordHistoryRepo{hashes: make(map[uint][]string)}
+	s := NewPasswordPolicyService(policy, history)
+
+	// The user went through three passwords; the oldest dropped out of the
+	// history of two.
+	var current string
+	for _, password := range []string{"password-0001", "password-0002", "password-0003"} {
+		if current, err = s.HashPassword(nil, password); err != nil {
+			t.Fatalf("HashPassword: %v", err)
+		}
+		if err := s.RecordPassword(7, current); err != nil {
+			t.Fatalf("RecordPassword: %v", err)
+		}
+	}
+	user := &models.User{ID: 7, PasswordHash: &current}
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		password   string
+		wantStatus int
+		wantRules  []string
+	}{
+		{name: "new password", user: user, password: "password-0004", wantStatus: http.StatusOK},
+		{name: "current password", user: user, password: "password-0003", wantStatus: http.StatusBadRequest, wantRules: []string{"reused"}},
+		{name: "previous password", user: user, password: "password-0002", wantStatus: http.StatusBadRequest, wantRules: []string{"reused"}},
+		{name: "password older than the history", user: user, password: "password-0001", wantStatus: http.StatusOK},
+		{name: "new account", password: "password-0003", wantStatus: http.StatusOK},
+		{name: "every violation listed", user: user, password: "short", wantStatus: http.StatusBadRequest, wantRules: []string{"min_length", "digit"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := s.HashPassword(tt.user, tt.password)
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+			if err == nil {
+				if !utils.CheckPassword(tt.password, hash) {
+					t.Error("returned hash does not match the password")
+				}
+				return
+			}
+
+			validationErr := err.(*utils.ValidationError)
+			var rules []string
+			for _, violation := range validationErr.Violations {
+				rules = append(rules, violation.Rule)
+			}
+			if !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("rules = %v, want %v", rules, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestPasswordIsExpired(t *testing.T) {
+	policy, err := utils.NewPasswordPolicy(input.PasswordPolicyConfig{MaxAge: 90 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy: %v", err)
+	}
+	s := NewPasswordPolicyService(policy, nil)
+
+	recently := time.Now().Add(-24 * time.Hour)
+	longAgo := time.Now().Add(-100 * 24 * time.Hour)
+
+	tests := []struct {
+		name string
+		user models.User
+		want bool
+	}{
+		{name: "changed recently", user: models.User{CreatedAt: longAgo, PasswordChangedAt: &recently}},
+		{name: "changed long ago", user: models.User{CreatedAt: longAgo, PasswordChangedAt: &longAgo}, want: true},
+		{name: "never changed, created recently", user: models.User{CreatedAt: recently}},
+		{name: "never changed, created long ago", user: models.User{CreatedAt: longAgo}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsExpired(&tt.user); got != tt.want {
+				t.Errorf("IsExpired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetService interface {
+	// ForgotPassword emails a reset link when the address belongs to an active
+	// account with a password. It reports success either way so that
+	// accounts cannot be discovered through it.
+	ForgotPassword(ctx context.Context, req *input.ForgotPasswordRequest) error
+	// ResetPassword consumes a reset token, sets the new password and signs
+	// the user out everywhere.
+	ResetPassword(ctx context.Context, req *input.CompletePasswordResetRequest) error
+}
+
+type passwordResetService struct {
+	passwordResetRepo   repo.PasswordResetRepository
+	userRepo            repo.UserRepository
+	sessionService      SessionService
+	passwordPolicy      PasswordPolicyService
+	notificationService NotificationService
+	auditService        AuditService
+	config              input.PasswordResetConfig
+}
+
+func NewPasswordResetService(
+	passwordResetRepo repo.PasswordResetRepository,
+	userRepo repo.UserRepository,
+	sessionService SessionService,
+	passwordPolicy PasswordPolicyService,
+	notificationService NotificationService,
+	auditService AuditService,
+	cfg input.PasswordResetConfig,
+) PasswordResetService {
+	return &passwordResetService{
+		passwordResetRepo:   passwordResetRepo,
+		userRepo:            userRepo,
+		sessionService:      sessionService,
+		passwordPolicy:      passwordPolicy,
+		notificationService: notificationService,
+		auditService:        auditService,
+		config:              cfg,
+	}
+}
+
+func (s *passwordResetService) ForgotPassword(ctx context.Context, req *input.ForgotPasswordRequest) error {
+	event := models.AuthEvent{Type: models.AuthEventPasswordResetRequest, Identifier: req.Email}
+
+	user, err := s.userRepo.GetByEmail(req.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.auditService.Record(ctx, event, errors.New("no account with this email"))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	event.SubjectID = &user.ID
+
+	if user.Status != models.UserStatusActive || user.PasswordHash == nil {
+		log.Printf("[PASSWORD_RESET] Ignoring reset request for user %d", user.ID)
+		s.auditService.Record(ctx, event, errors.New("account is not active or has no password"))
+		return nil
+	}
+
+	token, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return err
+	}
+
+	ip, _ := utils.ClientInfo(ctx)
+	record := &models.PasswordResetToken{
+		UserID:      user.ID,
+		TokenHash:   utils.HashToken(token),
+		RequestedIP: ip,
+		ExpiresAt:   time.Now().Add(s.config.TokenTTL),
+	}
+	if err := s.passwordResetRepo.Create(record); err != nil {
+		return err
+	}
+
+	if err := s.passwordResetRepo.DeleteExpired(); err != nil {
+		log.Printf("[PASSWORD_RESET] Failed to delete expired tokens: %v", err)
+	}
+
+	// A failure is only logged: answering differently for existing accounts
+	// would tell callers which emails are registered.
+	if err := s.sendResetEmail(ctx, *user.Email, token); err != nil {
+		log.Printf("[PASSWORD_RESET] Failed to queue reset email for user %d: %v", user.ID, err)
+	}
+
+	log.Printf("[PASSWORD_RESET] Reset token issued for user %d", user.ID)
+	s.auditService.Record(ctx, event, nil)
+	return nil
+}
+
+func (s *passwordResetService) ResetPassword(ctx context.Context, req *input.CompletePasswordResetRequest) error {
+	event := models.AuthEvent{Type: models.AuthEventPasswordReset}
+	userID, err := s.resetPassword(ctx, req)
+	if userID != 0 {
+		event.SubjectID = &userID
+	}
+	s.auditService.Record(ctx, event, err)
+	return err
+}
+
+// resetPassword returns the ID of the account the token was issued for, or
+// zero when the token matched none.
+func (s *passwordResetService) resetPassword(ctx context.Context, req *input.CompletePasswordResetRequest) (uint, error) {
+	record, err := s.passwordResetRepo.GetActiveByHash(utils.HashToken(req.Token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, utils.NewBadRequestError("reset token is invalid or has expired")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	user, err := s.userRepo.GetByID(record.UserID)
+	if err != nil {
+		return 0, utils.NewBadRequestError("reset token is invalid or has expired")
+	}
+
+	if user.Status != models.UserStatusActive {
+		return user.ID, utils.NewForbiddenError("user account is not active")
+	}
+
+	// The password is checked first so that a rejected password does not
+	// use up the link.
+	passwordHash, err := s.passwordPolicy.HashPassword(user, req.NewPassword)
+	if err != nil {
+		return user.ID, err
+	}
+
+	consumed, err := s.passwordResetRepo.Consume(record.ID)
+	if err != nil {
+		return user.ID, err
+	}
+	if !consumed {
+		return user.ID, utils.NewBadRequestError("reset token is invalid or has expired")
+	}
+
+	user.PasswordHash = &passwordHash
+	if err := s.userRepo.Update(user); err != nil {
+		return user.ID, err
+	}
+
+	if err := s.userRepo.UpdatePasswordChangedAt(user.ID); err != nil {
+		return user.ID, err
+	}
+	s.passwordPolicy.RecordPassword(user.ID, passwordHash)
+
+	// Proving control of the email address is enough to lift a lockout.
+	if err := s.userRepo.ClearLoginFailures(user.ID); err != nil {
+		return user.ID, err
+	}
+
+	if err := s.sessionService.LogoutAll(ctx, user.ID, "password_reset"); err != nil {
+		return user.ID, err
+	}
+
+	log.Printf("[PASSWORD_RESET] Password reset for user %d", user.ID)
+	return user.ID, nil
+}
+
+// sendResetEmail sends the reset link, or the bare token when no reset page
+// is configured.
+func (s *passwordResetService) sendResetEmail(ctx context.Context, email, token string) error {
+	data := map[string]string{
+		"token":              token,
+		"expires_in_minutes": strconv.Itoa(int(s.config.TokenTTL.Minutes())),
+	}
+
+	if s.config.ResetURL != "" {
+		link, err := url.Parse(s.config.ResetURL)
+		if err != nil {
+			return fmt.Errorf("invalid password reset URL: %w", err)
+		}
+		query := link.Query()
+		query.Set("token", token)
+		link.RawQuery = query.Encode()
+		data["link"] = link.String()
+	}
+
+	return s.notificationService.SendEmail(ctx, email, "password_reset", data)
+}
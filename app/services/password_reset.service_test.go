@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+type fakePasswordResetRepo struct {
+	repo.PasswordResetRepository
+	tokens []*models.PasswordResetToken
+}
+
+func (r *fakePasswordResetRepo) Create(token *models.PasswordResetToken) error {
+	token.ID = uint(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *fakePasswordResetRepo) GetActiveByHash(tokenHash string) (*models.PasswordResetToken, error) {
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash && token.ConsumedAt == nil && time.Now().Before(token.ExpiresAt) {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakePasswordResetRepo) Consume(id uint) (bool, error) {
+	for _, token := range r.tokens {
+		if token.ID == id && token.ConsumedAt == nil {
+			now := time.Now()
+			token.ConsumedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakePasswordResetRepo) DeleteExpired() error {
+	return nil
+}
+
+type sentNotification struct {
+	address  string
+	template string
+	data     map[string]string
+}
+
+// fakeNotificationService records the messages it was asked to send.
+type fakeNotificationService struct {
+	sent []sentNotification
+}
+
+func (s *fakeNotificationService) SendEmail(ctx context.Context, address, template string, data map[string]string) error {
+	s.sent = append(s.sent, sentNotification{address: address, template: template, data: data})
+	return nil
+}
+
+func (s *fakeNotificationService) SendSMS(ctx context.Context, phone, template string, data map[string]string) error {
+	s.sent = append(s.sent, sentNotification{address: phone, template: template, data: data})
+	return nil
+}
+
+// fakeAuditService records events with whether they succeeded.
+type fakeAuditService struct {
+	AuditService
+	events []models.AuthEvent
+	failed []bool
+}
+
+func (s *fakeAuditService) Record(ctx context.Context, event models.AuthEvent, err error) {
+	s.events = append(s.events, event)
+	s.failed = append(s.failed, err != nil)
+}
+
+type passwordResetTest struct {
+	s             *passwordResetService
+	users         *fakeUserRepo
+	resets        *fakePasswordResetRepo
+	sessions      *fakeSessionService
+	notifications *fakeNotificationService
+	audit         *fakeAuditService
+}
+
+func newPasswordResetTest(t *testing.T, users ...*models.User) *passwordResetTest {
+	t.Helper()
+
+	policy, err := utils.NewPasswordPolicy(input.PasswordPolicyConfig{MinLength: 10, RequireDigit: true})
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy: %v", err)
+	}
+
+	rt := &passwordResetTest{
+		users:         &fakeUserRepo{users: make(map[uint]*models.User)},
+		resets:        &fakePasswordResetRepo{},
+		sessions:      &fakeSessionService{},
+		notifications: &fakeNotificationService{},
+		audit:         &fakeAuditService{},
+	}
+	for _, user := range users {
+		rt.users.users[user.ID] = user
+	}
+	rt.s = NewPasswordResetService(
+		rt.resets,
+		rt.users,
+		rt.sessions,
+		NewPasswordPolicyService(policy, nil),
+		rt.notifications,
+		rt.audit,
+		input.PasswordResetConfig{ResetURL: "https://app.example.com/reset?lang=en", TokenTTL: 30 * time.Minute},
+	).(*passwordResetService)
+	return rt
+}
+
+func TestForgotPassword(t *testing.T) {
+	passwordHash, err := utils.HashPassword("old-password-1")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	email := func(address string) *string { return &address }
+
+	tests := []struct {
+		name      string
+		user      *models.User
+		email     string
+		wantEmail bool
+	}{
+		{
+			name:      "active account",
+			user:      &models.User{ID: 7, Email: email("admin@example.com"), PasswordHash: &passwordHash, Status: models.UserStatusActive},
+			email:     "admin@example.com",
+			wantEmail: true,
+		},
+		{
+			name:  "unknown email",
+			user:  &models.User{ID: 7, Email: email("admin@example.com"), PasswordHash: &passwordHash, Status: models.UserStatusActive},
+			email: "other@example.com",
+		},
+		{
+			name:  "inactive account",
+			user:  &models.User{ID: 7, Email: email("admin@example.com"), PasswordHash: &passwordHash, Status: models.UserStatusInactive},
+			email: "admin@example.com",
+		},
+		{
+			name:  "account without a password",
+			user:  &models.User{ID: 7, Email: email("admin@example.com"), Status: models.UserStatusActive},
+			email: "admin@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newPasswordResetTest(t, tt.user)
+
+			// The answer is the same whether or not an email was sent.
+			if err := rt.s.ForgotPassword(context.Background(), &input.ForgotPasswordRequest{Email: tt.email}); err != nil {
+				t.Fatalf("ForgotPassword: %v", err)
+			}
+
+			if len(rt.audit.failed) != 1 || rt.audit.failed[0] == tt.wantEmail {
+				t.Errorf("audit failed = %v, want one event failed %v", rt.audit.failed, !tt.wantEmail)
+			}
+			if !tt.wantEmail {
+				if len(rt.notifications.sent) != 0 || len(rt.resets.tokens) != 0 {
+					t.Errorf("sent %d emails and stored %d tokens, want none", len(rt.notifications.sent), len(rt.resets.tokens))
+				}
+				return
+			}
+
+			if len(rt.notifications.sent) != 1 || len(rt.resets.tokens) != 1 {
+				t.Fatalf("sent %d emails and stored %d tokens, want one of each", len(rt.notifications.sent), len(rt.resets.tokens))
+			}
+			sent, stored := rt.notifications.sent[0], rt.resets.tokens[0]
+			token := sent.data["token"]
+			if sent.address != tt.email || sent.template != "password_reset" || token == "" {
+				t.Errorf("sent %+v", sent)
+			}
+			if stored.TokenHash != utils.HashToken(token) {
+				t.Error("stored token is not the hash of the emailed one")
+			}
+			if ttl := time.Until(stored.ExpiresAt); ttl <= 29*time.Minute || ttl > 30*time.Minute {
+				t.Errorf("token expires in %v, want 30m", ttl)
+			}
+
+			link, err := url.Parse(sent.data["link"])
+			if err != nil {
+				t.Fatalf("link: %v", err)
+			}
+			if link.Query().Get("token") != token || link.Query().Get("lang") != "en" {
+				t.Errorf("link = %s, want the reset URL with the token added", link)
+			}
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	const goodPassword = "new-password-2"
+
+	tests := []struct {
+		name string
+		// prepare alters the issued token or its user before the reset.
+		prepare    func(rt *passwordResetTest)
+		password   string
+		wantStatus int
+		// wantUsable is whether the token still resets the password after
+		// the attempt.
+		wantUsable bool
+	}{
+		{name: "valid token", password: goodPassword, wantStatus: http.StatusOK},
+		{name: "password breaks the policy", password: "short", wantStatus: http.StatusBadRequest, wantUsable: true},
+		{
+			name:       "token already used",
+			prepare:    func(rt *passwordResetTest) { rt.resets.Consume(rt.resets.tokens[0].ID) },
+			password:   goodPassword,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "expired token",
+			prepare:    func(rt *passwordResetTest) { rt.resets.tokens[0].ExpiresAt = time.Now().Add(-time.Second) },
+			password:   goodPassword,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "account deactivated after the request",
+			prepare:    func(rt *passwordResetTest) { rt.users.users[7].Status = models.UserStatusInactive },
+			password:   goodPassword,
+			wantStatus: http.StatusForbidden,
+			wantUsable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := "admin@example.com"
+			oldHash, err := utils.HashPassword("old-password-1")
+			if err != nil {
+				t.Fatalf("HashPassword: %v", err)
+			}
+			lockedUntil := time.Now().Add(time.Hour)
+			rt := newPasswordResetTest(t, &models.User{
+				ID:                  7,
+				Email:               &email,
+				PasswordHash:        &oldHash,
+				Status:              models.UserStatusActive,
+				FailedLoginAttempts: 3,
+				LockedUntil:         &lockedUntil,
+			})
+
+			if err := rt.s.ForgotPassword(context.Background(), &input.ForgotPasswordRequest{Email: email}); err != nil {
+				t.Fatalf("ForgotPassword: %v", err)
+			}
+			token := rt.notifications.sent[0].data["token"]
+			if tt.prepare != nil {
+				tt.prepare(rt)
+			}
+
+			err = rt.s.ResetPassword(context.Background(), &input.CompletePasswordResetRequest{Token: token, NewPassword: tt.password})
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+
+			user := rt.users.users[7]
+			reset := err == nil
+			if changed := !utils.CheckPassword("old-password-1", *user.PasswordHash); changed != reset {
+				t.Errorf("password changed = %v, want %v", changed, reset)
+			}
+			if (user.PasswordChangedAt != nil) != reset {
+				t.Errorf("PasswordChangedAt = %v, want set %v", user.PasswordChangedAt, reset)
+			}
+			if (len(rt.sessions.loggedOut) == 1) != reset {
+				t.Errorf("users signed out = %v, want signed out %v", rt.sessions.loggedOut, reset)
+			}
+			if reset && (user.LockedUntil != nil || user.FailedLoginAttempts != 0) {
+				t.Error("reset kept the account locked")
+			}
+
+			if _, err := rt.resets.GetActiveByHash(utils.HashToken(token)); (err == nil) != tt.wantUsable {
+				t.Errorf("token usable after the attempt = %v, want %v", err == nil, tt.wantUsable)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/repo"
+
+	"gorm.io/gorm"
+)
+
+type PermissionService interface {
+	RolePermissions(roleName string) ([]string, error)
+	// InvalidateRole drops the cached permissions of a role on every
+	// replica after it was changed.
+	InvalidateRole(roleName string)
+}
+
+type permissionService struct {
+	roleRepo          repo.RoleRepository
+	revocationService RevocationService
+
+	mu    sync.RWMutex
+	cache map[string]cachedPermissions
+}
+
+type cachedPermissions struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+// permissionCacheTTL bounds how long a role change made directly in the
+// database takes to apply.
+const permissionCacheTTL = time.Minute
+
+// NewPermissionService shares role invalidations through revocationService,
+// whose sync carries them to the other replicas.
+func NewPermissionService(roleRepo repo.RoleRepository, revocationService RevocationService) PermissionService {
+	s := &permissionService{
+		roleRepo:          roleRepo,
+		revocationService: revocationService,
+		cache:             make(map[string]cachedPermissions),
+	}
+
+	revocationService.OnRoleInvalidated(s.forget)
+
+	return s
+}
+
+// RolePermissions returns the permissions of an active role. Unknown and
+// inactive roles have none.
+func (s *permissionService) RolePermissions(roleName string) ([]string, error) {
+	s.mu.RLock()
+	entry, ok := s.cache[roleName]
+	s.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.permissions, nil
+	}
+
+	var permissions []string
+	role, err := s.roleRepo.GetByName(roleName)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && role.IsActive {
+		permissions = role.Permissions
+	}
+
+	s.mu.Lock()
+	s.cache[roleName] = cachedPermissions{
+		permissions: permissions,
+		expiresAt:   time.Now().Add(permissionCacheTTL),
+	}
+	s.mu.Unlock()
+
+	return permissions, nil
+}
+
+// InvalidateRole always drops the local entry, so this replica applies the
+// change at once even when it cannot be published.
+func (s *permissionService) InvalidateRole(roleName string) {
+	s.forget(roleName)
+
+	if err := s.revocationService.InvalidateRole(roleName); err != nil {
+		log.Printf("[PERMISSION] Failed to publish invalidation of role %s: %v", roleName, err)
+	}
+}
+
+func (s *permissionService) forget(roleName string) {
+	s.mu.Lock()
+	delete(s.cache, roleName)
+	s.mu.Unlock()
+}
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/config"
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyDocument is the on-disk format of the forward-auth policy file. See
+// app/config/policies/forward_auth.yaml for an annotated example.
+type PolicyDocument struct {
+	Version int          `json:"version" yaml:"version"`
+	Rules   []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+type PolicyRule struct {
+	Name    string        `json:"name" yaml:"name"`
+	Methods []string      `json:"methods" yaml:"methods"`
+	Paths   []string      `json:"paths" yaml:"paths"`
+	Exclude []string      `json:"exclude" yaml:"exclude"`
+	Public  bool          `json:"public" yaml:"public"`
+	Allow   []PolicyGrant `json:"allow" yaml:"allow"`
+}
+
+type PolicyGrant struct {
+	UserTypes   []string     `json:"user_types" yaml:"user_types"`
+	Permissions []string     `json:"permissions" yaml:"permissions"`
+	Owner       *PolicyOwner `json:"owner" yaml:"owner"`
+}
+
+// PolicyOwner requires the path parameter Param to equal one of the caller's
+// identifiers listed in Claims.
+type PolicyOwner struct {
+	Param  string   `json:"param" yaml:"param"`
+	Claims []string `json:"claims" yaml:"claims"`
+}
+
+type PolicyService interface {
+	// IsPublic reports whether the request may pass without a token.
+	IsPublic(method, uri string) bool
+	// Evaluate returns the decision of the first rule matching the request.
+	// claims may be nil for anonymous requests.
+	Evaluate(method, uri string, claims *output.Claims) output.PolicyDecision
+}
+
+type policyService struct {
+	path    string
+	mutex   sync.RWMutex
+	rules   []compiledRule
+	modTime time.Time
+	size    int64
+}
+
+type compiledRule struct {
+	PolicyRule
+	methods map[string]bool
+	paths   [][]string
+	exclude [][]string
+}
+
+var ownerClaims = map[string]func(*output.Claims) string{
+	"user_id": func(c *output.Claims) string {
+		if c.UserID == 0 {
+			return ""
+		}
+		return strconv.FormatUint(uint64(c.UserID), 10)
+	},
+	"firebase_uid": func(c *output.Claims) string { return c.FirebaseUID },
+	"google_id":    func(c *output.Claims) string { return c.GoogleID },
+	"apple_id":     func(c *output.Claims) string { return c.AppleID },
+}
+
+// NewPolicyService loads the policy from cfg.PolicyFile, or the built-in
+// default when no file is configured. A configured file is polled every
+// cfg.ReloadInterval and swapped in when it changes; a file that fails to
+// load is logged and the previous policy stays active.
+func NewPolicyService(cfg input.ForwardAuthConfig) (PolicyService, error) {
+	s := &policyService{path: cfg.PolicyFile}
+
+	if s.path == "" {
+		rules, err := compilePolicy(config.DefaultForwardAuthPolicy, ".yaml")
+		if err != nil {
+			return nil, fmt.Errorf("default forward-auth policy: %w", err)
+		}
+		s.rules = rules
+		return s, nil
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	if cfg.ReloadInterval > 0 {
+		go s.watch(cfg.ReloadInterval)
+	}
+
+	return s, nil
+}
+
+func (s *policyService) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(s.path)
+		if err != nil {
+			log.Printf("[POLICY] Failed to stat %s: %v", s.path, err)
+			continue
+		}
+
+		s.mutex.RLock()
+		changed := !info.ModTime().Equal(s.modTime) || info.Size() != s.size
+		s.mutex.RUnlock()
+
+		if !changed {
+			continue
+		}
+
+		if err := s.reload(); err != nil {
+			log.Printf("[POLICY] Keeping previous policy, reload failed: %v", err)
+		}
+	}
+}
+
+func (s *policyService) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("forward-auth policy %s: %w", s.path, err)
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("forward-auth policy %s: %w", s.path, err)
+	}
+
+	rules, err := compilePolicy(data, filepath.Ext(s.path))
+	if err != nil {
+		return fmt.Errorf("forward-auth policy %s: %w", s.path, err)
+	}
+
+	s.mutex.Lock()
+	s.rules = rules
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	s.mutex.Unlock()
+
+	log.Printf("[POLICY] Loaded %d forward-auth rules from %s", len(rules), s.path)
+	return nil
+}
+
+func (s *policyService) IsPublic(method, uri string) bool {
+	rule, _ := s.match(method, uri)
+	return rule != nil && rule.Public
+}
+
+func (s *policyService) Evaluate(method, uri string, claims *output.Claims) output.PolicyDecision {
+	rule, params := s.match(method, uri)
+	if rule == nil {
+		return output.PolicyDecision{Reason: "no rule matches the request"}
+	}
+
+	decision := output.PolicyDecision{
+		Rule:   rule.Name,
+		Public: rule.Public,
+		Params: params,
+	}
+
+	if rule.Public {
+		decision.Allowed = true
+		decision.Reason = "public route"
+		return decision
+	}
+
+	if claims == nil {
+		decision.Reason = "authentication required"
+		return decision
+	}
+
+	for i, grant := range rule.Allow {
+		if grantAllows(grant, claims, params) {
+			decision.Allowed = true
+			decision.Reason = fmt.Sprintf("grant %d of rule %q matched", i+1, rule.Name)
+			return decision
+		}
+	}
+
+	decision.Reason = fmt.Sprintf("no grant of rule %q matched", rule.Name)
+	return decision
+}
+
+func (s *policyService) match(method, uri string) (*compiledRule, map[string]string) {
+	method = strings.ToUpper(method)
+	segments := splitPolicyPath(uri)
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for i := range s.rules {
+		rule := &s.rules[i]
+		if len(rule.methods) > 0 && !rule.methods[method] {
+			continue
+		}
+
+		params, ok := rule.matchPath(segments)
+		if !ok {
+			continue
+		}
+
+		return rule, params
+	}
+
+	return nil, nil
+}
+
+func (r *compiledRule) matchPath(segments []string) (map[string]string, bool) {
+	for _, pattern := range r.exclude {
+		if matchPolicySegments(pattern, segments, map[string]string{}) {
+			return nil, false
+		}
+	}
+
+	for _, pattern := range r.paths {
+		params := map[string]string{}
+		if matchPolicySegments(pattern, segments, params) {
+			return params, true
+		}
+	}
+
+	return nil, false
+}
+
+func grantAllows(grant PolicyGrant, claims *output.Claims, params map[string]string) bool {
+	if len(grant.UserTypes) > 0 && !containsString(grant.UserTypes, utils.ActingUserType(claims)) {
+		return false
+	}
+
+	if len(grant.Permissions) > 0 {
+		held := false
+		for _, permission := range grant.Permissions {
+			if allowed, err := utils.HasPermission(claims, permission); err == nil && allowed {
+				held = true
+				break
+			}
+		}
+		if !held {
+			return false
+		}
+	}
+
+	if grant.Owner != nil {
+		value := params[grant.Owner.Param]
+		if value == "" {
+			return false
+		}
+
+		names := grant.Owner.Claims
+		if len(names) == 0 {
+			names = []string{"user_id"}
+		}
+
+		owned := false
+		for _, name := range names {
+			if id := ownerClaims[name](claims); id != "" && id == value {
+				owned = true
+				break
+			}
+		}
+		if !owned {
+			return false
+		}
+	}
+
+	return true
+}
+
+func compilePolicy(data []byte, ext string) ([]compiledRule, error) {
+	var doc PolicyDocument
+
+	if strings.EqualFold(ext, ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, err
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, err
+		}
+	}
+
+	if doc.Version != 1 {
+		return nil, fmt.Errorf("unsupported policy version %d", doc.Version)
+	}
+
+	rules := make([]compiledRule, 0, len(doc.Rules))
+	for i, rule := range doc.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if len(rule.Paths) == 0 {
+			return nil, fmt.Errorf("rule %q: at least one path is required", rule.Name)
+		}
+		if rule.Public && len(rule.Allow) > 0 {
+			return nil, fmt.Errorf("rule %q: public rules cannot list grants", rule.Name)
+		}
+		if !rule.Public && len(rule.Allow) == 0 {
+			return nil, fmt.Errorf("rule %q: must be public or list at least one grant", rule.Name)
+		}
+
+		compiled := compiledRule{PolicyRule: rule, methods: map[string]bool{}}
+		for _, method := range rule.Methods {
+			if method == "*" {
+				compiled.methods = nil
+				break
+			}
+			compiled.methods[strings.ToUpper(method)] = true
+		}
+
+		// params counts, for each parameter name, how many paths capture it.
+		params := map[string]int{}
+		for _, path := range rule.Paths {
+			if !strings.HasPrefix(path, "/") {
+				return nil, fmt.Errorf("rule %q: path %q must start with /", rule.Name, path)
+			}
+			segments := splitPolicyPath(path)
+			for _, segment := range segments {
+				if strings.HasPrefix(segment, ":") {
+					params[segment[1:]]++
+				}
+			}
+			compiled.paths = append(compiled.paths, segments)
+		}
+		for _, path := range rule.Exclude {
+			compiled.exclude = append(compiled.exclude, splitPolicyPath(path))
+		}
+
+		for _, grant := range rule.Allow {
+			if grant.Owner == nil {
+				continue
+			}
+			if params[grant.Owner.Param] != len(rule.Paths) {
+				return nil, fmt.Errorf("rule %q: owner param %q is not captured by every path", rule.Name, grant.Owner.Param)
+			}
+			for _, name := range grant.Owner.Claims {
+				if _, ok := ownerClaims[name]; !ok {
+					return nil, fmt.Errorf("rule %q: unknown owner claim %q", rule.Name, name)
+				}
+			}
+		}
+
+		rules = append(rules, compiled)
+	}
+
+	return rules, nil
+}
+
+// splitPolicyPath splits a request URI or pattern into path segments,
+// dropping any query string. "/" yields no segments.
+func splitPolicyPath(uri string) []string {
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		uri = uri[:i]
+	}
+
+	uri = strings.TrimPrefix(uri, "/")
+	if uri == "" {
+		return nil
+	}
+
+	return strings.Split(uri, "/")
+}
+
+func matchPolicySegments(pattern, path []string, params map[string]string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	segment := pattern[0]
+	if segment == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchPolicySegments(pattern[1:], path[i:], params) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+
+	if strings.HasPrefix(segment, ":") {
+		name := segment[1:]
+		params[name] = path[0]
+		if matchPolicySegments(pattern[1:], path[1:], params) {
+			return true
+		}
+		delete(params, name)
+		return false
+	}
+
+	if segment != "*" && segment != path[0] {
+		return false
+	}
+
+	return matchPolicySegments(pattern[1:], path[1:], params)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+)
+
+func TestMatchPolicySegments(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		path       string
+		want       bool
+		wantParams map[string]string
+	}{
+		{pattern: "/", path: "/", want: true},
+		{pattern: "/", path: "/users", want: false},
+		{pattern: "/users", path: "/users", want: true},
+		{pattern: "/users", path: "/users/1", want: false},
+		{pattern: "/users/*", path: "/users/1", want: true},
+		{pattern: "/users/*", path: "/users", want: false},
+		{pattern: "/users/:id", path: "/users/42?expand=role", want: true, wantParams: map[string]string{"id": "42"}},
+		{pattern: "/users/:id/orders/:order", path: "/users/42/orders/7", want: true, wantParams: map[string]string{"id": "42", "order": "7"}},
+		{pattern: "/users/:id/orders", path: "/users/42/invoices", want: false},
+		{pattern: "/health/**", path: "/health", want: true},
+		{pattern: "/health/**", path: "/health/live/deep", want: true},
+		{pattern: "/customers/:id/**", path: "/customers/abc", want: true, wantParams: map[string]string{"id": "abc"}},
+		{pattern: "/customers/:id/**", path: "/customers/abc/orders/1", want: true, wantParams: map[string]string{"id": "abc"}},
+		{pattern: "/customers/:id/**", path: "/customers", want: false},
+		{pattern: "**/admin/**", path: "/public/products/admin/list", want: true},
+		{pattern: "**/admin/**", path: "/public/products/list", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			params := map[string]string{}
+			got := matchPolicySegments(splitPolicyPath(tt.pattern), splitPolicyPath(tt.path), params)
+			if got != tt.want {
+				t.Fatalf("match = %v, want %v", got, tt.want)
+			}
+
+			want := tt.wantParams
+			if want == nil {
+				want = map[string]string{}
+			}
+			if got && !reflect.DeepEqual(params, want) {
+				t.Errorf("params = %v, want %v", params, want)
+			}
+			if !got && len(params) != 0 {
+				t.Errorf("failed match left params %v", params)
+			}
+		})
+	}
+}
+
+func TestPolicyOwnerGrant(t *testing.T) {
+	rules, err := compilePolicy([]byte(`
+version: 1
+rules:
+  - name: profile
+    paths: ["/profiles/:id/**"]
+    allow:
+      - owner: {param: id}
+  - name: customer
+    paths: ["/customers/:id/**"]
+    allow:
+      - user_types: [mobile_user]
+        owner:
+          param: id
+          claims: [firebase_uid, google_id, apple_id]
+`), ".yaml")
+	if err != nil {
+		t.Fatalf("compilePolicy: %v", err)
+	}
+	svc := &policyService{rules: rules}
+
+	mobile := &output.Claims{UserID: 7, UserType: "mobile_user", FirebaseUID: "fb-7", GoogleID: "g-7"}
+
+	tests := []struct {
+		name   string
+		uri    string
+		claims *output.Claims
+		want   bool
+	}{
+		{name: "user id defaults", uri: "/profiles/7", claims: mobile, want: true},
+		{name: "other user id", uri: "/profiles/8", claims: mobile},
+		{name: "firebase uid", uri: "/customers/fb-7/orders", claims: mobile, want: true},
+		{name: "google id", uri: "/customers/g-7", claims: mobile, want: true},
+		{name: "user id not listed", uri: "/customers/7", claims: mobile},
+		{name: "other firebase uid", uri: "/customers/fb-8", claims: mobile},
+		{name: "unset claim never matches", uri: "/customers/x", claims: &output.Claims{UserID: 7, UserType: "mobile_user"}},
+		{name: "wrong user type", uri: "/customers/fb-7", claims: &output.Claims{UserID: 7, UserType: "partner", FirebaseUID: "fb-7"}},
+		{name: "anonymous", uri: "/customers/fb-7", claims: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := svc.Evaluate("GET", tt.uri, tt.claims)
+			if decision.Allowed != tt.want {
+				t.Errorf("Evaluate(%s) allowed = %v, want %v (%s)", tt.uri, decision.Allowed, tt.want, decision.Reason)
+			}
+		})
+	}
+}
+
+func TestPolicyRejectsUncapturedOwnerParam(t *testing.T) {
+	_, err := compilePolicy([]byte(`
+version: 1
+rules:
+  - name: broken
+    paths: ["/customers/:id", "/customers"]
+    allow:
+      - owner: {param: id}
+`), ".yaml")
+	if err == nil {
+		t.Fatal("compilePolicy accepted an owner param missing from one path")
+	}
+}
+
+func TestDefaultPolicyCustomerSelf(t *testing.T) {
+	policy, err := NewPolicyService(input.ForwardAuthConfig{})
+	if err != nil {
+		t.Fatalf("NewPolicyService: %v", err)
+	}
+
+	claims := &output.Claims{UserID: 7, UserType: "mobile_user", FirebaseUID: "fb-7"}
+	if decision := policy.Evaluate("GET", "/customers/fb-7/addresses", claims); !decision.Allowed || decision.Rule != "customer-self" {
+		t.Errorf("own customer record: %+v", decision)
+	}
+	if decision := policy.Evaluate("GET", "/customers/fb-8/addresses", claims); decision.Allowed {
+		t.Errorf("other customer record allowed: %+v", decision)
+	}
+}
+
+func TestPolicyUserTypesIgnoreClientTokens(t *testing.T) {
+	rules, err := compilePolicy([]byte(`
+version: 1
+rules:
+  - name: admin
+    paths: ["/admin/**"]
+    allow:
+      - user_types: [superadmin]
+`), ".yaml")
+	if err != nil {
+		t.Fatalf("compilePolicy: %v", err)
+	}
+	svc := &policyService{rules: rules}
+
+	tests := []struct {
+		name   string
+		claims *output.Claims
+		want   bool
+	}{
+		{name: "direct login", claims: &output.Claims{UserID: 1, UserType: "superadmin"}, want: true},
+		{name: "oauth client", claims: &output.Claims{UserID: 1, UserType: "superadmin", ClientID: "reports", Scope: "openid"}},
+		{name: "oauth client with admin scope", claims: &output.Claims{UserID: 1, UserType: "superadmin", ClientID: "console", Scope: "openid admin"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if decision := svc.Evaluate("GET", "/admin/users", tt.claims); decision.Allowed != tt.want {
+				t.Errorf("allowed = %v, want %v (%s)", decision.Allowed, tt.want, decision.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+type RateLimitService interface {
+	// LimitLogin counts a password login attempt against the caller's IP,
+	// the submitted email and, when it belongs to an account, the user.
+	LimitLogin(ctx context.Context, email string, userID uint) error
+	// LimitOTPSend counts an OTP email or SMS against the caller's IP, the
+	// recipient and, when it belongs to an account, the user.
+	LimitOTPSend(ctx context.Context, identifier string, userID uint) error
+	// CheckLockout fails while the user is locked out of password logins.
+	CheckLockout(user *models.User) error
+	// RecordLoginFailure counts a wrong password and fails if that locked
+	// the account.
+	RecordLoginFailure(user *models.User) error
+	// RecordLoginSuccess forgets earlier failures and lockouts.
+	RecordLoginSuccess(user *models.User)
+}
+
+type rateLimitService struct {
+	repo     repo.RateLimitRepository
+	userRepo repo.UserRepository
+	config   input.RateLimitConfig
+}
+
+// rateLimitPurgeInterval is how often expired counters are deleted.
+const rateLimitPurgeInterval = time.Hour
+
+func NewRateLimitService(cfg input.RateLimitConfig, rateLimitRepo repo.RateLimitRepository, userRepo repo.UserRepository) RateLimitService {
+	s := &rateLimitService{
+		repo:     rateLimitRepo,
+		userRepo: userRepo,
+		config:   cfg,
+	}
+
+	go s.purge()
+
+	return s
+}
+
+func (s *rateLimitService) purge() {
+	ticker := time.NewTicker(rateLimitPurgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.repo.DeleteExpired(time.Now()); err != nil {
+			log.Printf("[RATE_LIMIT] Failed to delete expired counters: %v", err)
+		}
+	}
+}
+
+func (s *rateLimitService) LimitLogin(ctx context.Context, email string, userID uint) error {
+	return s.limit(ctx, "login", email, userID,
+		utils.RateLimit{Limit: s.config.LoginPerIP, Window: s.config.LoginWindow},
+		utils.RateLimit{Limit: s.config.LoginPerIdentity, Window: s.config.LoginWindow},
+	)
+}
+
+func (s *rateLimitService) LimitOTPSend(ctx context.Context, identifier string, userID uint) error {
+	return s.limit(ctx, "otp", identifier, userID,
+		utils.RateLimit{Limit: s.config.OTPPerIP, Window: s.config.OTPWindow},
+		utils.RateLimit{Limit: s.config.OTPPerIdentity, Window: s.config.OTPWindow},
+	)
+}
+
+// limit counts the attempt under every key before deciding, so that an
+// attempt rejected for one key still counts towards the others. Store
+// errors let the attempt through rather than locking everyone out.
+func (s *rateLimitService) limit(ctx context.Context, action, identifier string, userID uint, perIP, perIdentity utils.RateLimit) error {
+	ip, _ := utils.ClientInfo(ctx)
+
+	checks := map[string]utils.RateLimit{}
+	if ip != "" {
+		checks[action+":ip:"+ip] = perIP
+	}
+	if identifier != "" {
+		checks[action+":id:"+strings.ToLower(strings.TrimSpace(identifier))] = perIdentity
+	}
+	if userID != 0 {
+		checks[fmt.Sprintf("%s:user:%d", action, userID)] = perIdentity
+	}
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for key, limit := range checks {
+		if limit.Limit <= 0 || limit.Window <= 0 {
+			continue
+		}
+
+		windowStart := limit.WindowStart(now)
+		previous, current, err := s.repo.Hit(key, windowStart, limit.Window)
+		if err != nil {
+			log.Printf("[RATE_LIMIT] Failed to count attempt for %s: %v", key, err)
+			continue
+		}
+
+		if allowed, wait := limit.Allow(previous, current, now.Sub(windowStart)); !allowed {
+			log.Printf("[RATE_LIMIT] Limit of %d per %s exceeded for %s", limit.Limit, limit.Window, key)
+			if wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return utils.NewRateLimitError("too many attempts, try again later", retryAfter)
+	}
+
+	return nil
+}
+
+func (s *rateLimitService) CheckLockout(user *models.User) error {
+	now := time.Now()
+	if !user.IsLocked(now) {
+		return nil
+	}
+	return utils.NewRateLimitError("account is temporarily locked after too many failed logins", user.LockedUntil.Sub(now))
+}
+
+func (s *rateLimitService) RecordLoginFailure(user *models.User) error {
+	if s.config.LockoutThreshold <= 0 {
+		return nil
+	}
+
+	lockedUntil, err := s.userRepo.RecordLoginFailure(user.ID, s.config.LockoutThreshold, s.config.LockoutDuration, s.config.LockoutMaxDuration)
+	if err != nil {
+		log.Printf("[RATE_LIMIT] Failed to record login failure for user %d: %v", user.ID, err)
+		return nil
+	}
+
+	user.LockedUntil = lockedUntil
+	if user.IsLocked(time.Now()) {
+		log.Printf("[RATE_LIMIT] User %d locked until %s", user.ID, lockedUntil.Format(time.RFC3339))
+	}
+	return s.CheckLockout(user)
+}
+
+func (s *rateLimitService) RecordLoginSuccess(user *models.User) {
+	if user.FailedLoginAttempts == 0 && user.LockoutCount == 0 && user.LockedUntil == nil {
+		return
+	}
+
+	if err := s.userRepo.ClearLoginFailures(user.ID); err != nil {
+		log.Printf("[RATE_LIMIT] Failed to clear login failures for user %d: %v", user.ID, err)
+		return
+	}
+
+	user.FailedLoginAttempts = 0
+	user.LockoutCount = 0
+	user.LockedUntil = nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+func TestLoginLockoutEscalation(t *testing.T) {
+	const threshold = 3
+	user := &models.User{ID: 7}
+	users := &fakeUserRepo{users: map[uint]*models.User{user.ID: user}}
+	s := &rateLimitService{userRepo: users, config: input.RateLimitConfig{
+		LockoutThreshold:   threshold,
+		LockoutDuration:    time.Minute,
+		LockoutMaxDuration: 5 * time.Minute,
+	}}
+
+	// lockOut fails threshold logins and returns how long the account is
+	// then locked for.
+	lockOut := func(t *testing.T) time.Duration {
+		t.Helper()
+		for i := 1; i < threshold; i++ {
+			if err := s.RecordLoginFailure(user); err != nil {
+				t.Fatalf("failure %d locked the account: %v", i, err)
+			}
+		}
+		var rateLimitErr *utils.RateLimitError
+		if err := s.RecordLoginFailure(user); !errors.As(err, &rateLimitErr) {
+			t.Fatalf("failure %d = %v, want the account locked", threshold, err)
+		}
+		if err := s.CheckLockout(user); statusOf(err) != http.StatusTooManyRequests {
+			t.Errorf("CheckLockout while locked = %v", err)
+		}
+		return rateLimitErr.RetryAfter
+	}
+	// expire lets the current lockout run out.
+	expire := func() {
+		past := time.Now().Add(-time.Second)
+		user.LockedUntil = &past
+	}
+
+	tests := []struct {
+		name     string
+		before   func()
+		wantLock time.Duration
+	}{
+		{name: "first lockout", wantLock: time.Minute},
+		{name: "second lockout doubles", before: expire, wantLock: 2 * time.Minute},
+		{name: "third lockout doubles again", before: expire, wantLock: 4 * time.Minute},
+		{name: "capped at the maximum", before: expire, wantLock: 5 * time.Minute},
+		{name: "still capped", before: expire, wantLock: 5 * time.Minute},
+		{
+			name: "successful login resets the escalation",
+			before: func() {
+				expire()
+				if err := s.CheckLockout(user); err != nil {
+					t.Fatalf("CheckLockout after expiry: %v", err)
+				}
+				s.RecordLoginSuccess(user)
+			},
+			wantLock: time.Minute,
+		},
+	}
+
+	// The cases build on each other.
+	for _, tt := range tests {
+		if tt.before != nil {
+			tt.before()
+		}
+		got := lockOut(t)
+		if got > tt.wantLock || got < tt.wantLock-time.Second {
+			t.Errorf("%s: locked for %v, want %v", tt.name, got, tt.wantLock)
+		}
+	}
+}
+
+func TestLoginLockoutDisabled(t *testing.T) {
+	user := &models.User{ID: 7}
+	s := &rateLimitService{userRepo: &fakeUserRepo{users: map[uint]*models.User{user.ID: user}}}
+
+	for i := 0; i < 100; i++ {
+		if err := s.RecordLoginFailure(user); err != nil {
+			t.Fatalf("failure %d: %v", i+1, err)
+		}
+	}
+	if user.FailedLoginAttempts != 0 {
+		t.Errorf("failures counted with lockout disabled: %d", user.FailedLoginAttempts)
+	}
+}
+
+type loginAttempt struct {
+	ip     string
+	email  string
+	userID uint
+}
+
+func TestLimitLogin(t *testing.T) {
+	withIP := func(ip string) context.Context {
+		return context.WithValue(context.Background(), utils.ClientIPKey, ip)
+	}
+
+	tests := []struct {
+		name string
+		// attempts are made in order; the last one is checked.
+		attempts   []loginAttempt
+		wantStatus int
+	}{
+		{
+			name:       "within limits",
+			attempts:   []loginAttempt{{"203.0.113.7", "a@example.com", 7}, {"203.0.113.7", "a@example.com", 7}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "one IP across many accounts",
+			attempts:   []loginAttempt{{"203.0.113.7", "a@example.com", 0}, {"203.0.113.7", "b@example.com", 0}, {"203.0.113.7", "c@example.com", 0}},
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name: "one account from many IPs",
+			attempts: []loginAttempt{
+				{"203.0.113.1", "a@example.com", 7}, {"203.0.113.2", "a@example.com", 7},
+				{"203.0.113.3", "a@example.com", 7}, {"203.0.113.4", "A@Example.com ", 7},
+			},
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name: "one user under several emails",
+			attempts: []loginAttempt{
+				{"203.0.113.1", "a@example.com", 7}, {"203.0.113.2", "b@example.com", 7},
+				{"203.0.113.3", "c@example.com", 7}, {"203.0.113.4", "d@example.com", 7},
+			},
+			wantStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &rateLimitService{repo: repo.NewMemoryRateLimitRepository(), config: input.RateLimitConfig{
+				LoginPerIP:       2,
+				LoginPerIdentity: 3,
+				LoginWindow:      time.Hour,
+			}}
+
+			var err error
+			for _, attempt := range tt.attempts {
+				err = s.LimitLogin(withIP(attempt.ip), attempt.email, attempt.userID)
+			}
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+
+			var rateLimitErr *utils.RateLimitError
+			if errors.As(err, &rateLimitErr) && (rateLimitErr.RetryAfter <= 0 || rateLimitErr.RetryAfter > 2*time.Hour) {
+				t.Errorf("Retry-After = %v", rateLimitErr.RetryAfter)
+			}
+		})
+	}
+}
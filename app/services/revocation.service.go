@@ -0,0 +1,375 @@
+package services
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+)
+
+type RevocationService interface {
+	// RevokeToken revokes a single access token by its jti.
+	RevokeToken(tokenID string, userID uint, expiresAt time.Time, reason string) error
+	// RevokeSession revokes every access token carrying sessionID as "sid".
+	RevokeSession(sessionID string, userID uint, reason string) error
+	// RevokeUser revokes every access token issued to a user so far.
+	RevokeUser(userID uint, reason string) error
+	// RevokeServiceAccount revokes every token issued to a service account
+	// so far.
+	RevokeServiceAccount(accountID uint, reason string) error
+	// InvalidateRole tells every replica to drop what it cached about a
+	// role's permissions. It revokes no tokens.
+	InvalidateRole(roleName string) error
+	// OnRoleInvalidated registers fn to be called once for each role
+	// invalidation, whichever replica published it.
+	OnRoleInvalidated(fn func(roleName string))
+	IsRevoked(claims *output.Claims) bool
+	Stats() output.RevocationStats
+}
+
+// revocationService answers IsRevoked from an in-memory index that is kept
+// in step with the backend by polling, so a revocation written by one replica
+// reaches the others within one sync interval.
+//
+// Rows can commit out of created_at order, so each sync re-reads the window
+// since the previous one started plus an overlap of two intervals, and the
+// whole index is reloaded every revocationReloadInterval to catch anything
+// that committed later still.
+type revocationService struct {
+	backend string
+	repo    repo.TokenRevocationRepository
+	overlap time.Duration
+
+	mu            sync.RWMutex
+	syncedAt      time.Time
+	tokens        map[string]time.Time
+	sessions      map[string]time.Time
+	users         map[uint]revocationCutoff
+	services      map[uint]revocationCutoff
+	roles         map[uint]time.Time
+	roleListeners []func(roleName string)
+	lastSyncAt    *time.Time
+	lastSyncError string
+
+	rejected atomic.Uint64
+}
+
+// revocationCutoff revokes the tokens of a user or service account issued
+// before cutoff.
+type revocationCutoff struct {
+	cutoff    time.Time
+	expiresAt time.Time
+}
+
+// revocationReloadInterval is how often expired revocations are deleted from
+// the backend and the index is reloaded in full.
+const revocationReloadInterval = time.Hour
+
+// roleInvalidationTTL is how long a role invalidation is kept. Permission
+// caches expire by then, so a replica that missed it has reloaded anyway.
+const roleInvalidationTTL = permissionCacheTTL
+
+func NewRevocationService(cfg input.RevocationConfig, revocationRepo repo.TokenRevocationRepository) RevocationService {
+	s := &revocationService{
+		backend:  cfg.Backend,
+		repo:     revocationRepo,
+		overlap:  2 * cfg.SyncInterval,
+		tokens:   make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+		users:    make(map[uint]revocationCutoff),
+		services: make(map[uint]revocationCutoff),
+		roles:    make(map[uint]time.Time),
+	}
+
+	s.sync(true)
+
+	if cfg.SyncInterval > 0 {
+		go s.run(cfg.SyncInterval)
+	}
+
+	return s
+}
+
+func (s *revocationService) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastReload := time.Now()
+	for range ticker.C {
+		full := time.Since(lastReload) >= revocationReloadInterval
+		if full {
+			lastReload = time.Now()
+			if err := s.repo.DeleteExpired(lastReload); err != nil {
+				log.Printf("[REVOCATION] Failed to purge expired revocations: %v", err)
+			}
+		}
+
+		s.sync(full)
+	}
+}
+
+// sync applies the revocations created since the last successful sync, less
+// the overlap, or every active revocation when full is set. Applying a
+// revocation twice is harmless, so rows read again are not filtered out;
+// role invalidations are announced only the first time they are seen.
+func (s *revocationService) sync(full bool) {
+	now := time.Now()
+
+	var since time.Time
+	if !full {
+		s.mu.RLock()
+		since = s.syncedAt
+		s.mu.RUnlock()
+
+		if !since.IsZero() {
+			since = since.Add(-s.overlap)
+		}
+	}
+
+	revocations, err := s.repo.ListActiveSince(since, now)
+
+	var invalidated []string
+	defer func() { s.announceRoles(invalidated) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastSyncAt = &now
+	if err != nil {
+		s.lastSyncError = err.Error()
+		log.Printf("[REVOCATION] Sync failed: %v", err)
+		return
+	}
+	s.lastSyncError = ""
+	s.syncedAt = now
+
+	for i := range revocations {
+		if roleName := s.apply(&revocations[i]); roleName != "" {
+			invalidated = append(invalidated, roleName)
+		}
+	}
+
+	for tokenID, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, tokenID)
+		}
+	}
+	for sessionID, expiresAt := range s.sessions {
+		if !now.Before(expiresAt) {
+			delete(s.sessions, sessionID)
+		}
+	}
+	for userID, revocation := range s.users {
+		if !now.Before(revocation.expiresAt) {
+			delete(s.users, userID)
+		}
+	}
+	for accountID, revocation := range s.services {
+		if !now.Before(revocation.expiresAt) {
+			delete(s.services, accountID)
+		}
+	}
+	for id, expiresAt := range s.roles {
+		if !now.Before(expiresAt) {
+			delete(s.roles, id)
+		}
+	}
+}
+
+// apply adds a revocation to the index. For a role invalidation seen for the
+// first time it returns the role, which the caller announces once it has
+// released s.mu. The caller must hold s.mu.
+func (s *revocationService) apply(revocation *models.TokenRevocation) string {
+	if revocation.RoleName != "" {
+		if _, seen := s.roles[revocation.ID]; seen {
+			return ""
+		}
+		s.roles[revocation.ID] = revocation.ExpiresAt
+		return revocation.RoleName
+	}
+
+	if revocation.TokenID != "" {
+		s.tokens[revocation.TokenID] = revocation.ExpiresAt
+		return ""
+	}
+
+	if revocation.SessionID != "" {
+		s.sessions[revocation.SessionID] = revocation.ExpiresAt
+		return ""
+	}
+
+	if revocation.ServiceAccountID != 0 {
+		applyCutoff(s.services, revocation.ServiceAccountID, revocation)
+		return ""
+	}
+
+	applyCutoff(s.users, revocation.UserID, revocation)
+	return ""
+}
+
+// applyCutoff keeps the latest cutoff recorded for id.
+func applyCutoff(cutoffs map[uint]revocationCutoff, id uint, revocation *models.TokenRevocation) {
+	if current, ok := cutoffs[id]; !ok || revocation.RevokedAt.After(current.cutoff) {
+		cutoffs[id] = revocationCutoff{
+			cutoff:    revocation.RevokedAt,
+			expiresAt: revocation.ExpiresAt,
+		}
+	}
+}
+
+// announceRoles calls the role listeners for each invalidated role.
+func (s *revocationService) announceRoles(roleNames []string) {
+	if len(roleNames) == 0 {
+		return
+	}
+
+	s.mu.RLock()
+	listeners := slices.Clone(s.roleListeners)
+	s.mu.RUnlock()
+
+	for _, roleName := range roleNames {
+		for _, listener := range listeners {
+			listener(roleName)
+		}
+	}
+}
+
+func (s *revocationService) record(revocation *models.TokenRevocation) error {
+	if err := s.repo.Create(revocation); err != nil {
+		return err
+	}
+
+	// Apply locally right away rather than waiting for the next sync to read
+	// the row back.
+	s.mu.Lock()
+	roleName := s.apply(revocation)
+	s.mu.Unlock()
+
+	if roleName != "" {
+		s.announceRoles([]string{roleName})
+	}
+	return nil
+}
+
+func (s *revocationService) RevokeToken(tokenID string, userID uint, expiresAt time.Time, reason string) error {
+	if tokenID == "" || !time.Now().Before(expiresAt) {
+		return nil
+	}
+
+	return s.record(&models.TokenRevocation{
+		TokenID:   tokenID,
+		UserID:    userID,
+		Reason:    reason,
+		RevokedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (s *revocationService) RevokeSession(sessionID string, userID uint, reason string) error {
+	if sessionID == "" {
+		return nil
+	}
+
+	now := time.Now()
+	return s.record(&models.TokenRevocation{
+		SessionID: sessionID,
+		UserID:    userID,
+		Reason:    reason,
+		RevokedAt: now,
+		ExpiresAt: now.Add(utils.AccessTokenTTL),
+	})
+}
+
+func (s *revocationService) InvalidateRole(roleName string) error {
+	now := time.Now()
+	return s.record(&models.TokenRevocation{
+		RoleName:  roleName,
+		Reason:    "role_changed",
+		RevokedAt: now,
+		ExpiresAt: now.Add(roleInvalidationTTL),
+	})
+}
+
+func (s *revocationService) OnRoleInvalidated(fn func(roleName string)) {
+	s.mu.Lock()
+	s.roleListeners = append(s.roleListeners, fn)
+	s.mu.Unlock()
+}
+
+func (s *revocationService) RevokeUser(userID uint, reason string) error {
+	now := time.Now()
+	return s.record(&models.TokenRevocation{
+		UserID:    userID,
+		Reason:    reason,
+		RevokedAt: now,
+		ExpiresAt: now.Add(utils.AccessTokenTTL),
+	})
+}
+
+func (s *revocationService) RevokeServiceAccount(accountID uint, reason string) error {
+	now := time.Now()
+	return s.record(&models.TokenRevocation{
+		ServiceAccountID: accountID,
+		Reason:           reason,
+		RevokedAt:        now,
+		ExpiresAt:        now.Add(serviceTokenTTL),
+	})
+}
+
+// IsRevoked checks the token's jti and sid and whether it was issued before
+// the tokens of its user or service account were last revoked. iat has
+// one-second resolution, so a token issued within the same second as the
+// revocation counts as issued before it and is revoked too. A client that
+// signed in again in that second recovers by refreshing a moment later.
+func (s *revocationService) IsRevoked(claims *output.Claims) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revoked := false
+	if claims.TokenID != "" {
+		_, revoked = s.tokens[claims.TokenID]
+	}
+
+	if !revoked && claims.SessionID != "" {
+		_, revoked = s.sessions[claims.SessionID]
+	}
+
+	cutoffs, id := s.users, claims.UserID
+	if claims.UserType == string(models.UserTypeService) {
+		cutoffs, id = s.services, claims.ServiceAccountID
+	}
+	if !revoked {
+		if revocation, ok := cutoffs[id]; ok {
+			revoked = claims.IssuedAt <= revocation.cutoff.Unix()
+		}
+	}
+
+	if revoked {
+		s.rejected.Add(1)
+	}
+
+	return revoked
+}
+
+func (s *revocationService) Stats() output.RevocationStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return output.RevocationStats{
+		Backend:                s.backend,
+		RevokedTokens:          len(s.tokens),
+		RevokedSessions:        len(s.sessions),
+		RevokedUsers:           len(s.users),
+		RevokedServiceAccounts: len(s.services),
+		RejectedTokens:         s.rejected.Load(),
+		LastSyncAt:             s.lastSyncAt,
+		LastSyncError:          s.lastSyncError,
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+)
+
+func newTestRevocationService(backend repo.TokenRevocationRepository) *revocationService {
+	return NewRevocationService(input.RevocationConfig{Backend: "memory"}, backend).(*revocationService)
+}
+
+func TestRoleInvalidationReachesOtherReplicas(t *testing.T) {
+	backend := repo.NewMemoryTokenRevocationRepository()
+	roles := &fakeRoleRepo{roles: map[string]*models.Role{
+		"editor": {RoleName: "editor", IsActive: true, Permissions: models.StringArray{"items:read"}},
+	}}
+
+	revocationA := newTestRevocationService(backend)
+	revocationB := newTestRevocationService(backend)
+	replicaA := NewPermissionService(roles, revocationA)
+	replicaB := NewPermissionService(roles, revocationB)
+
+	for _, replica := range []PermissionService{replicaA, replicaB} {
+		if _, err := replica.RolePermissions("editor"); err != nil {
+			t.Fatalf("RolePermissions: %v", err)
+		}
+	}
+
+	roles.roles["editor"].Permissions = models.StringArray{"items:read", "items:write"}
+	replicaA.InvalidateRole("editor")
+
+	want := []string{"items:read", "items:write"}
+	if got, _ := replicaA.RolePermissions("editor"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replica A permissions = %v, want %v", got, want)
+	}
+
+	revocationB.sync(false)
+	if got, _ := replicaB.RolePermissions("editor"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replica B permissions after sync = %v, want %v", got, want)
+	}
+}
+
+func TestRoleInvalidationAnnouncedOnce(t *testing.T) {
+	backend := repo.NewMemoryTokenRevocationRepository()
+	publisher := newTestRevocationService(backend)
+	subscriber := newTestRevocationService(backend)
+
+	var published, received []string
+	publisher.OnRoleInvalidated(func(roleName string) { published = append(published, roleName) })
+	subscriber.OnRoleInvalidated(func(roleName string) { received = append(received, roleName) })
+
+	if err := publisher.InvalidateRole("editor"); err != nil {
+		t.Fatalf("InvalidateRole: %v", err)
+	}
+
+	// Full reloads and overlapping syncs read the row again.
+	publisher.sync(true)
+	subscriber.sync(false)
+	subscriber.sync(true)
+	subscriber.sync(false)
+
+	want := []string{"editor"}
+	if !reflect.DeepEqual(published, want) {
+		t.Errorf("publisher announced %v, want %v", published, want)
+	}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("subscriber announced %v, want %v", received, want)
+	}
+}
+
+func TestRoleInvalidationRevokesNoToken(t *testing.T) {
+	s := newTestRevocationService(repo.NewMemoryTokenRevocationRepository())
+
+	if err := s.InvalidateRole("editor"); err != nil {
+		t.Fatalf("InvalidateRole: %v", err)
+	}
+
+	claims := &output.Claims{UserType: "admin", Role: "editor", IssuedAt: time.Now().Add(-time.Minute).Unix()}
+	if s.IsRevoked(claims) {
+		t.Error("a role invalidation revoked a token")
+	}
+	if stats := s.Stats(); stats.RevokedUsers != 0 {
+		t.Errorf("RevokedUsers = %d, want 0", stats.RevokedUsers)
+	}
+}
+
+func TestServiceAccountRevocationCutoff(t *testing.T) {
+	s := newTestRevocationService(repo.NewMemoryTokenRevocationRepository())
+	if err := s.RevokeServiceAccount(5, "secret_rotated"); err != nil {
+		t.Fatalf("RevokeServiceAccount: %v", err)
+	}
+
+	before := time.Now().Add(-time.Minute).Unix()
+	after := time.Now().Add(time.Minute).Unix()
+
+	tests := []struct {
+		name   string
+		claims output.Claims
+		want   bool
+	}{
+		{name: "account token issued before", claims: output.Claims{UserType: "service", ServiceAccountID: 5, IssuedAt: before}, want: true},
+		{name: "account token issued after", claims: output.Claims{UserType: "service", ServiceAccountID: 5, IssuedAt: after}},
+		{name: "other account", claims: output.Claims{UserType: "service", ServiceAccountID: 6, IssuedAt: before}},
+		{name: "user with the same ID", claims: output.Claims{UserType: "admin", UserID: 5, IssuedAt: before}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsRevoked(&tt.claims); got != tt.want {
+				t.Errorf("IsRevoked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRevokedCutoffs(t *testing.T) {
+	s := newTestRevocationService(repo.NewMemoryTokenRevocationRepository())
+
+	cutoff := time.Now().Add(-time.Minute)
+	s.users[7] = revocationCutoff{cutoff: cutoff, expiresAt: time.Now().Add(time.Hour)}
+	s.tokens["revoked-jti"] = time.Now().Add(time.Hour)
+	s.sessions["revoked-sid"] = time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		claims output.Claims
+		want   bool
+	}{
+		{name: "issued a second before the cutoff", claims: output.Claims{UserID: 7, IssuedAt: cutoff.Unix() - 1}, want: true},
+		{name: "issued in the cutoff's second", claims: output.Claims{UserID: 7, IssuedAt: cutoff.Unix()}, want: true},
+		{name: "issued a second after the cutoff", claims: output.Claims{UserID: 7, IssuedAt: cutoff.Unix() + 1}},
+		{name: "other user", claims: output.Claims{UserID: 8, IssuedAt: cutoff.Unix() - 1}},
+		{name: "revoked jti", claims: output.Claims{UserID: 8, TokenID: "revoked-jti", IssuedAt: cutoff.Unix() + 1}, want: true},
+		{name: "revoked session", claims: output.Claims{UserID: 8, SessionID: "revoked-sid", IssuedAt: cutoff.Unix() + 1}, want: true},
+		{name: "service token with the user's ID", claims: output.Claims{UserType: "service", ServiceAccountID: 7, IssuedAt: cutoff.Unix() - 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsRevoked(&tt.claims); got != tt.want {
+				t.Errorf("IsRevoked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeUserRevokesTokenIssuedInTheSameSecond(t *testing.T) {
+	s := newTestRevocationService(repo.NewMemoryTokenRevocationRepository())
+
+	issued := &output.Claims{UserID: 7, IssuedAt: time.Now().Unix()}
+	if err := s.RevokeUser(7, "logout_all"); err != nil {
+		t.Fatalf("RevokeUser: %v", err)
+	}
+
+	if !s.IsRevoked(issued) {
+		t.Error("a token issued in the second of the revocation survived it")
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"regexp"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+type RoleService interface {
+	CreateRole(ctx context.Context, req *input.CreateRoleRequest) (*output.RoleResponse, error)
+	GetRoles(ctx context.Context) ([]output.RoleResponse, error)
+	GetRole(ctx context.Context, id uint) (*output.RoleResponse, error)
+	UpdateRole(ctx context.Context, id uint, req *input.UpdateRoleRequest) (*output.RoleResponse, error)
+	DeleteRole(ctx context.Context, id uint) error
+	GetRoleUsers(ctx context.Context, id uint, page, limit int) (*output.PaginatedResponse, error)
+}
+
+type roleService struct {
+	roleRepo          repo.RoleRepository
+	permissionService PermissionService
+}
+
+var permissionPattern = regexp.MustCompile(`^(\*|[a-z0-9-]+:(\*|[a-z0-9-]+))$`)
+
+func NewRoleService(roleRepo repo.RoleRepository, permissionService PermissionService) RoleService {
+	return &roleService{
+		roleRepo:          roleRepo,
+		permissionService: permissionService,
+	}
+}
+
+func (s *roleService) CreateRole(ctx context.Context, req *input.CreateRoleRequest) (*output.RoleResponse, error) {
+	if err := validatePermissions(req.Permissions); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.roleRepo.GetByName(req.RoleName); err == nil {
+		return nil, utils.NewHTTPError(http.StatusConflict, "role already exists")
+	}
+
+	role := &models.Role{
+		RoleName:    req.RoleName,
+		Description: req.Description,
+		Permissions: models.StringArray(req.Permissions),
+		IsActive:    true,
+	}
+	if err := s.roleRepo.Create(role); err != nil {
+		return nil, err
+	}
+
+	s.permissionService.InvalidateRole(role.RoleName)
+
+	resp := newRoleResponse(role, 0)
+	return &resp, nil
+}
+
+func (s *roleService) GetRoles(ctx context.Context) ([]output.RoleResponse, error) {
+	roles, err := s.roleRepo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]output.RoleResponse, 0, len(roles))
+	for i := range roles {
+		count, err := s.roleRepo.CountUsers(roles[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, newRoleResponse(&roles[i], count))
+	}
+	return resp, nil
+}
+
+func (s *roleService) GetRole(ctx context.Context, id uint) (*output.RoleResponse, error) {
+	role, err := s.getRole(id)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.roleRepo.CountUsers(role.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := newRoleResponse(role, count)
+	return &resp, nil
+}
+
+// UpdateRole edits a role's description, permissions or active flag. Role
+// names are immutable because issued tokens refer to roles by name.
+func (s *roleService) UpdateRole(ctx context.Context, id uint, req *input.UpdateRoleRequest) (*output.RoleResponse, error) {
+	role, err := s.getRole(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		if err := validatePermissions(req.Permissions); err != nil {
+			return nil, err
+		}
+		role.Permissions = models.StringArray(req.Permissions)
+	}
+	if req.IsActive != nil {
+		if !*req.IsActive && role.RoleName == string(models.UserTypeSuperAdmin) {
+			return nil, utils.NewBadRequestError("the superadmin role cannot be deactivated")
+		}
+		role.IsActive = *req.IsActive
+	}
+
+	if err := s.roleRepo.Update(role); err != nil {
+		return nil, err
+	}
+
+	s.permissionService.InvalidateRole(role.RoleName)
+
+	count, err := s.roleRepo.CountUsers(role.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := newRoleResponse(role, count)
+	return &resp, nil
+}
+
+// DeleteRole removes a custom role. Built-in roles and roles that still have
+// users cannot be deleted.
+func (s *roleService) DeleteRole(ctx context.Context, id uint) error {
+	role, err := s.getRole(id)
+	if err != nil {
+		return err
+	}
+
+	if _, builtIn := models.DefaultRolePermissions[role.RoleName]; builtIn {
+		return utils.NewBadRequestError("built-in roles cannot be deleted")
+	}
+
+	count, err := s.roleRepo.CountUsers(role.ID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return utils.NewHTTPError(http.StatusConflict, "role still has users assigned")
+	}
+
+	if err := s.roleRepo.Delete(role.ID); err != nil {
+		return err
+	}
+
+	s.permissionService.InvalidateRole(role.RoleName)
+
+	return nil
+}
+
+func (s *roleService) GetRoleUsers(ctx context.Context, id uint, page, limit int) (*output.PaginatedResponse, error) {
+	if _, err := s.getRole(id); err != nil {
+		return nil, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	users, total, err := s.roleRepo.ListUsers(id, (page-1)*limit, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	userList := make([]output.UserListResponse, len(users))
+	for i, user := range users {
+		userList[i] = output.UserListResponse{
+			ID:          user.ID,
+			Email:       user.Email,
+			Username:    user.Username,
+			Phone:       user.Phone,
+			UserType:    string(user.UserType),
+			Role:        user.Role.RoleName,
+			Status:      string(user.Status),
+			CreatedAt:   user.CreatedAt,
+			CreatedBy:   user.CreatedBy,
+			LastLoginAt: user.LastLoginAt,
+		}
+	}
+
+	return &output.PaginatedResponse{
+		Success: true,
+		Data:    userList,
+		Meta: output.PaginationMeta{
+			CurrentPage: page,
+			PerPage:     limit,
+			Total:       int(total),
+			TotalPages:  int(math.Ceil(float64(total) / float64(limit))),
+		},
+	}, nil
+}
+
+func (s *roleService) getRole(id uint) (*models.Role, error) {
+	role, err := s.roleRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewNotFoundError("role not found")
+		}
+		return nil, err
+	}
+	return role, nil
+}
+
+func validatePermissions(permissions []string) error {
+	for _, permission := range permissions {
+		if !permissionPattern.MatchString(permission) {
+			return utils.NewBadRequestError("invalid permission: " + permission)
+		}
+	}
+	return nil
+}
+
+func newRoleResponse(role *models.Role, userCount int64) output.RoleResponse {
+	permissions := []string(role.Permissions)
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return output.RoleResponse{
+		ID:          role.ID,
+		RoleName:    role.RoleName,
+		Description: role.Description,
+		Permissions: permissions,
+		IsActive:    role.IsActive,
+		UserCount:   userCount,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
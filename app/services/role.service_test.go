@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+
+	"gorm.io/gorm"
+)
+
+// fakeRoleRepo keeps roles by name. userCounts holds the number of users
+// assigned to each role ID.
+type fakeRoleRepo struct {
+	repo.RoleRepository
+	roles      map[string]*models.Role
+	userCounts map[uint]int64
+	nextID     uint
+}
+
+func (r *fakeRoleRepo) GetByName(name string) (*models.Role, error) {
+	role, ok := r.roles[name]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *role
+	return &copied, nil
+}
+
+func (r *fakeRoleRepo) GetByID(id uint) (*models.Role, error) {
+	for _, role := range r.roles {
+		if role.ID == id {
+			copied := *role
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeRoleRepo) CountUsers(roleID uint) (int64, error) {
+	return r.userCounts[roleID], nil
+}
+
+func (r *fakeRoleRepo) Create(role *models.Role) error {
+	r.nextID++
+	role.ID = r.nextID
+	return r.Update(role)
+}
+
+func (r *fakeRoleRepo) Update(role *models.Role) error {
+	copied := *role
+	r.roles[role.RoleName] = &copied
+	return nil
+}
+
+func (r *fakeRoleRepo) Delete(id uint) error {
+	for name, role := range r.roles {
+		if role.ID == id {
+			delete(r.roles, name)
+		}
+	}
+	return nil
+}
+
+func TestRoleChanges(t *testing.T) {
+	active := true
+	inactive := false
+
+	tests := []struct {
+		name   string
+		change func(s RoleService) error
+		// role is the role whose permissions are resolved after the
+		// change, "accountant" unless set.
+		role            string
+		wantStatus      int
+		wantPermissions []string
+	}{
+		{
+			name: "create",
+			change: func(s RoleService) error {
+				_, err := s.CreateRole(context.Background(), &input.CreateRoleRequest{RoleName: "auditor", Permissions: []string{"invoices:read"}})
+				return err
+			},
+			role:            "auditor",
+			wantStatus:      http.StatusOK,
+			wantPermissions: []string{"invoices:read"},
+		},
+		{
+			name: "create existing role",
+			change: func(s RoleService) error {
+				_, err := s.CreateRole(context.Background(), &input.CreateRoleRequest{RoleName: "accountant"})
+				return err
+			},
+			wantStatus:      http.StatusConflict,
+			wantPermissions: []string{"invoices:read"},
+		},
+		{
+			name: "update permissions",
+			change: func(s RoleService) error {
+				_, err := s.UpdateRole(context.Background(), 10, &input.UpdateRoleRequest{Permissions: []string{"invoices:*", "payments:read"}})
+				return err
+			},
+			wantStatus:      http.StatusOK,
+			wantPermissions: []string{"invoices:*", "payments:read"},
+		},
+		{
+			name: "update with an invalid permission",
+			change: func(s RoleService) error {
+				_, err := s.UpdateRole(context.Background(), 10, &input.UpdateRoleRequest{Permissions: []string{"invoices:read", "Invoices:Write"}})
+				return err
+			},
+			wantStatus:      http.StatusBadRequest,
+			wantPermissions: []string{"invoices:read"},
+		},
+		{
+			name: "deactivate",
+			change: func(s RoleService) error {
+				_, err := s.UpdateRole(context.Background(), 10, &input.UpdateRoleRequest{IsActive: &inactive})
+				return err
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "deactivate superadmin",
+			change: func(s RoleService) error {
+				_, err := s.UpdateRole(context.Background(), 1, &input.UpdateRoleRequest{IsActive: &inactive})
+				return err
+			},
+			wantStatus:      http.StatusBadRequest,
+			wantPermissions: []string{"invoices:read"},
+		},
+		{
+			name: "reactivate superadmin",
+			change: func(s RoleService) error {
+				_, err := s.UpdateRole(context.Background(), 1, &input.UpdateRoleRequest{IsActive: &active})
+				return err
+			},
+			wantStatus:      http.StatusOK,
+			wantPermissions: []string{"invoices:read"},
+		},
+		{
+			name:       "delete",
+			change:     func(s RoleService) error { return s.DeleteRole(context.Background(), 10) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:            "delete built-in role",
+			change:          func(s RoleService) error { return s.DeleteRole(context.Background(), 1) },
+			wantStatus:      http.StatusBadRequest,
+			wantPermissions: []string{"invoices:read"},
+		},
+		{
+			name:            "delete role with users",
+			change:          func(s RoleService) error { return s.DeleteRole(context.Background(), 11) },
+			wantStatus:      http.StatusConflict,
+			wantPermissions: []string{"invoices:read"},
+		},
+		{
+			name:            "delete missing role",
+			change:          func(s RoleService) error { return s.DeleteRole(context.Background(), 99) },
+			wantStatus:      http.StatusNotFound,
+			wantPermissions: []string{"invoices:read"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles := &fakeRoleRepo{
+				roles: map[string]*models.Role{
+					"superadmin": {ID: 1, RoleName: "superadmin", IsActive: true, Permissions: models.StringArray{"*"}},
+					"accountant": {ID: 10, RoleName: "accountant", IsActive: true, Permissions: models.StringArray{"invoices:read"}},
+					"clerk":      {ID: 11, RoleName: "clerk", IsActive: true},
+				},
+				userCounts: map[uint]int64{11: 2},
+				nextID:     11,
+			}
+			permissions := NewPermissionService(roles, newTestRevocationService(repo.NewMemoryTokenRevocationRepository()))
+			s := NewRoleService(roles, permissions)
+
+			// Warm the cache so a change that is not invalidated shows.
+			if _, err := permissions.RolePermissions("accountant"); err != nil {
+				t.Fatalf("RolePermissions: %v", err)
+			}
+
+			if got := statusOf(tt.change(s)); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", got, tt.wantStatus)
+			}
+
+			role := tt.role
+			if role == "" {
+				role = "accountant"
+			}
+			got, _ := permissions.RolePermissions(role)
+			if len(got) != len(tt.wantPermissions) || (len(got) > 0 && !reflect.DeepEqual(got, tt.wantPermissions)) {
+				t.Errorf("%s permissions = %v, want %v", role, got, tt.wantPermissions)
+			}
+		})
+	}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	tests := []struct {
+		permission string
+		valid      bool
+	}{
+		{permission: "*", valid: true},
+		{permission: "invoices:read", valid: true},
+		{permission: "invoices:*", valid: true},
+		{permission: "sales-orders:write", valid: true},
+		{permission: "invoices"},
+		{permission: "*:read"},
+		{permission: "Invoices:read"},
+		{permission: "invoices:read:own"},
+		{permission: " invoices:read"},
+		{permission: ""},
+	}
+
+	for _, tt := range tests {
+		err := validatePermissions([]string{tt.permission})
+		if (err == nil) != tt.valid {
+			t.Errorf("validatePermissions(%q) = %v, want valid %v", tt.permission, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+type ServiceAccountService interface {
+	CreateServiceAccount(ctx context.Context, createdBy uint, req *input.CreateServiceAccountRequest) (*output.ServiceAccountResponse, error)
+	GetServiceAccounts(ctx context.Context) ([]output.ServiceAccountResponse, error)
+	// RotateSecret, DeactivateServiceAccount and DeleteServiceAccount all
+	// revoke the tokens already issued to the account.
+	RotateSecret(ctx context.Context, id uint) (*output.ServiceAccountResponse, error)
+	DeactivateServiceAccount(ctx context.Context, id uint) error
+	DeleteServiceAccount(ctx context.Context, id uint) error
+	ClientCredentials(ctx context.Context, clientID, clientSecret, scope string) (*output.OAuthTokenResponse, error)
+	// Authenticate checks the client credentials of an active service
+	// account.
+	Authenticate(ctx context.Context, clientID, clientSecret string) (*models.ServiceAccount, error)
+}
+
+type serviceAccountService struct {
+	serviceAccountRepo repo.ServiceAccountRepository
+	revocationService  RevocationService
+}
+
+// serviceTokenTTL is kept short because service tokens carry no refresh
+// token; callers simply request a new one.
+const serviceTokenTTL = 15 * time.Minute
+
+func NewServiceAccountService(serviceAccountRepo repo.ServiceAccountRepository, revocationService RevocationService) ServiceAccountService {
+	return &serviceAccountService{
+		serviceAccountRepo: serviceAccountRepo,
+		revocationService:  revocationService,
+	}
+}
+
+func (s *serviceAccountService) CreateServiceAccount(ctx context.Context, createdBy uint, req *input.CreateServiceAccountRequest) (*output.ServiceAccountResponse, error) {
+	clientID, err := utils.GenerateRandomString(24)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &models.ServiceAccount{
+		ClientID:         "svc_" + clientID,
+		ClientSecretHash: utils.HashToken(secret),
+		Name:             req.Name,
+		Description:      req.Description,
+		AllowedScopes:    models.StringArray(req.AllowedScopes),
+		IsActive:         true,
+		CreatedBy:        &createdBy,
+	}
+	if err := s.serviceAccountRepo.Create(account); err != nil {
+		return nil, err
+	}
+
+	resp := newServiceAccountResponse(account)
+	resp.ClientSecret = secret
+	return &resp, nil
+}
+
+func (s *serviceAccountService) GetServiceAccounts(ctx context.Context) ([]output.ServiceAccountResponse, error) {
+	accounts, err := s.serviceAccountRepo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]output.ServiceAccountResponse, 0, len(accounts))
+	for i := range accounts {
+		resp = append(resp, newServiceAccountResponse(&accounts[i]))
+	}
+	return resp, nil
+}
+
+// RotateSecret replaces the client secret. The old secret and every token
+// issued with it stop working immediately.
+func (s *serviceAccountService) RotateSecret(ctx context.Context, id uint) (*output.ServiceAccountResponse, error) {
+	account, err := s.getServiceAccount(id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateRandomString(48)
+	if err != nil {
+		return nil, err
+	}
+
+	account.ClientSecretHash = utils.HashToken(secret)
+	if err := s.serviceAccountRepo.Update(account); err != nil {
+		return nil, err
+	}
+	if err := s.revocationService.RevokeServiceAccount(account.ID, "secret_rotated"); err != nil {
+		return nil, err
+	}
+
+	resp := newServiceAccountResponse(account)
+	resp.ClientSecret = secret
+	return &resp, nil
+}
+
+func (s *serviceAccountService) DeactivateServiceAccount(ctx context.Context, id uint) error {
+	account, err := s.getServiceAccount(id)
+	if err != nil {
+		return err
+	}
+
+	account.IsActive = false
+	if err := s.serviceAccountRepo.Update(account); err != nil {
+		return err
+	}
+	return s.revocationService.RevokeServiceAccount(account.ID, "deactivated")
+}
+
+func (s *serviceAccountService) DeleteServiceAccount(ctx context.Context, id uint) error {
+	if _, err := s.getServiceAccount(id); err != nil {
+		return err
+	}
+
+	if err := s.serviceAccountRepo.Delete(id); err != nil {
+		return err
+	}
+	return s.revocationService.RevokeServiceAccount(id, "deleted")
+}
+
+func (s *serviceAccountService) getServiceAccount(id uint) (*models.ServiceAccount, error) {
+	account, err := s.serviceAccountRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewNotFoundError("service account not found")
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+// ClientCredentials implements the client_credentials grant. The requested
+// scope must be a subset of the account's allowed scopes and defaults to all
+// of them.
+func (s *serviceAccountService) ClientCredentials(ctx context.Context, clientID, clientSecret, scope string) (*output.OAuthTokenResponse, error) {
+	account, err := s.Authenticate(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := account.AllowedScopes
+	if strings.TrimSpace(scope) != "" {
+		granted = strings.Fields(scope)
+		for _, requested := range granted {
+			if !account.AllowsScope(requested) {
+				return nil, utils.NewOAuthError(http.StatusBadRequest, "invalid_scope", "scope "+requested+" is not allowed for this client")
+			}
+		}
+	}
+	grantedScope := strings.Join(granted, " ")
+
+	accessToken, err := utils.GenerateServiceToken(account.ID, account.ClientID, grantedScope, serviceTokenTTL)
+	if err != nil {
+		return nil, utils.NewOAuthError(http.StatusInternalServerError, "server_error", "failed to issue token")
+	}
+
+	if err := s.serviceAccountRepo.UpdateLastUsed(account.ID); err != nil {
+		log.Printf("ClientCredentials: failed to record last use of service account %d: %v", account.ID, err)
+	}
+	log.Printf("Issued service token to %s (service account %d) with scope %q", account.ClientID, account.ID, grantedScope)
+
+	return &output.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(serviceTokenTTL.Seconds()),
+		Scope:       grantedScope,
+	}, nil
+}
+
+func (s *serviceAccountService) Authenticate(ctx context.Context, clientID, clientSecret string) (*models.ServiceAccount, error) {
+	invalidClient := utils.NewOAuthError(http.StatusUnauthorized, "invalid_client", "client authentication failed")
+
+	if clientID == "" || clientSecret == "" {
+		return nil, invalidClient
+	}
+
+	account, err := s.serviceAccountRepo.GetByClientID(clientID)
+	if err != nil || !account.IsActive || !utils.CompareTokenHash(clientSecret, account.ClientSecretHash) {
+		return nil, invalidClient
+	}
+
+	return account, nil
+}
+
+func newServiceAccountResponse(account *models.ServiceAccount) output.ServiceAccountResponse {
+	return output.ServiceAccountResponse{
+		ID:            account.ID,
+		ClientID:      account.ClientID,
+		Name:          account.Name,
+		Description:   account.Description,
+		AllowedScopes: account.AllowedScopes,
+		IsActive:      account.IsActive,
+		LastUsedAt:    account.LastUsedAt,
+		CreatedAt:     account.CreatedAt,
+	}
+}
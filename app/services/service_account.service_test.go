@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+type fakeServiceAccountRepo struct {
+	repo.ServiceAccountRepository
+	accounts map[uint]*models.ServiceAccount
+}
+
+func (r *fakeServiceAccountRepo) GetByID(id uint) (*models.ServiceAccount, error) {
+	account, ok := r.accounts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return account, nil
+}
+
+func (r *fakeServiceAccountRepo) GetByClientID(clientID string) (*models.ServiceAccount, error) {
+	for _, account := range r.accounts {
+		if account.ClientID == clientID {
+			return account, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeServiceAccountRepo) UpdateLastUsed(id uint) error {
+	now := time.Now()
+	r.accounts[id].LastUsedAt = &now
+	return nil
+}
+
+func (r *fakeServiceAccountRepo) Update(account *models.ServiceAccount) error {
+	r.accounts[account.ID] = account
+	return nil
+}
+
+func (r *fakeServiceAccountRepo) Delete(id uint) error {
+	delete(r.accounts, id)
+	return nil
+}
+
+func TestServiceAccountChangesRevokeIssuedTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s ServiceAccountService, id uint) error
+	}{
+		{name: "rotate secret", change: func(s ServiceAccountService, id uint) error {
+			_, err := s.RotateSecret(context.Background(), id)
+			return err
+		}},
+		{name: "deactivate", change: func(s ServiceAccountService, id uint) error {
+			return s.DeactivateServiceAccount(context.Background(), id)
+		}},
+		{name: "delete", change: func(s ServiceAccountService, id uint) error {
+			return s.DeleteServiceAccount(context.Background(), id)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts := &fakeServiceAccountRepo{accounts: map[uint]*models.ServiceAccount{
+				5: {ID: 5, ClientID: "svc_reports", IsActive: true},
+			}}
+			revocation := newTestRevocationService(repo.NewMemoryTokenRevocationRepository())
+			s := NewServiceAccountService(accounts, revocation)
+
+			issued := &output.Claims{UserType: "service", ServiceAccountID: 5, IssuedAt: time.Now().Add(-time.Minute).Unix()}
+			if revocation.IsRevoked(issued) {
+				t.Fatal("token revoked before the change")
+			}
+
+			if err := tt.change(s, 5); err != nil {
+				t.Fatalf("change: %v", err)
+			}
+
+			if !revocation.IsRevoked(issued) {
+				t.Error("token issued before the change is still valid")
+			}
+		})
+	}
+}
+
+func TestClientCredentialsScope(t *testing.T) {
+	const secret = "s3cret-value"
+
+	tests := []struct {
+		name       string
+		scope      string
+		secret     string
+		inactive   bool
+		wantStatus int
+		wantScope  string
+	}{
+		{name: "default to allowed scopes", secret: secret, wantStatus: http.StatusOK, wantScope: "reports:read reports:write"},
+		{name: "subset", scope: "reports:read", secret: secret, wantStatus: http.StatusOK, wantScope: "reports:read"},
+		{name: "scope outside the allowed set", scope: "reports:read users:write", secret: secret, wantStatus: http.StatusBadRequest},
+		{name: "wrong secret", secret: "wrong", wantStatus: http.StatusUnauthorized},
+		{name: "inactive account", secret: secret, inactive: true, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts := &fakeServiceAccountRepo{accounts: map[uint]*models.ServiceAccount{
+				5: {
+					ID:               5,
+					ClientID:         "svc_reports",
+					ClientSecretHash: utils.HashToken(secret),
+					AllowedScopes:    models.StringArray{"reports:read", "reports:write"},
+					IsActive:         !tt.inactive,
+				},
+			}}
+			s := NewServiceAccountService(accounts, newTestRevocationService(repo.NewMemoryTokenRevocationRepository()))
+
+			resp, err := s.ClientCredentials(context.Background(), "svc_reports", tt.secret, tt.scope)
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if resp.Scope != tt.wantScope {
+				t.Errorf("granted scope = %q, want %q", resp.Scope, tt.wantScope)
+			}
+			claims, err := utils.ValidateJWT(resp.AccessToken)
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+			if claims.Scope != tt.wantScope || claims.ServiceAccountID != 5 || claims.UserID != 0 {
+				t.Errorf("claims scope = %q, account = %d, user = %d", claims.Scope, claims.ServiceAccountID, claims.UserID)
+			}
+			if accounts.accounts[5].LastUsedAt == nil {
+				t.Error("last use not recorded")
+			}
+		})
+	}
+}
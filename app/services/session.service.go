@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SessionService interface {
+	// StartSession records a new sign-in for the refresh token family
+	// familyID and returns its session ID.
+	StartSession(ctx context.Context, userID uint, familyID, clientID string, expiresAt time.Time) (string, error)
+	// RenewSession extends the session of familyID after a refresh.
+	RenewSession(ctx context.Context, userID uint, familyID, clientID string, expiresAt time.Time) (string, error)
+	GetSessions(ctx context.Context, userID uint, currentSessionID string) ([]output.SessionResponse, error)
+	RevokeSession(ctx context.Context, userID uint, sessionID string) error
+	// EndFamilySession removes the session of a refresh token family whose
+	// tokens have been revoked, and revokes its access tokens.
+	EndFamilySession(ctx context.Context, familyID string, reason string) error
+	LogoutAll(ctx context.Context, userID uint, reason string) error
+	ForceLogout(ctx context.Context, userID uint) error
+}
+
+type sessionService struct {
+	sessionRepo       repo.UserSessionRepository
+	refreshTokenRepo  repo.RefreshTokenRepository
+	userRepo          repo.UserRepository
+	revocationService RevocationService
+}
+
+func NewSessionService(
+	sessionRepo repo.UserSessionRepository,
+	refreshTokenRepo repo.RefreshTokenRepository,
+	userRepo repo.UserRepository,
+	revocationService RevocationService,
+) SessionService {
+	return &sessionService{
+		sessionRepo:       sessionRepo,
+		refreshTokenRepo:  refreshTokenRepo,
+		userRepo:          userRepo,
+		revocationService: revocationService,
+	}
+}
+
+func (s *sessionService) StartSession(ctx context.Context, userID uint, familyID, clientID string, expiresAt time.Time) (string, error) {
+	ip, userAgent := utils.ClientInfo(ctx)
+	now := time.Now()
+
+	session := &models.UserSession{
+		UserID:     userID,
+		SessionID:  uuid.New().String(),
+		FamilyID:   familyID,
+		ClientID:   clientID,
+		IPAddress:  ip,
+		UserAgent:  userAgent,
+		LastSeenAt: &now,
+		ExpiresAt:  expiresAt,
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return "", err
+	}
+
+	return session.SessionID, nil
+}
+
+// RenewSession starts a session when the family predates session tracking.
+func (s *sessionService) RenewSession(ctx context.Context, userID uint, familyID, clientID string, expiresAt time.Time) (string, error) {
+	session, err := s.sessionRepo.GetByFamilyID(familyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.StartSession(ctx, userID, familyID, clientID, expiresAt)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	session.LastSeenAt = &now
+	session.ExpiresAt = expiresAt
+	if ip, userAgent := utils.ClientInfo(ctx); ip != "" {
+		session.IPAddress = ip
+		session.UserAgent = userAgent
+	}
+	if err := s.sessionRepo.Update(session); err != nil {
+		return "", err
+	}
+
+	return session.SessionID, nil
+}
+
+func (s *sessionService) GetSessions(ctx context.Context, userID uint, currentSessionID string) ([]output.SessionResponse, error) {
+	sessions, err := s.sessionRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]output.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, output.SessionResponse{
+			SessionID:  session.SessionID,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			ClientID:   session.ClientID,
+			Current:    session.SessionID == currentSessionID,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+		})
+	}
+
+	return responses, nil
+}
+
+func (s *sessionService) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	session, err := s.sessionRepo.GetBySessionID(sessionID)
+	if err != nil || session.UserID != userID {
+		return utils.NewNotFoundError("session not found")
+	}
+
+	return s.endSession(session, "session_revoked")
+}
+
+func (s *sessionService) EndFamilySession(ctx context.Context, familyID string, reason string) error {
+	session, err := s.sessionRepo.GetByFamilyID(familyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.endSession(session, reason)
+}
+
+func (s *sessionService) endSession(session *models.UserSession, reason string) error {
+	if session.FamilyID != "" {
+		if err := s.refreshTokenRepo.RevokeFamily(session.FamilyID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.revocationService.RevokeSession(session.SessionID, session.UserID, reason); err != nil {
+		return err
+	}
+
+	return s.sessionRepo.Delete(session.SessionID)
+}
+
+func (s *sessionService) LogoutAll(ctx context.Context, userID uint, reason string) error {
+	if err := s.refreshTokenRepo.RevokeByUserID(userID); err != nil {
+		return err
+	}
+
+	if err := s.revocationService.RevokeUser(userID, reason); err != nil {
+		return err
+	}
+
+	return s.sessionRepo.DeleteByUserID(userID)
+}
+
+func (s *sessionService) ForceLogout(ctx context.Context, userID uint) error {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return utils.NewNotFoundError("user not found")
+	}
+
+	log.Printf("[SESSION] Force logout of user %d", userID)
+	return s.LogoutAll(ctx, userID, "force_logout")
+}
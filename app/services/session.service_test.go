@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+// fakeUserSessionRepo keeps sessions in memory, keyed by session ID.
+type fakeUserSessionRepo struct {
+	repo.UserSessionRepository
+	sessions map[string]*models.UserSession
+}
+
+func (r *fakeUserSessionRepo) Create(session *models.UserSession) error {
+	session.CreatedAt = time.Now()
+	return r.Update(session)
+}
+
+func (r *fakeUserSessionRepo) Update(session *models.UserSession) error {
+	stored := *session
+	r.sessions[session.SessionID] = &stored
+	return nil
+}
+
+func (r *fakeUserSessionRepo) GetBySessionID(sessionID string) (*models.UserSession, error) {
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *session
+	return &found, nil
+}
+
+func (r *fakeUserSessionRepo) GetByFamilyID(familyID string) (*models.UserSession, error) {
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID {
+			found := *session
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserSessionRepo) GetByUserID(userID uint) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeUserSessionRepo) Delete(sessionID string) error {
+	delete(r.sessions, sessionID)
+	return nil
+}
+
+func (r *fakeUserSessionRepo) DeleteByUserID(userID uint) error {
+	for sessionID, session := range r.sessions {
+		if session.UserID == userID {
+			delete(r.sessions, sessionID)
+		}
+	}
+	return nil
+}
+
+func TestSessionRevocation(t *testing.T) {
+	tests := []struct {
+		name string
+		// end acts on the sessions of user 7, phone and laptop, and of
+		// user 8, other.
+		end        func(s SessionService, phone, laptop string) error
+		wantStatus int
+		// wantEnded lists which of phone, laptop and other were ended:
+		// session removed, refresh tokens and access tokens revoked.
+		wantEnded [3]bool
+	}{
+		{
+			name: "revoke own session",
+			end: func(s SessionService, phone, laptop string) error {
+				return s.RevokeSession(context.Background(), 7, phone)
+			},
+			wantStatus: http.StatusOK,
+			wantEnded:  [3]bool{true, false, false},
+		},
+		{
+			name: "revoke another user's session",
+			end: func(s SessionService, phone, laptop string) error {
+				return s.RevokeSession(context.Background(), 8, phone)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "revoke unknown session",
+			end: func(s SessionService, phone, laptop string) error {
+				return s.RevokeSession(context.Background(), 7, "unknown")
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "refresh token family revoked",
+			end: func(s SessionService, phone, laptop string) error {
+				return s.EndFamilySession(context.Background(), "family-laptop", "refresh_token_reuse")
+			},
+			wantStatus: http.StatusOK,
+			wantEnded:  [3]bool{false, true, false},
+		},
+		{
+			name: "logout all",
+			end: func(s SessionService, phone, laptop string) error {
+				return s.LogoutAll(context.Background(), 7, "logout_all")
+			},
+			wantStatus: http.StatusOK,
+			wantEnded:  [3]bool{true, true, false},
+		},
+		{
+			name:       "force logout",
+			end:        func(s SessionService, phone, laptop string) error { return s.ForceLogout(context.Background(), 7) },
+			wantStatus: http.StatusOK,
+			wantEnded:  [3]bool{true, true, false},
+		},
+		{
+			name:       "force logout of unknown user",
+			end:        func(s SessionService, phone, laptop string) error { return s.ForceLogout(context.Background(), 99) },
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := &fakeUserSessionRepo{sessions: make(map[string]*models.UserSession)}
+			refreshTokens := &fakeRefreshTokenRepo{tokens: make(map[string]*models.RefreshToken)}
+			revocation := newTestRevocationService(repo.NewMemoryTokenRevocationRepository())
+			users := &fakeUserRepo{users: map[uint]*models.User{7: {ID: 7}, 8: {ID: 8}}}
+			s := NewSessionService(sessions, refreshTokens, users, revocation)
+
+			expiresAt := time.Now().Add(time.Hour)
+			type signIn struct {
+				userID    uint
+				familyID  string
+				sessionID string
+			}
+			signIns := []*signIn{{userID: 7, familyID: "family-phone"}, {userID: 7, familyID: "family-laptop"}, {userID: 8, familyID: "family-other"}}
+			for _, in := range signIns {
+				sessionID, err := s.StartSession(context.Background(), in.userID, in.familyID, "", expiresAt)
+				if err != nil {
+					t.Fatalf("StartSession: %v", err)
+				}
+				in.sessionID = sessionID
+				refreshTokens.Create(&models.RefreshToken{TokenID: in.familyID + "-token", FamilyID: in.familyID, UserID: in.userID, ExpiresAt: expiresAt})
+			}
+			issuedAt := time.Now().Add(-time.Minute).Unix()
+
+			err := tt.end(s, signIns[0].sessionID, signIns[1].sessionID)
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%v)", got, tt.wantStatus, err)
+			}
+
+			for i, in := range signIns {
+				_, kept := sessions.sessions[in.sessionID]
+				refreshRevoked := refreshTokens.tokens[in.familyID+"-token"].IsRevoked
+				accessRevoked := revocation.IsRevoked(&output.Claims{UserID: in.userID, SessionID: in.sessionID, IssuedAt: issuedAt})
+
+				if kept == tt.wantEnded[i] || refreshRevoked != tt.wantEnded[i] || accessRevoked != tt.wantEnded[i] {
+					t.Errorf("%s: session kept = %v, refresh token revoked = %v, access token revoked = %v, want ended %v",
+						in.familyID, kept, refreshRevoked, accessRevoked, tt.wantEnded[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetSessions(t *testing.T) {
+	sessions := &fakeUserSessionRepo{sessions: make(map[string]*models.UserSession)}
+	s := NewSessionService(sessions, nil, nil, nil)
+
+	ctx := context.WithValue(context.Background(), utils.ClientIPKey, "203.0.113.7")
+	ctx = context.WithValue(ctx, utils.UserAgentKey, "Mozilla/5.0")
+	current, err := s.StartSession(ctx, 7, "family-phone", "", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if _, err := s.StartSession(context.Background(), 7, "family-laptop", "dashboard", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if _, err := s.StartSession(context.Background(), 8, "family-other", "", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+
+	renewed := time.Now().Add(2 * time.Hour)
+	if sessionID, err := s.RenewSession(context.Background(), 7, "family-phone", "", renewed); err != nil || sessionID != current {
+		t.Fatalf("RenewSession = %s, %v, want %s", sessionID, err, current)
+	}
+
+	list, err := s.GetSessions(context.Background(), 7, current)
+	if err != nil {
+		t.Fatalf("GetSessions: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("GetSessions returned %d sessions, want 2", len(list))
+	}
+	for _, session := range list {
+		if session.Current != (session.SessionID == current) {
+			t.Errorf("session %s current = %v", session.SessionID, session.Current)
+		}
+		if !session.Current {
+			continue
+		}
+		if session.IPAddress != "203.0.113.7" || session.UserAgent != "Mozilla/5.0" {
+			t.Errorf("current session device = %s, %s; renewing without client info must keep it", session.IPAddress, session.UserAgent)
+		}
+		if !session.ExpiresAt.Equal(renewed) {
+			t.Errorf("current session expires at %v, want %v", session.ExpiresAt, renewed)
+		}
+	}
+}
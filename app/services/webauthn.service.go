@@ -0,0 +1,447 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, userID uint) (*output.WebAuthnCreationOptions, error)
+	FinishRegistration(ctx context.Context, userID uint, req *input.WebAuthnRegistrationRequest) (*output.WebAuthnCredentialResponse, error)
+	BeginLogin(ctx context.Context, req *input.WebAuthnLoginBeginRequest) (*output.WebAuthnRequestOptions, error)
+	// FinishLogin verifies an assertion and returns the user it belongs to and
+	// whether the authenticator verified the user (PIN or biometric).
+	FinishLogin(ctx context.Context, req *input.WebAuthnAssertionRequest) (*models.User, bool, error)
+	GetCredentials(ctx context.Context, userID uint) ([]output.WebAuthnCredentialResponse, error)
+	DeleteCredential(ctx context.Context, userID, credentialID uint) error
+}
+
+type webAuthnService struct {
+	webAuthnRepo repo.WebAuthnRepository
+	userRepo     repo.UserRepository
+	config       input.WebAuthnConfig
+	origins      map[string]bool
+}
+
+const (
+	webAuthnCeremonyRegister = "register"
+	webAuthnCeremonyLogin    = "login"
+)
+
+// NewWebAuthnService defaults the allowed origins to https://<RPID> when none
+// are configured.
+func NewWebAuthnService(webAuthnRepo repo.WebAuthnRepository, userRepo repo.UserRepository, cfg input.WebAuthnConfig) WebAuthnService {
+	origins := cfg.Origins
+	if len(origins) == 0 {
+		origins = []string{"https://" + cfg.RPID}
+	}
+
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.TrimRight(origin, "/")] = true
+	}
+
+	return &webAuthnService{
+		webAuthnRepo: webAuthnRepo,
+		userRepo:     userRepo,
+		config:       cfg,
+		origins:      allowed,
+	}
+}
+
+func (s *webAuthnService) BeginRegistration(ctx context.Context, userID uint) (*output.WebAuthnCreationOptions, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, utils.NewNotFoundError("user not found")
+	}
+
+	credentials, err := s.webAuthnRepo.GetCredentialsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.newChallenge(webAuthnCeremonyRegister, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := mfaAccountName(user)
+	displayName := name
+	if user.Username != nil && *user.Username != "" {
+		displayName = *user.Username
+	}
+
+	return &output.WebAuthnCreationOptions{
+		Challenge: challenge,
+		RP: output.WebAuthnRelyingParty{
+			ID:   s.config.RPID,
+			Name: s.config.RPName,
+		},
+		User: output.WebAuthnUserEntity{
+			ID:          webAuthnUserHandle(userID),
+			Name:        name,
+			DisplayName: displayName,
+		},
+		PubKeyCredParams: []output.WebAuthnCredentialParameter{
+			{Type: "public-key", Alg: utils.COSEAlgES256},
+			{Type: "public-key", Alg: utils.COSEAlgEdDSA},
+			{Type: "public-key", Alg: utils.COSEAlgRS256},
+		},
+		Timeout:            s.config.ChallengeTTL.Milliseconds(),
+		ExcludeCredentials: credentialDescriptors(credentials),
+		AuthenticatorSelection: output.WebAuthnAuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// FinishRegistration verifies a new credential. Attestation is requested as
+// "none", so the attestation statement is not evaluated and the authenticator
+// model is not trusted for anything.
+func (s *webAuthnService) FinishRegistration(ctx context.Context, userID uint, req *input.WebAuthnRegistrationRequest) (*output.WebAuthnCredentialResponse, error) {
+	clientDataJSON, err := utils.DecodeBase64URL(req.Response.ClientDataJSON)
+	if err != nil {
+		return nil, utils.NewBadRequestError("invalid clientDataJSON")
+	}
+
+	if _, err := s.verifyClientData(clientDataJSON, utils.WebAuthnTypeCreate, webAuthnCeremonyRegister, &userID); err != nil {
+		return nil, err
+	}
+
+	attestationObject, err := utils.DecodeBase64URL(req.Response.AttestationObject)
+	if err != nil {
+		return nil, utils.NewBadRequestError("invalid attestationObject")
+	}
+
+	_, rawAuthData, err := utils.ParseAttestationObject(attestationObject)
+	if err != nil {
+		return nil, utils.NewBadRequestError(err.Error())
+	}
+
+	authData, err := utils.ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, utils.NewBadRequestError(err.Error())
+	}
+
+	if err := s.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+	if authData.CredentialID == nil {
+		return nil, utils.NewBadRequestError("authenticator data has no attested credential")
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(authData.CredentialID)
+	if strings.TrimRight(req.ID, "=") != credentialID {
+		return nil, utils.NewBadRequestError("credential ID does not match the authenticator data")
+	}
+
+	_, algorithm, err := utils.ParseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return nil, utils.NewBadRequestError(err.Error())
+	}
+
+	if _, err := s.webAuthnRepo.GetCredentialByCredentialID(credentialID); err == nil {
+		return nil, utils.NewBadRequestError("passkey is already registered")
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+
+	credential := &models.WebAuthnCredential{
+		UserID:         userID,
+		CredentialID:   credentialID,
+		PublicKey:      authData.PublicKey,
+		Algorithm:      algorithm,
+		SignCount:      authData.SignCount,
+		Transports:     strings.Join(req.Response.Transports, ","),
+		Name:           name,
+		BackupEligible: authData.BackupEligible(),
+		BackupState:    authData.BackupState(),
+	}
+	if aaguid, err := uuid.FromBytes(authData.AAGUID); err == nil && aaguid != uuid.Nil {
+		credential.AAGUID = aaguid.String()
+	}
+
+	if err := s.webAuthnRepo.CreateCredential(credential); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[WEBAUTHN] Passkey %d registered for user %d", credential.ID, userID)
+
+	resp := newWebAuthnCredentialResponse(credential)
+	return &resp, nil
+}
+
+// BeginLogin starts an assertion. With an email the user's passkeys are
+// listed and the challenge is bound to that user; an unknown email, or one
+// without passkeys, falls back to a discoverable login.
+func (s *webAuthnService) BeginLogin(ctx context.Context, req *input.WebAuthnLoginBeginRequest) (*output.WebAuthnRequestOptions, error) {
+	allow := []output.WebAuthnCredentialDescriptor{}
+	var userID *uint
+
+	if req.Email != "" {
+		if user, err := s.userRepo.GetByEmail(req.Email); err == nil {
+			credentials, err := s.webAuthnRepo.GetCredentialsByUserID(user.ID)
+			if err != nil {
+				return nil, err
+			}
+			if len(credentials) > 0 {
+				allow = credentialDescriptors(credentials)
+				userID = &user.ID
+			}
+		}
+	}
+
+	challenge, err := s.newChallenge(webAuthnCeremonyLogin, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &output.WebAuthnRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.config.ChallengeTTL.Milliseconds(),
+		RPID:             s.config.RPID,
+		AllowCredentials: allow,
+		UserVerification: "preferred",
+	}, nil
+}
+
+func (s *webAuthnService) FinishLogin(ctx context.Context, req *input.WebAuthnAssertionRequest) (*models.User, bool, error) {
+	clientDataJSON, err := utils.DecodeBase64URL(req.Response.ClientDataJSON)
+	if err != nil {
+		return nil, false, utils.NewBadRequestError("invalid clientDataJSON")
+	}
+
+	challenge, err := s.verifyClientData(clientDataJSON, utils.WebAuthnTypeGet, webAuthnCeremonyLogin, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	credential, err := s.webAuthnRepo.GetCredentialByCredentialID(strings.TrimRight(req.ID, "="))
+	if err != nil {
+		return nil, false, utils.NewUnauthorizedError("unknown passkey")
+	}
+
+	if challenge.UserID != nil && *challenge.UserID != credential.UserID {
+		return nil, false, utils.NewUnauthorizedError("passkey does not belong to this login")
+	}
+
+	if req.Response.UserHandle != "" {
+		userHandle, err := utils.DecodeBase64URL(req.Response.UserHandle)
+		if err != nil || webAuthnUserHandle(credential.UserID) != base64.RawURLEncoding.EncodeToString(userHandle) {
+			return nil, false, utils.NewUnauthorizedError("passkey does not belong to this user")
+		}
+	}
+
+	if credential.CloneDetected {
+		return nil, false, utils.NewForbiddenError("passkey has been disabled, remove it and register it again")
+	}
+
+	rawAuthData, err := utils.DecodeBase64URL(req.Response.AuthenticatorData)
+	if err != nil {
+		return nil, false, utils.NewBadRequestError("invalid authenticatorData")
+	}
+
+	authData, err := utils.ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, false, utils.NewBadRequestError(err.Error())
+	}
+
+	if err := s.verifyAuthenticatorData(authData); err != nil {
+		return nil, false, err
+	}
+
+	signature, err := utils.DecodeBase64URL(req.Response.Signature)
+	if err != nil {
+		return nil, false, utils.NewBadRequestError("invalid signature")
+	}
+
+	if err := utils.VerifyWebAuthnSignature(credential.PublicKey, rawAuthData, clientDataJSON, signature); err != nil {
+		return nil, false, utils.NewUnauthorizedError("invalid passkey signature")
+	}
+
+	// Authenticators that keep a signature counter must increase it on every
+	// assertion; a count that does not move forward means the key material
+	// has been copied. Synced passkeys report 0 throughout.
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		log.Printf("[WEBAUTHN] Sign count of passkey %d went from %d to %d, possible clone", credential.ID, credential.SignCount, authData.SignCount)
+		if err := s.webAuthnRepo.MarkCloneDetected(credential.ID); err != nil {
+			log.Printf("[WEBAUTHN] Failed to flag passkey %d: %v", credential.ID, err)
+		}
+		return nil, false, utils.NewForbiddenError("passkey has been disabled, remove it and register it again")
+	}
+
+	recorded, err := s.webAuthnRepo.RecordAssertion(credential.ID, credential.SignCount, authData.SignCount, authData.BackupState())
+	if err != nil {
+		return nil, false, err
+	}
+	if !recorded && authData.SignCount != 0 {
+		return nil, false, utils.NewUnauthorizedError("passkey was used concurrently, try again")
+	}
+
+	user, err := s.userRepo.GetByID(credential.UserID)
+	if err != nil {
+		return nil, false, utils.NewUnauthorizedError("user not found")
+	}
+
+	if user.Status != models.UserStatusActive {
+		return nil, false, utils.NewForbiddenError("user account is not active")
+	}
+
+	s.userRepo.UpdateLastLogin(user.ID)
+
+	return user, authData.UserVerified(), nil
+}
+
+func (s *webAuthnService) GetCredentials(ctx context.Context, userID uint) ([]output.WebAuthnCredentialResponse, error) {
+	credentials, err := s.webAuthnRepo.GetCredentialsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]output.WebAuthnCredentialResponse, 0, len(credentials))
+	for i := range credentials {
+		responses = append(responses, newWebAuthnCredentialResponse(&credentials[i]))
+	}
+
+	return responses, nil
+}
+
+func (s *webAuthnService) DeleteCredential(ctx context.Context, userID, credentialID uint) error {
+	deleted, err := s.webAuthnRepo.DeleteCredential(userID, credentialID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return utils.NewNotFoundError("passkey not found")
+	}
+
+	log.Printf("[WEBAUTHN] Passkey %d removed by user %d", credentialID, userID)
+	return nil
+}
+
+func (s *webAuthnService) newChallenge(ceremony string, userID *uint) (string, error) {
+	challenge, err := utils.GenerateWebAuthnChallenge()
+	if err != nil {
+		return "", err
+	}
+
+	record := &models.WebAuthnChallenge{
+		ChallengeHash: utils.HashToken(challenge),
+		Ceremony:      ceremony,
+		UserID:        userID,
+		ExpiresAt:     time.Now().Add(s.config.ChallengeTTL),
+	}
+	if err := s.webAuthnRepo.CreateChallenge(record); err != nil {
+		return "", err
+	}
+
+	if err := s.webAuthnRepo.DeleteExpiredChallenges(); err != nil {
+		log.Printf("[WEBAUTHN] Failed to delete expired challenges: %v", err)
+	}
+
+	return challenge, nil
+}
+
+// verifyClientData checks the ceremony type and origin and consumes the
+// challenge the client data was signed for. For registrations userID must
+// match the user the challenge was issued to.
+func (s *webAuthnService) verifyClientData(raw []byte, clientDataType, ceremony string, userID *uint) (*models.WebAuthnChallenge, error) {
+	clientData, err := utils.ParseWebAuthnClientData(raw)
+	if err != nil {
+		return nil, utils.NewBadRequestError(err.Error())
+	}
+
+	if clientData.Type != clientDataType {
+		return nil, utils.NewBadRequestError(fmt.Sprintf("client data type must be %s", clientDataType))
+	}
+
+	if !s.origins[clientData.Origin] {
+		return nil, utils.NewBadRequestError("origin is not allowed")
+	}
+
+	challenge, err := s.webAuthnRepo.ConsumeChallenge(utils.HashToken(strings.TrimRight(clientData.Challenge, "=")), ceremony)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.NewUnauthorizedError("challenge is invalid or has expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if userID != nil && (challenge.UserID == nil || *challenge.UserID != *userID) {
+		return nil, utils.NewUnauthorizedError("challenge is invalid or has expired")
+	}
+
+	return challenge, nil
+}
+
+func (s *webAuthnService) verifyAuthenticatorData(authData *utils.AuthenticatorData) error {
+	if !utils.WebAuthnRPIDHashMatches(authData, s.config.RPID) {
+		return utils.NewBadRequestError("authenticator data is for a different relying party")
+	}
+	if !authData.UserPresent() {
+		return utils.NewBadRequestError("user presence is required")
+	}
+	return nil
+}
+
+// webAuthnUserHandle is the opaque user.id given to authenticators, which
+// returns it in discoverable logins.
+func webAuthnUserHandle(userID uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(userID), 10)))
+}
+
+func credentialDescriptors(credentials []models.WebAuthnCredential) []output.WebAuthnCredentialDescriptor {
+	descriptors := make([]output.WebAuthnCredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, output.WebAuthnCredentialDescriptor{
+			Type:       "public-key",
+			ID:         credential.CredentialID,
+			Transports: splitTransports(credential.Transports),
+		})
+	}
+	return descriptors
+}
+
+func splitTransports(transports string) []string {
+	if transports == "" {
+		return nil
+	}
+	return strings.Split(transports, ",")
+}
+
+func newWebAuthnCredentialResponse(credential *models.WebAuthnCredential) output.WebAuthnCredentialResponse {
+	return output.WebAuthnCredentialResponse{
+		ID:             credential.ID,
+		Name:           credential.Name,
+		CredentialID:   credential.CredentialID,
+		AAGUID:         credential.AAGUID,
+		Transports:     splitTransports(credential.Transports),
+		BackupEligible: credential.BackupEligible,
+		BackupState:    credential.BackupState,
+		CloneDetected:  credential.CloneDetected,
+		CreatedAt:      credential.CreatedAt,
+		LastUsedAt:     credential.LastUsedAt,
+	}
+}
@@ -0,0 +1,502 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+	"github.com/bbapp-org/auth-service/app/repo"
+	"github.com/bbapp-org/auth-service/app/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	testRPID   = "auth.example.com"
+	testOrigin = "https://auth.example.com"
+)
+
+// fakeWebAuthnRepo keeps credentials and challenges in memory.
+type fakeWebAuthnRepo struct {
+	credentials map[string]*models.WebAuthnCredential
+	challenges  map[string]*models.WebAuthnChallenge
+	nextID      uint
+}
+
+func newFakeWebAuthnRepo() *fakeWebAuthnRepo {
+	return &fakeWebAuthnRepo{
+		credentials: make(map[string]*models.WebAuthnCredential),
+		challenges:  make(map[string]*models.WebAuthnChallenge),
+	}
+}
+
+func (r *fakeWebAuthnRepo) CreateCredential(credential *models.WebAuthnCredential) error {
+	r.nextID++
+	credential.ID = r.nextID
+	stored := *credential
+	r.credentials[credential.CredentialID] = &stored
+	return nil
+}
+
+func (r *fakeWebAuthnRepo) GetCredentialByCredentialID(credentialID string) (*models.WebAuthnCredential, error) {
+	credential, ok := r.credentials[credentialID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *credential
+	return &found, nil
+}
+
+func (r *fakeWebAuthnRepo) GetCredentialsByUserID(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, *credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *fakeWebAuthnRepo) RecordAssertion(id uint, previousCount, signCount uint32, backupState bool) (bool, error) {
+	for _, credential := range r.credentials {
+		if credential.ID == id && credential.SignCount == previousCount {
+			credential.SignCount = signCount
+			credential.BackupState = backupState
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeWebAuthnRepo) MarkCloneDetected(id uint) error {
+	for _, credential := range r.credentials {
+		if credential.ID == id {
+			credential.CloneDetected = true
+		}
+	}
+	return nil
+}
+
+func (r *fakeWebAuthnRepo) DeleteCredential(userID, id uint) (bool, error) {
+	for key, credential := range r.credentials {
+		if credential.ID == id && credential.UserID == userID {
+			delete(r.credentials, key)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeWebAuthnRepo) CreateChallenge(challenge *models.WebAuthnChallenge) error {
+	r.challenges[challenge.ChallengeHash] = challenge
+	return nil
+}
+
+func (r *fakeWebAuthnRepo) ConsumeChallenge(challengeHash, ceremony string) (*models.WebAuthnChallenge, error) {
+	challenge, ok := r.challenges[challengeHash]
+	if !ok || challenge.Ceremony != ceremony || challenge.ConsumedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	now := time.Now()
+	challenge.ConsumedAt = &now
+	return challenge, nil
+}
+
+func (r *fakeWebAuthnRepo) DeleteExpiredChallenges() error {
+	return nil
+}
+
+// fakeUserRepo serves the users the services under test look up. Methods
+// it does not override panic through the nil embedded interface.
+type fakeUserRepo struct {
+	repo.UserRepository
+	users map[uint]*models.User
+}
+
+func (r *fakeUserRepo) GetByID(id uint) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+func (r *fakeUserRepo) GetByEmail(email string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Email != nil && *user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepo) GetByPhone(phone string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Phone != nil && *user.Phone == phone {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepo) GetByGoogleID(googleID string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.GoogleID != nil && *user.GoogleID == googleID {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepo) GetByAppleID(appleID string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.AppleID != nil && *user.AppleID == appleID {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepo) Update(user *models.User) error {
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *fakeUserRepo) Merge(source, target *models.User) error {
+	r.users[source.ID], r.users[target.ID] = source, target
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateLastLogin(id uint) error {
+	return nil
+}
+
+func (r *fakeUserRepo) UpdatePasswordChangedAt(id uint) error {
+	now := time.Now()
+	r.users[id].PasswordChangedAt = &now
+	return nil
+}
+
+// RecordLoginFailure applies the assignments of the repository's UPDATE
+// statement.
+func (r *fakeUserRepo) RecordLoginFailure(id uint, threshold int, lockout, maxLockout time.Duration) (*time.Time, error) {
+	user := r.users[id]
+	if user.FailedLoginAttempts+1 < threshold {
+		user.FailedLoginAttempts++
+		return user.LockedUntil, nil
+	}
+
+	duration := lockout << user.LockoutCount
+	if duration > maxLockout || duration <= 0 {
+		duration = maxLockout
+	}
+	lockedUntil := time.Now().Add(duration)
+	user.LockedUntil = &lockedUntil
+	user.LockoutCount++
+	user.FailedLoginAttempts = 0
+	return user.LockedUntil, nil
+}
+
+func (r *fakeUserRepo) ClearLoginFailures(id uint) error {
+	user := r.users[id]
+	user.FailedLoginAttempts, user.LockoutCount, user.LockedUntil = 0, 0, nil
+	return nil
+}
+
+// softAuthenticator is an ES256 authenticator held in memory. It produces
+// the responses a browser would relay from a hardware security key.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	rpID         string
+	origin       string
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatalf("generate credential ID: %v", err)
+	}
+
+	return &softAuthenticator{key: key, credentialID: credentialID, rpID: testRPID, origin: testOrigin}
+}
+
+func (a *softAuthenticator) id() string {
+	return base64.RawURLEncoding.EncodeToString(a.credentialID)
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, clientDataType, challenge string) []byte {
+	t.Helper()
+
+	clientData, err := json.Marshal(utils.WebAuthnClientData{Type: clientDataType, Challenge: challenge, Origin: a.origin})
+	if err != nil {
+		t.Fatalf("marshal client data: %v", err)
+	}
+	return clientData
+}
+
+// authenticatorData lays out rpIdHash, flags and the sign count, followed by
+// attested credential data when attested is set.
+func (a *softAuthenticator) authenticatorData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	flags := byte(0x01 | 0x04) // user present, user verified
+	if attested {
+		flags |= 0x40
+	}
+
+	data := append([]byte(nil), rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+// coseKey encodes the public key as an EC2 P-256 COSE_Key:
+// {1: 2, 3: -7, -1: 1, -2: x, -3: y}.
+func (a *softAuthenticator) coseKey() []byte {
+	x := a.key.PublicKey.X.FillBytes(make([]byte, 32))
+	y := a.key.PublicKey.Y.FillBytes(make([]byte, 32))
+
+	key := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21}
+	key = append(key, cborBytes(x)...)
+	key = append(key, 0x22)
+	return append(key, cborBytes(y)...)
+}
+
+func (a *softAuthenticator) register(t *testing.T, challenge string) *input.WebAuthnRegistrationRequest {
+	t.Helper()
+
+	attestationObject := []byte{0xa3}
+	attestationObject = append(attestationObject, cborText("fmt")...)
+	attestationObject = append(attestationObject, cborText("none")...)
+	attestationObject = append(attestationObject, cborText("attStmt")...)
+	attestationObject = append(attestationObject, 0xa0)
+	attestationObject = append(attestationObject, cborText("authData")...)
+	attestationObject = append(attestationObject, cborBytes(a.authenticatorData(true))...)
+
+	return &input.WebAuthnRegistrationRequest{
+		ID:   a.id(),
+		Type: "public-key",
+		Response: input.WebAuthnAttestationResponse{
+			ClientDataJSON:    base64.RawURLEncoding.EncodeToString(a.clientData(t, utils.WebAuthnTypeCreate, challenge)),
+			AttestationObject: base64.RawURLEncoding.EncodeToString(attestationObject),
+		},
+	}
+}
+
+func (a *softAuthenticator) assert(t *testing.T, challenge string) *input.WebAuthnAssertionRequest {
+	t.Helper()
+
+	clientData := a.clientData(t, utils.WebAuthnTypeGet, challenge)
+	authData := a.authenticatorData(false)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+
+	return &input.WebAuthnAssertionRequest{
+		ID:   a.id(),
+		Type: "public-key",
+		Response: input.WebAuthnAssertionResponse{
+			ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientData),
+			AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+			Signature:         base64.RawURLEncoding.EncodeToString(signature),
+		},
+	}
+}
+
+func cborHead(major byte, length int) []byte {
+	switch {
+	case length < 24:
+		return []byte{major<<5 | byte(length)}
+	case length < 256:
+		return []byte{major<<5 | 24, byte(length)}
+	default:
+		return []byte{major<<5 | 25, byte(length >> 8), byte(length)}
+	}
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, len(b)), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, len(s)), s...)
+}
+
+func newTestWebAuthnService() (WebAuthnService, *fakeWebAuthnRepo) {
+	email := "user@example.com"
+	users := &fakeUserRepo{users: map[uint]*models.User{
+		1: {ID: 1, Email: &email, Status: models.UserStatusActive},
+	}}
+	webAuthnRepo := newFakeWebAuthnRepo()
+
+	return NewWebAuthnService(webAuthnRepo, users, input.WebAuthnConfig{
+		RPID:         testRPID,
+		RPName:       "Test",
+		Origins:      []string{testOrigin},
+		ChallengeTTL: 5 * time.Minute,
+	}), webAuthnRepo
+}
+
+func registerSoftAuthenticator(t *testing.T, svc WebAuthnService, authenticator *softAuthenticator) {
+	t.Helper()
+
+	options, err := svc.BeginRegistration(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	if _, err := svc.FinishRegistration(context.Background(), 1, authenticator.register(t, options.Challenge)); err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+}
+
+func loginSoftAuthenticator(t *testing.T, svc WebAuthnService, authenticator *softAuthenticator) (uint, error) {
+	t.Helper()
+
+	options, err := svc.BeginLogin(context.Background(), &input.WebAuthnLoginBeginRequest{Email: "user@example.com"})
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+
+	user, _, err := svc.FinishLogin(context.Background(), authenticator.assert(t, options.Challenge))
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+func requireHTTPError(t *testing.T, err error, code int) {
+	t.Helper()
+
+	var httpErr *utils.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != code {
+		t.Fatalf("got error %v, want HTTP %d", err, code)
+	}
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	svc, _ := newTestWebAuthnService()
+	authenticator := newSoftAuthenticator(t)
+	registerSoftAuthenticator(t, svc, authenticator)
+
+	for count := uint32(1); count <= 2; count++ {
+		authenticator.signCount = count
+		userID, err := loginSoftAuthenticator(t, svc, authenticator)
+		if err != nil {
+			t.Fatalf("login with sign count %d: %v", count, err)
+		}
+		if userID != 1 {
+			t.Fatalf("login returned user %d, want 1", userID)
+		}
+	}
+}
+
+func TestWebAuthnLoginRejectsReplayedChallenge(t *testing.T) {
+	svc, _ := newTestWebAuthnService()
+	authenticator := newSoftAuthenticator(t)
+	registerSoftAuthenticator(t, svc, authenticator)
+
+	options, err := svc.BeginLogin(context.Background(), &input.WebAuthnLoginBeginRequest{Email: "user@example.com"})
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+
+	authenticator.signCount = 1
+	if _, _, err := svc.FinishLogin(context.Background(), authenticator.assert(t, options.Challenge)); err != nil {
+		t.Fatalf("first assertion: %v", err)
+	}
+
+	authenticator.signCount = 2
+	_, _, err = svc.FinishLogin(context.Background(), authenticator.assert(t, options.Challenge))
+	requireHTTPError(t, err, http.StatusUnauthorized)
+}
+
+func TestWebAuthnLoginDetectsClonedAuthenticator(t *testing.T) {
+	svc, webAuthnRepo := newTestWebAuthnService()
+	authenticator := newSoftAuthenticator(t)
+	registerSoftAuthenticator(t, svc, authenticator)
+
+	authenticator.signCount = 5
+	if _, err := loginSoftAuthenticator(t, svc, authenticator); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// A copy of the key that has fallen behind the original's counter.
+	authenticator.signCount = 3
+	_, err := loginSoftAuthenticator(t, svc, authenticator)
+	requireHTTPError(t, err, http.StatusForbidden)
+
+	if !webAuthnRepo.credentials[authenticator.id()].CloneDetected {
+		t.Fatal("credential was not flagged as cloned")
+	}
+
+	// The credential stays disabled even once the counter moves on.
+	authenticator.signCount = 10
+	_, err = loginSoftAuthenticator(t, svc, authenticator)
+	requireHTTPError(t, err, http.StatusForbidden)
+}
+
+func TestWebAuthnRejectsWrongOriginOrRPID(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		rpID   string
+	}{
+		{name: "origin", origin: "https://evil.example.com", rpID: testRPID},
+		{name: "origin with port", origin: "https://auth.example.com:8443", rpID: testRPID},
+		{name: "rp id", origin: testOrigin, rpID: "evil.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run("registration/"+tt.name, func(t *testing.T) {
+			svc, _ := newTestWebAuthnService()
+			authenticator := newSoftAuthenticator(t)
+			authenticator.origin, authenticator.rpID = tt.origin, tt.rpID
+
+			options, err := svc.BeginRegistration(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("BeginRegistration: %v", err)
+			}
+			_, err = svc.FinishRegistration(context.Background(), 1, authenticator.register(t, options.Challenge))
+			requireHTTPError(t, err, http.StatusBadRequest)
+		})
+
+		t.Run("login/"+tt.name, func(t *testing.T) {
+			svc, _ := newTestWebAuthnService()
+			authenticator := newSoftAuthenticator(t)
+			registerSoftAuthenticator(t, svc, authenticator)
+
+			authenticator.origin, authenticator.rpID = tt.origin, tt.rpID
+			authenticator.signCount = 1
+			_, err := loginSoftAuthenticator(t, svc, authenticator)
+			requireHTTPError(t, err, http.StatusBadRequest)
+		})
+	}
+}
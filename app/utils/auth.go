@@ -2,19 +2,22 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math/big"
+	"strconv"
 	"time"
 
 	"github.com/bbapp-org/auth-service/app/dto/output"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecretKey = []byte("your-secret-key-change-this-in-production")
-
 func GenerateOTP() (string, error) {
 	max := big.NewInt(999999)
 	n, err := rand.Int(rand.Reader, max)
@@ -24,6 +27,15 @@ func GenerateOTP() (string, error) {
 	return fmt.Sprintf("%06d", n.Int64()), nil
 }
 
+func HashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func CompareTokenHash(value, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(value)), []byte(hash)) == 1
+}
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
@@ -34,8 +46,46 @@ func CheckPassword(password, hash string) bool {
 	return err == nil
 }
 
+// AccessTokenTTL is the lifetime of user access tokens issued by GenerateJWT.
+const AccessTokenTTL = time.Hour * 24 * 7
+
+// Authentication method references (RFC 8176) used in the "amr" claim.
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRSMS         = "sms"
+	AMRMFA         = "mfa"
+	AMRHardwareKey = "hwk"
+	// AMRFederated marks a sign-in delegated to Google or Apple. It is not
+	// registered in RFC 8176 but is widely used for the purpose.
+	AMRFederated = "fed"
+)
+
 func GenerateJWT(claims output.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	return signToken(accessTokenClaims(claims, AccessTokenTTL))
+}
+
+// GenerateStepUpToken issues an access token that expires after ttl, for
+// claims whose AuthTime was just refreshed by reauthenticating.
+func GenerateStepUpToken(claims output.Claims, ttl time.Duration) (string, error) {
+	return signToken(accessTokenClaims(claims, ttl))
+}
+
+// GenerateImpersonationToken issues an access token for claims' user that
+// names claims.ImpersonatorID in an RFC 8693 "act" claim. It expires after
+// ttl and, having no refresh token, cannot be extended.
+func GenerateImpersonationToken(claims output.Claims, ttl time.Duration) (string, error) {
+	if claims.ImpersonatorID == 0 {
+		return "", errors.New("impersonation token needs an impersonator")
+	}
+
+	mapClaims := accessTokenClaims(claims, ttl)
+	mapClaims["act"] = jwt.MapClaims{"sub": fmt.Sprintf("%d", claims.ImpersonatorID)}
+	return signToken(mapClaims)
+}
+
+func accessTokenClaims(claims output.Claims, ttl time.Duration) jwt.MapClaims {
+	mapClaims := jwt.MapClaims{
 		"user_id":       claims.UserID,
 		"user_type":     claims.UserType,
 		"role":          claims.Role,
@@ -43,51 +93,116 @@ func GenerateJWT(claims output.Claims) (string, error) {
 		"phone":         claims.Phone,
 		"google_id":     claims.GoogleID,
 		"identity_type": claims.IdentityType,
+		"jti":           uuid.New().String(),
 		"iat":           time.Now().Unix(),
-		"exp":           time.Now().Add(time.Hour * 24 * 7).Unix(),
-		"iss":           "github.com/bbapp-org/auth-service",
+		"exp":           time.Now().Add(ttl).Unix(),
+		"iss":           Issuer(),
 		"sub":           fmt.Sprintf("%d", claims.UserID),
+	}
+	if claims.ClientID != "" {
+		mapClaims["client_id"] = claims.ClientID
+	}
+	if claims.Scope != "" {
+		mapClaims["scope"] = claims.Scope
+	}
+	if claims.SessionID != "" {
+		mapClaims["sid"] = claims.SessionID
+	}
+	if claims.AuthTime != 0 {
+		mapClaims["auth_time"] = claims.AuthTime
+	}
+	if len(claims.AMR) > 0 {
+		mapClaims["amr"] = claims.AMR
+	}
+	return mapClaims
+}
+
+// GenerateServiceToken issues a short-lived access token for a service
+// account. It carries the account in service_account_id and has no user_id,
+// and the subject is prefixed, so it can never be mistaken for a user.
+func GenerateServiceToken(accountID uint, clientID, scope string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return signToken(jwt.MapClaims{
+		"service_account_id": accountID,
+		"user_type":          "service",
+		"role":               "service",
+		"identity_type":      "client_credentials",
+		"client_id":          clientID,
+		"scope":              scope,
+		"jti":                uuid.New().String(),
+		"iat":                now.Unix(),
+		"exp":                now.Add(ttl).Unix(),
+		"iss":                Issuer(),
+		"sub":                "service:" + clientID,
 	})
+}
 
-	return token.SignedString(jwtSecretKey)
+// GenerateIDToken issues an OpenID Connect ID token carrying the standard
+// claims of info.
+func GenerateIDToken(info output.OIDCUserInfo, audience, nonce string, authTime time.Time) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                   Issuer(),
+		"sub":                   info.Sub,
+		"aud":                   audience,
+		"iat":                   now.Unix(),
+		"exp":                   now.Add(time.Hour).Unix(),
+		"auth_time":             authTime.Unix(),
+		"email_verified":        info.EmailVerified,
+		"phone_number_verified": info.PhoneNumberVerified,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	if info.Email != "" {
+		claims["email"] = info.Email
+	}
+	if info.PhoneNumber != "" {
+		claims["phone_number"] = info.PhoneNumber
+	}
+	if info.Name != "" {
+		claims["name"] = info.Name
+	}
+
+	return signToken(claims)
 }
 
-func GenerateRefreshToken(userID uint) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+func GenerateRefreshToken(userID uint, tokenID string) (string, error) {
+	return signToken(jwt.MapClaims{
 		"user_id": userID,
 		"type":    "refresh",
+		"jti":     tokenID,
 		"iat":     time.Now().Unix(),
 		"exp":     time.Now().Add(time.Hour * 24 * 90).Unix(),
-		"iss":     "github.com/bbapp-org/auth-service",
+		"iss":     Issuer(),
 		"sub":     fmt.Sprintf("%d", userID),
 	})
-
-	return token.SignedString(jwtSecretKey)
 }
 
 func ValidateJWT(tokenString string) (*output.Claims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecretKey, nil
-	})
+	token, err := jwt.Parse(tokenString, verificationKeyFunc)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID, ok := claims["user_id"].(float64)
-		if !ok {
-			return nil, errors.New("invalid user_id in token")
-		}
-
 		userType, ok := claims["user_type"].(string)
 		if !ok {
 			return nil, errors.New("invalid user_type in token")
 		}
 
+		// Service tokens name their account instead of a user.
+		userID, hasUserID := claims["user_id"].(float64)
+		serviceAccountID, _ := claims["service_account_id"].(float64)
+		if userType == "service" {
+			if hasUserID || serviceAccountID == 0 {
+				return nil, errors.New("invalid service_account_id in token")
+			}
+		} else if !hasUserID {
+			return nil, errors.New("invalid user_id in token")
+		}
+
 		role, ok := claims["role"].(string)
 		if !ok {
 			return nil, errors.New("invalid role in token")
@@ -97,8 +212,34 @@ func ValidateJWT(tokenString string) (*output.Claims, error) {
 		phone, _ := claims["phone"].(string)
 		googleID, _ := claims["google_id"].(string)
 		identityType, _ := claims["identity_type"].(string)
+		clientID, _ := claims["client_id"].(string)
+		scope, _ := claims["scope"].(string)
+		tokenID, _ := claims["jti"].(string)
+		sessionID, _ := claims["sid"].(string)
+		iat, _ := claims["iat"].(float64)
+		exp, _ := claims["exp"].(float64)
+		authTime, _ := claims["auth_time"].(float64)
+
+		var amr []string
+		if values, ok := claims["amr"].([]interface{}); ok {
+			for _, value := range values {
+				if method, ok := value.(string); ok {
+					amr = append(amr, method)
+				}
+			}
+		}
+
+		var impersonatorID uint
+		if act, ok := claims["act"].(map[string]interface{}); ok {
+			sub, _ := act["sub"].(string)
+			id, err := strconv.ParseUint(sub, 10, 32)
+			if err != nil || id == 0 {
+				return nil, errors.New("invalid act in token")
+			}
+			impersonatorID = uint(id)
+		}
 
-		return &output.Claims{
+		result := &output.Claims{
 			UserID:       uint(userID),
 			UserType:     userType,
 			Role:         role,
@@ -106,39 +247,111 @@ func ValidateJWT(tokenString string) (*output.Claims, error) {
 			Phone:        phone,
 			GoogleID:     googleID,
 			IdentityType: identityType,
-		}, nil
+			ClientID:     clientID,
+			Scope:        scope,
+			TokenID:      tokenID,
+			SessionID:    sessionID,
+			IssuedAt:     int64(iat),
+			ExpiresAt:    int64(exp),
+			AuthTime:     int64(authTime),
+			AMR:          amr,
+
+			ServiceAccountID: uint(serviceAccountID),
+			ImpersonatorID:   impersonatorID,
+		}
+
+		if IsTokenRevoked(result) {
+			return nil, ErrTokenRevoked
+		}
+
+		return result, nil
 	}
 
 	return nil, errors.New("invalid token")
 }
 
-func ValidateRefreshToken(tokenString string) (uint, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecretKey, nil
-	})
+// ValidateRefreshToken verifies a refresh JWT and returns the user ID and the
+// jti that links it to its refresh_tokens row.
+func ValidateRefreshToken(tokenString string) (uint, string, error) {
+	token, err := jwt.Parse(tokenString, verificationKeyFunc)
 
 	if err != nil {
-		return 0, err
+		return 0, "", err
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		tokenType, ok := claims["type"].(string)
 		if !ok || tokenType != "refresh" {
-			return 0, errors.New("invalid token type")
+			return 0, "", errors.New("invalid token type")
 		}
 
 		userID, ok := claims["user_id"].(float64)
 		if !ok {
-			return 0, errors.New("invalid user_id in token")
+			return 0, "", errors.New("invalid user_id in token")
 		}
 
-		return uint(userID), nil
+		tokenID, ok := claims["jti"].(string)
+		if !ok || tokenID == "" {
+			return 0, "", errors.New("invalid jti in token")
+		}
+
+		return uint(userID), tokenID, nil
+	}
+
+	return 0, "", errors.New("invalid refresh token")
+}
+
+// MFA challenge purposes: the holder must either verify an enrolled factor
+// or enroll one before tokens are issued.
+const (
+	MFAPurposeVerify = "verify"
+	MFAPurposeEnroll = "enroll"
+)
+
+// GenerateMFAChallengeToken issues the short-lived token that stands in for
+// access tokens between the password step and the second factor. It has no
+// user_type claim, so ValidateJWT never accepts it as an access token.
+func GenerateMFAChallengeToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return signToken(jwt.MapClaims{
+		"user_id": userID,
+		"type":    "mfa_challenge",
+		"purpose": purpose,
+		"jti":     uuid.New().String(),
+		"iat":     now.Unix(),
+		"exp":     now.Add(ttl).Unix(),
+		"iss":     Issuer(),
+		"sub":     fmt.Sprintf("%d", userID),
+	})
+}
+
+// ValidateMFAChallengeToken returns the user ID and purpose of a challenge.
+func ValidateMFAChallengeToken(tokenString string) (uint, string, error) {
+	token, err := jwt.Parse(tokenString, verificationKeyFunc)
+	if err != nil {
+		return 0, "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return 0, "", errors.New("invalid MFA token")
+	}
+
+	if tokenType, _ := claims["type"].(string); tokenType != "mfa_challenge" {
+		return 0, "", errors.New("invalid token type")
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, "", errors.New("invalid user_id in token")
+	}
+
+	purpose, _ := claims["purpose"].(string)
+	if purpose != MFAPurposeVerify && purpose != MFAPurposeEnroll {
+		return 0, "", errors.New("invalid MFA token purpose")
 	}
 
-	return 0, errors.New("invalid refresh token")
+	return uint(userID), purpose, nil
 }
 
 func GenerateRandomString(length int) (string, error) {
@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestServiceTokenCarriesNoUserID(t *testing.T) {
+	token, err := GenerateServiceToken(7, "svc_reports", "invoices:read", time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateServiceToken: %v", err)
+	}
+
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+	if claims.UserID != 0 || claims.ServiceAccountID != 7 {
+		t.Errorf("claims user_id = %d, service_account_id = %d, want 0 and 7", claims.UserID, claims.ServiceAccountID)
+	}
+	if claims.IdentityType != "client_credentials" || claims.ClientID != "svc_reports" {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestValidateJWTRejectsAmbiguousPrincipal(t *testing.T) {
+	now := time.Now()
+	base := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"role": "service",
+			"iat":  now.Unix(),
+			"exp":  now.Add(time.Minute).Unix(),
+			"iss":  Issuer(),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		claims func() jwt.MapClaims
+	}{
+		{
+			name: "service token with a user id",
+			claims: func() jwt.MapClaims {
+				c := base()
+				c["user_type"] = "service"
+				c["user_id"] = 7
+				c["service_account_id"] = 7
+				return c
+			},
+		},
+		{
+			name: "service token without an account",
+			claims: func() jwt.MapClaims {
+				c := base()
+				c["user_type"] = "service"
+				c["user_id"] = 7
+				return c
+			},
+		},
+		{
+			name: "user token without a user id",
+			claims: func() jwt.MapClaims {
+				c := base()
+				c["user_type"] = "admin"
+				c["service_account_id"] = 7
+				return c
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := signToken(tt.claims())
+			if err != nil {
+				t.Fatalf("signToken: %v", err)
+			}
+			if _, err := ValidateJWT(token); err == nil {
+				t.Error("ValidateJWT accepted the token")
+			}
+		})
+	}
+}
+
+func TestGenerateJWTRoundTrip(t *testing.T) {
+	in := output.Claims{UserID: 42, UserType: "admin", Role: "admin", ClientID: "dashboard", Scope: "openid", SessionID: "s-1", AuthTime: 1700000000, AMR: []string{AMRPassword}}
+	token, err := GenerateJWT(in)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+	if claims.UserID != 42 || claims.ServiceAccountID != 0 || claims.ClientID != "dashboard" || claims.SessionID != "s-1" || claims.AuthTime != in.AuthTime || len(claims.AMR) != 1 {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestImpersonationTokenActor(t *testing.T) {
+	if _, err := GenerateImpersonationToken(output.Claims{UserID: 7, UserType: "partner", Role: "partner"}, time.Minute); err == nil {
+		t.Error("GenerateImpersonationToken succeeded without an impersonator")
+	}
+
+	token, err := GenerateImpersonationToken(output.Claims{UserID: 7, UserType: "partner", Role: "partner", ImpersonatorID: 1}, time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateImpersonationToken: %v", err)
+	}
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+	if claims.UserID != 7 || claims.ImpersonatorID != 1 || claims.ExpiresAt-claims.IssuedAt != 60 {
+		t.Errorf("claims = %+v, want user 7 acted on by 1 for a minute", claims)
+	}
+
+	tests := []struct {
+		name string
+		act  interface{}
+	}{
+		{name: "non-numeric actor", act: jwt.MapClaims{"sub": "admin"}},
+		{name: "zero actor", act: jwt.MapClaims{"sub": "0"}},
+		{name: "actor without subject", act: jwt.MapClaims{}},
+	}
+	for _, tt := range tests {
+		token, err := signToken(jwt.MapClaims{
+			"user_id":   7,
+			"user_type": "partner",
+			"role":      "partner",
+			"act":       tt.act,
+			"iat":       time.Now().Unix(),
+			"exp":       time.Now().Add(time.Minute).Unix(),
+			"iss":       Issuer(),
+		})
+		if err != nil {
+			t.Fatalf("signToken: %v", err)
+		}
+		if _, err := ValidateJWT(token); err == nil {
+			t.Errorf("%s: ValidateJWT accepted the token", tt.name)
+		}
+	}
+}
+
+func TestGenerateIDToken(t *testing.T) {
+	authTime := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		info    output.OIDCUserInfo
+		nonce   string
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "full profile with nonce",
+			info:    output.OIDCUserInfo{Sub: "7", Email: "a@example.com", EmailVerified: true, PhoneNumber: "+15550100", Name: "alice"},
+			nonce:   "n-0S6_WzA2Mj",
+			present: []string{"email", "phone_number", "name", "nonce"},
+		},
+		{
+			name:   "phone only",
+			info:   output.OIDCUserInfo{Sub: "8", PhoneNumber: "+15550101", PhoneNumberVerified: true},
+			absent: []string{"email", "name", "nonce"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := GenerateIDToken(tt.info, "web", tt.nonce, authTime)
+			if err != nil {
+				t.Fatalf("GenerateIDToken: %v", err)
+			}
+
+			claims := jwt.MapClaims{}
+			if _, err := jwt.ParseWithClaims(token, claims, verificationKeyFunc, jwt.WithAudience("web"), jwt.WithIssuer(Issuer())); err != nil {
+				t.Fatalf("parse ID token: %v", err)
+			}
+
+			if claims["sub"] != tt.info.Sub {
+				t.Errorf("sub = %v, want %s", claims["sub"], tt.info.Sub)
+			}
+			if got, _ := claims["auth_time"].(float64); int64(got) != authTime.Unix() {
+				t.Errorf("auth_time = %v, want %d", claims["auth_time"], authTime.Unix())
+			}
+			if claims["email_verified"] != tt.info.EmailVerified || claims["phone_number_verified"] != tt.info.PhoneNumberVerified {
+				t.Errorf("verified claims = %v, %v", claims["email_verified"], claims["phone_number_verified"])
+			}
+			for _, name := range tt.present {
+				if _, ok := claims[name]; !ok {
+					t.Errorf("claim %s missing", name)
+				}
+			}
+			for _, name := range tt.absent {
+				if _, ok := claims[name]; ok {
+					t.Errorf("empty claim %s included", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"context"
+	"strings"
+)
+
+// Keys under which middleware.ClientInfo stores request metadata. Fiber
+// locals are readable from c.Context() through ctx.Value, so services can
+// look them up without extra parameters.
+const (
+	ClientIPKey       = "client_ip"
+	UserAgentKey      = "user_agent"
+	ClientLanguageKey = "client_language"
+)
+
+// ClientInfo returns the caller's IP address and user agent recorded for the
+// request behind ctx, or empty strings outside a request.
+func ClientInfo(ctx context.Context) (string, string) {
+	if ctx == nil {
+		return "", ""
+	}
+	ip, _ := ctx.Value(ClientIPKey).(string)
+	userAgent, _ := ctx.Value(UserAgentKey).(string)
+	return ip, userAgent
+}
+
+// ClientLanguage returns the caller's preferred language recorded for the
+// request behind ctx, or an empty string outside a request.
+func ClientLanguage(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	language, _ := ctx.Value(ClientLanguageKey).(string)
+	return language
+}
+
+// PreferredLanguage returns the first language tag of an Accept-Language
+// header, lower-cased and without its quality value.
+func PreferredLanguage(acceptLanguage string) string {
+	first, _, _ := strings.Cut(acceptLanguage, ",")
+	tag, _, _ := strings.Cut(first, ";")
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "*" {
+		return ""
+	}
+	return tag
+}
@@ -1,6 +1,12 @@
 package utils
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+)
 
 type HTTPError struct {
 	Code    int    `json:"code"`
@@ -46,9 +52,87 @@ func NewBadRequestError(message string) *HTTPError {
 	}
 }
 
+func NewConflictError(message string) *HTTPError {
+	return &HTTPError{
+		Code:    http.StatusConflict,
+		Message: message,
+	}
+}
+
 func NewInternalServerError(message string) *HTTPError {
 	return &HTTPError{
 		Code:    http.StatusInternalServerError,
 		Message: message,
 	}
 }
+
+// OAuthError is an error response as defined by RFC 6749 section 5.2.
+type OAuthError struct {
+	Status      int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func NewOAuthError(status int, code, description string) *OAuthError {
+	return &OAuthError{
+		Status:      status,
+		Code:        code,
+		Description: description,
+	}
+}
+
+// RateLimitError rejects a request that exceeded a rate limit or targets a
+// locked account. RetryAfter is sent to the client as the Retry-After header.
+type RateLimitError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Message
+}
+
+func NewRateLimitError(message string, retryAfter time.Duration) *RateLimitError {
+	return &RateLimitError{
+		Message:    message,
+		RetryAfter: retryAfter,
+	}
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds, at least one.
+func (e *RateLimitError) RetryAfterSeconds() int {
+	seconds := int((e.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// ValidationError reports every rule a value broke rather than only the
+// first, so clients can show all of them at once.
+type ValidationError struct {
+	Message    string
+	Violations []output.Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return e.Message + ": " + strings.Join(messages, "; ")
+}
+
+func NewValidationError(message string, violations []output.Violation) *ValidationError {
+	return &ValidationError{
+		Message:    message,
+		Violations: violations,
+	}
+}
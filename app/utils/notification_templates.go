@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/bbapp-org/auth-service/app/models"
+)
+
+// Parts of a notification template. Emails need a subject and an HTML body
+// and may have a text body; SMS only use the text body.
+const (
+	templatePartSubject = "subject"
+	templatePartHTML    = "html"
+	templatePartText    = "txt"
+)
+
+// NotificationTemplates renders emails and SMS from files laid out as
+// <name>/<language>.<part>.tmpl. A template missing in the requested
+// language falls back to its base language ("pt" for "pt-br") and then to
+// the default language.
+type NotificationTemplates struct {
+	defaultLanguage string
+	html            map[string]*htmltemplate.Template
+	text            map[string]*texttemplate.Template
+}
+
+// RenderedNotification is a template rendered in Language.
+type RenderedNotification struct {
+	Language string
+	Subject  string
+	HTMLBody string
+	TextBody string
+}
+
+// LoadNotificationTemplates parses every template in fsys up front, so a
+// broken template fails at startup rather than when it is first sent.
+func LoadNotificationTemplates(fsys fs.FS, defaultLanguage string) (*NotificationTemplates, error) {
+	t := &NotificationTemplates{
+		defaultLanguage: strings.ToLower(defaultLanguage),
+		html:            map[string]*htmltemplate.Template{},
+		text:            map[string]*texttemplate.Template{},
+	}
+
+	err := fs.WalkDir(fsys, ".", func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(file) != ".tmpl" {
+			return err
+		}
+
+		name := path.Base(path.Dir(file))
+		language, part, ok := strings.Cut(strings.TrimSuffix(path.Base(file), ".tmpl"), ".")
+		if !ok {
+			return fmt.Errorf("%s: expected <language>.<part>.tmpl", file)
+		}
+		key := templateKey(name, strings.ToLower(language), part)
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+
+		switch part {
+		case templatePartHTML:
+			t.html[key], err = htmltemplate.New(key).Parse(string(content))
+		case templatePartSubject, templatePartText:
+			t.text[key], err = texttemplate.New(key).Parse(string(content))
+		default:
+			return fmt.Errorf("%s: unknown template part %q", file, part)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Render renders template name for channel in the best available language.
+func (t *NotificationTemplates) Render(name string, channel models.NotificationChannel, language string, data map[string]string) (*RenderedNotification, error) {
+	for _, candidate := range t.languages(language) {
+		rendered, ok, err := t.render(name, channel, candidate, data)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return rendered, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s template %q", channel, name)
+}
+
+// render reports false when the parts channel needs are missing in language.
+func (t *NotificationTemplates) render(name string, channel models.NotificationChannel, language string, data map[string]string) (*RenderedNotification, bool, error) {
+	subject := t.text[templateKey(name, language, templatePartSubject)]
+	html := t.html[templateKey(name, language, templatePartHTML)]
+	text := t.text[templateKey(name, language, templatePartText)]
+
+	if channel == models.NotificationChannelSMS && text == nil {
+		return nil, false, nil
+	}
+	if channel == models.NotificationChannelEmail && (subject == nil || html == nil) {
+		return nil, false, nil
+	}
+
+	rendered := &RenderedNotification{Language: language}
+	var b strings.Builder
+
+	if channel == models.NotificationChannelEmail {
+		if err := subject.Execute(&b, data); err != nil {
+			return nil, false, err
+		}
+		rendered.Subject = strings.TrimSpace(b.String())
+		b.Reset()
+
+		if err := html.Execute(&b, data); err != nil {
+			return nil, false, err
+		}
+		rendered.HTMLBody = b.String()
+		b.Reset()
+	}
+
+	if text != nil {
+		if err := text.Execute(&b, data); err != nil {
+			return nil, false, err
+		}
+		rendered.TextBody = strings.TrimSpace(b.String())
+	}
+
+	return rendered, true, nil
+}
+
+// languages lists language, its base language and the default language, in
+// that order and without repeats.
+func (t *NotificationTemplates) languages(language string) []string {
+	language = strings.ToLower(language)
+	base, _, _ := strings.Cut(language, "-")
+
+	var candidates []string
+	for _, candidate := range []string{language, base, t.defaultLanguage} {
+		if candidate != "" && !slices.Contains(candidates, candidate) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+func templateKey(name, language, part string) string {
+	return name + "/" + language + "." + part
+}
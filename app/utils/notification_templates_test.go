@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/bbapp-org/auth-service/app/models"
+)
+
+func TestNotificationTemplatesRender(t *testing.T) {
+	templates, err := LoadNotificationTemplates(fstest.MapFS{
+		"otp/en.subject.tmpl": {Data: []byte("Your code\n")},
+		"otp/en.html.tmpl":    {Data: []byte("<p>{{.otp}}</p>")},
+		"otp/en.txt.tmpl":     {Data: []byte("Your code is {{.otp}}.\n")},
+		"otp/pt.subject.tmpl": {Data: []byte("Seu código")},
+		"otp/pt.html.tmpl":    {Data: []byte("<p>{{.otp}}</p>")},
+		"welcome/en.txt.tmpl": {Data: []byte("Welcome")},
+		"README.md":           {Data: []byte("not a template")},
+	}, "EN")
+	if err != nil {
+		t.Fatalf("LoadNotificationTemplates: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		template     string
+		channel      models.NotificationChannel
+		language     string
+		otp          string
+		wantErr      bool
+		wantLanguage string
+		wantSubject  string
+		wantHTML     string
+		wantText     string
+	}{
+		{name: "email", template: "otp", channel: models.NotificationChannelEmail, language: "en", otp: "123456", wantLanguage: "en", wantSubject: "Your code", wantHTML: "<p>123456</p>", wantText: "Your code is 123456."},
+		{name: "sms", template: "otp", channel: models.NotificationChannelSMS, language: "en", otp: "123456", wantLanguage: "en", wantText: "Your code is 123456."},
+		{name: "regional language falls back to its base", template: "otp", channel: models.NotificationChannelEmail, language: "pt-BR", otp: "123456", wantLanguage: "pt", wantSubject: "Seu código", wantHTML: "<p>123456</p>"},
+		{name: "sms missing in the language", template: "otp", channel: models.NotificationChannelSMS, language: "pt", otp: "123456", wantLanguage: "en", wantText: "Your code is 123456."},
+		{name: "unknown language", template: "otp", channel: models.NotificationChannelSMS, language: "fr", otp: "123456", wantLanguage: "en", wantText: "Your code is 123456."},
+		{name: "no language", template: "otp", channel: models.NotificationChannelSMS, otp: "123456", wantLanguage: "en", wantText: "Your code is 123456."},
+		{name: "html escaped", template: "otp", channel: models.NotificationChannelEmail, language: "en", otp: "<b>", wantLanguage: "en", wantSubject: "Your code", wantHTML: "<p>&lt;b&gt;</p>", wantText: "Your code is <b>."},
+		{name: "email without subject and html", template: "welcome", channel: models.NotificationChannelEmail, language: "en", wantErr: true},
+		{name: "unknown template", template: "missing", channel: models.NotificationChannelSMS, language: "en", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := templates.Render(tt.template, tt.channel, tt.language, map[string]string{"otp": tt.otp})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want := RenderedNotification{Language: tt.wantLanguage, Subject: tt.wantSubject, HTMLBody: tt.wantHTML, TextBody: tt.wantText}
+			if *rendered != want {
+				t.Errorf("Render = %+v, want %+v", *rendered, want)
+			}
+		})
+	}
+}
+
+func TestLoadNotificationTemplatesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{name: "missing part", fsys: fstest.MapFS{"otp/en.tmpl": {Data: []byte("x")}}},
+		{name: "unknown part", fsys: fstest.MapFS{"otp/en.body.tmpl": {Data: []byte("x")}}},
+		{name: "broken template", fsys: fstest.MapFS{"otp/en.txt.tmpl": {Data: []byte("{{.otp")}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := LoadNotificationTemplates(tt.fsys, "en"); err == nil {
+			t.Errorf("%s: LoadNotificationTemplates succeeded", tt.name)
+		}
+	}
+}
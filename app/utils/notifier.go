@@ -0,0 +1,319 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/models"
+)
+
+// Notification is a rendered message ready for delivery. Params carries the
+// values it was rendered from for providers that format messages themselves.
+type Notification struct {
+	Channel  models.NotificationChannel
+	To       string
+	Template string
+	Subject  string
+	HTMLBody string
+	TextBody string
+	Params   map[string]string
+}
+
+// Notifier delivers notifications. Send returning nil means the provider
+// accepted the message; any error is retried by the caller.
+type Notifier interface {
+	Send(ctx context.Context, notification *Notification) error
+}
+
+// NewNotifier builds the notifier for each channel from cfg.EmailProvider
+// and cfg.SMSProvider.
+func NewNotifier(cfg input.NotificationConfig) (Notifier, error) {
+	if cfg.SMSProvider == "smtp" {
+		return nil, fmt.Errorf("sms provider: smtp cannot deliver sms")
+	}
+
+	email, err := newProviderNotifier(cfg.EmailProvider, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("email provider: %w", err)
+	}
+
+	sms := email
+	if cfg.SMSProvider != cfg.EmailProvider {
+		sms, err = newProviderNotifier(cfg.SMSProvider, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("sms provider: %w", err)
+		}
+	}
+
+	return channelNotifier{
+		models.NotificationChannelEmail: email,
+		models.NotificationChannelSMS:   sms,
+	}, nil
+}
+
+func newProviderNotifier(provider string, cfg input.NotificationConfig) (Notifier, error) {
+	switch provider {
+	case "http":
+		return NewHTTPNotifier(cfg.ServiceURL, 30*time.Second), nil
+	case "smtp":
+		if cfg.SMTPHost == "" || cfg.SMTPFrom == "" {
+			return nil, fmt.Errorf("SMTP_HOST and SMTP_FROM are required")
+		}
+		return NewSMTPNotifier(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom), nil
+	case "console":
+		return NewWriterNotifier(os.Stdout), nil
+	case "file":
+		file, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", cfg.FilePath, err)
+		}
+		return NewWriterNotifier(file), nil
+	case "memory":
+		return NewMemoryNotifier(), nil
+	}
+	return nil, fmt.Errorf("unknown notification provider %q", provider)
+}
+
+// channelNotifier hands each notification to the notifier for its channel.
+type channelNotifier map[models.NotificationChannel]Notifier
+
+func (n channelNotifier) Send(ctx context.Context, notification *Notification) error {
+	notifier, ok := n[notification.Channel]
+	if !ok {
+		return fmt.Errorf("no notifier for channel %q", notification.Channel)
+	}
+	return notifier.Send(ctx, notification)
+}
+
+// HTTPNotifier posts notifications to the notification service.
+type HTTPNotifier struct {
+	client  *http.Client
+	baseURL string
+}
+
+func NewHTTPNotifier(baseURL string, timeout time.Duration) *HTTPNotifier {
+	return &HTTPNotifier{
+		client:  &http.Client{Timeout: timeout},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+type notificationServiceEmail struct {
+	ToAddress string `json:"to_address"`
+	Subject   string `json:"subject"`
+	HtmlBody  string `json:"html_body"`
+	TextBody  string `json:"text_body,omitempty"`
+}
+
+// notificationServiceSMS keeps the otp field the notification service
+// formats OTP texts from, alongside the rendered message.
+type notificationServiceSMS struct {
+	ToPhone string `json:"to_phone"`
+	OTP     string `json:"otp,omitempty"`
+	Message string `json:"message"`
+}
+
+func (n *HTTPNotifier) Send(ctx context.Context, notification *Notification) error {
+	path, payload := "/email/send", interface{}(notificationServiceEmail{
+		ToAddress: notification.To,
+		Subject:   notification.Subject,
+		HtmlBody:  notification.HTMLBody,
+		TextBody:  notification.TextBody,
+	})
+	if notification.Channel == models.NotificationChannelSMS {
+		path, payload = "/sms/send", notificationServiceSMS{
+			ToPhone: notification.To,
+			OTP:     notification.Params["otp"],
+			Message: notification.TextBody,
+		}
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", notification.Channel, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.baseURL+path, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s request: %w", notification.Channel, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("notification service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// SMTPNotifier sends email through an SMTP server, using STARTTLS when the
+// server offers it. It cannot send SMS.
+type SMTPNotifier struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPNotifier(host string, port int, username, password, from string) *SMTPNotifier {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPNotifier{
+		addr: fmt.Sprintf("%s:%d", host, port),
+		host: host,
+		auth: auth,
+		from: from,
+	}
+}
+
+func (n *SMTPNotifier) Send(ctx context.Context, notification *Notification) error {
+	if notification.Channel != models.NotificationChannelEmail {
+		return fmt.Errorf("smtp cannot deliver %s", notification.Channel)
+	}
+
+	message, err := n.buildMessage(notification)
+	if err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(n.addr, n.auth, n.from, []string{notification.To}, message); err != nil {
+		return fmt.Errorf("failed to send email via %s: %w", n.host, err)
+	}
+	return nil
+}
+
+// buildMessage writes a multipart/alternative message with the text body,
+// when there is one, ahead of the HTML body.
+func (n *SMTPNotifier) buildMessage(notification *Notification) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	parts := []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", notification.TextBody},
+		{"text/html; charset=UTF-8", notification.HTMLBody},
+	}
+	for _, part := range parts {
+		if part.content == "" {
+			continue
+		}
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", n.from)
+	fmt.Fprintf(&message, "To: %s\r\n", notification.To)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", notification.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Message-ID: <%s@%s>\r\n", randomMessageID(), n.host)
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", writer.Boundary())
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+func randomMessageID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// WriterNotifier writes each notification as a JSON line, for development.
+type WriterNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{w: w}
+}
+
+func (n *WriterNotifier) Send(ctx context.Context, notification *Notification) error {
+	line, err := json.Marshal(struct {
+		Time     time.Time                  `json:"time"`
+		Channel  models.NotificationChannel `json:"channel"`
+		To       string                     `json:"to"`
+		Template string                     `json:"template"`
+		Subject  string                     `json:"subject,omitempty"`
+		Text     string                     `json:"text,omitempty"`
+		HTML     string                     `json:"html,omitempty"`
+	}{time.Now(), notification.Channel, notification.To, notification.Template, notification.Subject, notification.TextBody, notification.HTMLBody})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err = n.w.Write(append(line, '\n'))
+	return err
+}
+
+// MemoryNotifier keeps every notification it is given, for tests.
+type MemoryNotifier struct {
+	mu   sync.Mutex
+	sent []Notification
+}
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+func (n *MemoryNotifier) Send(ctx context.Context, notification *Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, *notification)
+	return nil
+}
+
+// Sent returns the notifications received so far, oldest first.
+func (n *MemoryNotifier) Sent() []Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Notification(nil), n.sent...)
+}
+
+func (n *MemoryNotifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = nil
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+)
+
+// bcryptMaxLength is the number of bytes bcrypt looks at; longer passwords
+// are rejected rather than silently truncated.
+const bcryptMaxLength = 72
+
+// PasswordPolicy checks the composition of new passwords. Reuse is checked by
+// the caller, which has access to the user's password history.
+type PasswordPolicy struct {
+	config   input.PasswordPolicyConfig
+	breached map[string]struct{}
+}
+
+// NewPasswordPolicy loads the breached password list, if configured, into
+// memory.
+func NewPasswordPolicy(cfg input.PasswordPolicyConfig) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		config:   cfg,
+		breached: map[string]struct{}{},
+	}
+
+	if cfg.BreachedListFile == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(cfg.BreachedListFile)
+	if err != nil {
+		return nil, fmt.Errorf("breached password list: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		policy.breached[breachedListKey(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("breached password list: %w", err)
+	}
+
+	return policy, nil
+}
+
+// breachedListKey turns a list entry into an upper-case SHA-1 hex digest.
+// Entries already in that form, with or without a ":count" suffix, are kept.
+func breachedListKey(line string) string {
+	hash := line
+	if i := strings.IndexByte(hash, ':'); i == 40 {
+		hash = hash[:i]
+	}
+	if len(hash) == 40 {
+		if _, err := hex.DecodeString(hash); err == nil {
+			return strings.ToUpper(hash)
+		}
+	}
+	return passwordSHA1(line)
+}
+
+func passwordSHA1(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// HistorySize is how many previous passwords may not be reused.
+func (p *PasswordPolicy) HistorySize() int {
+	return p.config.HistorySize
+}
+
+// Check returns every composition rule the password breaks.
+func (p *PasswordPolicy) Check(password string) []output.Violation {
+	var violations []output.Violation
+
+	if length := utf8.RuneCountInString(password); length < p.config.MinLength {
+		violations = append(violations, output.Violation{
+			Rule:    "min_length",
+			Message: fmt.Sprintf("password must be at least %d characters long", p.config.MinLength),
+		})
+	}
+	if len(password) > bcryptMaxLength {
+		violations = append(violations, output.Violation{
+			Rule:    "max_length",
+			Message: fmt.Sprintf("password must be at most %d bytes long", bcryptMaxLength),
+		})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+
+	if p.config.RequireUpper && !upper {
+		violations = append(violations, output.Violation{Rule: "uppercase", Message: "password must contain an uppercase letter"})
+	}
+	if p.config.RequireLower && !lower {
+		violations = append(violations, output.Violation{Rule: "lowercase", Message: "password must contain a lowercase letter"})
+	}
+	if p.config.RequireDigit && !digit {
+		violations = append(violations, output.Violation{Rule: "digit", Message: "password must contain a digit"})
+	}
+	if p.config.RequireSymbol && !symbol {
+		violations = append(violations, output.Violation{Rule: "symbol", Message: "password must contain a symbol"})
+	}
+
+	if _, found := p.breached[passwordSHA1(password)]; found {
+		violations = append(violations, output.Violation{
+			Rule:    "breached",
+			Message: "password appears in a list of compromised passwords",
+		})
+	}
+
+	return violations
+}
+
+// IsExpired reports whether a password set at changedAt is older than the
+// maximum age.
+func (p *PasswordPolicy) IsExpired(changedAt time.Time, now time.Time) bool {
+	return p.config.MaxAge > 0 && now.Sub(changedAt) > p.config.MaxAge
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	const comment = "# plain passwords and SHA-1 digests, as published with counts"
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	entries := []string{
+		comment,
+		"Summer2024!",
+		"",
+		strings.ToLower(passwordSHA1("Winter2024!")) + ":5821",
+	}
+	if err := os.WriteFile(list, []byte(strings.Join(entries, "\n")), 0o600); err != nil {
+		t.Fatalf("write list: %v", err)
+	}
+
+	policy, err := NewPasswordPolicy(input.PasswordPolicyConfig{
+		MinLength:        10,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		BreachedListFile: list,
+	})
+	if err != nil {
+		t.Fatalf("NewPasswordPolicy: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		password  string
+		wantRules []string
+	}{
+		{name: "compliant", password: "Correct-Horse-9"},
+		{name: "every class missing", password: "", wantRules: []string{"min_length", "uppercase", "lowercase", "digit", "symbol"}},
+		{name: "too short", password: "Ab1!", wantRules: []string{"min_length"}},
+		{name: "length counts characters", password: "Äbcdéfgh1!"},
+		{name: "beyond bcrypt's limit", password: "Aa1!" + strings.Repeat("x", 69), wantRules: []string{"max_length"}},
+		{name: "no symbol", password: "CorrectHorse9", wantRules: []string{"symbol"}},
+		{name: "breached in plain text", password: "Summer2024!", wantRules: []string{"breached"}},
+		{name: "breached by digest", password: "Winter2024!", wantRules: []string{"breached"}},
+		{name: "comment line is not a password", password: comment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []string
+			for _, violation := range policy.Check(tt.password) {
+				rules = append(rules, violation.Rule)
+			}
+			if !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("Check(%q) rules = %v, want %v", tt.password, rules, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyMissingBreachedList(t *testing.T) {
+	if _, err := NewPasswordPolicy(input.PasswordPolicyConfig{BreachedListFile: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("NewPasswordPolicy accepted a missing breached password list")
+	}
+}
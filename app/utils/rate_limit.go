@@ -0,0 +1,41 @@
+package utils
+
+import "time"
+
+// RateLimit allows Limit attempts per sliding Window. The sliding window is
+// approximated from two fixed windows: the count of the previous window is
+// weighted by how much of it still overlaps the sliding window.
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// WindowStart returns the start of the fixed window containing now.
+func (l RateLimit) WindowStart(now time.Time) time.Time {
+	return now.Truncate(l.Window)
+}
+
+// Allow reports whether the attempts counted so far, including the current
+// one, fit the limit. elapsed is the time since the current window started.
+// When they do not, it also returns how long until another attempt would
+// be allowed.
+func (l RateLimit) Allow(previous, current int, elapsed time.Duration) (bool, time.Duration) {
+	window := float64(l.Window)
+	remaining := l.Window - elapsed
+	weight := float64(remaining) / window
+
+	if float64(previous)*weight+float64(current) <= float64(l.Limit) {
+		return true, 0
+	}
+
+	// Another attempt fits once the previous window's share has decayed
+	// enough, or, if the current window alone is over the limit, once the
+	// current window has become the previous one and decayed in turn.
+	if current < l.Limit && previous > 0 {
+		fits := float64(l.Limit-current-1) / float64(previous)
+		return false, remaining - time.Duration(fits*window)
+	}
+
+	fits := float64(l.Limit-1) / float64(current)
+	return false, remaining + l.Window - time.Duration(fits*window)
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitAllow(t *testing.T) {
+	limit := RateLimit{Limit: 10, Window: time.Minute}
+
+	tests := []struct {
+		name     string
+		previous int
+		current  int
+		elapsed  time.Duration
+		want     bool
+		wantWait time.Duration
+	}{
+		{name: "at the limit", current: 10, elapsed: 30 * time.Second, want: true},
+		{name: "over the limit in the current window", current: 11, elapsed: 30 * time.Second, wantWait: 30*time.Second + time.Minute - time.Minute*9/11},
+		{name: "previous window half decayed", previous: 10, current: 5, elapsed: 30 * time.Second, want: true},
+		{name: "previous window not decayed enough", previous: 10, current: 6, elapsed: 30 * time.Second, wantWait: 12 * time.Second},
+		{name: "previous window fully decayed", previous: 100, current: 1, elapsed: time.Minute, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, wait := limit.Allow(tt.previous, tt.current, tt.elapsed)
+			if allowed != tt.want {
+				t.Fatalf("Allow = %v, want %v", allowed, tt.want)
+			}
+			if diff := wait - tt.wantWait; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("wait = %v, want %v", wait, tt.wantWait)
+			}
+
+			// Waiting as long as told lets the next attempt through.
+			if !allowed {
+				elapsed := tt.elapsed + wait
+				previous, current := tt.previous, tt.current
+				if elapsed >= limit.Window {
+					elapsed -= limit.Window
+					previous, current = current, 0
+				}
+				if ok, _ := limit.Allow(previous, current+1, elapsed+time.Millisecond); !ok {
+					t.Errorf("attempt after waiting %v refused", wait)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitErrorRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		want       int
+	}{
+		{retryAfter: 0, want: 1},
+		{retryAfter: 200 * time.Millisecond, want: 1},
+		{retryAfter: time.Second, want: 1},
+		{retryAfter: 1500 * time.Millisecond, want: 2},
+		{retryAfter: 2 * time.Minute, want: 120},
+	}
+
+	for _, tt := range tests {
+		if got := NewRateLimitError("", tt.retryAfter).RetryAfterSeconds(); got != tt.want {
+			t.Errorf("RetryAfterSeconds(%v) = %d, want %d", tt.retryAfter, got, tt.want)
+		}
+	}
+}
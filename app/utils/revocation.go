@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"errors"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+)
+
+// ErrTokenRevoked is returned by ValidateJWT for a token that is otherwise
+// valid but has been revoked.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationChecker reports whether the token described by claims has been
+// revoked.
+type RevocationChecker func(claims *output.Claims) bool
+
+var revocationChecker RevocationChecker
+
+// SetRevocationChecker installs the lookup used by ValidateJWT. It is called
+// once while routes are set up.
+func SetRevocationChecker(checker RevocationChecker) {
+	revocationChecker = checker
+}
+
+func IsTokenRevoked(claims *output.Claims) bool {
+	if revocationChecker == nil {
+		return false
+	}
+	return revocationChecker(claims)
+}
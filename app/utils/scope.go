@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+)
+
+// ResourceAction maps a request onto the "<resource>:<action>" scope that
+// guards it: the first path segment is the resource, and GET/HEAD requests
+// read while everything else writes.
+func ResourceAction(path, method string) (string, string) {
+	path = strings.SplitN(path, "?", 2)[0]
+	resource := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+
+	action := "write"
+	if method == "GET" || method == "HEAD" {
+		action = "read"
+	}
+	return resource, action
+}
+
+// ScopeAllows reports whether a space separated scope string grants action
+// on resource.
+func ScopeAllows(scope, resource, action string) bool {
+	return PermissionAllows(strings.Fields(scope), resource+":"+action)
+}
+
+// PermissionAllows reports whether any of granted covers permission. "*" and
+// "<resource>:*" act as wildcards.
+func PermissionAllows(granted []string, permission string) bool {
+	resource, _, _ := strings.Cut(permission, ":")
+	for _, g := range granted {
+		if g == "*" || g == resource+":*" || g == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminScope lets a token issued to an OAuth client act as its user's user
+// type. A client only gets it when it was registered with it explicitly.
+const AdminScope = "admin"
+
+// TokenRevokeScope lets a service account revoke tokens issued to other
+// clients. RFC 7009 otherwise limits a client to revoking its own tokens.
+const TokenRevokeScope = "tokens:revoke"
+
+// ActingUserType returns the user type that user-type checks, such as the
+// admin route middlewares and policy user_types grants, should see for
+// claims. A token issued to an OAuth client acts as no user type unless it
+// was granted AdminScope, so a client a superadmin once signed in to with
+// "openid" alone cannot reach the admin routes.
+func ActingUserType(claims *output.Claims) string {
+	if claims.ClientID != "" && claims.UserType != "service" && !slices.Contains(strings.Fields(claims.Scope), AdminScope) {
+		return ""
+	}
+	return claims.UserType
+}
+
+// PermissionResolver returns the permissions granted to a role.
+type PermissionResolver func(role string) ([]string, error)
+
+var permissionResolver PermissionResolver
+
+// SetPermissionResolver installs the lookup used by HasPermission. It is
+// called once while routes are set up.
+func SetPermissionResolver(resolver PermissionResolver) {
+	permissionResolver = resolver
+}
+
+// HasPermission reports whether the bearer of claims holds permission.
+// Service accounts are limited to their token scope. API keys and tokens
+// issued to OAuth clients need both the user's role permission and a
+// matching scope, so a client holding only OIDC scopes such as "openid
+// email" reaches no resource.
+func HasPermission(claims *output.Claims, permission string) (bool, error) {
+	granted := strings.Fields(claims.Scope)
+
+	if claims.UserType == "service" {
+		return PermissionAllows(granted, permission), nil
+	}
+
+	if permissionResolver == nil {
+		return false, errors.New("permission resolver is not configured")
+	}
+
+	rolePermissions, err := permissionResolver(claims.Role)
+	if err != nil {
+		return false, err
+	}
+	if !PermissionAllows(rolePermissions, permission) {
+		return false, nil
+	}
+
+	if claims.IdentityType == "api_key" || claims.ClientID != "" {
+		return PermissionAllows(granted, permission), nil
+	}
+	return true, nil
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/bbapp-org/auth-service/app/dto/output"
+)
+
+func TestPermissionAllows(t *testing.T) {
+	tests := []struct {
+		name       string
+		granted    []string
+		permission string
+		want       bool
+	}{
+		{name: "exact", granted: []string{"invoices:write"}, permission: "invoices:write", want: true},
+		{name: "other action", granted: []string{"invoices:read"}, permission: "invoices:write"},
+		{name: "resource wildcard", granted: []string{"invoices:*"}, permission: "invoices:delete", want: true},
+		{name: "global wildcard", granted: []string{"*"}, permission: "users:delete", want: true},
+		{name: "resource wildcard is not a prefix", granted: []string{"items:*"}, permission: "item-groups:read"},
+		{name: "resource wildcard on another resource", granted: []string{"items:*"}, permission: "invoices:read"},
+		{name: "action wildcard is not supported", granted: []string{"*:read"}, permission: "invoices:read"},
+		{name: "bare resource", granted: []string{"invoices"}, permission: "invoices:read"},
+		{name: "nothing granted", permission: "invoices:read"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PermissionAllows(tt.granted, tt.permission); got != tt.want {
+				t.Errorf("PermissionAllows(%v, %s) = %v, want %v", tt.granted, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	SetPermissionResolver(func(role string) ([]string, error) {
+		switch role {
+		case "superadmin":
+			return []string{"*"}, nil
+		case "partner":
+			return []string{"companies:*", "payments:read"}, nil
+		}
+		return nil, nil
+	})
+	t.Cleanup(func() { SetPermissionResolver(nil) })
+
+	tests := []struct {
+		name       string
+		claims     output.Claims
+		permission string
+		want       bool
+	}{
+		{name: "role grants", claims: output.Claims{Role: "partner"}, permission: "companies:write", want: true},
+		{name: "role denies", claims: output.Claims{Role: "partner"}, permission: "payments:delete"},
+		{name: "superadmin wildcard", claims: output.Claims{Role: "superadmin"}, permission: "users:delete", want: true},
+		{name: "service scope grants", claims: output.Claims{UserType: "service", Scope: "payments:read"}, permission: "payments:read", want: true},
+		{name: "service scope denies", claims: output.Claims{UserType: "service", Scope: "payments:read"}, permission: "payments:write"},
+		{name: "api key needs scope", claims: output.Claims{Role: "partner", IdentityType: "api_key", Scope: "companies:read"}, permission: "companies:write"},
+		{name: "api key within scope", claims: output.Claims{Role: "partner", IdentityType: "api_key", Scope: "companies:*"}, permission: "companies:write", want: true},
+		{name: "api key beyond role", claims: output.Claims{Role: "partner", IdentityType: "api_key", Scope: "payments:delete"}, permission: "payments:delete"},
+		{name: "oauth client with oidc scopes", claims: output.Claims{Role: "superadmin", ClientID: "dashboard", Scope: "openid email"}, permission: "users:read"},
+		{name: "oauth client within scope", claims: output.Claims{Role: "superadmin", ClientID: "dashboard", Scope: "openid users:read"}, permission: "users:read", want: true},
+		{name: "oauth client beyond role", claims: output.Claims{Role: "partner", ClientID: "dashboard", Scope: "payments:delete"}, permission: "payments:delete"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HasPermission(&tt.claims, tt.permission)
+			if err != nil {
+				t.Fatalf("HasPermission: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("HasPermission(%s) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActingUserType(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims output.Claims
+		want   string
+	}{
+		{name: "direct login", claims: output.Claims{UserType: "superadmin"}, want: "superadmin"},
+		{name: "oauth client with oidc scopes", claims: output.Claims{UserType: "superadmin", ClientID: "dashboard", Scope: "openid email"}},
+		{name: "oauth client with resource scopes", claims: output.Claims{UserType: "admin", ClientID: "dashboard", Scope: "users:*"}},
+		{name: "oauth client with admin scope", claims: output.Claims{UserType: "superadmin", ClientID: "dashboard", Scope: "openid admin"}, want: "superadmin"},
+		{name: "admin is a whole scope", claims: output.Claims{UserType: "superadmin", ClientID: "dashboard", Scope: "admins:read"}},
+		{name: "service account", claims: output.Claims{UserType: "service", ClientID: "svc_reports", Scope: "invoices:read"}, want: "service"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ActingUserType(&tt.claims); got != tt.want {
+				t.Errorf("ActingUserType = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -11,6 +11,27 @@ import (
 func SeedInitialData(db *gorm.DB) error {
 	log.Println("Seeding initial data...")
 
+	// Roles keep any permissions ops have configured; defaults only fill
+	// roles that are missing or have never been given permissions.
+	for roleName, permissions := range models.DefaultRolePermissions {
+		var existing models.Role
+		err := db.Where("role_name = ?", roleName).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			role := models.Role{
+				RoleName:    roleName,
+				Permissions: models.StringArray(permissions),
+				IsActive:    true,
+			}
+			if err := db.Create(&role).Error; err != nil {
+				log.Printf("Failed to create role %s: %v", roleName, err)
+			}
+		} else if err == nil && len(existing.Permissions) == 0 {
+			if err := db.Model(&existing).Update("permissions", models.StringArray(permissions)).Error; err != nil {
+				log.Printf("Failed to seed permissions for role %s: %v", roleName, err)
+			}
+		}
+	}
+
 	businessTypes := []models.BusinessType{
 		{
 			TypeName:    "Retail",
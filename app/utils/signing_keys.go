@@ -0,0 +1,617 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+	"github.com/bbapp-org/auth-service/app/dto/output"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type SigningKey struct {
+	KID        string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+	RetiresAt  *time.Time
+	path       string
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k *SigningKey) retired(now time.Time) bool {
+	return k.RetiresAt != nil && now.After(*k.RetiresAt)
+}
+
+// KeySet holds the active signing key and the previous keys that are still
+// accepted for verification. Keys come from PEM files, a key directory shared
+// between replicas, or are generated in memory.
+type KeySet struct {
+	mu         sync.RWMutex
+	cfg        input.JWTConfig
+	active     *SigningKey
+	previous   []*SigningKey
+	lastReload time.Time
+}
+
+var (
+	signingKeys   *KeySet
+	signingKeysMu sync.Mutex
+
+	tokenIssuer     = "github.com/bbapp-org/auth-service"
+	idTokenAudience = "bbapp"
+)
+
+// InitSigningKeys loads the signing keys described by cfg and starts the
+// rotation schedule. It must run before any token is issued.
+func InitSigningKeys(cfg input.JWTConfig) error {
+	ks, err := NewKeySet(cfg)
+	if err != nil {
+		return err
+	}
+
+	signingKeysMu.Lock()
+	signingKeys = ks
+	if cfg.Issuer != "" {
+		tokenIssuer = cfg.Issuer
+	}
+	if cfg.IDTokenAudience != "" {
+		idTokenAudience = cfg.IDTokenAudience
+	}
+	signingKeysMu.Unlock()
+
+	if cfg.PrivateKeyFile == "" && cfg.RotationInterval > 0 {
+		go ks.startRotation()
+	}
+	return nil
+}
+
+// Issuer is the iss claim stamped on every token and advertised in the
+// OpenID Connect discovery document.
+func Issuer() string {
+	return tokenIssuer
+}
+
+// DefaultIDTokenAudience is the aud of ID tokens issued outside an OAuth
+// client flow, e.g. by the direct /auth/login endpoints.
+func DefaultIDTokenAudience() string {
+	return idTokenAudience
+}
+
+// GetKeySet returns the process-wide key set, generating an in-memory key
+// on first use when InitSigningKeys was never called.
+func GetKeySet() (*KeySet, error) {
+	signingKeysMu.Lock()
+	defer signingKeysMu.Unlock()
+
+	if signingKeys == nil {
+		ks, err := NewKeySet(input.JWTConfig{SigningAlgorithm: "RS256"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		signingKeys = ks
+	}
+	return signingKeys, nil
+}
+
+func NewKeySet(cfg input.JWTConfig) (*KeySet, error) {
+	if cfg.SigningAlgorithm == "" {
+		cfg.SigningAlgorithm = "RS256"
+	}
+	if !isSupportedAlgorithm(cfg.SigningAlgorithm) {
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", cfg.SigningAlgorithm)
+	}
+
+	ks := &KeySet{cfg: cfg}
+
+	switch {
+	case cfg.PrivateKeyFile != "":
+		if err := ks.loadFiles(); err != nil {
+			return nil, err
+		}
+	case cfg.KeyDir != "":
+		if err := os.MkdirAll(cfg.KeyDir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create key directory: %w", err)
+		}
+		if err := ks.reloadDir(); err != nil {
+			return nil, err
+		}
+		if ks.active == nil {
+			if err := ks.rotateDir(); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		log.Printf("JWT: no JWT_PRIVATE_KEY_FILE or JWT_KEY_DIR configured, generating an in-memory %s key; tokens will not survive restarts or be shared across replicas", cfg.SigningAlgorithm)
+		key, err := generateSigningKey(cfg.SigningAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		ks.active = key
+	}
+
+	log.Printf("JWT: signing with %s key %s", ks.active.Algorithm, ks.active.KID)
+	return ks, nil
+}
+
+func (ks *KeySet) loadFiles() error {
+	key, err := loadSigningKey(ks.cfg.PrivateKeyFile, ks.cfg.SigningAlgorithm)
+	if err != nil {
+		return err
+	}
+	ks.active = key
+
+	for _, path := range ks.cfg.PreviousKeyFiles {
+		prev, err := loadSigningKey(path, "")
+		if err != nil {
+			return err
+		}
+		ks.previous = append(ks.previous, prev)
+	}
+	return nil
+}
+
+// reloadDir rebuilds the key set from the key directory. The newest key
+// signs; each older key stays valid for KeyRetention after the key that
+// superseded it was created, and is deleted once retired.
+func (ks *KeySet) reloadDir() error {
+	paths, err := filepath.Glob(filepath.Join(ks.cfg.KeyDir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	var keys []*SigningKey
+	for _, path := range paths {
+		key, err := loadSigningKey(path, "")
+		if err != nil {
+			log.Printf("JWT: skipping unreadable key %s: %v", path, err)
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			key.CreatedAt = info.ModTime()
+		}
+		key.path = path
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	now := time.Now()
+	if len(keys) == 0 && ks.active != nil {
+		// Keep signing with the keys already loaded rather than failing
+		// every token while the directory is unreadable.
+		log.Printf("JWT: no readable key in %s, keeping %s", ks.cfg.KeyDir, ks.active.KID)
+		ks.lastReload = now
+		return nil
+	}
+
+	var active *SigningKey
+	var previous []*SigningKey
+	for i, key := range keys {
+		if i == 0 {
+			active = key
+			continue
+		}
+		retiresAt := keys[i-1].CreatedAt.Add(ks.cfg.KeyRetention)
+		key.RetiresAt = &retiresAt
+		if key.retired(now) {
+			if err := os.Remove(key.path); err != nil {
+				log.Printf("JWT: failed to delete retired key %s: %v", key.path, err)
+			}
+			continue
+		}
+		previous = append(previous, key)
+	}
+
+	ks.active = active
+	ks.previous = previous
+	ks.lastReload = now
+	return nil
+}
+
+// Rotate makes a freshly generated key the signing key. The old key keeps
+// verifying tokens for the configured retention period.
+func (ks *KeySet) Rotate() (*SigningKey, error) {
+	if ks.cfg.PrivateKeyFile != "" {
+		return nil, errors.New("keys loaded from JWT_PRIVATE_KEY_FILE are rotated by replacing the file")
+	}
+
+	key, err := generateSigningKey(ks.cfg.SigningAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.cfg.KeyDir != "" {
+		if err := writeSigningKey(filepath.Join(ks.cfg.KeyDir, key.KID+".pem"), key); err != nil {
+			return nil, err
+		}
+		if err := ks.reloadDir(); err != nil {
+			return nil, err
+		}
+	} else {
+		if ks.active != nil {
+			retiresAt := key.CreatedAt.Add(ks.cfg.KeyRetention)
+			ks.active.RetiresAt = &retiresAt
+			ks.previous = append([]*SigningKey{ks.active}, ks.previous...)
+		}
+		ks.active = key
+		ks.pruneRetired()
+	}
+
+	log.Printf("JWT: rotated signing key, now signing with %s", ks.active.KID)
+	return ks.active, nil
+}
+
+func (ks *KeySet) pruneRetired() {
+	now := time.Now()
+	kept := ks.previous[:0]
+	for _, key := range ks.previous {
+		if !key.retired(now) {
+			kept = append(kept, key)
+		}
+	}
+	ks.previous = kept
+}
+
+// rotationDue reports whether the active key should be replaced. The caller
+// must hold ks.mu.
+func (ks *KeySet) rotationDue() bool {
+	if ks.active == nil {
+		return true
+	}
+	return ks.cfg.RotationInterval > 0 && time.Since(ks.active.CreatedAt) >= ks.cfg.RotationInterval
+}
+
+// rotateDir rotates the shared key directory if rotation is still due once
+// its lock is held. Every replica schedules rotations, so whichever gets the
+// lock first writes the new key and the others pick it up on reload.
+func (ks *KeySet) rotateDir() error {
+	unlock, err := lockKeyDir(ks.cfg.KeyDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ks.mu.Lock()
+	err = ks.reloadDir()
+	due := ks.rotationDue()
+	ks.mu.Unlock()
+	if err != nil || !due {
+		return err
+	}
+
+	_, err = ks.Rotate()
+	return err
+}
+
+const (
+	keyDirLockName = ".rotate.lock"
+	// keyDirLockWait is how long a replica waits for another to finish
+	// rotating, and keyDirLockStale how old a lock is when its holder is
+	// presumed to have died.
+	keyDirLockWait  = 10 * time.Second
+	keyDirLockStale = time.Minute
+)
+
+// lockKeyDir takes the rotation lock of dir, a file created exclusively so
+// that it works on any shared filesystem. The returned func releases it.
+func lockKeyDir(dir string) (func(), error) {
+	path := filepath.Join(dir, keyDirLockName)
+	deadline := time.Now().Add(keyDirLockWait)
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() {
+				if err := os.Remove(path); err != nil {
+					log.Printf("JWT: failed to release key directory lock: %v", err)
+				}
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock key directory: %w", err)
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > keyDirLockStale {
+			log.Printf("JWT: breaking stale key directory lock %s", path)
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("timed out waiting for the key directory lock")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (ks *KeySet) startRotation() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ks.mu.Lock()
+		if ks.cfg.KeyDir != "" {
+			if err := ks.reloadDir(); err != nil {
+				log.Printf("JWT: failed to reload key directory: %v", err)
+			}
+		} else {
+			ks.pruneRetired()
+		}
+		due := ks.rotationDue()
+		ks.mu.Unlock()
+
+		if !due {
+			continue
+		}
+
+		var err error
+		if ks.cfg.KeyDir != "" {
+			err = ks.rotateDir()
+		} else {
+			_, err = ks.Rotate()
+		}
+		if err != nil {
+			log.Printf("JWT: scheduled key rotation failed: %v", err)
+		}
+	}
+}
+
+func (ks *KeySet) SigningKey() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active
+}
+
+// VerificationKey returns the public key for kid as long as it has not been
+// retired. An unknown kid triggers a reload of the key directory, since
+// another replica may have rotated in the meantime.
+func (ks *KeySet) VerificationKey(kid string) (*SigningKey, bool) {
+	if key, ok := ks.lookup(kid); ok {
+		return key, true
+	}
+
+	if ks.cfg.KeyDir == "" {
+		return nil, false
+	}
+
+	ks.mu.Lock()
+	if time.Since(ks.lastReload) > 5*time.Second {
+		if err := ks.reloadDir(); err != nil {
+			log.Printf("JWT: failed to reload key directory: %v", err)
+		}
+	}
+	ks.mu.Unlock()
+
+	return ks.lookup(kid)
+}
+
+func (ks *KeySet) lookup(kid string) (*SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	if ks.active != nil && ks.active.KID == kid {
+		return ks.active, true
+	}
+	for _, key := range ks.previous {
+		if key.KID == kid && !key.retired(now) {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// JWKS publishes the public halves of the active and previous keys.
+func (ks *KeySet) JWKS() output.JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	jwks := output.JWKS{Keys: []output.JWK{}}
+	if ks.active != nil {
+		jwks.Keys = append(jwks.Keys, publicJWK(ks.active))
+	}
+	for _, key := range ks.previous {
+		if !key.retired(now) {
+			jwks.Keys = append(jwks.Keys, publicJWK(key))
+		}
+	}
+	return jwks
+}
+
+func publicJWK(key *SigningKey) output.JWK {
+	jwk := output.JWK{
+		KID: key.KID,
+		Use: "sig",
+		Alg: key.Algorithm,
+	}
+
+	switch pub := key.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+func isSupportedAlgorithm(alg string) bool {
+	switch alg {
+	case "RS256", "ES256", "EdDSA":
+		return true
+	}
+	return false
+}
+
+func generateSigningKey(alg string) (*SigningKey, error) {
+	var priv crypto.Signer
+	var err error
+
+	switch alg {
+	case "RS256":
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", alg, err)
+	}
+
+	return newSigningKey(priv, alg)
+}
+
+func newSigningKey(priv crypto.Signer, alg string) (*SigningKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+
+	return &SigningKey{
+		KID:        base64.RawURLEncoding.EncodeToString(sum[:])[:16],
+		Algorithm:  alg,
+		PrivateKey: priv,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// loadSigningKey reads a PKCS#8, PKCS#1 or SEC 1 private key. When alg is
+// empty it is derived from the key type.
+func loadSigningKey(path, alg string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+
+	var keyAlg string
+	var priv crypto.Signer
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		keyAlg, priv = "RS256", k
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("signing key %s must use the P-256 curve", path)
+		}
+		keyAlg, priv = "ES256", k
+	case ed25519.PrivateKey:
+		keyAlg, priv = "EdDSA", k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T in %s", parsed, path)
+	}
+
+	if alg != "" && !strings.EqualFold(alg, keyAlg) {
+		return nil, fmt.Errorf("signing key %s is a %s key but JWT_SIGNING_ALG is %s", path, keyAlg, alg)
+	}
+
+	return newSigningKey(priv, keyAlg)
+}
+
+func writeSigningKey(path string, key *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return os.WriteFile(path, data, 0600)
+}
+
+// signToken signs claims with the active key and stamps its kid.
+func signToken(claims jwt.MapClaims) (string, error) {
+	ks, err := GetKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	key := ks.SigningKey()
+	if key == nil {
+		return "", errors.New("no signing key available")
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.PrivateKey)
+}
+
+// verificationKeyFunc resolves the verification key from the token's kid and
+// rejects tokens whose alg does not match that key.
+func verificationKeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid header")
+	}
+
+	ks, err := GetKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := ks.VerificationKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown or retired signing key %q", kid)
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.PrivateKey.Public(), nil
+}
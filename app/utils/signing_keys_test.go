@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bbapp-org/auth-service/app/dto/input"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestReloadDirKeepsActiveKeyWhenDirEmpties(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := NewKeySet(input.JWTConfig{SigningAlgorithm: "ES256", KeyDir: dir, KeyRetention: time.Hour})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	active := ks.SigningKey()
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.pem"))
+	for _, path := range paths {
+		os.Remove(path)
+	}
+
+	ks.mu.Lock()
+	err = ks.reloadDir()
+	ks.mu.Unlock()
+	if err != nil {
+		t.Fatalf("reloadDir: %v", err)
+	}
+
+	if got := ks.SigningKey(); got == nil || got.KID != active.KID {
+		t.Errorf("active key after reload = %v, want %s", got, active.KID)
+	}
+}
+
+func TestSignTokenWithoutKey(t *testing.T) {
+	signingKeysMu.Lock()
+	saved := signingKeys
+	signingKeys = &KeySet{}
+	signingKeysMu.Unlock()
+	t.Cleanup(func() {
+		signingKeysMu.Lock()
+		signingKeys = saved
+		signingKeysMu.Unlock()
+	})
+
+	if _, err := signToken(jwt.MapClaims{"sub": "1"}); err == nil {
+		t.Error("signToken succeeded without a signing key")
+	}
+}
+
+func TestRotateDirOncePerInterval(t *testing.T) {
+	dir := t.TempDir()
+	cfg := input.JWTConfig{SigningAlgorithm: "ES256", KeyDir: dir, RotationInterval: time.Hour, KeyRetention: time.Hour}
+
+	tests := []struct {
+		name     string
+		age      time.Duration
+		wantKeys int
+	}{
+		{name: "fresh key", age: 0, wantKeys: 1},
+		{name: "key due for rotation", age: 2 * time.Hour, wantKeys: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(dir, filepath.Base(t.Name()))
+			cfg := cfg
+			cfg.KeyDir = dir
+
+			// Replicas share the directory and wake at the same time.
+			replicas := make([]*KeySet, 4)
+			for i := range replicas {
+				ks, err := NewKeySet(cfg)
+				if err != nil {
+					t.Fatalf("NewKeySet: %v", err)
+				}
+				replicas[i] = ks
+			}
+
+			paths, _ := filepath.Glob(filepath.Join(dir, "*.pem"))
+			if len(paths) != 1 {
+				t.Fatalf("replicas created %d keys at startup, want 1", len(paths))
+			}
+			old := time.Now().Add(-tt.age)
+			os.Chtimes(paths[0], old, old)
+
+			var wg sync.WaitGroup
+			for _, ks := range replicas {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := ks.rotateDir(); err != nil {
+						t.Errorf("rotateDir: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			paths, _ = filepath.Glob(filepath.Join(dir, "*.pem"))
+			if len(paths) != tt.wantKeys {
+				t.Errorf("key directory holds %d keys, want %d", len(paths), tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestKeySetRotationRetention(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			ks, err := NewKeySet(input.JWTConfig{SigningAlgorithm: alg, KeyRetention: time.Hour})
+			if err != nil {
+				t.Fatalf("NewKeySet: %v", err)
+			}
+			old := ks.SigningKey()
+
+			current, err := ks.Rotate()
+			if err != nil {
+				t.Fatalf("Rotate: %v", err)
+			}
+			if current.KID == old.KID {
+				t.Fatal("Rotate kept the signing key")
+			}
+
+			tests := []struct {
+				name string
+				kid  string
+				want bool
+			}{
+				{name: "current key", kid: current.KID, want: true},
+				{name: "previous key within retention", kid: old.KID, want: true},
+				{name: "unknown key", kid: "unknown"},
+			}
+			for _, tt := range tests {
+				if _, ok := ks.VerificationKey(tt.kid); ok != tt.want {
+					t.Errorf("%s: VerificationKey = %v, want %v", tt.name, ok, tt.want)
+				}
+			}
+			if got := len(ks.JWKS().Keys); got != 2 {
+				t.Errorf("JWKS publishes %d keys, want 2", got)
+			}
+
+			retired := time.Now().Add(-time.Second)
+			old.RetiresAt = &retired
+			if _, ok := ks.VerificationKey(old.KID); ok {
+				t.Error("retired key still verifies")
+			}
+			if jwks := ks.JWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].KID != current.KID {
+				t.Errorf("JWKS after retirement = %+v, want only %s", jwks.Keys, current.KID)
+			}
+		})
+	}
+}
+
+func TestLoadSigningKeyAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	key, err := generateSigningKey("ES256")
+	if err != nil {
+		t.Fatalf("generateSigningKey: %v", err)
+	}
+	path := filepath.Join(dir, "key.pem")
+	if err := writeSigningKey(path, key); err != nil {
+		t.Fatalf("writeSigningKey: %v", err)
+	}
+
+	tests := []struct {
+		alg     string
+		wantErr bool
+	}{
+		{alg: ""},
+		{alg: "ES256"},
+		{alg: "RS256", wantErr: true},
+		{alg: "EdDSA", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		loaded, err := loadSigningKey(path, tt.alg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("loadSigningKey(%q) error = %v, wantErr %v", tt.alg, err, tt.wantErr)
+			continue
+		}
+		if err == nil && loaded.KID != key.KID {
+			t.Errorf("loadSigningKey(%q) kid = %s, want %s", tt.alg, loaded.KID, key.KID)
+		}
+	}
+}
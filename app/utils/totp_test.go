@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key of RFC 6238, "12345678901234567890",
+// in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC 6238 appendix B vectors, truncated to 6 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		code, err := TOTPCode(rfc6238Secret, tt.unix/TOTPPeriod)
+		if err != nil {
+			t.Fatalf("TOTPCode(%d): %v", tt.unix, err)
+		}
+		if code != tt.code {
+			t.Errorf("TOTPCode(%d) = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		now := time.Unix(tt.unix, 0)
+		step := tt.unix / TOTPPeriod
+
+		tests := []struct {
+			name     string
+			now      time.Time
+			code     string
+			wantOK   bool
+			wantStep int64
+		}{
+			{name: "current step", now: now, code: tt.code, wantOK: true, wantStep: step},
+			{name: "padded with spaces", now: now, code: " " + tt.code + " ", wantOK: true, wantStep: step},
+			{name: "one step late", now: now.Add(TOTPPeriod * time.Second), code: tt.code, wantOK: true, wantStep: step},
+			{name: "one step early", now: now.Add(-TOTPPeriod * time.Second), code: tt.code, wantOK: true, wantStep: step},
+			{name: "two steps late", now: now.Add(2 * TOTPPeriod * time.Second), code: tt.code},
+			{name: "wrong code", now: now, code: wrongTOTPCode(tt.code)},
+			{name: "too short", now: now, code: tt.code[:5]},
+			{name: "eight digits", now: now, code: "00" + tt.code},
+		}
+
+		for _, c := range tests {
+			gotStep, ok := VerifyTOTP(rfc6238Secret, c.code, c.now)
+			if ok != c.wantOK || (ok && gotStep != c.wantStep) {
+				t.Errorf("%d/%s: VerifyTOTP = (%d, %v), want (%d, %v)", tt.unix, c.name, gotStep, ok, c.wantStep, c.wantOK)
+			}
+		}
+	}
+}
+
+// wrongTOTPCode changes the last digit of code.
+func wrongTOTPCode(code string) string {
+	last := code[len(code)-1]
+	return code[:len(code)-1] + string('0'+(last-'0'+1)%10)
+}
@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// WebAuthn client data types.
+const (
+	WebAuthnTypeCreate = "webauthn.create"
+	WebAuthnTypeGet    = "webauthn.get"
+)
+
+// COSE algorithm identifiers accepted for passkeys.
+const (
+	COSEAlgES256 int64 = -7
+	COSEAlgEdDSA int64 = -8
+	COSEAlgRS256 int64 = -257
+)
+
+// Authenticator data flags.
+const (
+	authDataUserPresent    = 0x01
+	authDataUserVerified   = 0x04
+	authDataBackupEligible = 0x08
+	authDataBackupState    = 0x10
+	authDataAttested       = 0x40
+	authDataExtensions     = 0x80
+)
+
+// WebAuthnClientData is the collected client data the browser signs over.
+type WebAuthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// AuthenticatorData is the parsed authenticatorData structure. The credential
+// fields are only set when the authenticator attested a new credential.
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+func (d *AuthenticatorData) UserPresent() bool    { return d.Flags&authDataUserPresent != 0 }
+func (d *AuthenticatorData) UserVerified() bool   { return d.Flags&authDataUserVerified != 0 }
+func (d *AuthenticatorData) BackupEligible() bool { return d.Flags&authDataBackupEligible != 0 }
+func (d *AuthenticatorData) BackupState() bool    { return d.Flags&authDataBackupState != 0 }
+
+// GenerateWebAuthnChallenge returns 32 random bytes, base64url encoded as
+// they appear in client data.
+func GenerateWebAuthnChallenge() (string, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(challenge), nil
+}
+
+// DecodeBase64URL decodes base64url with or without padding, as browsers and
+// WebAuthn libraries differ.
+func DecodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func ParseWebAuthnClientData(raw []byte) (*WebAuthnClientData, error) {
+	var clientData WebAuthnClientData
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return nil, fmt.Errorf("invalid client data: %w", err)
+	}
+	return &clientData, nil
+}
+
+// ParseAttestationObject returns the attestation format and the raw
+// authenticator data of an attestationObject.
+func ParseAttestationObject(data []byte) (string, []byte, error) {
+	decoded, rest, err := DecodeCBOR(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid attestation object: %w", err)
+	}
+	if len(rest) != 0 {
+		return "", nil, errors.New("invalid attestation object: trailing data")
+	}
+
+	object, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return "", nil, errors.New("invalid attestation object")
+	}
+
+	format, _ := object["fmt"].(string)
+	authData, _ := object["authData"].([]byte)
+	if format == "" || authData == nil {
+		return "", nil, errors.New("invalid attestation object")
+	}
+
+	return format, authData, nil
+}
+
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data too short")
+	}
+
+	authData := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.Flags&authDataAttested != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data too short")
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLength {
+			return nil, errors.New("attested credential data too short")
+		}
+		authData.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, remaining, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %w", err)
+		}
+		authData.PublicKey = rest[:len(rest)-len(remaining)]
+		rest = remaining
+	}
+
+	if authData.Flags&authDataExtensions != 0 {
+		_, remaining, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid authenticator extensions: %w", err)
+		}
+		rest = remaining
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("authenticator data has trailing bytes")
+	}
+
+	return authData, nil
+}
+
+// ParseCOSEKey decodes a COSE_Key holding an ES256, EdDSA (Ed25519) or RS256
+// public key and returns it with its algorithm.
+func ParseCOSEKey(data []byte) (crypto.PublicKey, int64, error) {
+	decoded, _, err := DecodeCBOR(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid COSE key: %w", err)
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("invalid COSE key")
+	}
+
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch alg {
+	case COSEAlgES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if kty != 2 || crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid ES256 COSE key")
+		}
+		publicKey := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, 0, errors.New("ES256 COSE key is not on the curve")
+		}
+		return publicKey, alg, nil
+	case COSEAlgEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if kty != 1 || crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid EdDSA COSE key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case COSEAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if kty != 3 || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RS256 COSE key")
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, alg, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported COSE algorithm %d", alg)
+	}
+}
+
+// VerifyWebAuthnSignature checks an assertion signature, which covers the
+// authenticator data followed by the SHA-256 of the client data JSON.
+func VerifyWebAuthnSignature(coseKey, authData, clientDataJSON, signature []byte) error {
+	publicKey, _, err := ParseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+	digest := sha256.Sum256(signed)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, signed, signature) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("invalid signature")
+		}
+	}
+
+	return nil
+}
+
+// WebAuthnRPIDHashMatches reports whether authenticator data was produced
+// for rpID.
+func WebAuthnRPIDHashMatches(authData *AuthenticatorData, rpID string) bool {
+	expected := sha256.Sum256([]byte(rpID))
+	return subtle.ConstantTimeCompare(authData.RPIDHash, expected[:]) == 1
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/bbapp-org/auth-service/app/config/database"
 	"github.com/bbapp-org/auth-service/app/helper"
 	"github.com/bbapp-org/auth-service/app/routes"
+	"github.com/bbapp-org/auth-service/app/utils"
 
 	_ "github.com/bbapp-org/auth-service/docs"
 	"github.com/gofiber/fiber/v2"
@@ -26,6 +27,10 @@ var (
 func main() {
 	cfg := config.LoadConfig()
 
+	if err := utils.InitSigningKeys(cfg.JWT); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
 	database.ConnectDatabase(cfg)
 
 	db := database.GetDB()
@@ -34,6 +39,13 @@ func main() {
 	}
 
 	app := fiber.New(fiber.Config{
+		// Rate limits, lockouts and the audit log key on the client IP, so
+		// the proxy header is only believed from SERVER_TRUSTED_PROXIES.
+		// middleware.ClientInfo reads both settings from here.
+		ProxyHeader:             cfg.Server.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.Server.TrustedProxies,
+		EnableIPValidation:      true,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {